@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/jwtutil"
+	"github.com/humancto/mozzy/internal/oauth"
+	"github.com/humancto/mozzy/internal/ui"
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+var (
+	authNoBrowser bool
+	authAll       bool
+	authWait      time.Duration
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Log in with OAuth2 / OIDC and manage cached tokens",
+	Long: `Obtain OAuth2 / OpenID Connect tokens for an environment.
+
+The environment's "oauth" settings in .mozzy.json declare the flow:
+client_credentials, password, refresh_token, authorization_code (PKCE, with
+a loopback redirect) or device_code. Endpoints can be given directly
+(token_url, auth_url, device_url) or discovered from an OIDC "issuer".
+
+Tokens are cached per environment in .mozzy/tokens.json and sent as
+"Authorization: Bearer" on every request made with that environment. They
+are refreshed automatically shortly before they expire. The interactive
+flows (authorization_code, device_code) need 'mozzy auth login' once; the
+others fetch a token on first use.
+
+Examples:
+  mozzy env set dev oauth.flow client_credentials
+  mozzy env set dev oauth.token_url https://idp.example.com/oauth/token
+  mozzy env set dev oauth.client_id cli
+  mozzy env set dev oauth.client_secret '{{client_secret}}'
+  mozzy --env dev auth login
+  mozzy --env dev auth status
+  mozzy auth logout --all`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Run the environment's OAuth flow and cache the token",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile, err := activateProfile()
+		if err != nil {
+			return err
+		}
+		env := profile.Name()
+		if env == "" {
+			return fmt.Errorf("select an environment with --env or default_env")
+		}
+		if profile.OAuth == nil {
+			return fmt.Errorf("environment %q has no oauth settings (mozzy env set %s oauth.flow <flow>)", env, env)
+		}
+		if authNoBrowser {
+			oauth.NoBrowser()
+		}
+
+		src, err := profile.TokenSource(vars.Interpolate, transportOptions(profile))
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), authWait)
+		defer cancel()
+		tok, err := oauth.Login(ctx, src.Config, src.Client, os.Stderr)
+		if err != nil {
+			return err
+		}
+		err = oauth.UpdateStore(src.Path, func(s *oauth.Store) error {
+			s.Tokens[env] = tok
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		msg := fmt.Sprintf("Logged in to %s with %s", env, tok.Flow)
+		if who := tokenSubject(tok); who != "" {
+			msg += " as " + who
+		}
+		fmt.Println(ui.SuccessBanner(msg))
+		fmt.Println(ui.DimStyle.Render(fmt.Sprintf("Token expires %s, stored in %s", tokenExpiry(tok), src.Path)))
+		return nil
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show cached OAuth tokens",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := oauth.StorePath(config.ProjectDir())
+		s, err := oauth.LoadStore(path)
+		if err != nil {
+			return err
+		}
+		envs := s.Envs()
+		if !authAll {
+			env := vars.EnvName(envName)
+			if env == "" {
+				return fmt.Errorf("select an environment with --env or default_env, or use --all")
+			}
+			envs = nil
+			if s.Tokens[env] != nil {
+				envs = []string{env}
+			}
+		}
+		if len(envs) == 0 {
+			fmt.Println(ui.WarningBanner("No OAuth tokens cached"))
+			fmt.Println("\n" + ui.InfoStyle.Render("💡 Declare an oauth flow on the environment and run 'mozzy auth login'"))
+			return nil
+		}
+
+		fmt.Printf("\n%s\n\n", ui.TitleStyle.Render("🔑 OAuth Tokens"))
+		table := ui.NewTable([]string{"Env", "Flow", "Subject", "Scope", "Expires", "Refresh"})
+		for _, env := range envs {
+			t := s.Tokens[env]
+			refresh := "no"
+			if t.RefreshToken != "" {
+				refresh = "yes"
+			}
+			table.AddRow([]string{env, t.Flow, tokenSubject(t), t.Scope, tokenExpiry(t), refresh})
+		}
+		fmt.Println(table.Render())
+		fmt.Println(ui.DimStyle.Render("Stored in " + path))
+		return nil
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the cached OAuth token",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		env := vars.EnvName(envName)
+		if !authAll && env == "" {
+			return fmt.Errorf("select an environment with --env or default_env, or use --all")
+		}
+		removed := 0
+		err := oauth.UpdateStore(oauth.StorePath(config.ProjectDir()), func(s *oauth.Store) error {
+			if authAll {
+				removed = len(s.Tokens)
+				s.Tokens = map[string]*oauth.Token{}
+			} else if s.Tokens[env] != nil {
+				removed = 1
+				delete(s.Tokens, env)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		switch {
+		case authAll:
+			fmt.Println(ui.SuccessBanner(fmt.Sprintf("Removed %d cached token(s)", removed)))
+		case removed == 0:
+			fmt.Println(ui.WarningBanner(fmt.Sprintf("No token cached for %s", env)))
+		default:
+			fmt.Println(ui.SuccessBanner(fmt.Sprintf("Logged out of %s", env)))
+		}
+		return nil
+	},
+}
+
+// tokenSubject names who a token was issued to, from the ID token or a JWT
+// access token.
+func tokenSubject(t *oauth.Token) string {
+	for _, jwt := range []string{t.IDToken, t.AccessToken} {
+		if jwt == "" {
+			continue
+		}
+		_, claims, err := jwtutil.Decode(jwt)
+		if err != nil {
+			continue
+		}
+		for _, claim := range []string{"email", "preferred_username", "sub"} {
+			if v, ok := claims[claim].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+func tokenExpiry(t *oauth.Token) string {
+	if t.ExpiresAt == nil {
+		return "never"
+	}
+	d := time.Until(*t.ExpiresAt).Round(time.Second)
+	if d <= 0 {
+		return "expired"
+	}
+	return "in " + d.String()
+}
+
+func init() {
+	authLoginCmd.Flags().BoolVar(&authNoBrowser, "no-browser", false, "Print the sign-in URL instead of opening a browser")
+	authLoginCmd.Flags().DurationVar(&authWait, "wait", 5*time.Minute, "How long to wait for the user to finish signing in")
+	authStatusCmd.Flags().BoolVar(&authAll, "all", false, "Show tokens of every environment")
+	authLogoutCmd.Flags().BoolVar(&authAll, "all", false, "Remove the tokens of every environment")
+	authCmd.AddCommand(authLoginCmd, authStatusCmd, authLogoutCmd)
+	rootCmd.AddCommand(authCmd)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/humancto/mozzy/internal/payload"
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+var (
+	bodyJSON        string
+	bodyFile        string
+	bodyContentType string
+	bodyForm        bool
+	bodyParts       []string
+	bodyCompress    string
+)
+
+const bodyItemsHelp = `
+Request items after the URL build the body, httpie-style:
+  name=value       string field          age:=30          raw JSON value
+  bio=@about.txt   field from a file     tags:=@tags.json JSON from a file
+  avatar@me.png    file (multipart)      page==2          query parameter
+  X-Trace:abc      header
+
+Fields are sent as a JSON object, or url-encoded with --form; any file
+switches to multipart/form-data. --file - and --json - read the body from
+stdin, and the Content-Type of --file and file parts follows the extension.`
+
+// addBodyFlags registers the request body options on a verb and documents
+// the inline request items.
+func addBodyFlags(c *cobra.Command) {
+	c.Flags().StringVar(&bodyJSON, "json", "", "JSON payload (string, @file.json, or - for stdin)")
+	c.Flags().StringVar(&bodyFile, "file", "", "Raw body from file (- for stdin); Content-Type follows the extension")
+	c.Flags().StringVar(&bodyContentType, "content-type", "", "Override Content-Type header")
+	c.Flags().BoolVarP(&bodyForm, "form", "f", false, "Send name=value items url-encoded instead of as JSON")
+	c.Flags().StringArrayVarP(&bodyParts, "part", "F", nil, "Multipart part: name@file[;type=mime][;filename=x] or name=value (repeatable)")
+	c.Flags().StringVar(&bodyCompress, "compress", "", "Compress the request body: gzip or zstd (sets Content-Encoding)")
+	c.Long = c.Short + ".\n" + bodyItemsHelp
+}
+
+// requestBody builds the body from the body flags and the request items,
+// resolving {{vars}} in item values.
+func requestBody(items []string) (*payload.Body, error) {
+	parsed, err := payload.ParseItems(items)
+	if err != nil {
+		return nil, err
+	}
+	for i := range parsed {
+		parsed[i].Value = vars.Interpolate(parsed[i].Value)
+	}
+	return payload.Build(payload.Spec{
+		JSON:        bodyJSON,
+		File:        bodyFile,
+		ContentType: bodyContentType,
+		Form:        bodyForm,
+		Parts:       bodyParts,
+		Items:       parsed,
+	}, os.Stdin)
+}
+
+// withQuery adds the name==value items to target's query string.
+func withQuery(target string, q url.Values) (string, error) {
+	if len(q) == 0 {
+		return target, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	// Append rather than re-encode so the existing query is left as given
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += q.Encode()
+	return u.String(), nil
+}
@@ -5,15 +5,19 @@ import (
 )
 
 var deleteCmd = &cobra.Command{
-	Use:   "DELETE <url-or-path>",
+	Use:   "DELETE <url-or-path> [items...]",
 	Short: "Send an HTTP DELETE request",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runVerb(cmd, "DELETE", args[0], nil, false)
+		return runVerb(cmd, "DELETE", args)
 	},
 }
 
 func init() {
-	deleteCmd.Flags().StringArray("capture", nil, "Capture variables: name=.json.path (repeatable)")
+	addBodyFlags(deleteCmd)
+	deleteCmd.Flags().StringArray("capture", nil, "Capture variables: name=<jq expression>, e.g. token=.data.token (repeatable)")
+	deleteCmd.Flags().Duration("capture-ttl", 0, "Expire captured variables after this long, e.g. 15m (JWTs default to their exp)")
+	addRawOutputFlag(deleteCmd)
+	addStreamFlags(deleteCmd)
 	rootCmd.AddCommand(deleteCmd)
 }
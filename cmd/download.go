@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/humancto/mozzy/internal/download"
+	"github.com/humancto/mozzy/internal/envelope"
+	"github.com/humancto/mozzy/internal/httpclient"
 )
 
 var (
@@ -27,6 +30,7 @@ Features:
 Examples:
   mozzy download https://example.com/file.zip
   mozzy download https://example.com/file.zip -o myfile.zip
+  mozzy download https://example.com/file.zip --output json
   mozzy download https://example.com/file.zip --overwrite
   mozzy download https://example.com/large.iso --no-progress`,
 	Args: cobra.ExactArgs(1),
@@ -34,7 +38,7 @@ Examples:
 }
 
 func init() {
-	downloadCmd.Flags().StringVarP(&downloadOutput, "output", "o", "", "Output filename (default: auto-detect from URL)")
+	downloadCmd.Flags().StringVarP(&downloadOutput, "out", "o", "", "Output filename (default: auto-detect from URL)")
 	downloadCmd.Flags().BoolVar(&downloadOverwrite, "overwrite", false, "Overwrite existing file")
 	downloadCmd.Flags().BoolVar(&downloadNoProgress, "no-progress", false, "Disable progress bar")
 	rootCmd.AddCommand(downloadCmd)
@@ -43,19 +47,63 @@ func init() {
 func runDownload(cmd *cobra.Command, args []string) error {
 	url := args[0]
 
+	// --output used to name the file; keep accepting that for anything
+	// that isn't an output format
+	if _, err := envelope.ParseFormat(outputFormat); err != nil && downloadOutput == "" {
+		fmt.Fprintf(os.Stderr, "⚠️  download --output <file> is deprecated, use -o/--out\n")
+		downloadOutput, outputFormat = outputFormat, ""
+	}
+	format, err := outputMode()
+	if err != nil {
+		return err
+	}
+
+	profile, err := activateProfile()
+	if err != nil {
+		return err
+	}
+	transport, err := clientTransport(profile)
+	if err != nil {
+		return err
+	}
+
 	opts := download.DownloadOptions{
 		URL:             url,
 		OutputPath:      downloadOutput,
 		ShowProgress:    !downloadNoProgress,
 		OverwriteExist:  downloadOverwrite,
 		FollowRedirects: true,
+		Transport:       transport,
 	}
 
-	outputPath, err := download.Download(opts)
+	result, err := download.Fetch(opts)
+	if format != envelope.Text {
+		env := &envelope.Envelope{
+			Request: envelope.Request{Method: "GET", URL: url},
+			Timings: envelope.NewTimings(httpclient.TimingInfo{Total: result.Duration}),
+		}
+		if res := result.Response; res != nil {
+			// The body went to the file, so the envelope points there instead
+			env.Request.Headers = envelope.Redact(res.Request.Header)
+			env.Response = envelope.NewResponse(res, nil)
+			env.Response.Size = result.Bytes
+		}
+		if result.Path != "" {
+			env.File = &envelope.File{Path: result.Path, Bytes: result.Bytes}
+		}
+		if err != nil {
+			env.Error = err.Error()
+		}
+		if werr := printEnvelope(format, env); werr != nil {
+			return werr
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
 
-	fmt.Printf("\n✅ Downloaded to: %s\n", outputPath)
+	if format == envelope.Text {
+		fmt.Printf("\n✅ Downloaded to: %s\n", result.Path)
+	}
 	return nil
 }
@@ -1,44 +1,79 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/ui"
+)
+
+var (
+	envAddBase    string
+	envAddExtends string
+	envAddToken   string
+	envAddHeaders []string
+	envAddVars    []string
 )
 
 var envCmd = &cobra.Command{
 	Use:   "env",
-	Short: "Show available environments from .mozzy.json",
+	Short: "Show and edit environments in .mozzy.json",
+	Long: `Show and edit environment profiles in .mozzy.json.
+
+A profile sets the base URL plus default headers, auth, TLS settings and
+variables for every request made with --env <name> (or default_env).
+Profiles can inherit from another profile with "extends".
+
+Examples:
+  mozzy env
+  mozzy env add base --base-url https://api.example.com --header "Accept: application/json"
+  mozzy env add staging --extends base --base-url https://staging.example.com
+  mozzy env set staging variables.user_id 42
+  mozzy env set staging tls.insecure true
+  mozzy env set staging limits.api.example.com.rate 20/s
+  mozzy env set staging auth.type basic
+  mozzy env set staging oauth.flow client_credentials
+  mozzy env use staging
+  mozzy env remove staging`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		data, err := os.ReadFile(".mozzy.json")
+		cfg, err := config.Load()
 		if err != nil {
-			return fmt.Errorf("no .mozzy.json file found in current directory")
-		}
-
-		var config map[string]interface{}
-		if err := json.Unmarshal(data, &config); err != nil {
-			return fmt.Errorf("invalid .mozzy.json: %w", err)
+			return err
 		}
 
-		envs, ok := config["environments"].(map[string]interface{})
-		if !ok || len(envs) == 0 {
+		if len(cfg.Environments) == 0 {
 			fmt.Println("No environments defined in .mozzy.json")
 			fmt.Println()
 			fmt.Println("Example .mozzy.json:")
 			fmt.Println(`{
+  "default_env": "dev",
   "environments": {
+    "base": {
+      "headers": {
+        "Accept": "application/json"
+      }
+    },
     "dev": {
+      "extends": "base",
       "base_url": "http://localhost:3000",
       "headers": {
         "X-Env": "development"
+      },
+      "variables": {
+        "user_id": "42"
       }
     },
     "prod": {
+      "extends": "base",
       "base_url": "https://api.example.com",
-      "auth_token": "your-token-here"
+      "auth_token": "{{prod_token}}",
+      "tls": {
+        "ca_cert": "certs/internal-ca.pem"
+      }
     }
   }
 }`)
@@ -52,22 +87,44 @@ var envCmd = &cobra.Command{
 		fmt.Println(cyan("🌍 Available Environments"))
 		fmt.Println()
 
-		for name, env := range envs {
-			envMap, ok := env.(map[string]interface{})
-			if !ok {
-				continue
+		active := cfg.ActiveName(envName)
+		for _, name := range cfg.Names() {
+			marker := ""
+			if name == active {
+				marker = green(" (active)")
 			}
+			fmt.Printf("%s %s%s\n", green("•"), cyan(name), marker)
 
-			fmt.Printf("%s %s\n", green("•"), cyan(name))
-
-			if baseURL, ok := envMap["base_url"].(string); ok {
-				fmt.Printf("  %s %s\n", gray("Base URL:"), baseURL)
+			p, err := cfg.Resolve(name)
+			if err != nil {
+				fmt.Printf("  %s %v\n", color.RedString("Error:"), err)
+				fmt.Println()
+				continue
+			}
+			if parent := cfg.Environments[name].Extends; parent != "" {
+				fmt.Printf("  %s %s\n", gray("Extends:"), parent)
 			}
-			if authToken, ok := envMap["auth_token"].(string); ok && authToken != "" {
+			if p.BaseURL != "" {
+				fmt.Printf("  %s %s\n", gray("Base URL:"), p.BaseURL)
+			}
+			if p.Auth != nil {
+				fmt.Printf("  %s %s configured ✓\n", gray("Auth:"), p.Auth.Type)
+			} else if p.OAuth != nil {
+				fmt.Printf("  %s oauth %s configured ✓\n", gray("Auth:"), p.OAuth.Flow)
+			} else if p.AuthToken != "" {
 				fmt.Printf("  %s %s\n", gray("Auth:"), "configured ✓")
 			}
-			if headers, ok := envMap["headers"].(map[string]interface{}); ok && len(headers) > 0 {
-				fmt.Printf("  %s %d custom headers\n", gray("Headers:"), len(headers))
+			if len(p.Headers) > 0 {
+				fmt.Printf("  %s %d custom headers\n", gray("Headers:"), len(p.Headers))
+			}
+			if len(p.Variables) > 0 {
+				fmt.Printf("  %s %d variables\n", gray("Variables:"), len(p.Variables))
+			}
+			if p.TLS != nil {
+				fmt.Printf("  %s %s\n", gray("TLS:"), describeTLS(p.TLS))
+			}
+			if p.Proxy != nil && p.Proxy.URL != "" {
+				fmt.Printf("  %s %s\n", gray("Proxy:"), p.Proxy.URL)
 			}
 			fmt.Println()
 		}
@@ -77,6 +134,180 @@ var envCmd = &cobra.Command{
 	},
 }
 
+var envUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the default environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if _, err := cfg.Resolve(args[0]); err != nil {
+			return err
+		}
+		cfg.DefaultEnv = args[0]
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Println(ui.SuccessBanner(fmt.Sprintf("Default environment set to %s", args[0])))
+		return nil
+	},
+}
+
+var envAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add an environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if _, exists := cfg.Environments[name]; exists {
+			return fmt.Errorf("environment %q already exists (use 'mozzy env set')", name)
+		}
+
+		p := &config.Profile{
+			Extends:   envAddExtends,
+			BaseURL:   envAddBase,
+			AuthToken: envAddToken,
+		}
+		for _, h := range envAddHeaders {
+			k, v, ok := strings.Cut(h, ":")
+			if !ok {
+				return fmt.Errorf("invalid header %q (want 'Key: Value')", h)
+			}
+			if err := p.SetField("headers."+strings.TrimSpace(k), strings.TrimSpace(v)); err != nil {
+				return err
+			}
+		}
+		for _, kv := range envAddVars {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("invalid variable %q (want name=value)", kv)
+			}
+			if err := p.SetField("variables."+k, v); err != nil {
+				return err
+			}
+		}
+
+		cfg.Environments[name] = p
+		if _, err := cfg.Resolve(name); err != nil {
+			return err
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Println(ui.SuccessBanner(fmt.Sprintf("Added environment %s", name)))
+		return nil
+	},
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <name> <key> <value>",
+	Short: "Change a setting of an environment",
+	Long: `Change a single setting of an environment.
+
+Keys: base_url, auth_token, extends, headers.<Name>, variables.<name>,
+tls.insecure, tls.ca_cert, tls.cert, tls.key, tls.cert_password,
+tls.min_version, tls.max_version, tls.ciphers (comma-separated) and
+tls.server_name, proxy.url, proxy.user, proxy.no_proxy, auth.<field>
+(auth.type plus the scheme's settings, e.g. auth.username), oauth.<field>
+(oauth.flow plus token_url, client_id, scope, ...; see 'mozzy auth') and
+limits.<host>.rate|burst|breaker|breaker_cooldown ("*" for any host). An
+empty value removes a header, variable or limit.`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, key, value := args[0], args[1], args[2]
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		p, ok := cfg.Environments[name]
+		if !ok {
+			return fmt.Errorf("environment %q not found in %s", name, config.FileName)
+		}
+		if err := p.SetField(key, value); err != nil {
+			return err
+		}
+		if _, err := cfg.Resolve(name); err != nil {
+			return err
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Println(ui.SuccessBanner(fmt.Sprintf("Updated %s.%s", name, key)))
+		return nil
+	},
+}
+
+var envRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove an environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Environments[name]; !ok {
+			return fmt.Errorf("environment %q not found in %s", name, config.FileName)
+		}
+		for _, other := range cfg.Names() {
+			if cfg.Environments[other].Extends == name {
+				return fmt.Errorf("environment %q is extended by %q", name, other)
+			}
+		}
+		delete(cfg.Environments, name)
+		if cfg.DefaultEnv == name {
+			cfg.DefaultEnv = ""
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Println(ui.SuccessBanner(fmt.Sprintf("Removed environment %s", name)))
+		return nil
+	},
+}
+
+func describeTLS(t *config.TLS) string {
+	var parts []string
+	if t.Insecure {
+		parts = append(parts, "insecure")
+	}
+	if t.CACert != "" {
+		parts = append(parts, "custom CA")
+	}
+	if t.Cert != "" {
+		parts = append(parts, "client cert")
+	}
+	if t.MinVersion != "" {
+		parts = append(parts, "min TLS "+t.MinVersion)
+	}
+	if t.MaxVersion != "" {
+		parts = append(parts, "max TLS "+t.MaxVersion)
+	}
+	if len(t.Ciphers) > 0 {
+		parts = append(parts, fmt.Sprintf("%d ciphers", len(t.Ciphers)))
+	}
+	if t.ServerName != "" {
+		parts = append(parts, "SNI "+t.ServerName)
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, ", ")
+}
+
 func init() {
+	envAddCmd.Flags().StringVar(&envAddBase, "base-url", "", "Base URL for the environment")
+	envAddCmd.Flags().StringVar(&envAddExtends, "extends", "", "Inherit settings from another environment")
+	envAddCmd.Flags().StringVar(&envAddToken, "auth-token", "", "Bearer token (may reference {{vars}})")
+	envAddCmd.Flags().StringArrayVar(&envAddHeaders, "header", nil, "Default header 'Key: Value' (repeatable)")
+	envAddCmd.Flags().StringArrayVar(&envAddVars, "var", nil, "Variable name=value (repeatable)")
+	envCmd.AddCommand(envUseCmd, envAddCmd, envSetCmd, envRemoveCmd)
 	rootCmd.AddCommand(envCmd)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/humancto/mozzy/internal/collection"
+	"github.com/humancto/mozzy/internal/envelope"
 	"github.com/humancto/mozzy/internal/formatter"
 	"github.com/humancto/mozzy/internal/history"
 	"github.com/humancto/mozzy/internal/httpclient"
@@ -26,6 +27,10 @@ Examples:
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
+		format, err := outputMode()
+		if err != nil {
+			return err
+		}
 
 		coll, err := collection.Load()
 		if err != nil {
@@ -38,8 +43,15 @@ Examples:
 		}
 
 		// Print what we're running
-		infoColor := color.New(color.FgCyan)
-		fmt.Printf("%s %s\n\n", color.CyanString("🚀"), infoColor.Sprintf("Executing saved request: %s", name))
+		if format == envelope.Text {
+			infoColor := color.New(color.FgCyan)
+			fmt.Printf("%s %s\n\n", color.CyanString("🚀"), infoColor.Sprintf("Executing saved request: %s", name))
+		}
+
+		profile, err := activateProfile()
+		if err != nil {
+			return err
+		}
 
 		// Build headers from saved request + CLI overrides
 		hdrs := []string{}
@@ -51,11 +63,17 @@ Examples:
 			hdrs = append(hdrs, vars.Interpolate(h))
 		}
 
-		// Auth token override
-		token := vars.Interpolate(authToken)
+		// Auth override from the command line
+		token, authCfg, err := requestAuth(vars.Interpolate)
+		if err != nil {
+			return err
+		}
 
-		// Interpolate URL
-		url := vars.Interpolate(req.URL)
+		// Resolve relative URLs against the environment and interpolate
+		url, err := resolveTarget(profile, req.URL)
+		if err != nil {
+			return err
+		}
 
 		// Interpolate body
 		body := []byte(vars.Interpolate(req.Body))
@@ -70,18 +88,32 @@ Examples:
 
 		// Execute request
 		httpReq := httpclient.Request{
-			Method:  req.Method,
-			URL:     url,
-			Headers: hdrs,
-			Token:   token,
-			Body:    body,
-			JSON:    req.Body != "" && strings.HasPrefix(strings.TrimSpace(req.Body), "{"),
+			Method:    req.Method,
+			URL:       url,
+			Headers:   hdrs,
+			Token:     token,
+			Body:      body,
+			JSON:      req.Body != "" && strings.HasPrefix(strings.TrimSpace(req.Body), "{"),
+			CookieJar: cookieJar,
+			TLS:       tlsOptions(),
+			Proxy:     proxyOptions(),
+			Dial:      dialOptions(),
+			Redirect:  redirectOptions(),
+			Auth:      authCfg,
+			Raw:       rawBody,
 		}
+		profile.Apply(&httpReq, vars.Interpolate)
 
-		res, resBody, ms, err := httpclient.Do(ctx, httpReq)
+		result, err := httpclient.Send(ctx, httpReq)
+		if format != envelope.Text {
+			if werr := printEnvelope(format, envelope.New(httpReq, result, err)); werr != nil {
+				return werr
+			}
+		}
 		if err != nil {
 			return err
 		}
+		res, resBody, ms := result.Response, result.Body, result.Timings.Total
 		defer res.Body.Close()
 
 		_ = history.Append(history.Entry{
@@ -93,10 +125,11 @@ Examples:
 			BodySize:  len(body),
 		})
 
-		formatter.PrintStatusLine(req.Method, url, res.StatusCode, ms)
-
-		if err := formatter.PrintJSONOrText(resBody, jqQuery); err != nil {
-			return err
+		if format == envelope.Text {
+			formatter.PrintStatusLine(req.Method, url, res.StatusCode, ms)
+			if err := printResponseBody(resBody, res.Header.Get("Content-Type")); err != nil {
+				return err
+			}
 		}
 
 		if failOnErr && res.StatusCode >= 400 {
@@ -108,5 +141,6 @@ Examples:
 }
 
 func init() {
+	addRawOutputFlag(execCmd)
 	rootCmd.AddCommand(execCmd)
 }
@@ -3,38 +3,61 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"net/url"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/humancto/mozzy/internal/envelope"
 	"github.com/humancto/mozzy/internal/formatter"
 	"github.com/humancto/mozzy/internal/history"
 	"github.com/humancto/mozzy/internal/httpclient"
 	"github.com/humancto/mozzy/internal/vars"
 )
 
-func runVerb(cmd *cobra.Command, method string, target string, body []byte, isJSON bool) error {
-	// Resolve base/env
-	resolvedBase := vars.ResolveBase(baseURL, envName)
-	if resolvedBase != "" {
-		u, err := url.Parse(resolvedBase)
-		if err != nil { return err }
-		p, err := url.Parse(target)
-		if err != nil { return err }
-		target = u.ResolveReference(p).String()
+// runVerb sends method to args[0]; the remaining args are request items
+// that build the body, query and headers.
+func runVerb(cmd *cobra.Command, method string, args []string) error {
+	format, err := outputMode()
+	if err != nil { return err }
+	if format != envelope.Text && streaming() {
+		return fmt.Errorf("--output %s can't be combined with streaming; events are printed as they arrive", format)
+	}
+	if tableFormat != "" && streaming() {
+		return fmt.Errorf("--table can't be combined with streaming; events are printed as they arrive")
 	}
 
-	// Interpolate {{vars}} into URL and headers
-	target = vars.Interpolate(target)
-	hdrs := make([]string, len(headers))
-	for i, h := range headers { hdrs[i] = vars.Interpolate(h) }
-	token := vars.Interpolate(authToken)
+	// Resolve base/env
+	profile, err := activateProfile()
+	if err != nil { return err }
+	target, err := resolveTarget(profile, args[0])
+	if err != nil { return err }
+
+	b, err := requestBody(args[1:])
+	if err != nil { return err }
+	target, err = withQuery(target, b.Query)
+	if err != nil { return err }
+	body := b.Data
+
+	// Interpolate {{vars}} into headers; item and --header values win over
+	// the inferred Content-Type
+	var hdrs []string
+	if b.ContentType != "" {
+		hdrs = append(hdrs, "Content-Type: "+b.ContentType)
+	}
+	hdrs = append(hdrs, b.Headers...)
+	for _, h := range headers { hdrs = append(hdrs, vars.Interpolate(h)) }
+	token, authCfg, err := requestAuth(vars.Interpolate)
+	if err != nil { return err }
 
 	// Timeout
 	dur, err := time.ParseDuration(timeoutStr)
 	if err != nil { dur = 30 * time.Second }
 	ctx, cancel := context.WithTimeout(cmd.Context(), dur)
+	if streaming() && !cmd.Flags().Changed("timeout") {
+		// Streams run until they end or are stopped, not for the default 30s
+		cancel()
+		ctx, cancel = context.WithCancel(cmd.Context())
+	}
 	defer cancel()
 
 	req := httpclient.Request{
@@ -43,16 +66,32 @@ func runVerb(cmd *cobra.Command, method string, target string, body []byte, isJS
 		Headers:        hdrs,
 		Token:          token,
 		Body:           body,
-		JSON:           isJSON,
 		Verbose:        verbose,
 		RetryCount:     retryCount,
 		RetryCondition: retryCondition,
+		Retry:          retryOptions(),
 		CookieJar:      cookieJar,
 		Throttle:       throttle,
+		TLS:            tlsOptions(),
+		Proxy:          proxyOptions(),
+		Dial:           dialOptions(),
+		Redirect:       redirectOptions(),
+		Auth:           authCfg,
+		Compress:       bodyCompress,
+		Raw:            rawBody,
 	}
+	profile.Apply(&req, vars.Interpolate)
 
-	res, resBody, ms, err := httpclient.Do(ctx, req)
+	if streaming() {
+		return runStream(ctx, cmd, req)
+	}
+
+	result, err := httpclient.Send(ctx, req)
+	if format != envelope.Text {
+		if werr := printEnvelope(format, envelope.New(req, result, err)); werr != nil { return werr }
+	}
 	if err != nil { return err }
+	res, resBody, ms := result.Response, result.Body, result.Timings.Total
 	defer res.Body.Close()
 
 	_ = history.Append(history.Entry{
@@ -64,18 +103,20 @@ func runVerb(cmd *cobra.Command, method string, target string, body []byte, isJS
 		BodySize:  len(body),
 	})
 
-	formatter.PrintStatusLine(method, target, res.StatusCode, ms)
-
-	if err := formatter.PrintJSONOrText(resBody, jqQuery); err != nil { return err }
+	if format == envelope.Text {
+		formatter.PrintStatusLine(method, target, res.StatusCode, ms)
+		if err := printResponseBody(resBody, res.Header.Get("Content-Type")); err != nil { return err }
+	}
 
 	if failOnErr && res.StatusCode >= 400 {
 		os.Exit(1)
 	}
 
-	// Capture support: --capture name=.json.path
+	// Capture support: --capture name=<jq expression>
 	caps, _ := cmd.Flags().GetStringArray("capture")
+	ttl, _ := cmd.Flags().GetDuration("capture-ttl")
 	for _, c := range caps {
-		if err := vars.Capture(resBody, c); err != nil {
+		if err := vars.CapturePersistent(resBody, c, ttl); err != nil {
 			fmt.Fprintf(os.Stderr, "warn: capture failed: %v\n", err)
 		}
 	}
@@ -83,15 +124,19 @@ func runVerb(cmd *cobra.Command, method string, target string, body []byte, isJS
 }
 
 var getCmd = &cobra.Command{
-	Use:   "GET <url-or-path>",
+	Use:   "GET <url-or-path> [items...]",
 	Short: "Send an HTTP GET request",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runVerb(cmd, "GET", args[0], nil, false)
+		return runVerb(cmd, "GET", args)
 	},
 }
 
 func init() {
-	getCmd.Flags().StringArray("capture", nil, "Capture variables: name=.json.path (repeatable)")
+	addBodyFlags(getCmd)
+	getCmd.Flags().StringArray("capture", nil, "Capture variables: name=<jq expression>, e.g. token=.data.token (repeatable)")
+	getCmd.Flags().Duration("capture-ttl", 0, "Expire captured variables after this long, e.g. 15m (JWTs default to their exp)")
+	addRawOutputFlag(getCmd)
+	addStreamFlags(getCmd)
 	rootCmd.AddCommand(getCmd)
 }
@@ -143,12 +143,16 @@ func executeSavedRequest(cmd *cobra.Command, req collection.Request) error {
 	}
 
 	httpReq := httpclient.Request{
-		Method:  req.Method,
-		URL:     req.URL,
-		Headers: hdrs,
-		Body:    []byte(req.Body),
-		JSON:    req.Body != "",
-		Verbose: verbose,
+		Method:   req.Method,
+		URL:      req.URL,
+		Headers:  hdrs,
+		Body:     []byte(req.Body),
+		JSON:     req.Body != "",
+		Verbose:  verbose,
+		TLS:      tlsOptions(),
+		Proxy:    proxyOptions(),
+		Dial:     dialOptions(),
+		Redirect: redirectOptions(),
 	}
 
 	res, resBody, ms, err := httpclient.Do(ctx, httpReq)
@@ -168,7 +172,7 @@ func executeSavedRequest(cmd *cobra.Command, req collection.Request) error {
 
 	formatter.PrintStatusLine(req.Method, req.URL, res.StatusCode, ms)
 
-	if err := formatter.PrintJSONOrText(resBody, jqQuery); err != nil {
+	if err := printResponseBody(resBody, res.Header.Get("Content-Type")); err != nil {
 		return err
 	}
 
@@ -194,10 +198,14 @@ func executeHistoryEntry(cmd *cobra.Command, entry history.Entry) error {
 	defer cancel()
 
 	httpReq := httpclient.Request{
-		Method:  entry.Method,
-		URL:     entry.URL,
-		Headers: []string{},
-		Verbose: verbose,
+		Method:   entry.Method,
+		URL:      entry.URL,
+		Headers:  []string{},
+		Verbose:  verbose,
+		TLS:      tlsOptions(),
+		Proxy:    proxyOptions(),
+		Dial:     dialOptions(),
+		Redirect: redirectOptions(),
 	}
 
 	res, resBody, ms, err := httpclient.Do(ctx, httpReq)
@@ -217,7 +225,7 @@ func executeHistoryEntry(cmd *cobra.Command, entry history.Entry) error {
 
 	formatter.PrintStatusLine(entry.Method, entry.URL, res.StatusCode, ms)
 
-	if err := formatter.PrintJSONOrText(resBody, jqQuery); err != nil {
+	if err := printResponseBody(resBody, res.Header.Get("Content-Type")); err != nil {
 		return err
 	}
 
@@ -230,5 +238,6 @@ func executeHistoryEntry(cmd *cobra.Command, entry history.Entry) error {
 
 func init() {
 	interactiveCmd.Flags().Bool("saved", false, "Browse saved requests instead of history")
+	addRawOutputFlag(interactiveCmd)
 	rootCmd.AddCommand(interactiveCmd)
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/humancto/mozzy/internal/breaker"
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/ratelimit"
+)
+
+var (
+	rateLimit       string
+	rateBurst       int
+	breakerFailures int
+	breakerCooldown time.Duration
+)
+
+// limitOptions returns the rate limit and circuit breaker given on the
+// command line. They apply to every host and beat the environment's limits.
+func limitOptions() (httpclient.Limits, error) {
+	rate, err := ratelimit.ParseRate(rateLimit)
+	if err != nil {
+		return httpclient.Limits{}, err
+	}
+	return httpclient.Limits{
+		Rate:    rate,
+		Burst:   rateBurst,
+		Breaker: breaker.Settings{Failures: breakerFailures, Cooldown: breakerCooldown},
+	}, nil
+}
+
+// newGuard builds the limiter and breaker shared by all requests of one
+// command from the flags and the environment's per-host limits. It returns
+// nil when nothing is configured.
+func newGuard(profile *config.Profile) (*httpclient.Guard, error) {
+	override, err := limitOptions()
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := profile.HostLimits()
+	if err != nil {
+		return nil, err
+	}
+	return httpclient.NewGuard(override, hosts), nil
+}
+
+// addLimitFlags registers --rate, --burst and the circuit breaker flags on
+// commands that send many requests (load, run, test).
+func addLimitFlags(c *cobra.Command) {
+	flags := c.Flags()
+	flags.StringVar(&rateLimit, "rate", "", "Client-side rate limit per host, e.g. 20/s, 600/m")
+	flags.IntVar(&rateBurst, "burst", 0, "Requests allowed back to back under --rate (default 1)")
+	flags.IntVar(&breakerFailures, "breaker", 0, "Open a per-host circuit breaker after N consecutive failures (5xx or network errors)")
+	// no default here, so an unset flag doesn't replace the environment's breaker_cooldown
+	flags.DurationVar(&breakerCooldown, "breaker-cooldown", 0, "How long an open circuit waits before a probe request (default "+breaker.DefaultCooldown.String()+")")
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/humancto/mozzy/internal/config"
+)
+
+func TestNewGuard_ProfileCooldown(t *testing.T) {
+	defer func(f int, c time.Duration) { breakerFailures, breakerCooldown = f, c }(breakerFailures, breakerCooldown)
+	profile := &config.Profile{Limits: map[string]*config.Limit{"*": {Breaker: 3, BreakerCooldown: "5s"}}}
+
+	parse := func(args ...string) {
+		c := &cobra.Command{}
+		addLimitFlags(c)
+		if err := c.ParseFlags(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parse("--breaker", "2")
+	g, err := newGuard(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Limits("http://api.test/").Breaker; got.Failures != 2 || got.Cooldown != 5*time.Second {
+		t.Errorf("--breaker alone: %+v, want the environment's 5s cooldown", got)
+	}
+
+	parse("--breaker-cooldown", "1m")
+	g, _ = newGuard(profile)
+	if got := g.Limits("http://api.test/").Breaker; got.Failures != 3 || got.Cooldown != time.Minute {
+		t.Errorf("--breaker-cooldown: %+v", got)
+	}
+}
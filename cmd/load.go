@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -10,7 +11,9 @@ import (
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
+	"github.com/humancto/mozzy/internal/breaker"
 	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/vars"
 )
 
 var (
@@ -36,23 +39,57 @@ func init() {
 	loadCmd.Flags().IntVar(&loadRequests, "requests", 100, "Total number of requests to send")
 	loadCmd.Flags().IntVar(&loadConcurrent, "concurrent", 10, "Number of concurrent workers")
 	loadCmd.Flags().StringVar(&loadDuration, "duration", "", "Run for duration (e.g. 30s) instead of fixed requests")
+	addLimitFlags(loadCmd)
 	rootCmd.AddCommand(loadCmd)
 }
 
 func runLoad(cmd *cobra.Command, args []string) error {
-	url := args[0]
+	profile, err := activateProfile()
+	if err != nil {
+		return err
+	}
+	url, err := resolveTarget(profile, args[0])
+	if err != nil {
+		return err
+	}
+	guard, err := newGuard(profile)
+	if err != nil {
+		return err
+	}
+	token, authCfg, err := requestAuth(vars.Interpolate)
+	if err != nil {
+		return err
+	}
+
+	hdrs := make([]string, len(headers))
+	for i, h := range headers {
+		hdrs[i] = vars.Interpolate(h)
+	}
 
 	// Prepare request
 	req := httpclient.Request{
-		Method:  "GET",
-		URL:     url,
-		Headers: headers,
-		Token:   authToken,
+		Method:   "GET",
+		URL:      url,
+		Headers:  hdrs,
+		Token:    token,
+		TLS:      tlsOptions(),
+		Proxy:    proxyOptions(),
+		Dial:     dialOptions(),
+		Redirect: redirectOptions(),
+		Guard:    guard,
+		Auth:     authCfg,
 	}
+	profile.Apply(&req, vars.Interpolate)
 
 	fmt.Printf("🔥 Load Testing\n")
 	fmt.Printf("   Target: %s\n", url)
 	fmt.Printf("   Concurrent workers: %d\n", loadConcurrent)
+	if l := guard.Limits(url); !l.Rate.IsZero() {
+		fmt.Printf("   Rate limit: %s\n", l.Rate)
+	}
+	if l := guard.Limits(url); l.Breaker.Failures > 0 {
+		fmt.Printf("   Circuit breaker: opens after %d consecutive failures\n", l.Breaker.Failures)
+	}
 
 	var totalRequests int64
 	var successCount int64
@@ -91,6 +128,7 @@ func runLoad(cmd *cobra.Command, args []string) error {
 						res, _, reqDuration, err := httpclient.Do(ctx, req)
 						if err != nil {
 							atomic.AddInt64(&errorCount, 1)
+							pauseWhileOpen(ctx, err)
 						} else {
 							res.Body.Close()
 							if res.StatusCode >= 200 && res.StatusCode < 300 {
@@ -132,6 +170,7 @@ func runLoad(cmd *cobra.Command, args []string) error {
 					res, _, reqDuration, err := httpclient.Do(ctx, req)
 					if err != nil {
 						atomic.AddInt64(&errorCount, 1)
+						pauseWhileOpen(ctx, err)
 					} else {
 						res.Body.Close()
 						if res.StatusCode >= 200 && res.StatusCode < 300 {
@@ -187,6 +226,13 @@ func runLoad(cmd *cobra.Command, args []string) error {
 	} else {
 		fmt.Printf("  Failed:       %d\n", errorCount)
 	}
+	waited, rejected := guard.Stats()
+	if rejected > 0 {
+		color.Yellow("  Short-circuited: %d (circuit open)\n", rejected)
+	}
+	if waited > 0 {
+		fmt.Printf("  Rate limited: %s spent waiting\n", waited.Round(time.Millisecond))
+	}
 
 	fmt.Printf("\nTiming:\n")
 	fmt.Printf("  Total time:   %s\n", elapsed.Round(time.Millisecond))
@@ -204,3 +250,20 @@ func runLoad(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// pauseWhileOpen keeps a worker from spinning on an open circuit breaker: it
+// sleeps until the breaker lets a probe through.
+func pauseWhileOpen(ctx context.Context, err error) {
+	var open *breaker.OpenError
+	if !errors.As(err, &open) {
+		return
+	}
+	wait := time.Until(open.Until)
+	if wait <= 0 {
+		wait = 100 * time.Millisecond // a probe is in flight
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(wait):
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/fatih/color"
@@ -14,6 +15,7 @@ import (
 	"github.com/humancto/mozzy/internal/collection"
 	"github.com/humancto/mozzy/internal/mock"
 	"github.com/humancto/mozzy/internal/ui"
+	"github.com/humancto/mozzy/internal/vars"
 )
 
 var (
@@ -128,12 +130,33 @@ func loadConfigFromCollection(port int) (*mock.Config, error) {
 		return nil, fmt.Errorf("no saved requests found in collection")
 	}
 
+	profile, err := activateProfile()
+	if err != nil {
+		return nil, err
+	}
+	base := ""
+	if profile != nil {
+		base = strings.TrimRight(vars.Interpolate(profile.BaseURL), "/")
+	}
+
 	config := mock.DefaultConfig(port)
 
 	// Convert saved requests to mock routes
 	for _, req := range requests {
+		req.URL = vars.Interpolate(req.URL)
+		req.Body = vars.Interpolate(req.Body)
+
+		// With an environment active, serve each request at its real path
+		// so the profile's base URL can point straight at the mock server
+		path := "/" + req.Name
+		if base != "" && strings.HasPrefix(req.URL, base+"/") {
+			path = collectionRoutePath(strings.TrimPrefix(req.URL, base))
+		} else if profile != nil && strings.HasPrefix(req.URL, "/") {
+			path = collectionRoutePath(req.URL)
+		}
+
 		route := mock.Route{
-			Path:        "/" + req.Name,
+			Path:        path,
 			Method:      req.Method,
 			StatusCode:  200,
 			Description: req.Description,
@@ -166,6 +189,14 @@ func loadConfigFromCollection(port int) (*mock.Config, error) {
 	return config, nil
 }
 
+// collectionRoutePath strips the query string from a saved request path.
+func collectionRoutePath(p string) string {
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	return p
+}
+
 func generateMockConfig() error {
 	sampleConfig := &mock.Config{
 		Port: 8080,
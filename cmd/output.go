@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/humancto/mozzy/internal/envelope"
+	"github.com/humancto/mozzy/internal/formatter"
+)
+
+var (
+	outputFormat string
+	indentWidth  int
+	themeName    string
+	maxDepth     int
+	maxArray     int
+)
+
+// outputMode returns the --output format. Anything but text prints a single
+// envelope on stdout, so the flags that reshape the printed body don't apply.
+func outputMode() (string, error) {
+	format, err := envelope.ParseFormat(outputFormat)
+	if err != nil {
+		return "", err
+	}
+	if format != envelope.Text && jqQuery != "" {
+		return "", fmt.Errorf("--jq can't be combined with --output %s; pipe the envelope to jq instead", format)
+	}
+	if _, err := tableMode(); err != nil {
+		return "", err
+	}
+	if _, err := prettyOptions(); err != nil {
+		return "", err
+	}
+	if format != envelope.Text && tableFormat != "" {
+		return "", fmt.Errorf("--table can't be combined with --output %s", format)
+	}
+	return format, nil
+}
+
+// prettyOptions returns how JSON bodies are printed, from --indent,
+// --theme, --max-depth and --max-array.
+func prettyOptions() (formatter.PrettyOptions, error) {
+	if indentWidth < 0 || maxDepth < 0 || maxArray < 0 {
+		return formatter.PrettyOptions{}, fmt.Errorf("--indent, --max-depth and --max-array can't be negative")
+	}
+	theme, err := formatter.ParseTheme(themeName)
+	if err != nil {
+		return formatter.PrettyOptions{}, err
+	}
+	return formatter.PrettyOptions{
+		Indent:   strings.Repeat(" ", indentWidth),
+		Theme:    theme,
+		MaxDepth: maxDepth,
+		MaxArray: maxArray,
+	}, nil
+}
+
+// printEnvelope writes v to stdout in format.
+func printEnvelope(format string, v any) error {
+	return envelope.Write(os.Stdout, format, v)
+}
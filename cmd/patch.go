@@ -1,45 +1,23 @@
 package cmd
 
 import (
-	"os"
-	"strings"
-
 	"github.com/spf13/cobra"
 )
 
-var (
-	patchJSON        string
-	patchBodyFile    string
-	patchFormData    []string
-	patchContentType string
-)
-
 var patchCmd = &cobra.Command{
-	Use:   "PATCH <url-or-path>",
+	Use:   "PATCH <url-or-path> [items...]",
 	Short: "Send an HTTP PATCH request",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var body []byte
-		if patchBodyFile != "" {
-			b, err := os.ReadFile(patchBodyFile); if err != nil { return err }
-			body = b
-		} else if patchJSON != "" {
-			if strings.HasPrefix(patchJSON, "@") {
-				p := strings.TrimPrefix(patchJSON, "@")
-				b, err := os.ReadFile(p); if err != nil { return err }
-				body = b
-			} else {
-				body = []byte(patchJSON)
-			}
-		}
-		return runVerb(cmd, "PATCH", args[0], body, patchJSON != "" && patchContentType == "")
+		return runVerb(cmd, "PATCH", args)
 	},
 }
 
 func init() {
-	patchCmd.Flags().StringVar(&patchJSON, "json", "", "JSON payload (string or @file.json)")
-	patchCmd.Flags().StringVar(&patchBodyFile, "file", "", "Raw body from file")
-	patchCmd.Flags().StringVar(&patchContentType, "content-type", "", "Override Content-Type header")
-	patchCmd.Flags().StringArray("capture", nil, "Capture variables: name=.json.path (repeatable)")
+	addBodyFlags(patchCmd)
+	patchCmd.Flags().StringArray("capture", nil, "Capture variables: name=<jq expression>, e.g. token=.data.token (repeatable)")
+	patchCmd.Flags().Duration("capture-ttl", 0, "Expire captured variables after this long, e.g. 15m (JWTs default to their exp)")
+	addRawOutputFlag(patchCmd)
+	addStreamFlags(patchCmd)
 	rootCmd.AddCommand(patchCmd)
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+// activateProfile resolves the environment selected by --env (or
+// default_env) and makes its variables available to {{name}} interpolation.
+func activateProfile() (*config.Profile, error) {
+	return vars.ActivateProfile(envName)
+}
+
+// resolveTarget interpolates target and joins it onto the active base URL.
+// Absolute URLs are returned unchanged.
+func resolveTarget(profile *config.Profile, target string) (string, error) {
+	// Interpolate first: URL parsing would escape the {{ }} placeholders
+	target = vars.Interpolate(target)
+	base := vars.Interpolate(config.BaseURL(profile, baseURL, envName))
+	if base == "" {
+		return target, nil
+	}
+	if strings.HasPrefix(base, "unix://") && strings.HasPrefix(target, "/") {
+		// The socket path is part of the base, so append rather than resolve
+		return strings.TrimRight(base, "/") + target, nil
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	p, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	return u.ResolveReference(p).String(), nil
+}
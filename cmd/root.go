@@ -3,19 +3,27 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/humancto/mozzy/internal/auth"
+	"github.com/humancto/mozzy/internal/formatter"
+	"github.com/humancto/mozzy/internal/retry"
 	"github.com/humancto/mozzy/internal/ui"
 )
 
 var (
 	baseURL        string
 	authToken      string
+	authType       string
+	authParams     []string
 	headers        []string
 	envName        string
 	jqQuery        string
+	rawOutput      bool
 	timeoutStr     string
 	failOnErr      bool
 	noColor        bool
@@ -23,8 +31,15 @@ var (
 	verbose        bool
 	retryCount     int
 	retryCondition string
+	retryDelay     time.Duration
+	retryMaxDelay  time.Duration
+	retryMaxWait   time.Duration
+	retryJitter    string
+	retryBudget    time.Duration
+	idempotencyKey string
 	cookieJar      string
 	throttle       string
+	rawBody        bool
 )
 
 var rootCmd = &cobra.Command{
@@ -53,10 +68,12 @@ func init() {
 {{end}}{{if or .Runnable .HasSubCommands}}{{.UsageString}}{{end}}`)
 
 	rootCmd.PersistentFlags().StringVar(&baseURL, "base", "", "Base URL, e.g. https://api.example.com (overridden by --env)")
-	rootCmd.PersistentFlags().StringVar(&authToken, "auth", "", "Bearer token (Authorization: Bearer ...)")
+	rootCmd.PersistentFlags().StringVar(&authToken, "auth", "", "Bearer token, or the credential for --auth-type (user:pass, ACCESS:SECRET, KEY_ID:SECRET, api key)")
+	rootCmd.PersistentFlags().StringVar(&authType, "auth-type", "", "Auth scheme: bearer, basic, digest, sigv4, hmac, apikey")
+	rootCmd.PersistentFlags().StringArrayVar(&authParams, "auth-param", nil, "Auth scheme setting name=value, e.g. region=eu-west-1 (repeatable)")
 	rootCmd.PersistentFlags().StringSliceVar(&headers, "header", nil, "Extra headers (repeat), e.g. --header 'X-Env: staging'")
 	rootCmd.PersistentFlags().StringVar(&envName, "env", "", "Named environment from .mozzy.json")
-	rootCmd.PersistentFlags().StringVar(&jqQuery, "jq", "", "Inline JSON query (JSONPath/JQ-lite)")
+	rootCmd.PersistentFlags().StringVar(&jqQuery, "jq", "", "jq expression applied to JSON responses, e.g. '.items[] | select(.active) | .id'")
 	rootCmd.PersistentFlags().StringVar(&timeoutStr, "timeout", "30s", "Request timeout, e.g. 2s, 500ms")
 	rootCmd.PersistentFlags().BoolVar(&failOnErr, "fail", false, "Exit non-zero on HTTP status >= 400 (CI-friendly)")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
@@ -64,8 +81,25 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show request/response headers and timing details")
 	rootCmd.PersistentFlags().IntVar(&retryCount, "retry", 0, "Number of retry attempts on failure (with exponential backoff)")
 	rootCmd.PersistentFlags().StringVar(&retryCondition, "retry-on", "", "Retry condition: 5xx, 429, >=500, network_error, etc. (comma-separated)")
+	rootCmd.PersistentFlags().DurationVar(&retryDelay, "retry-delay", retry.DefaultBase, "Initial retry backoff, doubled on each attempt")
+	rootCmd.PersistentFlags().DurationVar(&retryMaxDelay, "retry-max-delay", retry.DefaultMax, "Cap for a single retry backoff")
+	rootCmd.PersistentFlags().DurationVar(&retryMaxWait, "retry-max-wait", retry.DefaultMaxWait, "Cap for a wait asked for by Retry-After or RateLimit-Reset")
+	rootCmd.PersistentFlags().StringVar(&retryJitter, "retry-jitter", retry.JitterFull, "Backoff jitter: none, full, equal, decorrelated")
+	rootCmd.PersistentFlags().DurationVar(&retryBudget, "retry-budget", 0, "Give up retrying once attempts and waits would exceed this, e.g. 1m")
+	rootCmd.PersistentFlags().StringVar(&idempotencyKey, "idempotency-key", "", "Send an Idempotency-Key so POST/PATCH can be retried (no value: generate one)")
+	rootCmd.PersistentFlags().Lookup("idempotency-key").NoOptDefVal = "auto"
 	rootCmd.PersistentFlags().StringVar(&cookieJar, "cookie-jar", "", "File to store/load cookies for session management")
 	rootCmd.PersistentFlags().StringVar(&throttle, "throttle", "", "Network throttling: 56k, slow, gprs, edge, 3g, 4g, lte, 5g")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "", "Output format: text (default), or json/yaml for one machine-readable envelope")
+	rootCmd.PersistentFlags().StringVar(&tableFormat, "table", "", "Show an array of objects as a table: box (no value), csv, tsv or markdown")
+	rootCmd.PersistentFlags().Lookup("table").NoOptDefVal = "box"
+	rootCmd.PersistentFlags().StringSliceVar(&tableColumns, "columns", nil, "Table columns as dot paths, e.g. id,name,owner.login (default: every key)")
+	rootCmd.PersistentFlags().StringVar(&tableSortBy, "sort-by", "", "Sort table rows by a column path; prefix with - for descending")
+	rootCmd.PersistentFlags().IntVar(&indentWidth, "indent", 2, "Spaces per JSON indentation level (0 prints compact JSON)")
+	rootCmd.PersistentFlags().StringVar(&themeName, "theme", "default", "JSON colors: default, monokai, solarized, github, mono, plus role=SGR overrides, e.g. key=1;34")
+	rootCmd.PersistentFlags().IntVar(&maxDepth, "max-depth", 0, "Fold JSON objects and arrays nested deeper than N levels (0 = no limit)")
+	rootCmd.PersistentFlags().IntVar(&maxArray, "max-array", 0, "Show at most N elements of each JSON array (0 = all)")
+	rootCmd.PersistentFlags().BoolVar(&rawBody, "raw", false, "Keep gzip/deflate/br/zstd response bodies encoded instead of decoding them")
 
 	// Custom usage template with colors
 	rootCmd.SetUsageFunc(customUsage)
@@ -82,9 +116,64 @@ func init() {
 			color.NoColor = false
 			os.Setenv("CLICOLOR_FORCE", "1")
 		}
+		formatter.RawOutput = rawOutput
+		if opts, err := prettyOptions(); err == nil {
+			formatter.Pretty = opts
+		}
 	})
 }
 
+// retryOptions returns the backoff settings given on the command line.
+func retryOptions() retry.Options {
+	return retry.Options{
+		Base:           retryDelay,
+		Max:            retryMaxDelay,
+		MaxWait:        retryMaxWait,
+		Jitter:         retryJitter,
+		Budget:         retryBudget,
+		IdempotencyKey: idempotencyKey,
+	}
+}
+
+// requestAuth returns the credentials given on the command line: a plain
+// Bearer token for --auth alone, or the --auth-type scheme with --auth as its
+// credential and --auth-param settings. interpolate resolves {{vars}}; pass
+// nil to keep them for a workflow to resolve per step.
+func requestAuth(interpolate func(string) string) (string, auth.Config, error) {
+	if interpolate == nil {
+		interpolate = func(s string) string { return s }
+	}
+	if authType == "" {
+		if len(authParams) > 0 {
+			return "", auth.Config{}, fmt.Errorf("--auth-param needs --auth-type")
+		}
+		return interpolate(authToken), auth.Config{}, nil
+	}
+	c := auth.Config{Type: strings.ToLower(authType)}
+	if authToken != "" {
+		c.SetCredential(interpolate(authToken))
+	}
+	for _, p := range authParams {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			return "", c, fmt.Errorf("--auth-param %q: want name=value", p)
+		}
+		if err := c.Set(strings.TrimSpace(k), interpolate(v)); err != nil {
+			return "", c, err
+		}
+	}
+	if _, err := auth.New(c); err != nil {
+		return "", c, err
+	}
+	return "", c, nil
+}
+
+// addRawOutputFlag registers -r/--raw-output on commands that print response
+// bodies. It is not a global flag because proxy already uses -r.
+func addRawOutputFlag(c *cobra.Command) {
+	c.Flags().BoolVarP(&rawOutput, "raw-output", "r", false, "Print string results of --jq without JSON quotes")
+}
+
 func customUsage(cmd *cobra.Command) error {
 	out := cmd.OutOrStdout()
 
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
-	"gopkg.in/yaml.v3"
 	"github.com/spf13/cobra"
 	"github.com/humancto/mozzy/internal/chain"
 )
@@ -13,16 +17,112 @@ var runCmd = &cobra.Command{
 	Short: "Run a YAML workflow (steps with captures and vars)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		path := args[0]
-		b, err := os.ReadFile(path)
+		flow, err := chain.Load(args[0])
 		if err != nil { return err }
-		var flow chain.Flow
-		if err := yaml.Unmarshal(b, &flow); err != nil { return err }
+		if err := dataOptions(cmd, &flow); err != nil {
+			return err
+		}
 		flow.EnvName = envName
 		flow.BaseURL = baseURL
-		flow.GlobalAuth = authToken
-		return chain.Run(cmd.Context(), flow)
+		token, authCfg, err := requestAuth(nil)
+		if err != nil {
+			return err
+		}
+		flow.GlobalAuth = token
+		if authCfg.Type != "" {
+			flow.Auth = &authCfg
+		}
+		flow.TLS = tlsOptions()
+		flow.Proxy = proxyOptions()
+		flow.Dial = dialOptions()
+		flow.Redirect = redirectOptions()
+		if flow.Limits, err = limitOptions(); err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("retry") {
+			flow.Retry = retrySpec()
+		}
+		if cookieJar != "" {
+			flow.CookieJar = cookieJar
+		}
+		if flow.Output, err = outputMode(); err != nil {
+			return err
+		}
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
+		return chain.Run(ctx, flow)
 	},
 }
 
-func init() { rootCmd.AddCommand(runCmd) }
+var (
+	dataFile     string
+	dataMode     string
+	dataParallel int
+)
+
+func init() {
+	addLimitFlags(runCmd)
+	runCmd.Flags().StringVar(&dataFile, "data", "", "Run the steps once per row of a CSV, TSV, JSON or JSON Lines file")
+	runCmd.Flags().StringVar(&dataMode, "data-mode", "", "On a failing row: fail-fast (default) stops, continue runs the remaining rows")
+	runCmd.Flags().IntVar(&dataParallel, "data-parallel", 0, "Rows to run at once")
+	rootCmd.AddCommand(runCmd)
+}
+
+// dataOptions applies --data, --data-mode and --data-parallel over the
+// flow's own data block.
+func dataOptions(cmd *cobra.Command, flow *chain.Flow) error {
+	if dataFile != "" {
+		abs, err := filepath.Abs(dataFile)
+		if err != nil {
+			return err
+		}
+		if flow.Data == nil {
+			flow.Data = &chain.Data{}
+		}
+		flow.Data.File, flow.Data.Rows = abs, nil
+	}
+	if flow.Data == nil {
+		if cmd.Flags().Changed("data-mode") || cmd.Flags().Changed("data-parallel") {
+			return fmt.Errorf("--data-mode and --data-parallel need --data or a data block in the flow")
+		}
+		return nil
+	}
+	if dataMode != "" {
+		flow.Data.Mode = dataMode
+	}
+	if cmd.Flags().Changed("data-parallel") {
+		if dataParallel < 1 {
+			return fmt.Errorf("--data-parallel must be at least 1")
+		}
+		flow.Data.Parallel = dataParallel
+	}
+	return nil
+}
+
+// retrySpec turns the global retry flags into a workflow retry policy. It
+// replaces the flow's own retry block when --retry is given.
+func retrySpec() *chain.RetrySpec {
+	o := retryOptions()
+	return &chain.RetrySpec{
+		Retries:        retryCount,
+		On:             retryCondition,
+		Delay:          o.Base,
+		MaxDelay:       o.Max,
+		MaxWait:        o.MaxWait,
+		Jitter:         o.Jitter,
+		Budget:         o.Budget,
+		IdempotencyKey: o.IdempotencyKey,
+	}
+}
+
+// interruptContext returns a context cancelled by the first Ctrl+C or
+// SIGTERM, so a workflow's teardown steps can clean up; a second Ctrl+C
+// during teardown exits at once.
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
@@ -40,8 +40,8 @@ Examples:
 		body := ""
 		if method != "GET" && method != "DELETE" {
 			// Check for JSON flag from parent commands
-			if postJSON != "" {
-				body = postJSON
+			if bodyJSON != "" {
+				body = bodyJSON
 			}
 		}
 
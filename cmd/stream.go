@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/humancto/mozzy/internal/formatter"
+	"github.com/humancto/mozzy/internal/history"
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/stream"
+	"github.com/humancto/mozzy/internal/ui"
+	"github.com/humancto/mozzy/internal/vars"
+	"github.com/spf13/cobra"
+)
+
+var (
+	streamMode        bool
+	streamMaxEvents   int
+	streamUntil       string
+	streamReconnect   bool
+	streamLastEventID string
+)
+
+// addStreamFlags registers the streaming flags on the request verbs.
+func addStreamFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&streamMode, "stream", false, "Print SSE events, NDJSON records or lines as they arrive")
+	c.Flags().IntVar(&streamMaxEvents, "max-events", 0, "Stop streaming after N events (implies --stream)")
+	c.Flags().StringVar(&streamUntil, "until", "", "Stop streaming after the first event where this jq expression is true (implies --stream)")
+	c.Flags().BoolVar(&streamReconnect, "reconnect", false, "Reconnect SSE streams that close, sending Last-Event-ID (implies --stream)")
+	c.Flags().StringVar(&streamLastEventID, "last-event-id", "", "Resume an SSE stream after this event ID (implies --stream)")
+}
+
+// streaming reports whether the streaming flags ask for stream mode.
+func streaming() bool {
+	return streamMode || streamMaxEvents > 0 || streamUntil != "" || streamReconnect || streamLastEventID != ""
+}
+
+func streamOptions() stream.Options {
+	return stream.Options{
+		MaxEvents:   streamMaxEvents,
+		Until:       streamUntil,
+		Reconnect:   streamReconnect,
+		LastEventID: streamLastEventID,
+	}
+}
+
+// runStream sends req and prints the response body event by event. Ctrl+C
+// and an explicit --timeout end the stream normally.
+func runStream(ctx context.Context, cmd *cobra.Command, req httpclient.Request) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	caps, _ := cmd.Flags().GetStringArray("capture")
+	ttl, _ := cmd.Flags().GetDuration("capture-ttl")
+	var values []any
+
+	open := func(ctx context.Context, lastEventID string, attempt int) (*http.Response, error) {
+		r := req
+		if lastEventID != "" {
+			r.Headers = append(append([]string{}, req.Headers...), "Last-Event-ID: "+lastEventID)
+		}
+		if attempt > 0 {
+			fmt.Fprintln(os.Stderr, ui.DimStyle.Render(fmt.Sprintf("🔄 Reconnecting (Last-Event-ID: %s)...", orNone(lastEventID))))
+		}
+		res, ms, err := httpclient.Open(ctx, r)
+		if err != nil {
+			if attempt > 0 {
+				fmt.Fprintf(os.Stderr, "⚠️  Reconnect failed: %v\n", err)
+			}
+			return nil, err
+		}
+		if attempt == 0 {
+			_ = history.Append(history.Entry{
+				Timestamp: time.Now(),
+				Method:    r.Method,
+				URL:       r.URL,
+				Status:    res.StatusCode,
+				Duration:  ms,
+				BodySize:  len(r.Body),
+			})
+			formatter.PrintStatusLine(r.Method, r.URL, res.StatusCode, ms)
+		}
+		return res, nil
+	}
+
+	start := time.Now()
+	result, err := stream.Run(ctx, streamOptions(), open, func(ev stream.Event) error {
+		if len(caps) > 0 {
+			values = append(values, ev.Value())
+		}
+		return formatter.PrintEvent(ev, jqQuery)
+	})
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	summary := fmt.Sprintf("📡 %d event(s) in %s", result.Events, time.Since(start).Round(time.Millisecond))
+	switch {
+	case result.Stopped != "":
+		summary += " (stopped: " + result.Stopped + ")"
+	case err != nil:
+		summary += " (interrupted)"
+	}
+	if result.LastEventID != "" {
+		summary += ", last event ID " + result.LastEventID
+	}
+	fmt.Fprintln(os.Stderr, ui.DimStyle.Render(summary))
+
+	if failOnErr && result.Status >= 400 {
+		os.Exit(1)
+	}
+
+	if len(caps) > 0 {
+		// Captures see the stream as an array of event payloads
+		body, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		for _, c := range caps {
+			if err := vars.CapturePersistent(body, c, ttl); err != nil {
+				fmt.Fprintf(os.Stderr, "warn: capture failed: %v\n", err)
+			}
+		}
+	}
+	return nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/humancto/mozzy/internal/formatter"
+	"github.com/humancto/mozzy/internal/ui"
+)
+
+var (
+	tableFormat  string
+	tableColumns []string
+	tableSortBy  string
+)
+
+// tableMode returns the --table format, or "" to print bodies as usual.
+func tableMode() (string, error) {
+	format, err := formatter.ParseTableFormat(tableFormat)
+	if err != nil {
+		return "", err
+	}
+	if format == "" && (len(tableColumns) > 0 || tableSortBy != "") {
+		return "", fmt.Errorf("--columns and --sort-by need --table")
+	}
+	return format, nil
+}
+
+// printResponseBody prints a response body as a table with --table, and
+// otherwise pretty-printed by its Content-Type.
+func printResponseBody(body []byte, contentType string) error {
+	format, err := tableMode()
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		return formatter.PrintBody(body, contentType, jqQuery)
+	}
+	opts := formatter.TableOptions{Format: format, Columns: tableColumns, SortBy: tableSortBy}
+	if format == formatter.TableBox {
+		opts.Width = ui.TerminalWidth()
+	}
+	return formatter.PrintTable(color.Output, body, contentType, jqQuery, opts)
+}
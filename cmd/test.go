@@ -1,13 +1,11 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 
 	"github.com/humancto/mozzy/internal/chain"
 )
@@ -35,6 +33,7 @@ Example:
 
 func init() {
 	testCmd.Flags().StringVar(&testJUnitOutput, "junit-output", "", "Write JUnit XML report to file")
+	addLimitFlags(testCmd)
 	rootCmd.AddCommand(testCmd)
 }
 
@@ -42,20 +41,35 @@ func runTest(cmd *cobra.Command, args []string) error {
 	workflowFile := args[0]
 
 	// Read workflow file
-	b, err := os.ReadFile(workflowFile)
+	flow, err := chain.Load(workflowFile)
 	if err != nil {
 		return fmt.Errorf("failed to read workflow: %w", err)
 	}
 
-	var flow chain.Flow
-	if err := yaml.Unmarshal(b, &flow); err != nil {
-		return fmt.Errorf("failed to parse workflow: %w", err)
-	}
-
 	// Populate from flags
 	flow.BaseURL = baseURL
 	flow.EnvName = envName
-	flow.GlobalAuth = authToken
+	token, authCfg, err := requestAuth(nil)
+	if err != nil {
+		return err
+	}
+	flow.GlobalAuth = token
+	if authCfg.Type != "" {
+		flow.Auth = &authCfg
+	}
+	flow.TLS = tlsOptions()
+	flow.Proxy = proxyOptions()
+	flow.Dial = dialOptions()
+	flow.Redirect = redirectOptions()
+	if flow.Limits, err = limitOptions(); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("retry") {
+		flow.Retry = retrySpec()
+	}
+	if cookieJar != "" {
+		flow.CookieJar = cookieJar
+	}
 
 	fmt.Printf("🧪 Running test suite: %s\n", flow.Name)
 	if flow.Description != "" {
@@ -66,8 +80,9 @@ func runTest(cmd *cobra.Command, args []string) error {
 	startTime := time.Now()
 
 	// Run the workflow
-	ctx := context.Background()
+	ctx, stop := interruptContext(cmd.Context())
 	err = chain.Run(ctx, flow)
+	stop()
 
 	duration := time.Since(startTime)
 
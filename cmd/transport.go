@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+var (
+	tlsCert         string
+	tlsKey          string
+	tlsCertPassword string
+	tlsCACert       string
+	tlsInsecure     bool
+	tlsMinVersion   string
+	tlsMaxVersion   string
+	tlsCiphers      []string
+	tlsServerName   string
+
+	proxyURL     string
+	proxyUser    string
+	proxyNoProxy string
+
+	dialResolve    []string
+	dialConnectTo  []string
+	dialDNSServers []string
+	unixSocket     string
+
+	maxRedirects    int
+	noFollow        bool
+	locationTrusted bool
+)
+
+// tlsOptions returns the TLS settings given on the command line. Settings
+// left empty are filled in from the active environment by Profile.Apply.
+func tlsOptions() httpclient.TLSOptions {
+	return httpclient.TLSOptions{
+		Insecure:     tlsInsecure,
+		CACert:       tlsCACert,
+		Cert:         tlsCert,
+		Key:          tlsKey,
+		CertPassword: tlsCertPassword,
+		MinVersion:   tlsMinVersion,
+		MaxVersion:   tlsMaxVersion,
+		Ciphers:      strings.Join(tlsCiphers, ","),
+		ServerName:   tlsServerName,
+	}
+}
+
+// proxyOptions returns the outbound proxy given on the command line.
+func proxyOptions() httpclient.ProxyOptions {
+	return httpclient.ProxyOptions{
+		URL:     proxyURL,
+		User:    proxyUser,
+		NoProxy: proxyNoProxy,
+	}
+}
+
+// dialOptions returns the DNS and address overrides given on the command line.
+func dialOptions() httpclient.DialOptions {
+	return httpclient.DialOptions{
+		Resolve:    dialResolve,
+		ConnectTo:  dialConnectTo,
+		DNSServers: dialDNSServers,
+		UnixSocket: unixSocket,
+	}
+}
+
+// redirectOptions returns the redirect policy given on the command line.
+// --max-redirects 0 is the same as --no-follow.
+func redirectOptions() httpclient.RedirectOptions {
+	return httpclient.RedirectOptions{
+		NoFollow: noFollow || maxRedirects <= 0,
+		Max:      maxRedirects,
+		Trusted:  locationTrusted,
+	}
+}
+
+// transportOptions returns the command line connection settings completed
+// from the environment.
+func transportOptions(profile *config.Profile) httpclient.TransportOptions {
+	o := httpclient.TransportOptions{TLS: tlsOptions(), Proxy: proxyOptions(), Dial: dialOptions()}
+	profile.ApplyTLS(&o.TLS, vars.Interpolate)
+	profile.ApplyProxy(&o.Proxy, vars.Interpolate)
+	return o
+}
+
+// clientTransport returns a transport with the command line and environment
+// connection settings, for commands that send requests without httpclient.Do.
+func clientTransport(profile *config.Profile) (*http.Transport, error) {
+	return httpclient.Transport(transportOptions(profile))
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&tlsCert, "cert", "", "Client certificate for mTLS (PEM, or PKCS#12 .p12/.pfx)")
+	flags.StringVar(&tlsKey, "key", "", "Client private key (PEM) when not bundled with --cert")
+	flags.StringVar(&tlsCertPassword, "cert-password", "", "Password for a PKCS#12 client certificate")
+	flags.StringVar(&tlsCACert, "cacert", "", "PEM CA bundle to trust in addition to the system roots")
+	flags.BoolVarP(&tlsInsecure, "insecure", "k", false, "Skip TLS certificate verification")
+	flags.StringVar(&tlsMinVersion, "tls-min", "", "Minimum TLS version: 1.0, 1.1, 1.2, 1.3")
+	flags.StringVar(&tlsMaxVersion, "tls-max", "", "Maximum TLS version: 1.0, 1.1, 1.2, 1.3")
+	flags.StringSliceVar(&tlsCiphers, "ciphers", nil, "Allowed TLS 1.0-1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	flags.StringVar(&tlsServerName, "sni", "", "Override the TLS server name (SNI and certificate verification)")
+
+	flags.StringVarP(&proxyURL, "proxy", "x", "", "Send requests through a proxy: http://, https:// or socks5://[user:pass@]host:port")
+	flags.StringVar(&proxyUser, "proxy-user", "", "Proxy credentials user:password")
+	flags.StringVar(&proxyNoProxy, "noproxy", "", "Comma-separated hosts/domains/CIDRs that bypass the proxy (default: $NO_PROXY)")
+
+	flags.StringArrayVar(&dialResolve, "resolve", nil, "Pin host:port to an address, e.g. api.example.com:443:10.0.0.5 (repeatable)")
+	flags.StringArrayVar(&dialConnectTo, "connect-to", nil, "Connect to another host:port, e.g. api.example.com:443:canary.internal:8443 (repeatable)")
+	flags.StringVar(&unixSocket, "unix-socket", "", "Connect through a Unix domain socket, e.g. /var/run/docker.sock")
+	flags.StringSliceVar(&dialDNSServers, "dns-servers", nil, "DNS servers to use instead of the system resolver, e.g. 1.1.1.1,8.8.8.8:53")
+
+	flags.IntVar(&maxRedirects, "max-redirects", httpclient.DefaultMaxRedirects, "Follow at most N redirects (0 = don't follow)")
+	flags.BoolVar(&noFollow, "no-follow", false, "Don't follow redirects; show the 3xx response itself")
+	flags.BoolVar(&locationTrusted, "location-trusted", false, "Keep Authorization and Cookie headers when a redirect goes to another host")
+}
@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/humancto/mozzy/internal/envelope"
 	"github.com/humancto/mozzy/internal/formatter"
+	"github.com/humancto/mozzy/internal/httpclient"
 	"github.com/humancto/mozzy/internal/upload"
 )
 
@@ -56,11 +58,16 @@ func init() {
 	uploadCmd.Flags().StringSliceVar(&uploadFields, "data", nil, "Form field (format: name=value, repeatable)")
 	uploadCmd.Flags().BoolVar(&uploadNoProgress, "no-progress", false, "Disable progress display")
 	uploadCmd.MarkFlagRequired("file")
+	addRawOutputFlag(uploadCmd)
 	rootCmd.AddCommand(uploadCmd)
 }
 
 func runUpload(cmd *cobra.Command, args []string) error {
 	url := args[0]
+	format, err := outputMode()
+	if err != nil {
+		return err
+	}
 
 	if len(uploadFiles) == 0 {
 		return fmt.Errorf("at least one file is required (use -f or --file)")
@@ -104,6 +111,15 @@ func runUpload(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	profile, err := activateProfile()
+	if err != nil {
+		return err
+	}
+	transport, err := clientTransport(profile)
+	if err != nil {
+		return err
+	}
+
 	// Upload
 	opts := upload.UploadOptions{
 		URL:          url,
@@ -113,18 +129,38 @@ func runUpload(cmd *cobra.Command, args []string) error {
 		AuthToken:    authToken,
 		ShowProgress: !uploadNoProgress,
 		Timeout:      30 * time.Minute,
+		Transport:    transport,
 	}
 
+	start := time.Now()
 	resp, body, err := upload.UploadWithProgress(opts)
+	if format != envelope.Text {
+		env := &envelope.Envelope{
+			Request: envelope.Request{Method: "POST", URL: url},
+			Timings: envelope.NewTimings(httpclient.TimingInfo{Total: time.Since(start)}),
+		}
+		if resp != nil {
+			env.Request.Headers = envelope.Redact(resp.Request.Header)
+			env.Response = envelope.NewResponse(resp, body)
+		}
+		if err != nil {
+			env.Error = err.Error()
+		}
+		if werr := printEnvelope(format, env); werr != nil {
+			return werr
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("upload failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Print response
-	formatter.PrintStatusLine("POST", url, resp.StatusCode, 0)
-	if err := formatter.PrintJSONOrText(body, jqQuery); err != nil {
-		return err
+	if format == envelope.Text {
+		formatter.PrintStatusLine("POST", url, resp.StatusCode, 0)
+		if err := printResponseBody(body, resp.Header.Get("Content-Type")); err != nil {
+			return err
+		}
 	}
 
 	if failOnErr && resp.StatusCode >= 400 {
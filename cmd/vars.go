@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/humancto/mozzy/internal/ui"
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+var (
+	varsTTL    time.Duration
+	varsShared bool
+)
+
+var varsCmd = &cobra.Command{
+	Use:   "vars",
+	Short: "Manage persistent variables used by {{name}} interpolation",
+	Long: `Manage the project variable store in .mozzy/vars.json.
+
+Variables captured with --capture are saved here, scoped to the active
+environment (--env or default_env), and are available to later commands.
+
+Examples:
+  mozzy POST /login --json @creds.json --capture token=.access_token
+  mozzy GET /me --auth {{token}}
+  mozzy vars list
+  mozzy vars set tenant acme --ttl 1h
+  mozzy vars unset token
+  mozzy --env prod vars clear`,
+}
+
+var varsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored variables",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := vars.LoadStore()
+		if err != nil {
+			return err
+		}
+
+		items := s.List(varsScope())
+		if len(items) == 0 {
+			fmt.Println(ui.WarningBanner("No variables stored"))
+			fmt.Println("\n" + ui.InfoStyle.Render("💡 Use --capture name=<jq expression> (e.g. token=.data.token) on a request, or 'mozzy vars set <name> <value>'"))
+			return nil
+		}
+
+		fmt.Printf("\n%s\n\n", ui.TitleStyle.Render("📦 Variables"))
+
+		table := ui.NewTable([]string{"Name", "Scope", "Value", "Expires"})
+		for _, it := range items {
+			scope := it.Env
+			if scope == "" {
+				scope = "shared"
+			}
+			value := it.Value.Value
+			if len(value) > 50 {
+				value = value[:47] + "..."
+			}
+			expires := "never"
+			if it.Value.ExpiresAt != nil {
+				expires = "in " + time.Until(*it.Value.ExpiresAt).Round(time.Second).String()
+			}
+			table.AddRow([]string{it.Name, scope, value, expires})
+		}
+		fmt.Println(table.Render())
+		fmt.Println(ui.DimStyle.Render("Stored in " + vars.StorePath()))
+		return nil
+	},
+}
+
+var varsSetCmd = &cobra.Command{
+	Use:   "set <name> <value>",
+	Short: "Set a variable",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope := varsScope()
+		err := vars.UpdateStore(func(s *vars.Store) error {
+			s.Set(scope, args[0], args[1], varsTTL)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println(ui.SuccessBanner(fmt.Sprintf("Set %s%s", args[0], scopeSuffix(scope))))
+		return nil
+	},
+}
+
+var varsUnsetCmd = &cobra.Command{
+	Use:   "unset <name>",
+	Short: "Remove a variable",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope := varsScope()
+		found := false
+		err := vars.UpdateStore(func(s *vars.Store) error {
+			found = s.Unset(scope, args[0])
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("variable %q not found%s", args[0], scopeSuffix(scope))
+		}
+		fmt.Println(ui.SuccessBanner(fmt.Sprintf("Removed %s%s", args[0], scopeSuffix(scope))))
+		return nil
+	},
+}
+
+var varsClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all variables in the current scope",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope := varsScope()
+		err := vars.UpdateStore(func(s *vars.Store) error {
+			s.Clear(scope)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println(ui.SuccessBanner("Cleared variables" + scopeSuffix(scope)))
+		return nil
+	},
+}
+
+// varsScope returns the environment scope targeted by vars subcommands.
+func varsScope() string {
+	if varsShared {
+		return ""
+	}
+	return vars.EnvName(envName)
+}
+
+func scopeSuffix(scope string) string {
+	if scope == "" {
+		return " (shared)"
+	}
+	return fmt.Sprintf(" (env: %s)", scope)
+}
+
+func init() {
+	varsCmd.PersistentFlags().BoolVar(&varsShared, "shared", false, "Use the shared scope instead of the active environment")
+	varsSetCmd.Flags().DurationVar(&varsTTL, "ttl", 0, "Expire the variable after this long, e.g. 15m")
+	varsCmd.AddCommand(varsListCmd, varsSetCmd, varsUnsetCmd, varsClearCmd)
+	rootCmd.AddCommand(varsCmd)
+}
@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/humancto/mozzy/internal/jq"
 )
 
 type Assertion struct {
@@ -24,6 +26,8 @@ type Assertion struct {
 //   - response_time < 500ms
 //   - .items[0].id exists
 //   - length(.items) > 0
+//   - .items | length >= 2             (any jq expression on the left)
+//   - .items | all(.price > 0)         (jq expression that must be true)
 func Evaluate(expr string, statusCode int, body []byte, responseTime time.Duration) (*Assertion, error) {
 	expr = strings.TrimSpace(expr)
 
@@ -90,9 +94,119 @@ func Evaluate(expr string, statusCode int, body []byte, responseTime time.Durati
 		return result, nil
 	}
 
+	// Any other jq expression, e.g. "[.items[].id] | length > 1"
+	lhs := expr
+	if l, _, _, ok := splitComparison(expr); ok {
+		lhs = l
+	}
+	if _, err := jq.Compile(strings.TrimSuffix(lhs, " exists")); err == nil {
+		passed, msg, err := evaluateJSONPath(expr, jsonData)
+		if err != nil {
+			return nil, err
+		}
+		result.Passed = passed
+		result.Message = msg
+		return result, nil
+	}
+
 	return nil, fmt.Errorf("unsupported assertion format: %s", expr)
 }
 
+// Redirect is one hop of the redirect chain that led to a response.
+type Redirect struct {
+	Status   int
+	URL      string
+	Location string
+}
+
+// Response is everything an assertion can check.
+type Response struct {
+	Status    int
+	Body      []byte
+	Duration  time.Duration
+	URL       string     // final URL after redirects
+	Redirects []Redirect // hops before the final response, in order
+
+	// Vars resolves the variables named by vars.name expressions; nil means
+	// there are none.
+	Vars func(name string) (string, bool)
+}
+
+// redirectExprRe matches assertions about the redirect chain.
+var redirectExprRe = regexp.MustCompile(`^(redirects|final_url)([\s.\[|]|$)`)
+
+// varsExprRe matches assertions about variables, and varNameRe the variable
+// names they use: vars.token or vars["row.id"].
+var (
+	varsExprRe = regexp.MustCompile(`^vars([\s.\[|]|$)`)
+	varNameRe  = regexp.MustCompile(`\bvars(?:\.([A-Za-z_][A-Za-z0-9_]*)|\["([^"]+)"\])`)
+)
+
+// Check is Evaluate with access to the redirect chain:
+//   - redirects == 2                       (number of redirects followed)
+//   - redirects[0].status == 301
+//   - redirects[-1].location contains "/login"
+//   - redirects | all(.url | startswith("https"))
+//   - final_url == https://example.com/home
+//
+// and to variables, whose JSON values are decoded:
+//   - vars.token exists
+//   - vars.role == admin
+//   - vars.ids | length > 2
+func Check(expr string, r Response) (*Assertion, error) {
+	expr = strings.TrimSpace(expr)
+	if varsExprRe.MatchString(expr) {
+		passed, msg, err := evaluateJSONPath("."+expr, map[string]any{"vars": referencedVars(expr, r.Vars)})
+		if err != nil {
+			return nil, err
+		}
+		return &Assertion{Expression: expr, Passed: passed, Message: msg}, nil
+	}
+	if !redirectExprRe.MatchString(expr) {
+		return Evaluate(expr, r.Status, r.Body, r.Duration)
+	}
+
+	hops := make([]any, len(r.Redirects))
+	for i, h := range r.Redirects {
+		hops[i] = map[string]any{"status": h.Status, "url": h.URL, "location": h.Location}
+	}
+	meta := map[string]any{"redirects": hops, "final_url": r.URL}
+
+	query := "." + expr
+	if lhs, op, rhs, ok := splitComparison(expr); ok && strings.TrimSpace(lhs) == "redirects" {
+		// "redirects >= 1" compares the number of hops
+		query = ".redirects | length " + op + " " + rhs
+	}
+	passed, msg, err := evaluateJSONPath(query, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &Assertion{Expression: expr, Passed: passed, Message: msg}, nil
+}
+
+// referencedVars looks up the variables expr uses. Values holding a JSON
+// array or object are decoded so they can be queried.
+func referencedVars(expr string, lookup func(string) (string, bool)) map[string]any {
+	m := map[string]any{}
+	if lookup == nil {
+		return m
+	}
+	for _, match := range varNameRe.FindAllStringSubmatch(expr, -1) {
+		name := match[1] + match[2]
+		v, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		m[name] = v
+		if t := strings.TrimSpace(v); strings.HasPrefix(t, "[") || strings.HasPrefix(t, "{") {
+			if decoded, err := jq.Decode([]byte(t)); err == nil {
+				m[name] = decoded
+			}
+		}
+	}
+	return m
+}
+
 func evaluateStatus(expr string, statusCode int) (bool, error) {
 	// Remove "status" prefix and trim
 	condition := strings.TrimSpace(strings.TrimPrefix(expr, "status"))
@@ -177,28 +291,24 @@ func evaluateResponseTime(expr string, responseTime time.Duration) (bool, error)
 }
 
 func evaluateJSONPath(expr string, data interface{}) (bool, string, error) {
-	// Split on operators: ==, !=, contains, exists
+	// The left-hand side is any jq expression; the operator is one of
+	// ==, !=, >, <, >=, <=, contains or exists. Without an operator the
+	// expression itself must evaluate to true, e.g. ".items | all(.price > 0)"
 	var path, operator, expected string
 
-	if strings.Contains(expr, " contains ") {
-		parts := strings.SplitN(expr, " contains ", 2)
-		path = strings.TrimSpace(parts[0])
-		operator = "contains"
-		expected = strings.Trim(strings.TrimSpace(parts[1]), "\"")
-	} else if strings.Contains(expr, " exists") {
+	if strings.HasSuffix(expr, " exists") {
 		path = strings.TrimSpace(strings.TrimSuffix(expr, " exists"))
 		operator = "exists"
-	} else if strings.Contains(expr, " == ") {
-		parts := strings.SplitN(expr, " == ", 2)
-		path = strings.TrimSpace(parts[0])
-		operator = "=="
-		expected = strings.Trim(strings.TrimSpace(parts[1]), "\"")
-	} else if strings.Contains(expr, " != ") {
-		parts := strings.SplitN(expr, " != ", 2)
-		path = strings.TrimSpace(parts[0])
-		operator = "!="
-		expected = strings.Trim(strings.TrimSpace(parts[1]), "\"")
+	} else if lhs, op, rhs, ok := splitComparison(expr); ok {
+		path = lhs
+		operator = op
+		expected = strings.Trim(rhs, "\"")
 	} else {
+		path = expr
+		operator = "true"
+	}
+
+	if _, err := jq.Compile(path); err != nil {
 		return false, "", fmt.Errorf("invalid JSON path expression: %s", expr)
 	}
 
@@ -212,11 +322,17 @@ func evaluateJSONPath(expr string, data interface{}) (bool, string, error) {
 		}
 		return false, fmt.Sprintf("✗ Path %s does not exist", path), nil
 
+	case "true":
+		if value == true {
+			return true, fmt.Sprintf("✓ %s", path), nil
+		}
+		return false, fmt.Sprintf("✗ %s is %s, expected true", path, jq.String(value)), nil
+
 	case "==":
 		if !exists {
 			return false, fmt.Sprintf("✗ Path %s does not exist", path), nil
 		}
-		valueStr := fmt.Sprintf("%v", value)
+		valueStr := jq.String(value)
 		if valueStr == expected {
 			return true, fmt.Sprintf("✓ %s == %s", path, expected), nil
 		}
@@ -226,7 +342,7 @@ func evaluateJSONPath(expr string, data interface{}) (bool, string, error) {
 		if !exists {
 			return true, fmt.Sprintf("✓ Path %s does not exist (!=)", path), nil
 		}
-		valueStr := fmt.Sprintf("%v", value)
+		valueStr := jq.String(value)
 		if valueStr != expected {
 			return true, fmt.Sprintf("✓ %s != %s", path, expected), nil
 		}
@@ -236,16 +352,87 @@ func evaluateJSONPath(expr string, data interface{}) (bool, string, error) {
 		if !exists {
 			return false, fmt.Sprintf("✗ Path %s does not exist", path), nil
 		}
-		valueStr := fmt.Sprintf("%v", value)
+		valueStr := jq.String(value)
 		if strings.Contains(valueStr, expected) {
 			return true, fmt.Sprintf("✓ %s contains '%s'", path, expected), nil
 		}
 		return false, fmt.Sprintf("✗ %s (%s) does not contain '%s'", path, valueStr, expected), nil
+
+	case ">", "<", ">=", "<=":
+		if !exists {
+			return false, fmt.Sprintf("✗ Path %s does not exist", path), nil
+		}
+		got, err := strconv.ParseFloat(jq.String(value), 64)
+		if err != nil {
+			return false, fmt.Sprintf("✗ %s is %s, not a number", path, jq.String(value)), nil
+		}
+		want, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid number in assertion: %s", expected)
+		}
+		var passed bool
+		switch operator {
+		case ">":
+			passed = got > want
+		case "<":
+			passed = got < want
+		case ">=":
+			passed = got >= want
+		case "<=":
+			passed = got <= want
+		}
+		if passed {
+			return true, fmt.Sprintf("✓ %s is %s %s %s", path, jq.String(value), operator, expected), nil
+		}
+		return false, fmt.Sprintf("✗ %s is %s, expected %s %s", path, jq.String(value), operator, expected), nil
 	}
 
 	return false, "", fmt.Errorf("unsupported operator: %s", operator)
 }
 
+// splitComparison splits "lhs op rhs" at the last comparison operator that is
+// not nested inside parentheses, brackets, braces or a string literal, so the
+// left side can itself be a jq expression such as ".items | map(.id) | length".
+func splitComparison(expr string) (lhs, op, rhs string, ok bool) {
+	ops := []string{" contains ", " == ", " != ", " >= ", " <= ", " > ", " < "}
+	depth := 0
+	inString := false
+	at := -1
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		if inString {
+			if ch == '\\' {
+				i++
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ' ':
+			if depth != 0 {
+				continue
+			}
+			for _, candidate := range ops {
+				if strings.HasPrefix(expr[i:], candidate) {
+					at, op = i, candidate
+					break
+				}
+			}
+		}
+	}
+	if at < 0 {
+		return "", "", "", false
+	}
+	return strings.TrimSpace(expr[:at]), strings.TrimSpace(op), strings.TrimSpace(expr[at+len(op):]), true
+}
+
 func evaluateLength(expr string, data interface{}) (bool, string, error) {
 	// length(.items) > 0
 	re := regexp.MustCompile(`length\(([^)]+)\)\s*([><=!]+)\s*(\d+)`)
@@ -304,91 +491,20 @@ func evaluateLength(expr string, data interface{}) (bool, string, error) {
 	return false, fmt.Sprintf("✗ length(%s) is %d, expected %s %d", path, length, operator, expected), nil
 }
 
+// navigateJSONPath evaluates a jq expression against data and returns its
+// first result. Path expressions that address a missing key or index report
+// exists == false.
 func navigateJSONPath(path string, data interface{}) (interface{}, bool) {
 	if data == nil {
 		return nil, false
 	}
-
-	// Remove leading dot
-	path = strings.TrimPrefix(path, ".")
-	if path == "" {
-		return data, true
-	}
-
-	// Split path by dots and brackets
-	segments := parsePathSegments(path)
-
-	current := data
-	for _, seg := range segments {
-		if seg.isIndex {
-			// Array index
-			arr, ok := current.([]interface{})
-			if !ok || seg.index < 0 || seg.index >= len(arr) {
-				return nil, false
-			}
-			current = arr[seg.index]
-		} else {
-			// Object key
-			obj, ok := current.(map[string]interface{})
-			if !ok {
-				return nil, false
-			}
-			val, exists := obj[seg.key]
-			if !exists {
-				return nil, false
-			}
-			current = val
-		}
-	}
-
-	return current, true
-}
-
-type pathSegment struct {
-	key     string
-	isIndex bool
-	index   int
-}
-
-func parsePathSegments(path string) []pathSegment {
-	var segments []pathSegment
-	current := ""
-	inBracket := false
-
-	for i := 0; i < len(path); i++ {
-		ch := path[i]
-
-		switch ch {
-		case '[':
-			if current != "" {
-				segments = append(segments, pathSegment{key: current})
-				current = ""
-			}
-			inBracket = true
-		case ']':
-			if inBracket {
-				idx, _ := strconv.Atoi(current)
-				segments = append(segments, pathSegment{isIndex: true, index: idx})
-				current = ""
-				inBracket = false
-			}
-		case '.':
-			if !inBracket && current != "" {
-				segments = append(segments, pathSegment{key: current})
-				current = ""
-			} else if !inBracket {
-				// Skip leading dots
-			} else {
-				current += string(ch)
-			}
-		default:
-			current += string(ch)
-		}
+	exists, err := jq.Exists(path, data)
+	if err != nil || !exists {
+		return nil, false
 	}
-
-	if current != "" {
-		segments = append(segments, pathSegment{key: current})
+	value, err := jq.First(path, data)
+	if err != nil {
+		return nil, false
 	}
-
-	return segments
+	return value, true
 }
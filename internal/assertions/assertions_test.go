@@ -181,3 +181,112 @@ func TestNavigateJSONPath(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluateJQExpressions(t *testing.T) {
+	jsonBody := []byte(`{
+		"items": [
+			{"id": 1, "active": true, "price": 5, "name": "a > b"},
+			{"id": 2, "active": false, "price": 12.5, "name": "two"}
+		],
+		"user-id": "u1"
+	}`)
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{"pipe with comparison", ".items | length >= 2", true, false},
+		{"select and equals", ".items[] | select(.active) | .id == 1", true, false},
+		{"float comparison", ".items[1].price > 12", true, false},
+		{"boolean expression", ".items | all(.price > 0)", true, false},
+		{"boolean expression false", ".items | all(.active)", false, false},
+		{"array construction", "[.items[].id] | length == 2", true, false},
+		{"quoted operator in value", `.items[0].name == "a > b"`, true, false},
+		{"legacy dashed key", ".user-id == u1", true, false},
+		{"absent field does not exist", ".items[0].missing exists", false, false},
+		{"invalid expression", ".items | ??? == 1", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Evaluate(tt.expr, 200, jsonBody, 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && result.Passed != tt.want {
+				t.Errorf("Evaluate() = %v, want %v (message: %s)", result.Passed, tt.want, result.Message)
+			}
+		})
+	}
+}
+
+func TestCheckRedirects(t *testing.T) {
+	resp := Response{
+		Status: 200,
+		Body:   []byte(`{"redirects": "body field"}`),
+		URL:    "https://example.com/home",
+		Redirects: []Redirect{
+			{Status: 301, URL: "http://example.com/", Location: "https://example.com/"},
+			{Status: 302, URL: "https://example.com/", Location: "/home"},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"redirects == 2", true},
+		{"redirects < 2", false},
+		{"redirects[0].status == 301", true},
+		{"redirects[-1].location contains /home", true},
+		{"redirects | all(.status >= 300)", true},
+		{"redirects | map(.status) == [301,302]", true},
+		{"final_url == https://example.com/home", true},
+		{".redirects == body field", true}, // body paths are unaffected
+		{"status == 200", true},
+	}
+	for _, tt := range tests {
+		result, err := Check(tt.expr, resp)
+		if err != nil {
+			t.Errorf("Check(%q) error: %v", tt.expr, err)
+			continue
+		}
+		if result.Passed != tt.want {
+			t.Errorf("Check(%q) = %v, want %v (%s)", tt.expr, result.Passed, tt.want, result.Message)
+		}
+	}
+}
+
+func TestCheckVars(t *testing.T) {
+	vars := map[string]string{"role": "admin", "count": "3", "ids": "[1,2,3]", "row.id": "7"}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"vars.role == admin", true},
+		{`vars.role == "user"`, false},
+		{"vars.count > 2", true},
+		{"vars.ids | length == 3", true},
+		{"vars.ids[0] == 1", true},
+		{`vars["row.id"] == 7`, true},
+		{"vars.token exists", false},
+		{"vars.role exists", true},
+	}
+	for _, tt := range tests {
+		got, err := Check(tt.expr, Response{Vars: lookup})
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got.Passed != tt.want {
+			t.Errorf("%s: passed = %v, want %v (%s)", tt.expr, got.Passed, tt.want, got.Message)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package auth signs outgoing requests for the supported authentication
+// schemes: Bearer, Basic, Digest, AWS Signature V4, HMAC and API keys.
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Scheme types accepted by --auth-type, environments and workflows.
+const (
+	Bearer = "bearer"
+	Basic  = "basic"
+	Digest = "digest"
+	SigV4  = "sigv4"
+	HMAC   = "hmac"
+	APIKey = "apikey"
+)
+
+// Types lists the scheme names in the order they are documented.
+var Types = []string{Bearer, Basic, Digest, SigV4, HMAC, APIKey}
+
+// Config selects a scheme and holds its settings. Only the fields of the
+// chosen Type are used.
+type Config struct {
+	Type string `json:"type" yaml:"type"`
+
+	Token string `json:"token,omitempty" yaml:"token,omitempty"` // bearer
+
+	Username string `json:"username,omitempty" yaml:"username,omitempty"` // basic, digest
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+
+	AccessKey    string `json:"access_key,omitempty" yaml:"access_key,omitempty"` // sigv4
+	SecretKey    string `json:"secret_key,omitempty" yaml:"secret_key,omitempty"`
+	SessionToken string `json:"session_token,omitempty" yaml:"session_token,omitempty"`
+	Region       string `json:"region,omitempty" yaml:"region,omitempty"`
+	Service      string `json:"service,omitempty" yaml:"service,omitempty"`
+
+	KeyID           string `json:"key_id,omitempty" yaml:"key_id,omitempty"` // hmac
+	Secret          string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Algorithm       string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`               // sha256 (default), sha1, sha512
+	Canonical       string `json:"canonical,omitempty" yaml:"canonical,omitempty"`               // string to sign, see hmac.go
+	Header          string `json:"header,omitempty" yaml:"header,omitempty"`                     // header carrying the signature
+	Format          string `json:"format,omitempty" yaml:"format,omitempty"`                     // header value, e.g. "HMAC {key_id}:{signature}"
+	Encoding        string `json:"encoding,omitempty" yaml:"encoding,omitempty"`                 // hex (default) or base64
+	TimestampHeader string `json:"timestamp_header,omitempty" yaml:"timestamp_header,omitempty"` // default X-Timestamp
+	NonceHeader     string `json:"nonce_header,omitempty" yaml:"nonce_header,omitempty"`         // default X-Nonce
+
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"` // apikey: parameter or header name (default api_key)
+	In    string `json:"in,omitempty" yaml:"in,omitempty"`     // apikey: query (default) or header
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// Scheme adds credentials to a request. body is the request body, already
+// read, for schemes that sign it.
+type Scheme interface {
+	Apply(req *http.Request, body []byte) error
+}
+
+// Challenger is a scheme that needs the server's 401 challenge before it
+// can authenticate. Challenge reports whether the request should be sent
+// again.
+type Challenger interface {
+	Scheme
+	Challenge(res *http.Response) bool
+}
+
+// New returns the scheme for c. A zero Config returns nil.
+func New(c Config) (Scheme, error) {
+	switch strings.ToLower(c.Type) {
+	case "":
+		return nil, nil
+	case Bearer:
+		if c.Token == "" {
+			return nil, fmt.Errorf("bearer auth needs a token")
+		}
+		return bearerScheme{c.Token}, nil
+	case Basic:
+		if c.Username == "" {
+			return nil, fmt.Errorf("basic auth needs a username (--auth user:password)")
+		}
+		return basicScheme{c.Username, c.Password}, nil
+	case Digest:
+		if c.Username == "" {
+			return nil, fmt.Errorf("digest auth needs a username (--auth user:password)")
+		}
+		return &digestScheme{user: c.Username, password: c.Password}, nil
+	case SigV4:
+		return newSigV4(c)
+	case HMAC:
+		return newHMAC(c)
+	case APIKey:
+		return newAPIKey(c)
+	}
+	return nil, fmt.Errorf("unknown auth type %q (want %s)", c.Type, strings.Join(Types, ", "))
+}
+
+// SetCredential fills the credential fields of c from a single --auth value,
+// interpreted by type: a token for bearer, user:password for basic and
+// digest, ACCESS_KEY:SECRET_KEY[:SESSION_TOKEN] for sigv4, [KEY_ID:]SECRET
+// for hmac and the key itself for apikey.
+func (c *Config) SetCredential(s string) {
+	switch strings.ToLower(c.Type) {
+	case Basic, Digest:
+		c.Username, c.Password, _ = strings.Cut(s, ":")
+	case SigV4:
+		parts := strings.SplitN(s, ":", 3)
+		c.AccessKey = parts[0]
+		if len(parts) > 1 {
+			c.SecretKey = parts[1]
+		}
+		if len(parts) > 2 {
+			c.SessionToken = parts[2]
+		}
+	case HMAC:
+		if id, secret, ok := strings.Cut(s, ":"); ok {
+			c.KeyID, c.Secret = id, secret
+		} else {
+			c.Secret = s
+		}
+	case APIKey:
+		c.Value = s
+	default:
+		c.Token = s
+	}
+}
+
+// Set assigns one setting by its JSON name, as given with --auth-param or
+// `mozzy env set <env> auth.<name>`.
+func (c *Config) Set(name, value string) error {
+	f, ok := c.fields()[name]
+	if !ok {
+		names := make([]string, 0, len(c.fields()))
+		for n := range c.fields() {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown auth setting %q (want %s)", name, strings.Join(names, ", "))
+	}
+	*f = value
+	return nil
+}
+
+func (c *Config) fields() map[string]*string {
+	return map[string]*string{
+		"type": &c.Type, "token": &c.Token,
+		"username": &c.Username, "password": &c.Password,
+		"access_key": &c.AccessKey, "secret_key": &c.SecretKey, "session_token": &c.SessionToken,
+		"region": &c.Region, "service": &c.Service,
+		"key_id": &c.KeyID, "secret": &c.Secret, "algorithm": &c.Algorithm, "canonical": &c.Canonical,
+		"header": &c.Header, "format": &c.Format, "encoding": &c.Encoding,
+		"timestamp_header": &c.TimestampHeader, "nonce_header": &c.NonceHeader,
+		"name": &c.Name, "in": &c.In, "value": &c.Value,
+	}
+}
+
+// Interpolate returns a copy of c with fn applied to every setting, for
+// {{vars}} in credentials.
+func (c Config) Interpolate(fn func(string) string) Config {
+	if fn == nil {
+		return c
+	}
+	for _, f := range c.fields() {
+		*f = fn(*f)
+	}
+	return c
+}
+
+// SecretHeaders names the headers, other than Authorization, in which c's
+// scheme sends credentials, so reports can hide them.
+func (c Config) SecretHeaders() []string {
+	switch strings.ToLower(c.Type) {
+	case SigV4:
+		return []string{"X-Amz-Security-Token"}
+	case HMAC:
+		return []string{or(c.Header, "Authorization")}
+	case APIKey:
+		if strings.EqualFold(c.In, "header") {
+			return []string{or(c.Name, "X-API-Key")}
+		}
+	}
+	return nil
+}
+
+// SecretParam names the query parameter carrying c's API key, if any.
+func (c Config) SecretParam() string {
+	if strings.ToLower(c.Type) == APIKey && (c.In == "" || strings.EqualFold(c.In, "query")) {
+		return or(c.Name, "api_key")
+	}
+	return ""
+}
+
+// Transport applies a scheme to every request it sends. Requests to hosts
+// other than Host (after a cross-host redirect) are sent without
+// credentials unless Host is empty.
+type Transport struct {
+	Base   http.RoundTripper
+	Scheme Scheme
+	Host   string
+}
+
+// RoundTrip signs req and, for challenge-based schemes, answers a 401 by
+// sending it once more.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Host != "" && req.URL.Host != t.Host {
+		return t.Base.RoundTrip(req)
+	}
+	res, err := t.send(req)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := t.Scheme.(Challenger)
+	if !ok || res.StatusCode != http.StatusUnauthorized || !c.Challenge(res) {
+		return res, nil
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return t.send(req)
+}
+
+func (t *Transport) send(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	out := req.Clone(req.Context())
+	if req.GetBody != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if err := t.Scheme.Apply(out, body); err != nil {
+		return nil, err
+	}
+	return t.Base.RoundTrip(out)
+}
+
+type bearerScheme struct{ token string }
+
+func (s bearerScheme) Apply(req *http.Request, _ []byte) error {
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	return nil
+}
+
+type basicScheme struct{ user, password string }
+
+func (s basicScheme) Apply(req *http.Request, _ []byte) error {
+	req.SetBasicAuth(s.user, s.password)
+	return nil
+}
+
+type apiKeyScheme struct {
+	name, value string
+	header      bool
+}
+
+func newAPIKey(c Config) (Scheme, error) {
+	if c.Value == "" {
+		return nil, fmt.Errorf("apikey auth needs a key (--auth <key>)")
+	}
+	s := apiKeyScheme{name: c.Name, value: c.Value}
+	switch strings.ToLower(c.In) {
+	case "", "query":
+		if s.name == "" {
+			s.name = "api_key"
+		}
+	case "header":
+		s.header = true
+		if s.name == "" {
+			s.name = "X-API-Key"
+		}
+	default:
+		return nil, fmt.Errorf("apikey: in must be query or header, got %q", c.In)
+	}
+	return s, nil
+}
+
+func (s apiKeyScheme) Apply(req *http.Request, _ []byte) error {
+	if s.header {
+		req.Header.Set(s.name, s.value)
+		return nil
+	}
+	param := url.QueryEscape(s.name) + "=" + url.QueryEscape(s.value)
+	if req.URL.RawQuery != "" {
+		param = req.URL.RawQuery + "&" + param
+	}
+	req.URL.RawQuery = param
+	return nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestScheme implements HTTP Digest authentication (RFC 7616, with the
+// RFC 2617 forms). The first request is sent without credentials; the
+// server's 401 challenge is answered and reused, with a rising nonce count,
+// by later requests through the same scheme until the server sends a new
+// nonce.
+type digestScheme struct {
+	user, password string
+
+	mu        sync.Mutex
+	challenge map[string]string
+	nc        int
+	cnonce    func() string // overridden in tests
+}
+
+// Challenge records a Digest challenge from res.
+func (s *digestScheme) Challenge(res *http.Response) bool {
+	for _, h := range res.Header.Values("WWW-Authenticate") {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(h), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		c := parseAuthParams(params)
+		if c["nonce"] == "" {
+			continue
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		retry := s.challenge == nil || c["stale"] == "true" || c["nonce"] != s.challenge["nonce"]
+		s.challenge, s.nc = c, 0
+		return retry
+	}
+	return false
+}
+
+func (s *digestScheme) Apply(req *http.Request, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.challenge
+	if c == nil {
+		return nil // wait for the challenge
+	}
+
+	algo := c["algorithm"]
+	if algo == "" {
+		algo = "MD5"
+	}
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algo), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return fmt.Errorf("digest: unsupported algorithm %s", algo)
+	}
+	h := func(parts ...string) string {
+		d := newHash()
+		d.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	uri := req.URL.RequestURI()
+	s.nc++
+	nc := fmt.Sprintf("%08x", s.nc)
+	cnonce := s.newCnonce()
+
+	ha1 := h(s.user, c["realm"], s.password)
+	if strings.HasSuffix(strings.ToUpper(algo), "-SESS") {
+		ha1 = h(ha1, c["nonce"], cnonce)
+	}
+
+	qop := ""
+	for _, q := range strings.Split(c["qop"], ",") {
+		q = strings.TrimSpace(q)
+		if q == "auth" || (q == "auth-int" && qop == "") {
+			qop = q
+		}
+	}
+	ha2 := h(req.Method, uri)
+	if qop == "auth-int" {
+		ha2 = h(req.Method, uri, hashBody(newHash, body))
+	}
+
+	var response string
+	if qop == "" {
+		response = h(ha1, c["nonce"], ha2)
+	} else {
+		response = h(ha1, c["nonce"], nc, cnonce, qop, ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, s.user),
+		fmt.Sprintf(`realm="%s"`, c["realm"]),
+		fmt.Sprintf(`nonce="%s"`, c["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		"algorithm=" + algo,
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if op, ok := c["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, op))
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(fields, ", "))
+	return nil
+}
+
+func hashBody(newHash func() hash.Hash, body []byte) string {
+	d := newHash()
+	d.Write(body)
+	return hex.EncodeToString(d.Sum(nil))
+}
+
+func (s *digestScheme) newCnonce() string {
+	if s.cnonce != nil {
+		return s.cnonce()
+	}
+	return NewNonce()
+}
+
+// parseAuthParams parses the comma-separated key=value (or key="value")
+// list of a WWW-Authenticate challenge.
+func parseAuthParams(s string) map[string]string {
+	out := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimSpace(s[eq+1:])
+
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			val = strings.ReplaceAll(s[1:min(end, len(s))], `\"`, `"`)
+			s = s[min(end+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		out[key] = val
+		s = strings.TrimLeft(s, ", ")
+	}
+	return out
+}
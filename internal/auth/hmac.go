@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCanonical is the string signed by the hmac scheme unless the
+// canonical setting replaces it.
+const DefaultCanonical = `{method}\n{path}\n{timestamp}\n{body_sha256}`
+
+// hmacScheme signs a configurable canonical string with an HMAC and sends
+// the result in a header. The canonical string and header format are
+// templates with these placeholders:
+//
+//	{method} {path} {query} {host} {timestamp} {date} {nonce}
+//	{body} {body_sha256} {body_md5} {header:Name} {key_id} {signature}
+//
+// "\n" in a template is a newline. {timestamp} (Unix seconds) and {nonce}
+// are also sent in the timestamp and nonce headers, and {date} in Date, so
+// the server can rebuild the string.
+type hmacScheme struct {
+	keyID, secret string
+	newHash       func() hash.Hash
+	canonical     string
+	header        string
+	format        string
+	base64        bool
+	tsHeader      string
+	nonceHeader   string
+	now           func() time.Time // overridden in tests
+	nonce         func() string
+}
+
+func newHMAC(c Config) (Scheme, error) {
+	if c.Secret == "" {
+		return nil, fmt.Errorf("hmac auth needs a secret (--auth [KEY_ID:]SECRET)")
+	}
+	s := &hmacScheme{
+		keyID:       c.KeyID,
+		secret:      c.Secret,
+		canonical:   unescapeTemplate(or(c.Canonical, DefaultCanonical)),
+		header:      or(c.Header, "Authorization"),
+		format:      unescapeTemplate(or(c.Format, "HMAC {key_id}:{signature}")),
+		tsHeader:    or(c.TimestampHeader, "X-Timestamp"),
+		nonceHeader: or(c.NonceHeader, "X-Nonce"),
+		now:         time.Now,
+		nonce:       func() string { return NewNonce() },
+	}
+	if c.KeyID == "" && c.Format == "" {
+		s.format = "HMAC {signature}"
+	}
+	switch strings.ToLower(or(c.Algorithm, "sha256")) {
+	case "sha256", "hmac-sha256":
+		s.newHash = sha256.New
+	case "sha1", "hmac-sha1":
+		s.newHash = sha1.New
+	case "sha512", "hmac-sha512":
+		s.newHash = sha512.New
+	default:
+		return nil, fmt.Errorf("hmac: unsupported algorithm %q (want sha256, sha1 or sha512)", c.Algorithm)
+	}
+	switch strings.ToLower(c.Encoding) {
+	case "", "hex":
+	case "base64":
+		s.base64 = true
+	default:
+		return nil, fmt.Errorf("hmac: encoding must be hex or base64, got %q", c.Encoding)
+	}
+	return s, nil
+}
+
+var placeholder = regexp.MustCompile(`\{([a-z_0-9]+(?::[^}]+)?)\}`)
+
+func (s *hmacScheme) Apply(req *http.Request, body []byte) error {
+	now := s.now()
+	vals := map[string]string{
+		"method":      req.Method,
+		"path":        req.URL.EscapedPath(),
+		"query":       req.URL.RawQuery,
+		"host":        req.URL.Host,
+		"body":        string(body),
+		"body_sha256": sha256Hex(body),
+		"key_id":      s.keyID,
+	}
+	if vals["path"] == "" {
+		vals["path"] = "/"
+	}
+	sum := md5.Sum(body)
+	vals["body_md5"] = hex.EncodeToString(sum[:])
+
+	uses := func(name string) bool { return strings.Contains(s.canonical, "{"+name+"}") }
+	if uses("timestamp") {
+		vals["timestamp"] = strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(s.tsHeader, vals["timestamp"])
+	}
+	if uses("date") {
+		vals["date"] = now.UTC().Format(http.TimeFormat)
+		req.Header.Set("Date", vals["date"])
+	}
+	if uses("nonce") {
+		vals["nonce"] = s.nonce()
+		req.Header.Set(s.nonceHeader, vals["nonce"])
+	}
+
+	expand := func(tmpl string) string {
+		return placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+			name := m[1 : len(m)-1]
+			if h, ok := strings.CutPrefix(name, "header:"); ok {
+				return req.Header.Get(h)
+			}
+			if v, ok := vals[name]; ok {
+				return v
+			}
+			return m
+		})
+	}
+
+	mac := hmac.New(s.newHash, []byte(s.secret))
+	mac.Write([]byte(expand(s.canonical)))
+	if s.base64 {
+		vals["signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	} else {
+		vals["signature"] = hex.EncodeToString(mac.Sum(nil))
+	}
+	req.Header.Set(s.header, expand(s.format))
+	return nil
+}
+
+// NewNonce returns 16 random hex characters.
+func NewNonce() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// unescapeTemplate turns a literal \n or \t, as typed on the command line,
+// into the character.
+func unescapeTemplate(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(s)
+}
+
+func or(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
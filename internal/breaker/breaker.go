@@ -0,0 +1,170 @@
+// Package breaker implements a circuit breaker that stops sending requests
+// to a failing dependency and probes it again after a cooldown.
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultCooldown is how long the breaker stays open before a probe.
+const DefaultCooldown = 30 * time.Second
+
+// State is the position of the breaker.
+type State int
+
+const (
+	Closed   State = iota // requests flow normally
+	Open                  // requests are rejected until the cooldown ends
+	HalfOpen              // one probe request is allowed through
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// ErrOpen is returned, wrapped in an *OpenError, for rejected requests.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError reports a rejected request and when the next probe is allowed.
+// Until is zero while a half-open probe is in flight.
+type OpenError struct {
+	Until time.Time
+}
+
+func (e *OpenError) Error() string {
+	if e.Until.IsZero() {
+		return fmt.Sprintf("%s (probe in progress)", ErrOpen)
+	}
+	return fmt.Sprintf("%s (next probe in %s)", ErrOpen, time.Until(e.Until).Round(time.Second))
+}
+
+func (e *OpenError) Unwrap() error { return ErrOpen }
+
+// Settings configure a breaker. Failures of 0 disables it.
+type Settings struct {
+	Failures int           // consecutive failures that open the breaker
+	Cooldown time.Duration // time spent open before a probe (default DefaultCooldown)
+}
+
+// Breaker is safe for concurrent use.
+type Breaker struct {
+	settings Settings
+	onChange func(from, to State, failures int)
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// New returns a closed breaker. onChange, if not nil, is called on every
+// state transition with the consecutive failure count at that moment; it
+// runs with the breaker locked and must not call back into it.
+func New(s Settings, onChange func(from, to State, failures int)) *Breaker {
+	if s.Cooldown <= 0 {
+		s.Cooldown = DefaultCooldown
+	}
+	return &Breaker{settings: s, onChange: onChange, now: time.Now}
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a request may be sent. Once the cooldown has passed,
+// an open breaker lets a single probe through and rejects the rest until
+// the probe's outcome is recorded.
+func (b *Breaker) Allow() error {
+	if b.settings.Failures <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		until := b.openedAt.Add(b.settings.Cooldown)
+		if b.now().Before(until) {
+			return &OpenError{Until: until}
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return &OpenError{}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed request.
+func (b *Breaker) Record(success bool) {
+	if b.settings.Failures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		b.probing = false
+		if b.state != Closed {
+			b.setState(Closed)
+		}
+		return
+	}
+
+	b.failures++
+	switch b.state {
+	case HalfOpen:
+		b.probing = false
+		b.open()
+	case Closed:
+		if b.failures >= b.settings.Failures {
+			b.open()
+		}
+	}
+}
+
+// Release gives back a request allowed by Allow whose outcome says nothing
+// about the dependency, such as one cancelled before or while it was sent.
+// A half-open breaker then lets the next request probe instead.
+func (b *Breaker) Release() {
+	if b.settings.Failures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == HalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.setState(Open)
+}
+
+func (b *Breaker) setState(s State) {
+	from := b.state
+	b.state = s
+	if b.onChange != nil && from != s {
+		b.onChange(from, s, b.failures)
+	}
+}
@@ -0,0 +1,110 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type transition struct{ from, to State }
+
+func newTestBreaker(s Settings) (*Breaker, *time.Time, *[]transition) {
+	now := time.Now()
+	var seen []transition
+	b := New(s, func(from, to State, _ int) { seen = append(seen, transition{from, to}) })
+	b.now = func() time.Time { return now }
+	return b, &now, &seen
+}
+
+func TestBreaker_Cycle(t *testing.T) {
+	b, now, seen := newTestBreaker(Settings{Failures: 3, Cooldown: 10 * time.Second})
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Record(false)
+	}
+	b.Record(true) // a success resets the count
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("attempt %d rejected early: %v", i+1, err)
+		}
+		b.Record(false)
+	}
+	if b.State() != Open {
+		t.Fatalf("state = %v after 3 consecutive failures", b.State())
+	}
+
+	err := b.Allow()
+	var open *OpenError
+	if !errors.Is(err, ErrOpen) || !errors.As(err, &open) || !open.Until.Equal(now.Add(10*time.Second)) {
+		t.Fatalf("Allow() while open = %v", err)
+	}
+
+	// after the cooldown one probe goes through, the rest wait for it
+	*now = now.Add(10 * time.Second)
+	if err := b.Allow(); err != nil || b.State() != HalfOpen {
+		t.Fatalf("probe: %v, state %v", err, b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("second request during probe = %v", err)
+	}
+
+	// failed probe re-opens, a successful one closes
+	b.Record(false)
+	if b.State() != Open {
+		t.Fatalf("state after failed probe = %v", b.State())
+	}
+	*now = now.Add(10 * time.Second)
+	b.Allow()
+	b.Record(true)
+	if b.State() != Closed {
+		t.Fatalf("state after good probe = %v", b.State())
+	}
+
+	want := []transition{{Closed, Open}, {Open, HalfOpen}, {HalfOpen, Open}, {Open, HalfOpen}, {HalfOpen, Closed}}
+	if len(*seen) != len(want) {
+		t.Fatalf("transitions = %v, want %v", *seen, want)
+	}
+	for i := range want {
+		if (*seen)[i] != want[i] {
+			t.Errorf("transition %d = %v, want %v", i, (*seen)[i], want[i])
+		}
+	}
+}
+
+func TestBreaker_Release(t *testing.T) {
+	b, now, _ := newTestBreaker(Settings{Failures: 1, Cooldown: 10 * time.Second})
+	b.Allow()
+	b.Record(false)
+	*now = now.Add(10 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a released probe leaves the breaker half-open for the next request
+	b.Release()
+	if b.State() != HalfOpen {
+		t.Fatalf("state after release = %v", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("request after a released probe = %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("second request during the new probe = %v", err)
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b, _, seen := newTestBreaker(Settings{})
+	for i := 0; i < 100; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Record(false)
+	}
+	if b.State() != Closed || len(*seen) != 0 {
+		t.Errorf("disabled breaker changed state: %v %v", b.State(), *seen)
+	}
+}
@@ -0,0 +1,53 @@
+package chain
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/humancto/mozzy/internal/formatter"
+	"github.com/humancto/mozzy/internal/payload"
+)
+
+// stepBody builds a step's request body from exactly one of file, json,
+// form and multipart, and returns it with its Content-Type. Variables are
+// resolved with interpolate.
+func stepBody(s Step, interpolate func(string) string) ([]byte, string, error) {
+	set := 0
+	for _, used := range []bool{s.File != "", s.JSON != nil, len(s.Form) > 0, len(s.Multipart) > 0} {
+		if used {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, "", fmt.Errorf("step %q: use only one of file, json, form and multipart", s.Name)
+	}
+
+	switch {
+	case s.File != "":
+		path := interpolate(s.File)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return b, payload.ContentTypeFor(path), nil
+	case s.JSON != nil:
+		b, err := formatter.MarshalJSONWith(s.JSON, interpolate)
+		return b, "application/json", err
+	case len(s.Form) > 0:
+		fields := make(map[string]string, len(s.Form))
+		for k, v := range s.Form {
+			fields[k] = interpolate(v)
+		}
+		b, ct := payload.Form(fields)
+		return b, ct, nil
+	case len(s.Multipart) > 0:
+		parts := make([]payload.Part, len(s.Multipart))
+		for i, p := range s.Multipart {
+			p.Value = interpolate(p.Value)
+			p.File = interpolate(p.File)
+			parts[i] = p
+		}
+		return payload.Multipart(parts, nil)
+	}
+	return nil, "", nil
+}
@@ -0,0 +1,61 @@
+package chain
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/humancto/mozzy/internal/vars"
+	"gopkg.in/yaml.v3"
+)
+
+func TestStepBody(t *testing.T) {
+	vars.Set("user", "ada")
+	dir := t.TempDir()
+	img := filepath.Join(dir, "me.png")
+	os.WriteFile(img, []byte("PNG"), 0o644)
+
+	var f Flow
+	err := yaml.Unmarshal([]byte(`
+steps:
+  - name: token
+    form:
+      grant_type: password
+      username: "{{user}}"
+  - name: avatar
+    multipart:
+      - {name: caption, value: "hi {{user}}"}
+      - {name: avatar, file: `+img+`}
+  - name: raw
+    file: `+img+`
+  - name: both
+    json: {a: 1}
+    form: {b: "2"}
+`), &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, ct, err := stepBody(f.Steps[0], vars.Interpolate)
+	if err != nil || string(body) != "grant_type=password&username=ada" || ct != "application/x-www-form-urlencoded" {
+		t.Errorf("form body = %q (%s), %v", body, ct, err)
+	}
+
+	body, ct, err = stepBody(f.Steps[1], vars.Interpolate)
+	if err != nil || !strings.HasPrefix(ct, "multipart/form-data; boundary=") {
+		t.Fatalf("multipart content type = %q, %v", ct, err)
+	}
+	for _, want := range []string{`name="caption"`, "hi ada", `filename="me.png"`, "Content-Type: image/png"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("multipart body missing %q:\n%s", want, body)
+		}
+	}
+
+	if _, ct, _ := stepBody(f.Steps[2], vars.Interpolate); ct != "image/png" {
+		t.Errorf("file content type = %q, want it inferred from the extension", ct)
+	}
+	if _, _, err := stepBody(f.Steps[3], vars.Interpolate); err == nil {
+		t.Error("json together with form should fail")
+	}
+}
@@ -0,0 +1,199 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humancto/mozzy/internal/assertions"
+	"github.com/humancto/mozzy/internal/jq"
+)
+
+// Poll resends a step until its condition holds, e.g. for an async job that
+// answers 202 until it is done. Durations use Go syntax (500ms, 2s, 1m).
+type Poll struct {
+	Until    string        `yaml:"until"`
+	Interval time.Duration `yaml:"interval,omitempty"` // between attempts; default 1s
+	Timeout  time.Duration `yaml:"timeout,omitempty"`  // give up after this long; default 1m
+}
+
+// UnmarshalYAML also accepts `repeat_until: <condition>`.
+func (p *Poll) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*p = Poll{}
+		return n.Decode(&p.Until)
+	}
+	type plain Poll
+	var v plain
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	*p = Poll(v)
+	return nil
+}
+
+// wait checks a poll's response. It reports whether to send the request
+// again, after sleeping the interval, and fails once the condition can't be
+// met before the timeout.
+func (p *Poll) wait(ctx context.Context, sc *scope, resp assertions.Response, attempt int, started time.Time, out output) (bool, error) {
+	done, msg, err := condition(p.Until, sc, &resp)
+	if err != nil {
+		return false, fmt.Errorf("repeat_until: %w", err)
+	}
+	if done {
+		if attempt > 1 {
+			fmt.Fprintf(out.stderr, "⏳ Condition met after %d attempts\n", attempt)
+		}
+		return false, nil
+	}
+	interval, timeout := p.Interval, p.Timeout
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	if elapsed := time.Since(started); elapsed+interval > timeout {
+		return false, fmt.Errorf("repeat_until %q not met after %d attempts in %s", p.Until, attempt, elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintf(out.stderr, "⏳ Attempt %d (%d): %s; again in %s\n", attempt, resp.Status, msg, interval)
+	select {
+	case <-time.After(interval):
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// condition evaluates an if: or repeat_until: expression. It is an assertion
+// (status == 202, .state == "done", .items | length > 0) on the last
+// response, or on variables as vars.name, with {{name}} resolved first.
+func condition(expr string, sc *scope, last *assertions.Response) (bool, string, error) {
+	var r assertions.Response
+	if last != nil {
+		r = *last
+	}
+	r.Vars = sc.lookup
+	a, err := assertions.Check(sc.interpolate(expr), r)
+	if err != nil {
+		return false, "", err
+	}
+	return a.Passed, a.Message, nil
+}
+
+// do runs a step unless its if: condition fails, once per element for
+// foreach. last is the response of the step before it.
+func (r *runner) do(ctx context.Context, s Step, label string, sc *scope, out output, rep *Report, last *assertions.Response) stepResult {
+	if s.If != "" {
+		ok, msg, err := condition(s.If, sc, last)
+		if err != nil {
+			fmt.Fprintf(out.stderr, "\n❌ %s: if: %v\n", label, err)
+			return stepResult{err: fmt.Errorf("step %s: if: %w", s.Name, err)}
+		}
+		if !ok {
+			fmt.Fprintf(out.stderr, "\n⏭️  %s: skipped (%s)\n", label, strings.TrimSpace(strings.TrimPrefix(msg, "✗")))
+			return stepResult{ok: true, skipped: true}
+		}
+	}
+	switch {
+	case s.Parallel != nil:
+		return r.parallel(ctx, s, label, sc, out, rep, last)
+	case s.Foreach != "":
+		return r.foreach(ctx, s, label, sc, out)
+	case s.sub != nil:
+		return r.use(ctx, s, label, sc, out)
+	}
+	return r.step(ctx, s, label, sc, out)
+}
+
+// foreach sends a step once per element of a JSON array, with the element
+// as {{item}} (or the name set by as:), its fields as {{item.id}} and its
+// 0-based position as {{index}}. Each element sees the captures of the ones
+// before it. The loop stops at the first failing element unless on_failure
+// is continue.
+func (r *runner) foreach(ctx context.Context, s Step, label string, sc *scope, out output) stepResult {
+	items, err := foreachItems(s.Foreach, sc)
+	if err != nil {
+		fmt.Fprintf(out.stderr, "\n❌ %s: %v\n", label, err)
+		return stepResult{err: fmt.Errorf("step %s: %w", s.Name, err)}
+	}
+	if len(items) == 0 {
+		fmt.Fprintf(out.stderr, "\n⏭️  %s: no items\n", label)
+		return stepResult{ok: true}
+	}
+	name := s.As
+	if name == "" {
+		name = "item"
+	}
+
+	one := s
+	one.Foreach = ""
+	local := newScope(sc, nil)
+	total := stepResult{ok: true, captures: map[string]string{}}
+	for i, item := range items {
+		m := map[string]string{"index": strconv.Itoa(i)}
+		flattenVars(m, name, item)
+		res := r.step(ctx, one, fmt.Sprintf("%s [%d/%d]", label, i+1, len(items)), newScope(local, m), out)
+		for _, rep := range res.reports {
+			rep.Name = fmt.Sprintf("%s[%d]", s.Name, i)
+		}
+		total.reports = append(total.reports, res.reports...)
+		local.set(res.captures)
+		for k, v := range res.captures {
+			total.captures[k] = v
+		}
+		if res.response != nil {
+			total.response = res.response
+		}
+		if !res.ok {
+			if total.ok {
+				total.err = res.err
+			}
+			total.ok = false
+			if s.OnFailure != "continue" {
+				break
+			}
+		}
+	}
+	return total
+}
+
+// foreachItems resolves foreach: a variable name, or a {{placeholder}} or
+// literal holding a JSON array.
+func foreachItems(src string, sc *scope) ([]any, error) {
+	src = strings.TrimSpace(src)
+	text := sc.interpolate(src)
+	if !strings.HasPrefix(strings.TrimSpace(text), "[") {
+		v, ok := sc.lookup(src)
+		if !ok {
+			return nil, fmt.Errorf("foreach: no variable %q", src)
+		}
+		text = v
+	}
+	v, err := jq.Decode([]byte(text))
+	items, ok := v.([]any)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("foreach: %s is not a JSON array", src)
+	}
+	return items, nil
+}
+
+// flattenVars adds v to m as prefix, and its nested fields as prefix.name
+// and prefix.0.
+func flattenVars(m map[string]string, prefix string, v any) {
+	m[prefix] = jq.String(v)
+	switch x := v.(type) {
+	case map[string]any:
+		for k, e := range x {
+			flattenVars(m, prefix+"."+k, e)
+		}
+	case []any:
+		for i, e := range x {
+			flattenVars(m, prefix+"."+strconv.Itoa(i), e)
+		}
+	}
+}
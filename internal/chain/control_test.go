@@ -0,0 +1,165 @@
+package chain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// recorder is a test server answering each path with a fixed body and
+// remembering the requests it got.
+type recorder struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (rec *recorder) serve(bodies map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		rec.paths = append(rec.paths, r.URL.RequestURI())
+		rec.mu.Unlock()
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			body = `{}`
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func TestRun_If(t *testing.T) {
+	var rec recorder
+	srv := rec.serve(map[string]string{"/me": `{"role": "user", "admin": false}`})
+	defer srv.Close()
+
+	f := Flow{Output: "json", Steps: []Step{
+		{Name: "me", Method: "GET", URL: srv.URL + "/me", Capture: map[string]string{"if_role": ".role"}},
+		{Name: "admin", Method: "GET", URL: srv.URL + "/admin", If: ".admin == true", OnSuccess: "stop"},
+		{Name: "role", Method: "GET", URL: srv.URL + "/user", If: "vars.if_role == user"},
+		{Name: "templated", Method: "GET", URL: srv.URL + "/never", If: `"{{if_role}}" == "admin"`},
+		{Name: "last", Method: "GET", URL: srv.URL + "/last", If: "status == 200"},
+	}}
+	if err := run(context.Background(), f, &Report{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(rec.paths, " "); got != "/me /user /last" {
+		t.Errorf("requests = %s", got)
+	}
+
+	f.Steps = []Step{{Name: "bad", Method: "GET", URL: srv.URL, If: "status == abc"}}
+	if err := run(context.Background(), f, &Report{}); err == nil || !strings.Contains(err.Error(), "if:") {
+		t.Errorf("invalid condition: err = %v", err)
+	}
+}
+
+func TestRun_Foreach(t *testing.T) {
+	var rec recorder
+	srv := rec.serve(map[string]string{
+		"/items":   `{"items": [{"id": 1, "sku": "a"}, {"id": 2, "sku": "b"}, {"id": 3, "sku": "c"}]}`,
+		"/items/2": `{"price": 20}`,
+	})
+	defer srv.Close()
+
+	f := Flow{Output: "json", Steps: []Step{
+		{Name: "list", Method: "GET", URL: srv.URL + "/items", Capture: map[string]string{"fe_items": "[.items[]]"}},
+		{Name: "get", Method: "GET", URL: srv.URL + "/items/{{it.id}}?sku={{it.sku}}&i={{index}}&prev={{fe_price}}", Foreach: "fe_items", As: "it", Capture: map[string]string{"fe_price": ".price // \"none\""}},
+		{Name: "literal", Method: "GET", URL: srv.URL + "/n/{{item}}", Foreach: "[7, 8]"},
+		{Name: "after", Method: "GET", URL: srv.URL + "/after?price={{fe_price}}"},
+	}}
+	rep := &Report{}
+	if err := run(context.Background(), f, rep); err != nil {
+		t.Fatal(err)
+	}
+	want := "/items /items/1?sku=a&i=0&prev={{fe_price}} /items/2?sku=b&i=1&prev=none /items/3?sku=c&i=2&prev=20 /n/7 /n/8 /after?price=none"
+	if got := strings.Join(rec.paths, " "); got != want {
+		t.Errorf("requests:\n%s\nwant:\n%s", got, want)
+	}
+	if rep.Steps[1].Name != "get[0]" || rep.Steps[3].Name != "get[2]" {
+		t.Errorf("foreach steps should be reported per element, got %s, %s", rep.Steps[1].Name, rep.Steps[3].Name)
+	}
+
+	f.Steps = []Step{{Name: "get", Method: "GET", URL: srv.URL, Foreach: "fe_missing"}}
+	if err := run(context.Background(), f, &Report{}); err == nil || !strings.Contains(err.Error(), "no variable") {
+		t.Errorf("missing variable: err = %v", err)
+	}
+}
+
+func TestRun_RepeatUntil(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if r.URL.Path == "/stuck" || n < 3 {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"state": "running"}`))
+			return
+		}
+		w.Write([]byte(`{"state": "done"}`))
+	}))
+	defer srv.Close()
+
+	poll := &Poll{Until: `.state == "done"`, Interval: 10 * time.Millisecond, Timeout: time.Second}
+	f := Flow{Output: "json", Steps: []Step{
+		{Name: "job", Method: "GET", URL: srv.URL + "/job", RepeatUntil: poll, Assert: []string{"status == 200"}},
+	}}
+	rep := &Report{}
+	if err := run(context.Background(), f, rep); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || len(rep.Steps) != 1 || !rep.Steps[0].Passed {
+		t.Errorf("calls = %d, steps = %d", calls, len(rep.Steps))
+	}
+
+	f.Steps[0].URL = srv.URL + "/stuck"
+	f.Steps[0].RepeatUntil = &Poll{Until: `.state == "done"`, Interval: 20 * time.Millisecond, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	err := run(context.Background(), f, &Report{})
+	if err == nil || !strings.Contains(err.Error(), "not met after") {
+		t.Errorf("err = %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("polling should stop at the timeout, took %s", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	f.Steps[0].RepeatUntil = &Poll{Until: `.state == "done"`, Interval: time.Hour, Timeout: 2 * time.Hour}
+	if err := run(ctx, f, &Report{}); err == nil {
+		t.Error("cancelling the context should stop polling")
+	}
+}
+
+func TestPoll_UnmarshalYAML(t *testing.T) {
+	var s struct{ A, B Poll }
+	err := yaml.Unmarshal([]byte(`
+a: status == 200
+b: {until: .done == true, interval: 2s, timeout: 1m}
+`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.A.Until != "status == 200" || s.B.Until != ".done == true" || s.B.Interval != 2*time.Second || s.B.Timeout != time.Minute {
+		t.Errorf("%+v", s)
+	}
+}
+
+func TestCheckStep(t *testing.T) {
+	bad := map[string]Step{
+		"foreach parallel": {Name: "x", Foreach: "ids", Parallel: &Parallel{Steps: []Step{{Name: "a"}}}},
+		"poll stream":      {Name: "x", RepeatUntil: &Poll{Until: "status == 200"}, Stream: &StreamSpec{}},
+		"poll without":     {Name: "x", RepeatUntil: &Poll{}},
+	}
+	for name, s := range bad {
+		if err := checkSteps([]Step{s}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
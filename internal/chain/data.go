@@ -0,0 +1,313 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humancto/mozzy/internal/jq"
+	"github.com/humancto/mozzy/internal/ui"
+)
+
+// Data modes: fail-fast stops at the first failing row, continue runs every
+// row and fails the run at the end if any did.
+const (
+	DataFailFast = "fail-fast"
+	DataContinue = "continue"
+)
+
+// Data runs a flow's steps once per row of a dataset. Each row runs in its
+// own variable scope holding its fields as {{row.field}} and its 1-based
+// number as {{iteration}}; its captures aren't seen by other rows.
+type Data struct {
+	File     string           `yaml:"file,omitempty"`     // .csv, .tsv, .json or .jsonl, relative to the flow file
+	Rows     []map[string]any `yaml:"rows,omitempty"`     // inline rows instead of a file
+	Mode     string           `yaml:"mode,omitempty"`     // fail-fast (default) or continue
+	Parallel int              `yaml:"parallel,omitempty"` // rows run at once; default 1
+}
+
+// UnmarshalYAML also accepts `data: users.csv` and a plain list of rows.
+func (d *Data) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		*d = Data{}
+		return n.Decode(&d.File)
+	case yaml.SequenceNode:
+		*d = Data{}
+		return n.Decode(&d.Rows)
+	}
+	type plain Data
+	var v plain
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	*d = Data(v)
+	return nil
+}
+
+// load returns the rows and a description of where they came from.
+func (d *Data) load(dir string) ([]map[string]any, string, error) {
+	if d.File == "" {
+		if len(d.Rows) == 0 {
+			return nil, "", fmt.Errorf("data: set a file or rows")
+		}
+		return d.Rows, "inline data", nil
+	}
+	path := d.File
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rows, err := LoadRows(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return rows, filepath.Base(path), nil
+}
+
+// LoadRows reads a dataset: a CSV or TSV file with a header row, a JSON
+// array of objects, or JSON Lines. The format is chosen by extension, and
+// sniffed for other files.
+func LoadRows(path string) ([]map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".csv":
+		rows, err = csvRows(b, ',')
+	case ext == ".tsv":
+		rows, err = csvRows(b, '\t')
+	case ext == ".jsonl" || ext == ".ndjson":
+		rows, err = jsonLineRows(b)
+	case ext == ".json" || bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")):
+		rows, err = jsonRows(b)
+	default:
+		rows, err = csvRows(b, ',')
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no rows", path)
+	}
+	return rows, nil
+}
+
+func csvRows(b []byte, comma rune) ([]map[string]any, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.Comma = comma
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = rec[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func jsonRows(b []byte) ([]map[string]any, error) {
+	v, err := jq.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("want an array of objects")
+	}
+	rows := make([]map[string]any, len(items))
+	for i, it := range items {
+		if rows[i], ok = it.(map[string]any); !ok {
+			return nil, fmt.Errorf("row %d is not an object", i+1)
+		}
+	}
+	return rows, nil
+}
+
+func jsonLineRows(b []byte) ([]map[string]any, error) {
+	var rows []map[string]any
+	for i, line := range bytes.Split(b, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		v, err := jq.Decode(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		row, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("line %d is not an object", i+1)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// rowVars flattens a row into the variables of its iteration: row.name for
+// each field, with nested fields as row.owner.login and row.tags.0.
+func rowVars(row map[string]any, iteration int) map[string]string {
+	m := map[string]string{"iteration": strconv.Itoa(iteration)}
+	flattenVars(m, "row", row)
+	return m
+}
+
+// rowSummary shows a row's scalar fields as name=value pairs.
+func rowSummary(row map[string]any) string {
+	names := make([]string, 0, len(row))
+	for k, v := range row {
+		switch v.(type) {
+		case map[string]any, []any:
+			continue
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, k := range names {
+		parts[i] = k + "=" + jq.String(row[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// iterate runs the flow's steps once per data row, at most Data.Parallel
+// rows at a time, then prints a pass/fail table of the rows.
+func (r *runner) iterate(ctx context.Context, rep *Report) error {
+	d := r.f.Data
+	failFast := true
+	switch d.Mode {
+	case "", DataFailFast:
+	case DataContinue:
+		failFast = false
+	default:
+		return fmt.Errorf("unknown data mode %q (use %s or %s)", d.Mode, DataFailFast, DataContinue)
+	}
+	rows, source, err := d.load(r.f.Dir)
+	if err != nil {
+		return err
+	}
+	limit := min(max(d.Parallel, 1), len(rows))
+	fmt.Fprintf(os.Stderr, "\n🗂️  %d rows from %s", len(rows), source)
+	if limit > 1 {
+		fmt.Fprintf(os.Stderr, ", %d at a time", limit)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	iterations := make([]*IterationReport, len(rows))
+	errs := make([]error, len(rows))
+	scopes := make([]*scope, len(rows))
+	ordered(ctx, len(rows), limit, terminal, func(i int, out output) {
+		start := time.Now()
+		fmt.Fprintf(out.stderr, "\n🔁 Row %d/%d: %s\n", i+1, len(rows), rowSummary(rows[i]))
+		sub := &Report{}
+		scopes[i] = newScope(newScope(nil, rowVars(rows[i], i+1)), nil) // holds only the row's captures
+		errs[i] = r.steps(ctx, r.f.Steps, scopes[i], out, sub)
+		sub.finish(errs[i])
+		iterations[i] = &IterationReport{
+			Row:      i + 1,
+			Data:     rows[i],
+			Passed:   sub.Passed,
+			Steps:    sub.Steps,
+			Error:    sub.Error,
+			Duration: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if !sub.Passed && failFast {
+			cancel()
+		}
+	}, func(i int, ran bool) {
+		if !ran {
+			iterations[i] = &IterationReport{Row: i + 1, Data: rows[i], Skipped: true}
+		}
+	})
+	rep.Iterations = iterations
+	printIterations(os.Stderr, iterations)
+	r.rows = rowCaptures(scopes)
+
+	failed, first := 0, -1
+	for i, it := range iterations {
+		if !it.Passed && !it.Skipped {
+			failed++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	switch {
+	case failed == 0:
+		return nil
+	case !failFast:
+		return fmt.Errorf("%d of %d rows failed", failed, len(rows))
+	case errs[first] != nil:
+		return fmt.Errorf("row %d: %w", first+1, errs[first])
+	}
+	return fmt.Errorf("row %d failed", first+1)
+}
+
+// rowCaptures lists the captures of every row that made any, with its row
+// number, as the variable rows: [{"row": 1, "user_id": "5"}, ...], also
+// reachable as rows.0.user_id. Teardown sees it, so it can clean up after
+// each row with foreach: rows.
+func rowCaptures(scopes []*scope) map[string]string {
+	list := []any{}
+	for i, sc := range scopes {
+		if sc == nil || len(sc.vars) == 0 {
+			continue
+		}
+		item := map[string]any{"row": i + 1}
+		for k, v := range sc.vars {
+			item[k] = v
+		}
+		list = append(list, item)
+	}
+	m := map[string]string{}
+	flattenVars(m, "rows", list)
+	return m
+}
+
+// printIterations prints the pass/fail table of a data-driven run.
+func printIterations(w io.Writer, iterations []*IterationReport) {
+	t := ui.NewTable([]string{"#", "row", "result", "time", "error"})
+	passed, failed, skipped := 0, 0, 0
+	for _, it := range iterations {
+		result, took := "passed", fmt.Sprintf("%.0fms", it.Duration)
+		switch {
+		case it.Skipped:
+			result, took = "skipped", ""
+			skipped++
+		case it.Passed:
+			passed++
+		default:
+			result = "failed"
+			failed++
+		}
+		t.AddRow([]string{strconv.Itoa(it.Row), rowSummary(it.Data), result, took, it.Error})
+	}
+	t.Width = ui.TerminalWidth()
+	fmt.Fprintf(w, "\n%s", t.Render())
+	fmt.Fprintf(w, "%d passed, %d failed", passed, failed)
+	if skipped > 0 {
+		fmt.Fprintf(w, ", %d skipped", skipped)
+	}
+	fmt.Fprintln(w)
+}
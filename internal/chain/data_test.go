@@ -0,0 +1,147 @@
+package chain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+func TestLoadRows(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"users.csv":   "id,name\n1,Ada\n2,Grace\n",
+		"users.tsv":   "id\tname\n1\tAda\n2\tGrace\n",
+		"users.json":  `[{"id": 1, "name": "Ada"}, {"id": 2, "name": "Grace"}]`,
+		"users.jsonl": "{\"id\": 1, \"name\": \"Ada\"}\n\n{\"id\": 2, \"name\": \"Grace\"}\n",
+		"users.txt":   `[{"id": 1, "name": "Ada"}, {"id": 2, "name": "Grace"}]`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+		rows, err := LoadRows(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(rows) != 2 || rows[1]["name"] != "Grace" || vars.Expand("{{row.id}}", newScope(nil, rowVars(rows[0], 1)).lookup) != "1" {
+			t.Errorf("%s: rows = %v", name, rows)
+		}
+	}
+
+	for name, content := range map[string]string{"empty.csv": "id,name\n", "scalars.json": `[1, 2]`, "object.json": `{"id": 1}`} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := LoadRows(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRowVars(t *testing.T) {
+	got := rowVars(map[string]any{"id": 7, "owner": map[string]any{"login": "ada"}, "tags": []any{"x", "y"}}, 3)
+	want := map[string]string{
+		"iteration":       "3",
+		"row.id":          "7",
+		"row.owner.login": "ada",
+		"row.tags.1":      "y",
+		"row.owner":       `{"login":"ada"}`,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestData_UnmarshalYAML(t *testing.T) {
+	var f struct{ A, B, C Data }
+	err := yaml.Unmarshal([]byte(`
+a: users.csv
+b: [{id: 1}, {id: 2}]
+c: {file: users.json, mode: continue, parallel: 4}
+`), &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.A.File != "users.csv" || len(f.B.Rows) != 2 || f.C.File != "users.json" || f.C.Mode != DataContinue || f.C.Parallel != 4 {
+		t.Errorf("%+v", f)
+	}
+}
+
+func TestRun_Data(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.RequestURI())
+		mu.Unlock()
+		if r.URL.Path == "/login/bad" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		time.Sleep(30 * time.Millisecond)
+		w.Write([]byte(`{"token": "t-` + strings.TrimPrefix(r.URL.Path, "/login/") + `"}`))
+	}))
+	defer srv.Close()
+
+	flow := func(data Data) Flow {
+		return Flow{Output: "json", Data: &data, Steps: []Step{
+			{Name: "login", Method: "GET", URL: srv.URL + "/login/{{row.user}}", Capture: map[string]string{"data_token": ".token"}, Assert: []string{"status == 200"}},
+			{Name: "me", Method: "GET", URL: srv.URL + "/me?token={{data_token}}&n={{iteration}}"},
+		}}
+	}
+	rows := []map[string]any{{"user": "ada"}, {"user": "bad"}, {"user": "grace"}}
+
+	rep := &Report{}
+	err := run(context.Background(), flow(Data{Rows: rows}), rep)
+	if err == nil || !strings.HasPrefix(err.Error(), "row 2: ") {
+		t.Errorf("fail-fast err = %v", err)
+	}
+	if it := rep.Iterations; len(it) != 3 || !it[0].Passed || it[1].Passed || !it[2].Skipped {
+		t.Errorf("fail-fast iterations: %+v %+v %+v", it[0], it[1], it[2])
+	}
+	if _, ok := vars.Lookup("data_token"); ok {
+		t.Error("captures should stay in their row's scope")
+	}
+
+	seen = nil
+	rep = &Report{}
+	err = run(context.Background(), flow(Data{Rows: rows, Mode: DataContinue}), rep)
+	if err == nil || err.Error() != "1 of 3 rows failed" {
+		t.Errorf("continue err = %v", err)
+	}
+	if want := "/me?token=t-grace&n=3"; seen[len(seen)-1] != want {
+		t.Errorf("last request %s, want %s", seen[len(seen)-1], want)
+	}
+
+	start := time.Now()
+	ok := []map[string]any{{"user": "a"}, {"user": "b"}, {"user": "c"}, {"user": "d"}}
+	rep = &Report{}
+	if err := run(context.Background(), flow(Data{Rows: ok, Parallel: 4}), rep); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Errorf("4 rows in parallel took %s", d)
+	}
+	for i, it := range rep.Iterations {
+		if !it.Passed || it.Row != i+1 {
+			t.Errorf("row %d: %+v", i+1, it)
+		}
+	}
+}
+
+func TestRun_DataMode(t *testing.T) {
+	err := run(context.Background(), Flow{Data: &Data{Rows: []map[string]any{{}}, Mode: "sometimes"}}, &Report{})
+	if err == nil || !strings.Contains(err.Error(), "unknown data mode") {
+		t.Errorf("err = %v", err)
+	}
+}
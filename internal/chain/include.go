@@ -0,0 +1,192 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults apply to every step of a flow, and to the flows it uses or
+// includes unless they set their own.
+type Defaults struct {
+	Headers map[string]string `yaml:"headers,omitempty"` // added unless the step sets the header
+	Base    string            `yaml:"base,omitempty"`    // for /paths unless --base, --env or env: is set
+	Assert  []string          `yaml:"assert,omitempty"`  // checked before each step's own assertions
+}
+
+// merge layers d over base.
+func (d *Defaults) merge(base Defaults) Defaults {
+	if d == nil {
+		return base
+	}
+	out := Defaults{Headers: map[string]string{}, Base: base.Base}
+	for k, v := range base.Headers {
+		out.Headers[k] = v
+	}
+	for k, v := range d.Headers {
+		out.Headers[k] = v
+	}
+	if d.Base != "" {
+		out.Base = d.Base
+	}
+	out.Assert = append(append(out.Assert, base.Assert...), d.Assert...)
+	return out
+}
+
+// Load reads a flow file. Files it includes or uses are loaded when it runs.
+func Load(path string) (Flow, error) {
+	var f Flow
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Path, err = filepath.Abs(path); err != nil {
+		return f, err
+	}
+	f.Dir = filepath.Dir(f.Path)
+	return f, nil
+}
+
+// resolve loads the files f includes and the flows its steps use, relative
+// to f's directory. stack holds the files being resolved, to report cycles
+// such as a.yaml → b.yaml → a.yaml.
+func (f *Flow) resolve(stack []string) error {
+	if f.Path != "" {
+		stack = append(stack, f.Path)
+	}
+	f.included = map[string]*Flow{}
+	var defaults Defaults
+	for _, inc := range f.Include {
+		sub, err := f.load(inc, stack)
+		if err != nil {
+			return err
+		}
+		f.included[strings.TrimSuffix(filepath.Base(inc), filepath.Ext(inc))] = sub
+		defaults = sub.Defaults.merge(defaults)
+	}
+	if len(f.Include) > 0 {
+		d := f.Defaults.merge(defaults)
+		f.Defaults = &d
+	}
+
+	resolveSteps := func(steps []Step) error {
+		for i := range steps {
+			s := &steps[i]
+			if s.Use == "" {
+				continue
+			}
+			if sub, ok := f.included[s.Use]; ok {
+				s.sub = sub
+				continue
+			}
+			sub, err := f.load(s.Use, stack)
+			if err != nil {
+				return fmt.Errorf("step %q: %w", s.Name, err)
+			}
+			s.sub = sub
+		}
+		return nil
+	}
+	for _, steps := range [][]Step{f.Setup, f.Steps, f.Teardown} {
+		if err := resolveSteps(steps); err != nil {
+			return err
+		}
+		for _, s := range steps {
+			if s.Parallel != nil {
+				if err := resolveSteps(s.Parallel.Steps); err != nil {
+					return err
+				}
+			}
+		}
+		if err := checkSteps(steps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads and resolves a flow file named relative to f.
+func (f *Flow) load(name string, stack []string) (*Flow, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.Dir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range stack {
+		if p == path {
+			chain := append(append([]string{}, stack[i:]...), path)
+			for j := range chain {
+				chain[j] = filepath.Base(chain[j])
+			}
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(chain, " → "))
+		}
+	}
+	sub, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Data != nil || len(sub.Setup) > 0 || len(sub.Teardown) > 0 {
+		return nil, fmt.Errorf("%s: a flow with data, setup or teardown can't be included or used", name)
+	}
+	if err := sub.resolve(stack); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// use runs another flow's steps as one step. Its params, overridden by the
+// step's with: values, are variables of its own scope, which also sees the
+// caller's variables. The flow's captures, or just its outputs if it lists
+// them, are returned to the caller.
+func (r *runner) use(ctx context.Context, s Step, label string, sc *scope, out output) stepResult {
+	sub := s.sub
+	params := make(map[string]string, len(sub.Params)+len(s.With))
+	for k, v := range sub.Params {
+		params[k] = sc.interpolate(v)
+	}
+	for k, v := range s.With {
+		params[k] = sc.interpolate(v)
+	}
+	fmt.Fprintf(out.stderr, "\n📦 %s (%s)\n", label, s.Use)
+
+	local := newScope(newScope(sc, params), nil) // holds only the flow's captures
+	sr := *r
+	sr.defaults = sub.Defaults.merge(r.defaults)
+	rep := &Report{}
+	err := sr.steps(ctx, sub.Steps, local, out, rep)
+	for _, step := range rep.Steps {
+		step.Name = s.Name + " › " + step.Name
+	}
+
+	// like Report.finish: a step can fail without stopping with an error,
+	// e.g. a 401 without assertions
+	res := stepResult{reports: rep.Steps, captures: local.vars, ok: err == nil}
+	for _, step := range rep.Steps {
+		if !step.Passed && res.ok {
+			res.ok = false
+			err = fmt.Errorf("%s failed", step.Name)
+		}
+	}
+	if len(sub.Outputs) > 0 {
+		res.captures = map[string]string{}
+		for _, name := range sub.Outputs {
+			if v, ok := local.vars[name]; ok {
+				res.captures[name] = v
+			}
+		}
+	}
+	if !res.ok {
+		res.err = fmt.Errorf("step %s: %w", s.Name, err)
+	}
+	return res
+}
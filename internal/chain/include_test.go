@@ -0,0 +1,158 @@
+package chain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+// writeFlows writes flow files under a temp dir and returns its path.
+func writeFlows(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRun_Use(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.RequestURI()+" "+r.Header.Get("X-Team")+" "+r.Header.Get("X-Client"))
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(`{"token": "t-` + r.URL.Query().Get("user") + `", "id": 7}`))
+	}))
+	defer srv.Close()
+
+	dir := writeFlows(t, map[string]string{
+		"lib/common.yaml": `
+defaults:
+  headers: {X-Team: qa, X-Client: common}
+  assert: [status == 200]
+`,
+		"lib/auth.yaml": `
+params: {user: demo}
+outputs: [use_token]
+steps:
+  - name: login
+    method: GET
+    url: "{{api}}/login?user={{user}}"
+    capture: {use_token: .token, use_id: .id}
+`,
+		"lib/profile.yaml": `
+steps:
+  - name: me
+    method: GET
+    url: /me?token={{use_token}}
+    capture: {use_me: .id}
+`,
+		"flows/main.yaml": `
+include: [../lib/common.yaml, ../lib/auth.yaml]
+defaults:
+  headers: {X-Client: main}
+  base: ` + srv.URL + `
+steps:
+  - name: sign in
+    use: auth
+    with: {api: "` + srv.URL + `", user: ada}
+  - name: profile
+    use: ../lib/profile.yaml
+  - name: check
+    method: GET
+    url: /check?me={{use_me}}&id={{use_id}}
+    headers: {X-Team: own}
+`,
+	})
+	f, err := Load(filepath.Join(dir, "flows", "main.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Output = "json"
+	rep := &Report{}
+	if err := run(context.Background(), f, rep); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/login?user=ada qa main", "/me?token=t-ada qa main", "/check?me=7&id={{use_id}} own main"}
+	if strings.Join(seen, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(seen, "\n"), strings.Join(want, "\n"))
+	}
+	if v, _ := vars.Lookup("use_token"); v != "t-ada" {
+		t.Errorf("use_token = %q", v)
+	}
+	if len(rep.Steps) != 3 || rep.Steps[0].Name != "sign in › login" || len(rep.Steps[2].Assertions) != 1 {
+		t.Errorf("steps: %+v", rep.Steps)
+	}
+
+	// a failing step of the used flow fails the calling step
+	f.Steps[2] = Step{Name: "missing", Use: filepath.Join(dir, "lib", "missing.yaml")}
+	os.WriteFile(filepath.Join(dir, "lib", "missing.yaml"), []byte("steps: [{name: get, method: GET, url: /missing}]"), 0o644)
+	err = run(context.Background(), f, &Report{})
+	if err == nil || !strings.HasPrefix(err.Error(), "step missing: ") {
+		t.Errorf("err = %v", err)
+	}
+
+	// so does one answering 404 without assertions, which doesn't stop the
+	// used flow with an error
+	os.WriteFile(filepath.Join(dir, "lib", "common.yaml"), []byte("{}"), 0o644)
+	f, _ = Load(filepath.Join(dir, "flows", "main.yaml"))
+	f.Output = "json"
+	f.Steps[1] = Step{Name: "missing", Use: filepath.Join(dir, "lib", "missing.yaml"), OnSuccess: "check"}
+	seen = nil
+	err = run(context.Background(), f, &Report{})
+	if err == nil || err.Error() != "step missing: missing › get failed" {
+		t.Errorf("err = %v", err)
+	}
+	if len(seen) != 2 {
+		t.Errorf("the step after a failed use shouldn't run: %v", seen)
+	}
+}
+
+func TestRun_UseCycle(t *testing.T) {
+	dir := writeFlows(t, map[string]string{
+		"a.yaml":     "steps: [{name: b, use: sub/b.yaml}]",
+		"sub/b.yaml": "include: [../c.yaml]",
+		"c.yaml":     "steps: [{name: a, use: a.yaml}]",
+	})
+	f, err := Load(filepath.Join(dir, "a.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = run(context.Background(), f, &Report{})
+	if err == nil || !strings.Contains(err.Error(), "include cycle: a.yaml → b.yaml → c.yaml → a.yaml") {
+		t.Errorf("err = %v", err)
+	}
+
+	f.Steps = []Step{{Name: "x", Use: "nope.yaml"}}
+	if err := run(context.Background(), f, &Report{}); err == nil {
+		t.Error("a missing flow file should fail")
+	}
+}
+
+func TestCheckStep_Use(t *testing.T) {
+	for name, s := range map[string]Step{
+		"use request": {Name: "x", Use: "a.yaml", Method: "GET", URL: "/"},
+		"use foreach": {Name: "x", Use: "a.yaml", Foreach: "ids"},
+		"with alone":  {Name: "x", Method: "GET", URL: "/", With: map[string]string{"a": "b"}},
+	} {
+		if err := checkSteps([]Step{s}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/humancto/mozzy/internal/assertions"
+	"gopkg.in/yaml.v3"
+)
+
+// Parallel is a block of steps that run concurrently, e.g. independent
+// requests after a login. A step holding a block sends no request itself;
+// its on_success/on_failure apply to the block as a whole.
+type Parallel struct {
+	Max   int    `yaml:"max,omitempty"` // how many steps run at once; 0 runs them all
+	Steps []Step `yaml:"steps"`
+}
+
+// UnmarshalYAML also accepts a plain list of steps with no limit.
+func (p *Parallel) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.SequenceNode {
+		*p = Parallel{}
+		return n.Decode(&p.Steps)
+	}
+	type plain Parallel
+	var v plain
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	*p = Parallel(v)
+	return nil
+}
+
+// checkSteps rejects steps that can't be run. Steps in a parallel block can
+// only continue or stop the block, since jumping elsewhere has no meaning
+// while their siblings are still running.
+func checkSteps(steps []Step) error {
+	for _, s := range steps {
+		if err := checkStep(s); err != nil {
+			return err
+		}
+		if s.Parallel == nil {
+			continue
+		}
+		if s.Method != "" || s.URL != "" || s.Stream != nil {
+			return fmt.Errorf("step %q: a parallel block can't also send a request", s.Name)
+		}
+		if len(s.Parallel.Steps) == 0 {
+			return fmt.Errorf("step %q: parallel block has no steps", s.Name)
+		}
+		for _, p := range s.Parallel.Steps {
+			if err := checkStep(p); err != nil {
+				return err
+			}
+			if p.Parallel != nil {
+				return fmt.Errorf("step %q: parallel blocks can't be nested", p.Name)
+			}
+			if p.OnSuccess != "" && p.OnSuccess != "continue" {
+				return fmt.Errorf("step %q: on_success in a parallel block can only be continue", p.Name)
+			}
+			if p.OnFailure != "" && p.OnFailure != "stop" && p.OnFailure != "continue" {
+				return fmt.Errorf("step %q: on_failure in a parallel block can only be stop or continue", p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func checkStep(s Step) error {
+	switch {
+	case s.Foreach != "" && s.Parallel != nil:
+		return fmt.Errorf("step %q: foreach can't be used on a parallel block", s.Name)
+	case s.RepeatUntil != nil && (s.Parallel != nil || s.Stream != nil):
+		return fmt.Errorf("step %q: repeat_until needs a plain request step", s.Name)
+	case s.RepeatUntil != nil && strings.TrimSpace(s.RepeatUntil.Until) == "":
+		return fmt.Errorf("step %q: repeat_until needs a condition", s.Name)
+	case s.Use != "" && (s.Method != "" || s.URL != "" || s.Stream != nil || s.Parallel != nil || s.Foreach != "" || s.RepeatUntil != nil):
+		return fmt.Errorf("step %q: use runs another flow and can't also send a request", s.Name)
+	case s.With != nil && s.Use == "":
+		return fmt.Errorf("step %q: with needs use", s.Name)
+	}
+	return nil
+}
+
+// parallel runs a block's steps in sc, at most Max at a time; their if:
+// conditions see the response before the block. Each step's
+// output is printed whole, in declaration order. A failing step stops the
+// block unless its on_failure is continue: steps not yet started are skipped
+// and running ones cancelled. Captures are set once the whole block has
+// finished, in declaration order, so steps of the block never see each
+// other's and a name captured by two steps ends up with the later step's
+// value.
+func (r *runner) parallel(ctx context.Context, s Step, label string, sc *scope, out output, rep *Report, last *assertions.Response) stepResult {
+	steps := s.Parallel.Steps
+	limit := s.Parallel.Max
+	if limit <= 0 || limit > len(steps) {
+		limit = len(steps)
+	}
+	fmt.Fprintf(out.stderr, "\n⚡ %s (%d steps, %d at a time)\n", label, len(steps), limit)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]stepResult, len(steps))
+	var mu sync.Mutex
+	failure := -1 // the step that stopped the block
+	passed, skipped := 0, 0
+	start := time.Now()
+	ordered(ctx, len(steps), limit, out, func(i int, out output) {
+		p := steps[i]
+		results[i] = r.do(ctx, p, fmt.Sprintf("%s ▸ %s", label, p.Name), sc, out, rep, last)
+		if !results[i].ok && p.OnFailure != "continue" {
+			mu.Lock()
+			if failure < 0 {
+				failure = i
+			}
+			mu.Unlock()
+			cancel()
+		}
+	}, func(i int, ran bool) {
+		if !ran {
+			skipped++
+			fmt.Fprintf(out.stderr, "⏭️  %s ▸ %s: skipped\n", label, steps[i].Name)
+			return
+		}
+		rep.add(results[i].reports...)
+		switch {
+		case results[i].skipped:
+			skipped++
+		case results[i].ok:
+			passed++
+		}
+	})
+	// once every step has finished, so no step sees a sibling's captures
+	for _, res := range results {
+		sc.set(res.captures)
+	}
+	fmt.Fprintf(out.stderr, "\n⚡ %s: %d/%d passed", s.Name, passed, len(steps))
+	if skipped > 0 {
+		fmt.Fprintf(out.stderr, ", %d skipped", skipped)
+	}
+	fmt.Fprintf(out.stderr, " in %s\n", time.Since(start).Round(time.Millisecond))
+
+	if failure < 0 {
+		return stepResult{ok: true}
+	}
+	res := stepResult{err: results[failure].err}
+	if res.err != nil {
+		res.err = fmt.Errorf("parallel step %s: %w", steps[failure].Name, res.err)
+	}
+	return res
+}
+
+// ordered runs job(0) … job(n-1), starting them in order with at most limit
+// running at once, and calls after for each in order once it and the jobs
+// before it have finished. Jobs write to their own buffer, which is copied to
+// out before after is called, so their output is never interleaved; with a
+// limit of 1 they write to out directly. Jobs not started before ctx is
+// cancelled are reported with ran false.
+func ordered(ctx context.Context, n, limit int, out output, job func(i int, out output), after func(i int, ran bool)) {
+	if limit <= 1 {
+		for i := 0; i < n; i++ {
+			if ctx.Err() != nil {
+				after(i, false)
+				continue
+			}
+			job(i, out)
+			after(i, true)
+		}
+		return
+	}
+
+	started := make([]bool, n)
+	bufs := make([]buffer, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	go func() {
+		sem := make(chan struct{}, limit)
+		for i := 0; i < n; i++ {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				close(done[i])
+				continue
+			}
+			started[i] = true
+			go func(i int) {
+				defer func() {
+					<-sem
+					close(done[i])
+				}()
+				job(i, bufs[i].output())
+			}(i)
+		}
+	}()
+	for i := 0; i < n; i++ {
+		<-done[i]
+		if started[i] {
+			bufs[i].flush(out)
+		}
+		after(i, started[i])
+	}
+}
+
+// output is where a step prints: bodies and status lines to stdout, progress
+// to stderr.
+type output struct {
+	stdout, stderr io.Writer
+}
+
+var terminal = output{stdout: color.Output, stderr: os.Stderr}
+
+// buffer records a step's output so it can be printed later without being
+// interleaved with other steps, keeping the order of its stdout and stderr
+// writes.
+type buffer struct {
+	chunks []chunk
+}
+
+type chunk struct {
+	stderr bool
+	b      []byte
+}
+
+type bufferWriter struct {
+	buf    *buffer
+	stderr bool
+}
+
+func (w bufferWriter) Write(p []byte) (int, error) {
+	b := w.buf
+	if n := len(b.chunks); n > 0 && b.chunks[n-1].stderr == w.stderr {
+		b.chunks[n-1].b = append(b.chunks[n-1].b, p...)
+	} else {
+		b.chunks = append(b.chunks, chunk{stderr: w.stderr, b: append([]byte(nil), p...)})
+	}
+	return len(p), nil
+}
+
+func (b *buffer) output() output {
+	return output{stdout: bufferWriter{b, false}, stderr: bufferWriter{b, true}}
+}
+
+func (b *buffer) flush(to output) {
+	for _, c := range b.chunks {
+		w := to.stdout
+		if c.stderr {
+			w = to.stderr
+		}
+		w.Write(c.b)
+	}
+}
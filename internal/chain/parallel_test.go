@@ -0,0 +1,201 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+func TestRun_Parallel(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Output: "json",
+		Steps: []Step{
+			{Name: "fan-out", Parallel: &Parallel{Max: 2, Steps: []Step{
+				{Name: "a", Method: "GET", URL: srv.URL + "/a", Capture: map[string]string{"par_a": ".path", "par_last": ".path"}},
+				{Name: "b", Method: "GET", URL: srv.URL + "/b", Capture: map[string]string{"par_last": ".path"}},
+				{Name: "c", Method: "GET", URL: srv.URL + "/c"},
+				{Name: "d", Method: "GET", URL: srv.URL + "/d"},
+			}}},
+			{Name: "after", Method: "GET", URL: srv.URL + "/after{{par_a}}"},
+		},
+	}
+	rep := &Report{}
+	if err := run(context.Background(), f, rep); err != nil {
+		t.Fatal(err)
+	}
+	if peak != 2 {
+		t.Errorf("at most 2 requests should run at once, saw %d", peak)
+	}
+	if v, _ := vars.Lookup("par_last"); v != "/b" {
+		t.Errorf("par_last = %q, want the later step's /b", v)
+	}
+	if last := paths[len(paths)-1]; last != "/after/a" {
+		t.Errorf("step after the block requested %s", last)
+	}
+	var names []string
+	for _, s := range rep.Steps {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, " "); got != "a b c d after" {
+		t.Errorf("report steps = %s, want declaration order", got)
+	}
+}
+
+func TestRun_ParallelCapturesAfterBlock(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.RequestURI())
+		mu.Unlock()
+		w.Write([]byte(`{"id": 7}`))
+	}))
+	defer srv.Close()
+
+	// even one at a time, a step doesn't see an earlier sibling's captures
+	f := Flow{Output: "json", Steps: []Step{
+		{Name: "block", Parallel: &Parallel{Max: 1, Steps: []Step{
+			{Name: "a", Method: "GET", URL: srv.URL + "/a", Capture: map[string]string{"par_seq": ".id"}},
+			{Name: "b", Method: "GET", URL: srv.URL + "/b?id={{par_seq}}"},
+		}}},
+		{Name: "after", Method: "GET", URL: srv.URL + "/after?id={{par_seq}}"},
+	}}
+	if err := run(context.Background(), f, &Report{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(seen, " "); got != "/a /b?id={{par_seq}} /after?id=7" {
+		t.Errorf("requests = %s", got)
+	}
+}
+
+func TestRun_ParallelFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	block := func(onFailure string) []Step {
+		return []Step{
+			{Name: "block", OnFailure: onFailure, Parallel: &Parallel{Steps: []Step{
+				{Name: "slow", Method: "GET", URL: srv.URL + "/slow"},
+				{Name: "fail", Method: "GET", URL: srv.URL + "/fail", Assert: []string{"status == 200"}},
+			}}},
+			{Name: "next", Method: "GET", URL: srv.URL + "/next"},
+		}
+	}
+
+	start := time.Now()
+	rep := &Report{}
+	err := run(context.Background(), Flow{Output: "json", Steps: block("")}, rep)
+	if err == nil || !strings.Contains(err.Error(), "assertions failed for step: fail") {
+		t.Errorf("err = %v, want the failing step's error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("the slow step should be cancelled, run took %s", d)
+	}
+	if len(rep.Steps) != 2 {
+		t.Errorf("block should stop the flow, report has %d steps", len(rep.Steps))
+	}
+
+	rep = &Report{}
+	if err := run(context.Background(), Flow{Output: "json", Steps: block("continue")}, rep); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rep.Steps); n != 3 || rep.Steps[2].Name != "next" {
+		t.Errorf("on_failure: continue should run the next step, got %d steps", n)
+	}
+}
+
+func TestCheckSteps(t *testing.T) {
+	bad := map[string]Step{
+		"request": {Name: "p", URL: "/x", Parallel: &Parallel{Steps: []Step{{Name: "a"}}}},
+		"empty":   {Name: "p", Parallel: &Parallel{}},
+		"nested":  {Name: "p", Parallel: &Parallel{Steps: []Step{{Name: "a", Parallel: &Parallel{}}}}},
+		"jump":    {Name: "p", Parallel: &Parallel{Steps: []Step{{Name: "a", OnFailure: "cleanup"}}}},
+	}
+	for name, s := range bad {
+		if err := checkSteps([]Step{s}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	ok := Step{Name: "p", Parallel: &Parallel{Steps: []Step{{Name: "a", OnFailure: "continue"}}}}
+	if err := checkSteps([]Step{ok}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParallel_UnmarshalYAML(t *testing.T) {
+	var f Flow
+	err := yaml.Unmarshal([]byte(`
+steps:
+  - name: list
+    parallel:
+      - {name: a, method: GET, url: /a}
+      - {name: b, method: GET, url: /b}
+  - name: limited
+    parallel:
+      max: 3
+      steps:
+        - {name: c, method: GET, url: /c}
+`), &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := f.Steps[0].Parallel; p == nil || len(p.Steps) != 2 || p.Max != 0 {
+		t.Errorf("list form: %+v", p)
+	}
+	if p := f.Steps[1].Parallel; p == nil || len(p.Steps) != 1 || p.Max != 3 {
+		t.Errorf("mapping form: %+v", p)
+	}
+}
+
+func TestBuffer_KeepsOrder(t *testing.T) {
+	var b buffer
+	out := b.output()
+	out.stderr.Write([]byte("header\n"))
+	out.stdout.Write([]byte("body"))
+	out.stdout.Write([]byte("\n"))
+	out.stderr.Write([]byte("assert\n"))
+
+	var stdout, stderr, both bytes.Buffer
+	b.flush(output{stdout: io.MultiWriter(&stdout, &both), stderr: io.MultiWriter(&stderr, &both)})
+	if both.String() != "header\nbody\nassert\n" || stdout.String() != "body\n" || stderr.String() != "header\nassert\n" {
+		t.Errorf("stdout %q, stderr %q, combined %q", stdout.String(), stderr.String(), both.String())
+	}
+}
@@ -0,0 +1,95 @@
+package chain
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/humancto/mozzy/internal/envelope"
+	"github.com/humancto/mozzy/internal/httpclient"
+)
+
+// Report is the machine-readable result of a workflow run, printed instead
+// of the steps' output with --output json|yaml.
+type Report struct {
+	Workflow string        `json:"workflow,omitempty"`
+	Passed   bool          `json:"passed"`
+	Steps    []*StepReport `json:"steps"`
+	// Iterations holds the steps of each data row instead of Steps.
+	Iterations []*IterationReport `json:"iterations,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	// Teardown doesn't count towards Passed.
+	Teardown *TeardownReport `json:"teardown,omitempty"`
+}
+
+// StepReport is the envelope of one executed step with its verdict. A step
+// that was jumped to more than once appears once per run.
+type StepReport struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	*envelope.Envelope
+	Assertions []AssertionReport `json:"assertions,omitempty"`
+}
+
+// IterationReport is the result of running the steps for one data row.
+// Rows that didn't start because an earlier one failed are skipped.
+type IterationReport struct {
+	Row      int            `json:"row"`
+	Data     map[string]any `json:"data"`
+	Passed   bool           `json:"passed"`
+	Skipped  bool           `json:"skipped,omitempty"`
+	Steps    []*StepReport  `json:"steps,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Duration float64        `json:"duration_ms"`
+}
+
+// TeardownReport is the result of a flow's teardown steps.
+type TeardownReport struct {
+	Passed bool          `json:"passed"`
+	Steps  []*StepReport `json:"steps"`
+	Error  string        `json:"error,omitempty"`
+}
+
+type AssertionReport struct {
+	Expr    string `json:"expr"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// add records executed steps; nil entries are steps that sent no request.
+func (r *Report) add(steps ...*StepReport) {
+	for _, s := range steps {
+		if s != nil {
+			r.Steps = append(r.Steps, s)
+		}
+	}
+}
+
+// finish records how the run ended: it passed when it returned no error and
+// no step it went through failed.
+func (r *Report) finish(err error) {
+	r.Passed = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+	for _, s := range r.Steps {
+		if !s.Passed {
+			r.Passed = false
+		}
+	}
+	for _, it := range r.Iterations {
+		if !it.Passed && !it.Skipped {
+			r.Passed = false
+		}
+	}
+}
+
+// streamEnvelope describes a stream step, whose body is the JSON array of
+// its events.
+func streamEnvelope(req httpclient.Request, status int, body []byte, d time.Duration, err error) *envelope.Envelope {
+	e := envelope.New(req, nil, err)
+	if status != 0 {
+		e.Response = envelope.NewResponse(&http.Response{StatusCode: status, Header: http.Header{}}, body)
+	}
+	e.Timings = envelope.NewTimings(httpclient.TimingInfo{Total: d})
+	return e
+}
@@ -0,0 +1,45 @@
+package chain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRun_Report(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Name:   "report",
+		Output: "json",
+		Steps: []Step{
+			{Name: "get", Method: "GET", URL: srv.URL + "/users/7", Assert: []string{"status == 200", ".id == 8"}, OnFailure: "continue"},
+			{Name: "missing", Method: "GET", URL: srv.URL + "/missing", OnFailure: "continue"},
+		},
+	}
+	rep := &Report{Workflow: f.Name}
+	err := run(context.Background(), f, rep)
+	rep.finish(err)
+
+	if err != nil || rep.Passed || len(rep.Steps) != 2 {
+		t.Fatalf("report = %+v, err %v", rep, err)
+	}
+	get := rep.Steps[0]
+	if get.Passed || get.Response == nil || string(get.Response.Body) != `{"id":7}` {
+		t.Errorf("step get = %+v", get)
+	}
+	if len(get.Assertions) != 2 || !get.Assertions[0].Passed || get.Assertions[1].Passed || get.Assertions[1].Expr != ".id == 8" {
+		t.Errorf("assertions = %+v", get.Assertions)
+	}
+	if missing := rep.Steps[1]; missing.Passed || missing.Response.Status != 404 {
+		t.Errorf("step missing = %+v", missing)
+	}
+}
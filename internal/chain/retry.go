@@ -0,0 +1,68 @@
+package chain
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/retry"
+)
+
+// RetrySpec configures retries for a step, or for every step when set on
+// the flow. Durations use Go syntax (500ms, 2s, 1m).
+type RetrySpec struct {
+	Retries        int           `yaml:"retries"`
+	On             string        `yaml:"on,omitempty"` // same syntax as --retry-on
+	Delay          time.Duration `yaml:"delay,omitempty"`
+	MaxDelay       time.Duration `yaml:"max_delay,omitempty"`
+	MaxWait        time.Duration `yaml:"max_wait,omitempty"` // cap for Retry-After and RateLimit-Reset waits
+	Jitter         string        `yaml:"jitter,omitempty"`
+	Budget         time.Duration `yaml:"budget,omitempty"`
+	IdempotencyKey string        `yaml:"idempotency_key,omitempty"` // "auto" or a fixed key
+}
+
+func (s *RetrySpec) apply(r *httpclient.Request) {
+	if s == nil {
+		return
+	}
+	r.RetryCount = s.Retries
+	r.RetryCondition = s.On
+	r.Retry = retry.Options{
+		Base:           s.Delay,
+		Max:            s.MaxDelay,
+		MaxWait:        s.MaxWait,
+		Jitter:         s.Jitter,
+		Budget:         s.Budget,
+		IdempotencyKey: s.IdempotencyKey,
+	}
+}
+
+// printAttempts summarises a step's attempts when it was retried or a retry
+// was refused, e.g. "503 → wait 1.2s (backoff) → 200".
+func printAttempts(w io.Writer, attempts []retry.Attempt) {
+	if len(attempts) == 0 {
+		return
+	}
+	last := attempts[len(attempts)-1]
+	if len(attempts) == 1 && last.Reason == "" {
+		return
+	}
+
+	parts := make([]string, 0, len(attempts))
+	for i, a := range attempts {
+		outcome := fmt.Sprint(a.Status)
+		if a.Err != nil {
+			outcome = "error"
+		}
+		if i < len(attempts)-1 {
+			outcome += fmt.Sprintf(" → wait %s (%s)", a.Wait.Round(time.Millisecond), a.Reason)
+		}
+		parts = append(parts, outcome)
+	}
+	fmt.Fprintf(w, "🔁 Attempts: %s\n", strings.Join(parts, " → "))
+	if last.Reason != "" {
+		fmt.Fprintf(w, "   not retried: %s\n", last.Reason)
+	}
+}
@@ -0,0 +1,52 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/retry"
+)
+
+func TestRetrySpec_YAML(t *testing.T) {
+	var flow Flow
+	err := yaml.Unmarshal([]byte(`
+retry: {retries: 2, on: 5xx}
+steps:
+  - name: create
+    method: POST
+    url: /orders
+    retry:
+      retries: 4
+      on: 429,5xx
+      delay: 250ms
+      max_delay: 5s
+      jitter: equal
+      budget: 30s
+      idempotency_key: auto
+`), &flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req httpclient.Request
+	flow.Retry.apply(&req)
+	if req.RetryCount != 2 || req.RetryCondition != "5xx" {
+		t.Errorf("flow retry = %d %q", req.RetryCount, req.RetryCondition)
+	}
+
+	flow.Steps[0].Retry.apply(&req)
+	want := retry.Options{Base: 250 * time.Millisecond, Max: 5 * time.Second, Jitter: "equal", Budget: 30 * time.Second, IdempotencyKey: "auto"}
+	if req.RetryCount != 4 || req.RetryCondition != "429,5xx" || req.Retry != want {
+		t.Errorf("step retry = %d %q %+v", req.RetryCount, req.RetryCondition, req.Retry)
+	}
+
+	// a step without its own block keeps the request untouched
+	before := req
+	(*RetrySpec)(nil).apply(&req)
+	if req.RetryCount != before.RetryCount || req.Retry != before.Retry {
+		t.Error("nil spec changed the request")
+	}
+}
@@ -5,11 +5,17 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/humancto/mozzy/internal/assertions"
+	"github.com/humancto/mozzy/internal/auth"
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/envelope"
 	"github.com/humancto/mozzy/internal/vars"
 	"github.com/humancto/mozzy/internal/httpclient"
 	"github.com/humancto/mozzy/internal/formatter"
+	"github.com/humancto/mozzy/internal/payload"
+	"github.com/humancto/mozzy/internal/retry"
 )
 
 type Step struct {
@@ -19,157 +25,364 @@ type Step struct {
 	Headers   map[string]string `yaml:"headers,omitempty"`
 	JSON      any               `yaml:"json,omitempty"`
 	File      string            `yaml:"file,omitempty"`
+	Form      map[string]string `yaml:"form,omitempty"`      // url-encoded fields
+	Multipart []payload.Part    `yaml:"multipart,omitempty"` // multipart/form-data parts
 	Capture   map[string]string `yaml:"capture,omitempty"`
 	Assert    []string          `yaml:"assert,omitempty"`
 	OnSuccess string            `yaml:"on_success,omitempty"` // Step name or "continue" (default) or "stop"
 	OnFailure string            `yaml:"on_failure,omitempty"` // Step name or "stop" (default) or "continue"
+	Stream    *StreamSpec       `yaml:"stream,omitempty"`     // read the response as SSE/NDJSON events
+	Parallel  *Parallel         `yaml:"parallel,omitempty"`   // run a block of steps concurrently instead of a request
+
+	If          string `yaml:"if,omitempty"`           // skip the step unless this condition holds
+	Foreach     string `yaml:"foreach,omitempty"`      // send the request once per element of a JSON array variable
+	As          string `yaml:"as,omitempty"`           // foreach element variable; default item
+	RepeatUntil *Poll  `yaml:"repeat_until,omitempty"` // resend the request until this condition holds
+
+	Use  string            `yaml:"use,omitempty"`  // run another flow file, or an included one by name, as this step
+	With map[string]string `yaml:"with,omitempty"` // params for the used flow
+	sub  *Flow
+
+	FollowRedirects *bool        `yaml:"follow_redirects,omitempty"` // false returns the 3xx response itself
+	MaxRedirects    int          `yaml:"max_redirects,omitempty"`
+	Retry           *RetrySpec   `yaml:"retry,omitempty"` // overrides the flow's retry policy
+	Auth            *auth.Config `yaml:"auth,omitempty"`  // overrides the flow's auth
 }
 
 type Flow struct {
-	Name        string `yaml:"name"`
-	Description string `yaml:"description,omitempty"`
-	Env         string `yaml:"env"`
-	Steps       []Step `yaml:"steps"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description,omitempty"`
+	Env         string       `yaml:"env"`
+	CookieJar   string       `yaml:"cookie_jar,omitempty"` // cookies.txt or .json file shared by all steps
+	Retry       *RetrySpec   `yaml:"retry,omitempty"`      // default retry policy for every step; --retry overrides it
+	Auth        *auth.Config `yaml:"auth,omitempty"`       // default auth for every step; --auth-type overrides it
+	Setup       []Step       `yaml:"setup,omitempty"`      // run before the steps; a failure skips them
+	Steps       []Step       `yaml:"steps"`
+	Teardown    []Step       `yaml:"teardown,omitempty"` // always run last, even after a failure or cancellation
+	Data        *Data        `yaml:"data,omitempty"`     // run the steps once per row
+
+	Include  []string          `yaml:"include,omitempty"`  // flow files whose defaults apply here and that steps can use by name
+	Defaults *Defaults         `yaml:"defaults,omitempty"` // headers, base and assertions for every step
+	Params   map[string]string `yaml:"params,omitempty"`   // defaults for the with: values of steps using this flow
+	Outputs  []string          `yaml:"outputs,omitempty"`  // captures returned to a step using this flow; default all
+	included map[string]*Flow
 
 	// populated by cmd/run
-	EnvName    string `yaml:"-"`
-	BaseURL    string `yaml:"-"`
-	GlobalAuth string `yaml:"-"`
+	EnvName    string                     `yaml:"-"`
+	BaseURL    string                     `yaml:"-"`
+	GlobalAuth string                     `yaml:"-"`
+	TLS        httpclient.TLSOptions      `yaml:"-"`
+	Proxy      httpclient.ProxyOptions    `yaml:"-"`
+	Dial       httpclient.DialOptions     `yaml:"-"`
+	Redirect   httpclient.RedirectOptions `yaml:"-"`
+	Limits     httpclient.Limits          `yaml:"-"` // --rate and --breaker, layered over the environment's limits
+	Output     string                     `yaml:"-"` // json or yaml prints one Report instead of each step's output
+	Dir        string                     `yaml:"-"` // directory of the flow file; relative data files and includes are resolved against it
+	Path       string                     `yaml:"-"` // absolute path of the flow file, set by Load
 }
 
 func Run(ctx context.Context, f Flow) error {
-	base := vars.ResolveBase(f.BaseURL, firstNonEmpty(f.EnvName, f.Env))
+	rep := &Report{Workflow: f.Name}
+	err := run(ctx, f, rep)
+	if f.Output != "" && f.Output != envelope.Text {
+		rep.finish(err)
+		if werr := envelope.Write(os.Stdout, f.Output, rep); werr != nil {
+			return werr
+		}
+	}
+	if err == nil && rep.Teardown != nil && !rep.Teardown.Passed {
+		return fmt.Errorf("teardown: %s", rep.Teardown.Error)
+	}
+	return err
+}
 
+func run(ctx context.Context, f Flow, rep *Report) error {
+	if err := f.resolve(nil); err != nil {
+		return err
+	}
+	envName := firstNonEmpty(f.EnvName, f.Env)
+	profile, err := vars.ActivateProfile(envName)
+	if err != nil {
+		return err
+	}
+	hostLimits, err := profile.HostLimits()
+	if err != nil {
+		return err
+	}
+	r := &runner{
+		f:       f,
+		quiet:   f.Output != "" && f.Output != envelope.Text,
+		base:    config.BaseURL(profile, f.BaseURL, envName),
+		profile: profile,
+		guard:   httpclient.NewGuard(f.Limits, hostLimits),
+	}
+	r.defaults = f.Defaults.merge(Defaults{})
+
+	err = r.main(ctx, rep)
+	if len(f.Teardown) > 0 {
+		r.teardown(ctx, rep)
+	}
+	return err
+}
+
+// steps runs a list of steps in sc, following their on_success/on_failure
+// jumps, and returns the error of the failure that stopped it.
+func (r *runner) steps(ctx context.Context, steps []Step, sc *scope, out output, rep *Report) error {
 	// Build step name index for jumps
 	stepIndex := make(map[string]int)
-	for i, s := range f.Steps {
+	for i, s := range steps {
 		stepIndex[s.Name] = i
 	}
 
+	var last *assertions.Response
 	i := 0
-	for i < len(f.Steps) {
-		s := f.Steps[i]
-		stepSuccess := true
-
-		method := strings.ToUpper(s.Method)
-		url := s.URL
-		if base != "" && strings.HasPrefix(url, "/") {
-			url = strings.TrimRight(base, "/") + url
+	for i < len(steps) {
+		s := steps[i]
+		label := fmt.Sprintf("Step %d/%d: %s", i+1, len(steps), s.Name)
+		res := r.do(ctx, s, label, sc, out, rep, last)
+		rep.add(res.reports...)
+		sc.set(res.captures)
+		if res.response != nil {
+			last = res.response
+		}
+		if res.skipped {
+			i++
+			continue
 		}
-		url = vars.Interpolate(url)
 
-		// headers
-		hdrs := []string{}
-		for k, v := range s.Headers {
-			hdrs = append(hdrs, fmt.Sprintf("%s: %s", k, vars.Interpolate(v)))
+		// Handle conditional execution
+		nextStep := handleStepResult(i, s, res.ok, stepIndex)
+		if nextStep < 0 {
+			if !res.ok {
+				return res.err
+			}
+			break // Stop execution
 		}
-		token := "" // prefer explicit header if provided
-		if f.GlobalAuth != "" && !hasAuthHeader(hdrs) {
-			token = vars.Interpolate(f.GlobalAuth)
+		i = nextStep
+	}
+	return nil
+}
+
+// runner holds what every step of a flow shares.
+type runner struct {
+	f       Flow
+	quiet   bool
+	base    string
+	profile *config.Profile
+	guard   *httpclient.Guard
+
+	defaults Defaults          // of the flow whose steps are running
+	rows     map[string]string // the data rows' captures as rows, for teardown
+}
+
+// stepResult is the outcome of one step. Captures are returned rather than
+// set so that steps running in parallel can't see each other's.
+type stepResult struct {
+	reports  []*StepReport // one per request sent
+	captures map[string]string
+	response *assertions.Response // the last response, for later if: conditions
+	ok       bool
+	skipped  bool  // its if: condition didn't hold
+	err      error // returned by Run when the failure stops the flow
+}
+
+// step sends one step's request with sc's variables, printing to out, then
+// runs its captures and assertions.
+func (r *runner) step(ctx context.Context, s Step, label string, sc *scope, out output) stepResult {
+	f := r.f
+	method := strings.ToUpper(s.Method)
+	url := s.URL
+	base := r.base
+	if r.defaults.Base != "" && f.BaseURL == "" && firstNonEmpty(f.EnvName, f.Env) == "" {
+		base = r.defaults.Base // --base and a chosen environment win over the flow's
+	}
+	if base != "" && strings.HasPrefix(url, "/") {
+		url = strings.TrimRight(base, "/") + url
+	}
+	url = sc.interpolate(url)
+
+	// headers
+	hdrs := []string{}
+	for k, v := range s.Headers {
+		hdrs = append(hdrs, fmt.Sprintf("%s: %s", k, sc.interpolate(v)))
+	}
+	for k, v := range r.defaults.Headers {
+		if !hasHeader(hdrs, k) {
+			hdrs = append(hdrs, fmt.Sprintf("%s: %s", k, sc.interpolate(v)))
 		}
+	}
+	token := "" // prefer explicit header if provided
+	var stepAuth auth.Config
+	switch {
+	case s.Auth != nil:
+		stepAuth = s.Auth.Interpolate(sc.interpolate)
+	case hasAuthHeader(hdrs):
+	case f.Auth != nil:
+		stepAuth = f.Auth.Interpolate(sc.interpolate)
+	case f.GlobalAuth != "":
+		token = sc.interpolate(f.GlobalAuth)
+	}
 
-		// body
-		var body []byte
-		var isJSON bool
-		switch {
-		case s.File != "":
-			b, err := os.ReadFile(s.File)
-			if err != nil {
-				stepSuccess = false
-				if nextStep := handleStepResult(i, s, false, stepIndex); nextStep >= 0 {
-					i = nextStep
-					continue
-				}
-				return err
+	// body
+	body, contentType, err := stepBody(s, sc.interpolate)
+	if err != nil {
+		return stepResult{err: err}
+	}
+	if contentType != "" && !hasHeader(hdrs, "Content-Type") {
+		hdrs = append([]string{"Content-Type: " + contentType}, hdrs...)
+	}
+
+	req := httpclient.Request{
+		Method:    method,
+		URL:       url,
+		Headers:   hdrs,
+		Token:     token,
+		Body:      body,
+		CookieJar: f.CookieJar,
+		TLS:       f.TLS,
+		Proxy:     f.Proxy,
+		Dial:      f.Dial,
+		Redirect:  f.Redirect,
+		Log:       out.stderr, // buffered with the rest of the step's output in parallel blocks
+	}
+	if s.FollowRedirects != nil {
+		req.Redirect.NoFollow = !*s.FollowRedirects
+	}
+	if s.MaxRedirects > 0 {
+		req.Redirect.Max = s.MaxRedirects
+	}
+	req.Guard = r.guard
+	req.Auth = stepAuth
+	f.Retry.apply(&req)
+	s.Retry.apply(&req)
+	var attempts []retry.Attempt
+	req.OnAttempt = func(a retry.Attempt) { attempts = append(attempts, a) }
+	r.profile.Apply(&req, sc.interpolate)
+
+	res := stepResult{}
+	var resp assertions.Response
+	var report *StepReport
+	fmt.Fprintf(out.stderr, "\n📋 %s\n", label)
+	if s.Stream != nil {
+		spec := *s.Stream
+		spec.LastEventID = sc.interpolate(spec.LastEventID)
+		status, body, ms, err := runStream(ctx, req, spec, r.quiet, out)
+		report = &StepReport{Name: s.Name, Envelope: streamEnvelope(req, status, body, ms, err)}
+		res.reports = []*StepReport{report}
+		if err != nil {
+			fmt.Fprintf(out.stderr, "❌ Stream failed: %v\n", err)
+			res.err = err
+			return res
+		}
+		resp = assertions.Response{Status: status, Body: body, Duration: ms, URL: url}
+	} else {
+		var result *httpclient.Result
+		var pollErr error
+		started := time.Now()
+		for poll := 1; ; poll++ {
+			attempts = attempts[:0]
+			result, err = httpclient.Send(ctx, req)
+			if err != nil || s.RepeatUntil == nil {
+				break
 			}
-			body = b
-		case s.JSON != nil:
-			b, err := formatter.MarshalJSON(s.JSON)
-			if err != nil {
-				stepSuccess = false
-				if nextStep := handleStepResult(i, s, false, stepIndex); nextStep >= 0 {
-					i = nextStep
-					continue
-				}
-				return err
+			again, werr := s.RepeatUntil.wait(ctx, sc, response(result), poll, started, out)
+			if werr != nil {
+				pollErr = werr
 			}
-			body = b
-			isJSON = true
-		}
-
-		res, resBody, ms, err := httpclient.Do(ctx, httpclient.Request{
-			Method:  method,
-			URL:     url,
-			Headers: hdrs,
-			Token:   token,
-			Body:    body,
-			JSON:    isJSON,
-		})
-		if err != nil {
-			stepSuccess = false
-			fmt.Fprintf(os.Stderr, "\n📋 Step %d/%d: %s\n", i+1, len(f.Steps), s.Name)
-			fmt.Fprintf(os.Stderr, "❌ Request failed: %v\n", err)
-			if nextStep := handleStepResult(i, s, false, stepIndex); nextStep >= 0 {
-				i = nextStep
-				continue
+			if werr != nil || !again {
+				break
 			}
-			return err
+			result.Response.Body.Close()
 		}
-		defer res.Body.Close()
-
-		fmt.Fprintf(os.Stderr, "\n📋 Step %d/%d: %s\n", i+1, len(f.Steps), s.Name)
-		formatter.PrintStatusLine(method, url, res.StatusCode, ms)
-		if err := formatter.PrintJSONOrText(resBody, ""); err != nil { return err }
-
-		// Check HTTP status
-		if res.StatusCode >= 400 {
-			stepSuccess = false
+		report = &StepReport{Name: s.Name, Envelope: envelope.New(req, result, err)}
+		res.reports = []*StepReport{report}
+		if err != nil {
+			printAttempts(out.stderr, attempts)
+			fmt.Fprintf(out.stderr, "❌ Request failed: %v\n", err)
+			res.err = err
+			return res
 		}
+		defer result.Response.Body.Close()
 
-		// captures
-		for name, path := range s.Capture {
-			spec := fmt.Sprintf("%s=%s", name, path)
-			if err := vars.Capture(resBody, spec); err != nil {
-				fmt.Fprintf(os.Stderr, "warn: capture failed %q: %v\n", spec, err)
+		printAttempts(out.stderr, attempts)
+		resp = response(result)
+		if !r.quiet {
+			formatter.FprintStatusLine(out.stdout, method, url, resp.Status, resp.Duration)
+			if err := formatter.FprintBody(out.stdout, resp.Body, result.Response.Header.Get("Content-Type"), ""); err != nil {
+				res.err = err
+				return res
 			}
 		}
+		if pollErr != nil {
+			fmt.Fprintf(out.stderr, "❌ %v\n", pollErr)
+			res.response = &resp
+			res.err = pollErr
+			return res
+		}
+	}
+	res.response = &resp
+
+	// Check HTTP status
+	res.ok = resp.Status < 400
+
+	// captures
+	res.captures = make(map[string]string, len(s.Capture))
+	for name, path := range s.Capture {
+		spec := fmt.Sprintf("%s=%s", name, path)
+		name, value, err := vars.Extract(resp.Body, spec)
+		if err != nil {
+			fmt.Fprintf(out.stderr, "warn: capture failed %q: %v\n", spec, err)
+			continue
+		}
+		res.captures[name] = value
+	}
 
-		// assertions
-		if len(s.Assert) > 0 {
-			fmt.Fprintf(os.Stderr, "\n🧪 Running assertions...\n")
-			allPassed := true
-			for _, assertExpr := range s.Assert {
-				result, err := assertions.Evaluate(assertExpr, res.StatusCode, resBody, ms)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "  ⚠️  Error: %v\n", err)
-					allPassed = false
-					continue
-				}
-				fmt.Fprintf(os.Stderr, "  %s\n", result.Message)
-				if !result.Passed {
-					allPassed = false
-				}
+	// assertions
+	if asserts := append(append([]string{}, r.defaults.Assert...), s.Assert...); len(asserts) > 0 {
+		fmt.Fprintf(out.stderr, "\n🧪 Running assertions...\n")
+		allPassed := true
+		check := resp
+		check.Vars = newScope(sc, res.captures).lookup // this step's captures too
+		for _, assertExpr := range asserts {
+			result, err := assertions.Check(sc.interpolate(assertExpr), check)
+			if err != nil {
+				fmt.Fprintf(out.stderr, "  ⚠️  Error: %v\n", err)
+				report.Assertions = append(report.Assertions, AssertionReport{Expr: assertExpr, Message: err.Error()})
+				allPassed = false
+				continue
 			}
-			if !allPassed {
-				stepSuccess = false
-				fmt.Fprintf(os.Stderr, "❌ Assertions failed\n")
-				if nextStep := handleStepResult(i, s, false, stepIndex); nextStep >= 0 {
-					i = nextStep
-					continue
-				}
-				return fmt.Errorf("❌ assertions failed for step: %s", s.Name)
+			fmt.Fprintf(out.stderr, "  %s\n", result.Message)
+			report.Assertions = append(report.Assertions, AssertionReport{Expr: assertExpr, Passed: result.Passed, Message: result.Message})
+			if !result.Passed {
+				allPassed = false
 			}
-			fmt.Fprintf(os.Stderr, "✅ All assertions passed\n")
 		}
-
-		// Handle conditional execution
-		nextStep := handleStepResult(i, s, stepSuccess, stepIndex)
-		if nextStep < 0 {
-			break // Stop execution
+		if !allPassed {
+			fmt.Fprintf(out.stderr, "❌ Assertions failed\n")
+			res.ok = false
+			res.err = fmt.Errorf("❌ assertions failed for step: %s", s.Name)
+			return res
 		}
-		i = nextStep
+		fmt.Fprintf(out.stderr, "✅ All assertions passed\n")
 	}
-	return nil
+	report.Passed = res.ok
+	return res
+}
+
+// response is what assertions and conditions see of a response.
+func response(result *httpclient.Result) assertions.Response {
+	res := result.Response
+	r := assertions.Response{
+		Status:   res.StatusCode,
+		Body:     result.Body,
+		Duration: result.Timings.Total,
+		URL:      res.Request.URL.String(),
+	}
+	hops := httpclient.RedirectChain(res)
+	for _, h := range hops[:len(hops)-1] {
+		r.Redirects = append(r.Redirects, assertions.Redirect{Status: h.Status, URL: h.URL, Location: h.Location})
+	}
+	return r
 }
 
 // handleStepResult determines the next step based on success/failure and on_success/on_failure
@@ -204,8 +417,13 @@ func handleStepResult(currentIndex int, step Step, success bool, stepIndex map[s
 }
 
 func hasAuthHeader(h []string) bool {
+	return hasHeader(h, "Authorization")
+}
+
+func hasHeader(h []string, name string) bool {
+	prefix := strings.ToLower(name) + ":"
 	for _, v := range h {
-		if strings.HasPrefix(strings.ToLower(v), "authorization:") { return true }
+		if strings.HasPrefix(strings.ToLower(v), prefix) { return true }
 	}
 	return false
 }
@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/humancto/mozzy/internal/vars"
+)
+
+// scope holds variables local to part of a run, such as one data row's
+// iteration, so its captures don't leak into other rows. Lookups fall back
+// to the enclosing scope and finally the process-wide variables; a nil scope
+// is the process-wide one.
+type scope struct {
+	parent *scope
+	mu     sync.RWMutex
+	vars   map[string]string
+}
+
+func newScope(parent *scope, vars map[string]string) *scope {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	return &scope{parent: parent, vars: vars}
+}
+
+func (s *scope) lookup(name string) (string, bool) {
+	for ; s != nil; s = s.parent {
+		s.mu.RLock()
+		v, ok := s.vars[name]
+		s.mu.RUnlock()
+		if ok {
+			return v, true
+		}
+	}
+	return vars.Lookup(name)
+}
+
+func (s *scope) interpolate(str string) string {
+	return vars.Expand(str, s.lookup)
+}
+
+// set makes captured values available to the following steps.
+func (s *scope) set(captures map[string]string) {
+	if s == nil {
+		for name, value := range captures {
+			vars.Set(name, value)
+		}
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, value := range captures {
+		s.vars[name] = value
+	}
+}
@@ -0,0 +1,58 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// main runs the setup steps, then the steps, once per data row if the flow
+// has data.
+func (r *runner) main(ctx context.Context, rep *Report) error {
+	if len(r.f.Setup) > 0 {
+		fmt.Fprintf(os.Stderr, "\n🔧 Setup\n")
+		if err := r.steps(ctx, r.f.Setup, nil, terminal, rep); err != nil {
+			return fmt.Errorf("setup: %w", err)
+		}
+	}
+	if r.f.Data != nil {
+		return r.iterate(ctx, rep)
+	}
+	return r.steps(ctx, r.f.Steps, nil, terminal, rep)
+}
+
+// teardown runs the teardown steps with the variables captured so far,
+// including the data rows' as rows, recording their result in rep.Teardown.
+// It isn't cancelled with ctx, so it still cleans up after Ctrl+C, and a
+// failing step doesn't stop the ones after it unless its on_failure says so.
+func (r *runner) teardown(ctx context.Context, rep *Report) {
+	fmt.Fprintf(os.Stderr, "\n🧹 Teardown\n")
+	steps := make([]Step, len(r.f.Teardown))
+	for i, s := range r.f.Teardown {
+		if s.OnFailure == "" {
+			s.OnFailure = "continue"
+		}
+		steps[i] = s
+	}
+	var sc *scope
+	if r.rows != nil {
+		sc = newScope(nil, r.rows)
+	}
+	t := &Report{}
+	err := r.steps(context.WithoutCancel(ctx), steps, sc, terminal, t)
+	t.finish(err)
+	rep.Teardown = &TeardownReport{Passed: t.Passed, Steps: t.Steps, Error: t.Error}
+	if t.Passed {
+		return
+	}
+	if err == nil {
+		failed := 0
+		for _, s := range t.Steps {
+			if !s.Passed {
+				failed++
+			}
+		}
+		rep.Teardown.Error = fmt.Sprintf("%d of %d steps failed", failed, len(t.Steps))
+	}
+	fmt.Fprintf(os.Stderr, "\n⚠️  Teardown failed: %s\n", rep.Teardown.Error)
+}
@@ -0,0 +1,153 @@
+package chain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func teardownServer(t *testing.T) (*httptest.Server, func() string) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/slow":
+			<-r.Context().Done()
+			return
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(`{"id": 5}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(seen, ", ")
+	}
+}
+
+func TestRun_Teardown(t *testing.T) {
+	srv, seen := teardownServer(t)
+	ok := []string{"status == 200"}
+	f := Flow{Output: "json",
+		Setup: []Step{{Name: "create", Method: "POST", URL: srv.URL + "/things", Capture: map[string]string{"td_id": ".id"}}},
+		Steps: []Step{
+			{Name: "get", Method: "GET", URL: srv.URL + "/things/{{td_id}}", Assert: ok},
+			{Name: "fail", Method: "GET", URL: srv.URL + "/missing", Assert: ok},
+			{Name: "never", Method: "GET", URL: srv.URL + "/never"},
+		},
+		Teardown: []Step{
+			{Name: "broken", Method: "GET", URL: srv.URL + "/broken", Assert: ok},
+			{Name: "delete", Method: "DELETE", URL: srv.URL + "/things/{{td_id}}", Assert: ok},
+		},
+	}
+	rep := &Report{}
+	err := run(context.Background(), f, rep)
+	if err == nil || !strings.HasSuffix(err.Error(), "step: fail") {
+		t.Errorf("err = %v", err)
+	}
+	if want := "POST /things, GET /things/5, GET /missing, GET /broken, DELETE /things/5"; seen() != want {
+		t.Errorf("requests: %s\nwant: %s", seen(), want)
+	}
+	td := rep.Teardown
+	if td == nil || td.Passed || len(td.Steps) != 2 || td.Error != "1 of 2 steps failed" {
+		t.Fatalf("teardown: %+v", td)
+	}
+	if len(rep.Steps) != 3 {
+		t.Errorf("setup and steps should be reported apart from teardown, got %d steps", len(rep.Steps))
+	}
+
+	// a failing teardown fails an otherwise passing run on its own terms
+	f.Output = ""
+	f.Steps = f.Steps[:1]
+	if err := Run(context.Background(), f); err == nil || err.Error() != "teardown: 1 of 2 steps failed" {
+		t.Errorf("Run err = %v", err)
+	}
+
+	// a failing setup skips the steps but not the teardown
+	srv2, seen2 := teardownServer(t)
+	f = Flow{Output: "json",
+		Setup:    []Step{{Name: "create", Method: "GET", URL: srv2.URL + "/missing", Assert: ok}},
+		Steps:    []Step{{Name: "get", Method: "GET", URL: srv2.URL + "/get"}},
+		Teardown: []Step{{Name: "delete", Method: "DELETE", URL: srv2.URL + "/things"}},
+	}
+	rep = &Report{}
+	if err := run(context.Background(), f, rep); err == nil || !strings.HasPrefix(err.Error(), "setup: ") {
+		t.Errorf("setup err = %v", err)
+	}
+	if want := "GET /missing, DELETE /things"; seen2() != want {
+		t.Errorf("requests: %s\nwant: %s", seen2(), want)
+	}
+	if !rep.Teardown.Passed {
+		t.Errorf("teardown: %+v", rep.Teardown)
+	}
+}
+
+func TestRun_TeardownAfterCancel(t *testing.T) {
+	srv, seen := teardownServer(t)
+	f := Flow{Output: "json",
+		Steps:    []Step{{Name: "slow", Method: "GET", URL: srv.URL + "/slow"}},
+		Teardown: []Step{{Name: "delete", Method: "DELETE", URL: srv.URL + "/things"}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rep := &Report{}
+	if err := run(ctx, f, rep); err == nil {
+		t.Error("a cancelled run should fail")
+	}
+	if want := "GET /slow, DELETE /things"; seen() != want {
+		t.Errorf("requests: %s\nwant: %s", seen(), want)
+	}
+	if rep.Teardown == nil || !rep.Teardown.Passed {
+		t.Errorf("teardown should run after cancellation: %+v", rep.Teardown)
+	}
+}
+
+func TestRun_TeardownSeesRowCaptures(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/check/b" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(`{"id": "` + path.Base(r.URL.Path) + `"}`))
+	}))
+	defer srv.Close()
+
+	f := Flow{Output: "json",
+		Data: &Data{Rows: []map[string]any{{"user": "a"}, {"user": "b"}, {"user": "c"}}},
+		Steps: []Step{
+			{Name: "create", Method: "POST", URL: srv.URL + "/things/{{row.user}}", Capture: map[string]string{"thing": ".id"}},
+			{Name: "check", Method: "GET", URL: srv.URL + "/check/{{row.user}}", Assert: []string{"status == 200"}},
+		},
+		Teardown: []Step{
+			{Name: "delete", Method: "DELETE", URL: srv.URL + "/things/{{item.thing}}?row={{item.row}}", Foreach: "rows"},
+			{Name: "first", Method: "GET", URL: srv.URL + "/first/{{rows.0.thing}}"},
+		},
+	}
+	rep := &Report{}
+	if err := run(context.Background(), f, rep); err == nil || !strings.HasPrefix(err.Error(), "row 2: ") {
+		t.Errorf("err = %v", err)
+	}
+	want := "POST /things/a, GET /check/a, POST /things/b, GET /check/b, DELETE /things/a, DELETE /things/b, GET /first/a"
+	if got := strings.Join(seen, ", "); got != want {
+		t.Errorf("requests: %s\nwant: %s", got, want)
+	}
+	if rep.Teardown == nil || !rep.Teardown.Passed {
+		t.Errorf("teardown: %+v", rep.Teardown)
+	}
+}
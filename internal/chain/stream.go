@@ -0,0 +1,104 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humancto/mozzy/internal/formatter"
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/stream"
+)
+
+// StreamSpec makes a step read its response as a stream of events. Captures
+// and assertions then see the events as a JSON array of their payloads, e.g.
+// `.[-1].status == "done"` or `length >= 3`.
+type StreamSpec struct {
+	MaxEvents   int    `yaml:"max_events,omitempty"`
+	Until       string `yaml:"until,omitempty"`         // jq expression evaluated per event
+	Reconnect   bool   `yaml:"reconnect,omitempty"`     // resume closed SSE streams with Last-Event-ID
+	LastEventID string `yaml:"last_event_id,omitempty"` // interpolated
+	Timeout     string `yaml:"timeout,omitempty"`       // stop listening after this long, e.g. 10s
+}
+
+// UnmarshalYAML also accepts `stream: true`.
+func (s *StreamSpec) UnmarshalYAML(n *yaml.Node) error {
+	var on bool
+	if n.Kind == yaml.ScalarNode && n.Decode(&on) == nil {
+		if !on {
+			return fmt.Errorf("stream: false is not supported; omit the key instead")
+		}
+		*s = StreamSpec{}
+		return nil
+	}
+	type plain StreamSpec
+	var v plain
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	*s = StreamSpec(v)
+	return nil
+}
+
+// runStream sends req and prints events to out as they arrive, unless quiet. It
+// returns the status of the last response, the events as a JSON array and
+// the time spent streaming. Reaching the timeout ends the stream without an
+// error.
+func runStream(ctx context.Context, req httpclient.Request, spec StreamSpec, quiet bool, out output) (int, []byte, time.Duration, error) {
+	if spec.Timeout != "" {
+		d, err := time.ParseDuration(spec.Timeout)
+		if err != nil {
+			return 0, nil, 0, fmt.Errorf("invalid stream timeout %q: %w", spec.Timeout, err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	opts := stream.Options{
+		MaxEvents:   spec.MaxEvents,
+		Until:       spec.Until,
+		Reconnect:   spec.Reconnect,
+		LastEventID: spec.LastEventID,
+	}
+	open := func(ctx context.Context, lastEventID string, attempt int) (*http.Response, error) {
+		r := req
+		if lastEventID != "" {
+			r.Headers = append(append([]string{}, req.Headers...), "Last-Event-ID: "+lastEventID)
+		}
+		if attempt > 0 {
+			fmt.Fprintf(out.stderr, "🔄 Reconnecting (Last-Event-ID: %s)...\n", lastEventID)
+		}
+		res, ms, err := httpclient.Open(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if attempt == 0 && !quiet {
+			formatter.FprintStatusLine(out.stdout, r.Method, r.URL, res.StatusCode, ms)
+		}
+		return res, nil
+	}
+
+	start := time.Now()
+	values := []any{}
+	result, err := stream.Run(ctx, opts, open, func(ev stream.Event) error {
+		values = append(values, ev.Value())
+		if quiet {
+			return nil
+		}
+		return formatter.FprintEvent(out.stdout, ev, "")
+	})
+	elapsed := time.Since(start)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return result.Status, nil, elapsed, err
+	}
+	fmt.Fprintf(out.stderr, "📡 %d event(s) in %s\n", result.Events, elapsed.Round(time.Millisecond))
+
+	body, err := json.Marshal(values)
+	return result.Status, body, elapsed, err
+}
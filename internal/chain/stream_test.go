@@ -0,0 +1,65 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/humancto/mozzy/internal/httpclient"
+)
+
+func TestStreamSpec_YAML(t *testing.T) {
+	var flow Flow
+	err := yaml.Unmarshal([]byte(`
+steps:
+  - name: short
+    url: /events
+    stream: true
+  - name: long
+    url: /events
+    stream: {max_events: 2, until: '.done', timeout: 5s}
+`), &flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.Steps[0].Stream == nil || *flow.Steps[0].Stream != (StreamSpec{}) {
+		t.Errorf("stream: true = %+v", flow.Steps[0].Stream)
+	}
+	want := StreamSpec{MaxEvents: 2, Until: ".done", Timeout: "5s"}
+	if flow.Steps[1].Stream == nil || *flow.Steps[1].Stream != want {
+		t.Errorf("stream block = %+v, want %+v", flow.Steps[1].Stream, want)
+	}
+
+	if err := yaml.Unmarshal([]byte("steps: [{name: x, stream: false}]"), &flow); err == nil {
+		t.Error("expected an error for stream: false")
+	}
+}
+
+func TestRunStream_CollectsEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "id: %d\ndata: {\"n\":%d}\n\n", i, i)
+		}
+		fmt.Fprint(w, "data: bye\n\n")
+	}))
+	defer srv.Close()
+
+	req := httpclient.Request{Method: "GET", URL: srv.URL}
+	status, body, _, err := runStream(context.Background(), req, StreamSpec{Until: ".n == 3"}, false, terminal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 200 || string(body) != `[{"n":1},{"n":2},{"n":3}]` {
+		t.Errorf("status %d, body %s", status, body)
+	}
+
+	_, body, _, err = runStream(context.Background(), req, StreamSpec{}, false, terminal)
+	if err != nil || string(body) != `[{"n":1},{"n":2},{"n":3},"bye"]` {
+		t.Errorf("body %s, err %v", body, err)
+	}
+}
@@ -0,0 +1,166 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding lists every content coding Decode understands, for the
+// Accept-Encoding request header.
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// RequestEncodings are the codings --compress can apply to a request body.
+var RequestEncodings = []string{"gzip", "zstd"}
+
+// Codings splits a Content-Encoding header into its codings in the order
+// they were applied, leaving out identity.
+func Codings(header string) []string {
+	var out []string
+	for _, c := range strings.Split(header, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "" && c != "identity" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// NewReader returns a reader that undoes the codings of a Content-Encoding
+// header. The decoders are only created on the first Read, so wrapping a
+// stream does not block waiting for its first bytes.
+func NewReader(r io.Reader, header string) (io.Reader, error) {
+	codings := Codings(header)
+	for _, c := range codings {
+		if !supported(c) {
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", c)
+		}
+	}
+	if len(codings) == 0 {
+		return r, nil
+	}
+	return &lazyReader{src: r, codings: codings}, nil
+}
+
+// Decode undoes the codings of a Content-Encoding header on a whole body.
+// An empty body, e.g. of a HEAD request or a 204, is returned as is.
+func Decode(data []byte, header string) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	r, err := NewReader(bytes.NewReader(data), header)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Encode compresses a request body with the given coding.
+func Encode(data []byte, coding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch strings.ToLower(coding) {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("unsupported --compress %q (use %s)", coding, strings.Join(RequestEncodings, " or "))
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Ratio returns how much smaller the wire bytes are than the decoded ones,
+// as a percentage.
+func Ratio(wire, decoded int64) float64 {
+	if decoded <= 0 {
+		return 0
+	}
+	return (1 - float64(wire)/float64(decoded)) * 100
+}
+
+func supported(coding string) bool {
+	switch coding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+// decoder wraps r in the decoder for one coding.
+func decoder(r io.Reader, coding string) (io.Reader, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// deflate is meant to be zlib-wrapped, but some servers send a raw
+		// DEFLATE stream; the zlib header tells them apart
+		br := bufio.NewReader(r)
+		if head, err := br.Peek(2); err == nil && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 && head[0]&0x0f == 8 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported Content-Encoding %q", coding)
+}
+
+type lazyReader struct {
+	src     io.Reader
+	codings []string
+	r       io.Reader
+	err     error
+	closers []io.Closer
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.r == nil && l.err == nil {
+		// Codings are listed in the order applied, so undo them last first
+		r := l.src
+		for i := len(l.codings) - 1; i >= 0 && l.err == nil; i-- {
+			if r, l.err = decoder(r, l.codings[i]); l.err == nil {
+				if c, ok := r.(io.Closer); ok {
+					l.closers = append(l.closers, c)
+				}
+			}
+		}
+		l.r = r
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	if err == io.EOF {
+		// Release the decoders' buffers once the body is done
+		for _, c := range l.closers {
+			c.Close()
+		}
+		l.closers = nil
+	}
+	return n, err
+}
@@ -0,0 +1,105 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+var sample = []byte(strings.Repeat(`{"id":1,"name":"mozzy"}`, 100))
+
+func encodeBr(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := brotli.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	gz, _ := Encode(sample, "gzip")
+	zst, _ := Encode(sample, "zstd")
+	var zl, raw bytes.Buffer
+	zw := zlib.NewWriter(&zl)
+	zw.Write(sample)
+	zw.Close()
+	fw, _ := flate.NewWriter(&raw, flate.BestSpeed)
+	fw.Write(sample)
+	fw.Close()
+	// gzip applied first, then zstd on top
+	stacked, _ := Encode(gz, "zstd")
+
+	tests := []struct {
+		header string
+		data   []byte
+	}{
+		{"gzip", gz},
+		{"x-gzip", gz},
+		{"zstd", zst},
+		{"br", encodeBr(t, sample)},
+		{"deflate", zl.Bytes()},
+		{"deflate", raw.Bytes()},
+		{"gzip, zstd", stacked},
+		{"identity", sample},
+		{"", sample},
+	}
+	for _, tt := range tests {
+		got, err := Decode(tt.data, tt.header)
+		if err != nil || !bytes.Equal(got, sample) {
+			t.Errorf("Decode(%q) = %d bytes, %v; want the sample back", tt.header, len(got), err)
+		}
+	}
+
+	if _, err := Decode([]byte("x"), "compress"); err == nil {
+		t.Error("unknown coding should fail")
+	}
+	if got, err := Decode(nil, "gzip"); err != nil || len(got) != 0 {
+		t.Errorf("empty body = %q, %v", got, err)
+	}
+}
+
+func TestNewReader_Lazy(t *testing.T) {
+	// Wrapping must not read, so a stream isn't held up by its first event
+	r, err := NewReader(blockingReader{}, "gzip")
+	if err != nil || r == nil {
+		t.Fatalf("NewReader = %v, %v", r, err)
+	}
+	if _, err := NewReader(blockingReader{}, "snappy"); err == nil {
+		t.Error("unknown coding should fail up front")
+	}
+}
+
+type blockingReader struct{}
+
+func (blockingReader) Read([]byte) (int, error) { panic("read before first Read") }
+
+func TestEncode(t *testing.T) {
+	for _, c := range RequestEncodings {
+		enc, err := Encode(sample, c)
+		if err != nil || len(enc) >= len(sample) {
+			t.Fatalf("Encode(%s) = %d bytes, %v", c, len(enc), err)
+		}
+		r, _ := NewReader(bytes.NewReader(enc), c)
+		if got, _ := io.ReadAll(r); !bytes.Equal(got, sample) {
+			t.Errorf("%s round trip lost data", c)
+		}
+	}
+	if _, err := Encode(sample, "br"); err == nil {
+		t.Error("br request bodies are not supported")
+	}
+}
+
+func TestRatio(t *testing.T) {
+	if got := Ratio(25, 100); got != 75 {
+		t.Errorf("Ratio(25, 100) = %v, want 75", got)
+	}
+	if got := Ratio(10, 0); got != 0 {
+		t.Errorf("Ratio with nothing decoded = %v, want 0", got)
+	}
+}
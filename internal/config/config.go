@@ -0,0 +1,725 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/humancto/mozzy/internal/auth"
+	"github.com/humancto/mozzy/internal/breaker"
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/oauth"
+	"github.com/humancto/mozzy/internal/ratelimit"
+)
+
+// FileName is the project configuration file.
+const FileName = ".mozzy.json"
+
+// File is the parsed contents of .mozzy.json.
+type File struct {
+	DefaultEnv   string              `json:"default_env,omitempty"`
+	Environments map[string]*Profile `json:"environments,omitempty"`
+
+	path string
+}
+
+// Profile is an environment: a base URL plus the defaults applied to every
+// request made while it is active.
+type Profile struct {
+	Extends   string            `json:"extends,omitempty"`
+	BaseURL   string            `json:"base_url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	AuthToken string            `json:"auth_token,omitempty"`
+	Auth      *auth.Config      `json:"auth,omitempty"`  // any scheme; wins over oauth and auth_token
+	OAuth     *oauth.Config     `json:"oauth,omitempty"` // tokens obtained and refreshed automatically
+	TLS       *TLS              `json:"tls,omitempty"`
+	Proxy     *Proxy            `json:"proxy,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Limits    map[string]*Limit `json:"limits,omitempty"` // by "host", "host:port" or "*"
+
+	name string // environment the profile was resolved for
+}
+
+// TLS holds per-environment TLS settings. Relative file paths are resolved
+// against the directory containing .mozzy.json.
+type TLS struct {
+	Insecure     bool     `json:"insecure,omitempty"`
+	CACert       string   `json:"ca_cert,omitempty"`
+	Cert         string   `json:"cert,omitempty"` // PEM or PKCS#12 (.p12/.pfx)
+	Key          string   `json:"key,omitempty"`
+	CertPassword string   `json:"cert_password,omitempty"`
+	MinVersion   string   `json:"min_version,omitempty"`
+	MaxVersion   string   `json:"max_version,omitempty"`
+	Ciphers      []string `json:"ciphers,omitempty"`
+	ServerName   string   `json:"server_name,omitempty"`
+}
+
+func (t *TLS) empty() bool {
+	return !t.Insecure && t.CACert == "" && t.Cert == "" && t.Key == "" && t.CertPassword == "" &&
+		t.MinVersion == "" && t.MaxVersion == "" && len(t.Ciphers) == 0 && t.ServerName == ""
+}
+
+// Proxy routes an environment's requests through an HTTP(S) or SOCKS5 proxy.
+type Proxy struct {
+	URL     string `json:"url,omitempty"`
+	User    string `json:"user,omitempty"`     // "user:password", may reference {{vars}}
+	NoProxy string `json:"no_proxy,omitempty"` // comma-separated, like NO_PROXY
+}
+
+// Limit paces and guards the requests sent to one host.
+type Limit struct {
+	Rate            string `json:"rate,omitempty"`             // e.g. "20/s", "600/m"
+	Burst           int    `json:"burst,omitempty"`            // requests allowed back to back
+	Breaker         int    `json:"breaker,omitempty"`          // consecutive failures that open the circuit
+	BreakerCooldown string `json:"breaker_cooldown,omitempty"` // e.g. "30s"
+}
+
+// UnmarshalJSON also accepts a bare proxy URL string.
+func (x *Proxy) UnmarshalJSON(b []byte) error {
+	var u string
+	if err := json.Unmarshal(b, &u); err == nil {
+		*x = Proxy{URL: u}
+		return nil
+	}
+	type plain Proxy
+	var v plain
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*x = Proxy(v)
+	return nil
+}
+
+// UnmarshalJSON accepts both the object form and the legacy shorthand where
+// an environment is just its base URL string.
+func (p *Profile) UnmarshalJSON(b []byte) error {
+	var base string
+	if err := json.Unmarshal(b, &base); err == nil {
+		*p = Profile{BaseURL: base}
+		return nil
+	}
+	type plain Profile
+	var v plain
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*p = Profile(v)
+	return nil
+}
+
+// ProjectDir returns the nearest ancestor of the working directory that
+// contains .mozzy.json or a .mozzy/ folder, or the working directory itself.
+func ProjectDir() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	home, _ := os.UserHomeDir()
+	for dir := wd; ; {
+		// ~/.mozzy holds global history and collections, not project state
+		if dir != home {
+			if _, err := os.Stat(filepath.Join(dir, FileName)); err == nil {
+				return dir
+			}
+			if info, err := os.Stat(filepath.Join(dir, ".mozzy")); err == nil && info.IsDir() {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return wd
+		}
+		dir = parent
+	}
+}
+
+// Path returns the location of the project's .mozzy.json.
+func Path() string {
+	return filepath.Join(ProjectDir(), FileName)
+}
+
+// Load reads the project's .mozzy.json. A missing file yields an empty
+// config that can still be saved.
+func Load() (*File, error) {
+	return LoadFile(Path())
+}
+
+// LoadFile reads the config at path.
+func LoadFile(path string) (*File, error) {
+	f := &File{path: path, Environments: map[string]*Profile{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return f, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if f.Environments == nil {
+		f.Environments = map[string]*Profile{}
+	}
+	return f, nil
+}
+
+// Save writes the config back to the file it was loaded from.
+func (f *File) Save() error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, append(data, '\n'), 0o644)
+}
+
+// Path returns the file the config was loaded from.
+func (f *File) Path() string { return f.path }
+
+// Names returns the environment names in sorted order.
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Environments))
+	for name := range f.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ActiveName returns the environment in effect: cli if given, otherwise
+// default_env.
+func (f *File) ActiveName(cli string) string {
+	if cli != "" {
+		return cli
+	}
+	return f.DefaultEnv
+}
+
+// Resolve returns the named profile with its extends chain flattened: the
+// child's scalar settings win and header/variable maps are merged.
+func (f *File) Resolve(name string) (*Profile, error) {
+	p, err := f.resolve(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.name = name
+	return p, nil
+}
+
+func (f *File) resolve(name string, seen []string) (*Profile, error) {
+	for _, s := range seen {
+		if s == name {
+			return nil, fmt.Errorf("environment inheritance cycle: %s -> %s", strings.Join(seen, " -> "), name)
+		}
+	}
+	p, ok := f.Environments[name]
+	if !ok || p == nil {
+		if len(seen) > 0 {
+			return nil, fmt.Errorf("environment %q extends unknown environment %q", seen[len(seen)-1], name)
+		}
+		return nil, fmt.Errorf("environment %q not found in %s", name, FileName)
+	}
+
+	out := &Profile{}
+	if p.Extends != "" {
+		parent, err := f.resolve(p.Extends, append(seen, name))
+		if err != nil {
+			return nil, err
+		}
+		out = parent
+	}
+	out.merge(p, filepath.Dir(f.path))
+	out.Extends = ""
+	return out, nil
+}
+
+// merge overlays child onto p.
+func (p *Profile) merge(child *Profile, baseDir string) {
+	if child.BaseURL != "" {
+		p.BaseURL = child.BaseURL
+	}
+	if child.AuthToken != "" {
+		p.AuthToken = child.AuthToken
+	}
+	if child.Auth != nil {
+		// A scheme replaces the parent's as a unit, like a client certificate
+		a := *child.Auth
+		p.Auth = &a
+	}
+	if child.OAuth != nil {
+		o := *child.OAuth
+		p.OAuth = &o
+	}
+	p.Headers = mergeMap(p.Headers, child.Headers)
+	p.Variables = mergeMap(p.Variables, child.Variables)
+
+	for host, l := range child.Limits {
+		if p.Limits == nil {
+			p.Limits = map[string]*Limit{}
+		}
+		merged := Limit{}
+		if parent := p.Limits[host]; parent != nil {
+			merged = *parent
+		}
+		if l.Rate != "" {
+			merged.Rate = l.Rate
+		}
+		if l.Burst != 0 {
+			merged.Burst = l.Burst
+		}
+		if l.Breaker != 0 {
+			merged.Breaker = l.Breaker
+		}
+		if l.BreakerCooldown != "" {
+			merged.BreakerCooldown = l.BreakerCooldown
+		}
+		p.Limits[host] = &merged
+	}
+
+	if child.Proxy != nil {
+		if p.Proxy == nil {
+			p.Proxy = &Proxy{}
+		}
+		if child.Proxy.URL != "" {
+			// Credentials belong to the proxy they were given for
+			p.Proxy.URL = child.Proxy.URL
+			p.Proxy.User = child.Proxy.User
+		}
+		if child.Proxy.User != "" {
+			p.Proxy.User = child.Proxy.User
+		}
+		if child.Proxy.NoProxy != "" {
+			p.Proxy.NoProxy = child.Proxy.NoProxy
+		}
+	}
+
+	if child.TLS != nil {
+		if p.TLS == nil {
+			p.TLS = &TLS{}
+		}
+		t := child.TLS
+		p.TLS.Insecure = p.TLS.Insecure || t.Insecure
+		if t.CACert != "" {
+			p.TLS.CACert = resolvePath(baseDir, t.CACert)
+		}
+		if t.Cert != "" || t.Key != "" {
+			// A client certificate replaces the parent's as a unit
+			p.TLS.Cert = resolvePath(baseDir, t.Cert)
+			p.TLS.Key = resolvePath(baseDir, t.Key)
+			p.TLS.CertPassword = t.CertPassword
+		}
+		if t.MinVersion != "" {
+			p.TLS.MinVersion = t.MinVersion
+		}
+		if t.MaxVersion != "" {
+			p.TLS.MaxVersion = t.MaxVersion
+		}
+		if len(t.Ciphers) > 0 {
+			p.TLS.Ciphers = t.Ciphers
+		}
+		if t.ServerName != "" {
+			p.TLS.ServerName = t.ServerName
+		}
+	}
+}
+
+func mergeMap(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	out := make(map[string]string, len(dst)+len(src))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, v := range src {
+		out[k] = v
+	}
+	return out
+}
+
+func resolvePath(baseDir, p string) string {
+	if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "~") {
+		return p
+	}
+	return filepath.Join(baseDir, p)
+}
+
+// Active loads .mozzy.json and resolves the environment selected by cli (or
+// default_env). It returns a nil profile when no environment is selected.
+func Active(cli string) (*Profile, string, error) {
+	f, err := Load()
+	if err != nil {
+		return nil, "", err
+	}
+	name := f.ActiveName(cli)
+	if name == "" {
+		return nil, "", nil
+	}
+	p, err := f.Resolve(name)
+	if err != nil {
+		return nil, name, err
+	}
+	return p, name, nil
+}
+
+// BaseURL applies the --base/--env precedence: an explicitly selected
+// environment wins over --base, which wins over default_env.
+func BaseURL(p *Profile, cliBase, cliEnv string) string {
+	if cliEnv == "" && cliBase != "" {
+		return cliBase
+	}
+	if p != nil && p.BaseURL != "" {
+		return p.BaseURL
+	}
+	return cliBase
+}
+
+// HeaderLines returns the profile's headers as "Key: Value" lines, sorted by
+// key.
+func (p *Profile) HeaderLines() []string {
+	keys := make([]string, 0, len(p.Headers))
+	for k := range p.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+": "+p.Headers[k])
+	}
+	return lines
+}
+
+// Name returns the environment the profile was resolved for.
+func (p *Profile) Name() string {
+	if p == nil {
+		return ""
+	}
+	return p.name
+}
+
+// Apply fills in r's defaults from the profile. Settings already present on
+// r win: profile headers are placed before r's own (later headers override
+// earlier ones), the profile token is only used when r has none, and TLS
+// fields are only filled when unset. interpolate is applied to header values
+// and the token.
+func (p *Profile) Apply(r *httpclient.Request, interpolate func(string) string) {
+	if p == nil {
+		return
+	}
+	if interpolate == nil {
+		interpolate = func(s string) string { return s }
+	}
+
+	explicit := r.Token != "" || r.Auth.Type != ""
+	hdrs := make([]string, 0, len(p.Headers)+len(r.Headers))
+	for _, h := range p.HeaderLines() {
+		// An explicit token or scheme on the request beats a profile Authorization header
+		if explicit && hasHeader([]string{h}, "Authorization") {
+			continue
+		}
+		hdrs = append(hdrs, interpolate(h))
+	}
+	r.Headers = append(hdrs, r.Headers...)
+
+	p.ApplyTLS(&r.TLS, interpolate)
+	p.ApplyProxy(&r.Proxy, interpolate)
+
+	if !explicit && !hasHeader(r.Headers, "Authorization") {
+		if p.Auth != nil {
+			r.Auth = p.Auth.Interpolate(interpolate)
+		} else if p.OAuth != nil {
+			// If the transport can't be built, the request fails on the same
+			// error before it needs a token
+			src, err := p.TokenSource(interpolate, httpclient.TransportOptions{TLS: r.TLS, Proxy: r.Proxy, Dial: r.Dial})
+			if err == nil {
+				r.Scheme = src
+			}
+		} else if p.AuthToken != "" {
+			r.Token = interpolate(p.AuthToken)
+		}
+	}
+}
+
+// ApplyProxy fills proxy settings left unset in o from the profile. A proxy
+// given on the command line keeps its own credentials.
+func (p *Profile) ApplyProxy(o *httpclient.ProxyOptions, interpolate func(string) string) {
+	if p == nil || p.Proxy == nil {
+		return
+	}
+	if interpolate == nil {
+		interpolate = func(s string) string { return s }
+	}
+	if o.URL == "" && p.Proxy.URL != "" {
+		o.URL = interpolate(p.Proxy.URL)
+		if o.User == "" {
+			o.User = interpolate(p.Proxy.User)
+		}
+	}
+	if o.NoProxy == "" {
+		o.NoProxy = p.Proxy.NoProxy
+	}
+}
+
+// ApplyTLS fills TLS settings left unset in o from the profile. It is used
+// directly by commands that build their own transport (download, upload).
+func (p *Profile) ApplyTLS(o *httpclient.TLSOptions, interpolate func(string) string) {
+	if p == nil || p.TLS == nil {
+		return
+	}
+	if interpolate == nil {
+		interpolate = func(s string) string { return s }
+	}
+	o.Insecure = o.Insecure || p.TLS.Insecure
+	if o.CACert == "" {
+		o.CACert = p.TLS.CACert
+	}
+	if o.Cert == "" && o.Key == "" {
+		o.Cert = p.TLS.Cert
+		o.Key = p.TLS.Key
+		if o.CertPassword == "" {
+			o.CertPassword = interpolate(p.TLS.CertPassword)
+		}
+	}
+	if o.MinVersion == "" {
+		o.MinVersion = p.TLS.MinVersion
+	}
+	if o.MaxVersion == "" {
+		o.MaxVersion = p.TLS.MaxVersion
+	}
+	if o.Ciphers == "" {
+		o.Ciphers = strings.Join(p.TLS.Ciphers, ",")
+	}
+	if o.ServerName == "" {
+		o.ServerName = p.TLS.ServerName
+	}
+}
+
+// TokenSource returns the OAuth token source for the profile's oauth
+// settings, caching tokens in the project under the environment's name. The
+// token endpoint is reached over the transport for o, so it gets the same CA,
+// client certificate, proxy and dial overrides as the requests. It returns
+// nil when the profile has no oauth settings.
+func (p *Profile) TokenSource(interpolate func(string) string, o httpclient.TransportOptions) (*oauth.Source, error) {
+	if p == nil || p.OAuth == nil {
+		return nil, nil
+	}
+	t, err := httpclient.Transport(o)
+	if err != nil {
+		return nil, err
+	}
+	src := oauth.NewSource(p.OAuth.Interpolate(interpolate), p.name, oauth.StorePath(ProjectDir()))
+	src.Client = &http.Client{Timeout: 30 * time.Second, Transport: t}
+	return src, nil
+}
+
+// HostLimits parses the profile's per-host limits for httpclient.NewGuard.
+func (p *Profile) HostLimits() (map[string]httpclient.Limits, error) {
+	if p == nil || len(p.Limits) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]httpclient.Limits, len(p.Limits))
+	for host, l := range p.Limits {
+		if l == nil {
+			continue
+		}
+		rate, err := ratelimit.ParseRate(l.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("limits.%s: %w", host, err)
+		}
+		hl := httpclient.Limits{Rate: rate, Burst: l.Burst, Breaker: breaker.Settings{Failures: l.Breaker}}
+		if l.BreakerCooldown != "" {
+			if hl.Breaker.Cooldown, err = time.ParseDuration(l.BreakerCooldown); err != nil {
+				return nil, fmt.Errorf("limits.%s.breaker_cooldown: %w", host, err)
+			}
+		}
+		out[host] = hl
+	}
+	return out, nil
+}
+
+func hasHeader(lines []string, name string) bool {
+	prefix := strings.ToLower(name) + ":"
+	for _, h := range lines {
+		if strings.HasPrefix(strings.ToLower(h), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetField updates a single setting addressed by key, as used by
+// `mozzy env set`. Map keys use dotted form (headers.X-Env, variables.user).
+// An empty value removes header and variable entries.
+func (p *Profile) SetField(key, value string) error {
+	section, sub, _ := strings.Cut(key, ".")
+	switch section {
+	case "base_url":
+		p.BaseURL = value
+	case "auth_token":
+		p.AuthToken = value
+	case "extends":
+		p.Extends = value
+	case "headers":
+		if sub == "" {
+			return fmt.Errorf("use headers.<Name>, e.g. headers.X-Env")
+		}
+		p.Headers = setMapEntry(p.Headers, sub, value)
+	case "variables":
+		if sub == "" {
+			return fmt.Errorf("use variables.<name>, e.g. variables.user_id")
+		}
+		p.Variables = setMapEntry(p.Variables, sub, value)
+	case "proxy":
+		if p.Proxy == nil {
+			p.Proxy = &Proxy{}
+		}
+		switch sub {
+		case "", "url":
+			p.Proxy.URL = value
+		case "user":
+			p.Proxy.User = value
+		case "no_proxy":
+			p.Proxy.NoProxy = value
+		default:
+			return fmt.Errorf("unknown proxy setting %q (want url, user or no_proxy)", sub)
+		}
+		if *p.Proxy == (Proxy{}) {
+			p.Proxy = nil
+		}
+	case "tls":
+		if p.TLS == nil {
+			p.TLS = &TLS{}
+		}
+		switch sub {
+		case "insecure":
+			p.TLS.Insecure = value == "true" || value == "1" || value == "yes"
+		case "ca_cert":
+			p.TLS.CACert = value
+		case "cert":
+			p.TLS.Cert = value
+		case "key":
+			p.TLS.Key = value
+		case "cert_password":
+			p.TLS.CertPassword = value
+		case "min_version":
+			p.TLS.MinVersion = value
+		case "max_version":
+			p.TLS.MaxVersion = value
+		case "ciphers":
+			p.TLS.Ciphers = nil
+			for _, c := range strings.Split(value, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					p.TLS.Ciphers = append(p.TLS.Ciphers, c)
+				}
+			}
+		case "server_name":
+			p.TLS.ServerName = value
+		default:
+			return fmt.Errorf("unknown TLS setting %q (want insecure, ca_cert, cert, key, cert_password, min_version, max_version, ciphers or server_name)", sub)
+		}
+		if p.TLS.empty() {
+			p.TLS = nil
+		}
+	case "auth":
+		if sub == "" {
+			return fmt.Errorf("use auth.<field>, e.g. auth.type basic")
+		}
+		if p.Auth == nil {
+			p.Auth = &auth.Config{}
+		}
+		if err := p.Auth.Set(sub, value); err != nil {
+			return err
+		}
+		if *p.Auth == (auth.Config{}) {
+			p.Auth = nil
+		}
+	case "oauth":
+		if sub == "" {
+			return fmt.Errorf("use oauth.<field>, e.g. oauth.flow client_credentials")
+		}
+		if p.OAuth == nil {
+			p.OAuth = &oauth.Config{}
+		}
+		if err := p.OAuth.Set(sub, value); err != nil {
+			return err
+		}
+		if *p.OAuth == (oauth.Config{}) {
+			p.OAuth = nil
+		}
+	case "limits":
+		// The host may contain dots, so the field is the last segment
+		i := strings.LastIndex(sub, ".")
+		if i <= 0 {
+			return fmt.Errorf("use limits.<host>.<field>, e.g. limits.api.example.com.rate")
+		}
+		host, field := sub[:i], sub[i+1:]
+		if p.Limits == nil {
+			p.Limits = map[string]*Limit{}
+		}
+		l := p.Limits[host]
+		if l == nil {
+			l = &Limit{}
+		}
+		switch field {
+		case "rate":
+			if _, err := ratelimit.ParseRate(value); err != nil {
+				return err
+			}
+			l.Rate = value
+		case "burst", "breaker":
+			n := 0
+			if value != "" {
+				var err error
+				if n, err = strconv.Atoi(value); err != nil || n < 0 {
+					return fmt.Errorf("%s must be a non-negative number", field)
+				}
+			}
+			if field == "burst" {
+				l.Burst = n
+			} else {
+				l.Breaker = n
+			}
+		case "breaker_cooldown":
+			if value != "" {
+				if _, err := time.ParseDuration(value); err != nil {
+					return err
+				}
+			}
+			l.BreakerCooldown = value
+		default:
+			return fmt.Errorf("unknown limit %q (want rate, burst, breaker or breaker_cooldown)", field)
+		}
+		if *l == (Limit{}) {
+			delete(p.Limits, host)
+		} else {
+			p.Limits[host] = l
+		}
+		if len(p.Limits) == 0 {
+			p.Limits = nil
+		}
+	default:
+		return fmt.Errorf("unknown setting %q (want base_url, auth_token, extends, headers.<Name>, variables.<name>, proxy.<field>, tls.<field>, auth.<field>, oauth.<field> or limits.<host>.<field>)", key)
+	}
+	return nil
+}
+
+func setMapEntry(m map[string]string, k, v string) map[string]string {
+	if v == "" {
+		delete(m, k)
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	}
+	if m == nil {
+		m = map[string]string{}
+	}
+	m[k] = v
+	return m
+}
@@ -0,0 +1,428 @@
+package config
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/humancto/mozzy/internal/auth"
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/oauth"
+)
+
+func writeConfig(t *testing.T, body string) *File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	return f
+}
+
+func TestLoadFile_LegacyStringEnvironments(t *testing.T) {
+	f := writeConfig(t, `{"default_env":"dev","environments":{"dev":"http://localhost:3000"}}`)
+	p, err := f.Resolve("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.BaseURL != "http://localhost:3000" {
+		t.Errorf("BaseURL = %q, want legacy string value", p.BaseURL)
+	}
+}
+
+func TestResolve_Extends(t *testing.T) {
+	f := writeConfig(t, `{
+  "environments": {
+    "base": {
+      "base_url": "https://api.example.com",
+      "headers": {"Accept": "application/json", "X-Env": "base"},
+      "variables": {"region": "us", "user": "1"},
+      "tls": {"ca_cert": "ca.pem"}
+    },
+    "staging": {
+      "extends": "base",
+      "base_url": "https://staging.example.com",
+      "headers": {"X-Env": "staging"},
+      "variables": {"user": "2"},
+      "tls": {"insecure": true}
+    }
+  }
+}`)
+
+	p, err := f.Resolve("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.BaseURL != "https://staging.example.com" {
+		t.Errorf("BaseURL = %q", p.BaseURL)
+	}
+	wantHeaders := map[string]string{"Accept": "application/json", "X-Env": "staging"}
+	if !reflect.DeepEqual(p.Headers, wantHeaders) {
+		t.Errorf("Headers = %v, want %v", p.Headers, wantHeaders)
+	}
+	wantVars := map[string]string{"region": "us", "user": "2"}
+	if !reflect.DeepEqual(p.Variables, wantVars) {
+		t.Errorf("Variables = %v, want %v", p.Variables, wantVars)
+	}
+	if p.TLS == nil || !p.TLS.Insecure || p.TLS.CACert != filepath.Join(filepath.Dir(f.Path()), "ca.pem") {
+		t.Errorf("TLS = %+v, want inherited CA resolved next to config plus insecure", p.TLS)
+	}
+
+	// Resolving must not mutate the stored parent
+	if f.Environments["base"].Headers["X-Env"] != "base" {
+		t.Error("Resolve modified the parent profile")
+	}
+}
+
+func TestResolve_TLSClientCertAndLimits(t *testing.T) {
+	f := writeConfig(t, `{"environments":{
+  "base": {"tls": {"cert": "base.pem", "key": "base-key.pem", "min_version": "1.2", "ciphers": ["A"]}},
+  "internal": {"extends": "base", "tls": {"cert": "/abs/client.p12", "cert_password": "{{p12_pass}}", "server_name": "api.internal"}}
+}}`)
+
+	p, err := f.Resolve("internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TLS{Cert: "/abs/client.p12", CertPassword: "{{p12_pass}}", MinVersion: "1.2", Ciphers: []string{"A"}, ServerName: "api.internal"}
+	if !reflect.DeepEqual(*p.TLS, want) {
+		t.Errorf("TLS = %+v, want %+v (child cert must not inherit the parent key)", *p.TLS, want)
+	}
+
+	var o httpclient.TLSOptions
+	o.MinVersion = "1.3"
+	p.ApplyTLS(&o, func(s string) string { return strings.ReplaceAll(s, "{{p12_pass}}", "secret") })
+	if o.Cert != "/abs/client.p12" || o.CertPassword != "secret" || o.MinVersion != "1.3" || o.Ciphers != "A" || o.ServerName != "api.internal" {
+		t.Errorf("ApplyTLS = %+v", o)
+	}
+}
+
+func TestResolve_Proxy(t *testing.T) {
+	f := writeConfig(t, `{"environments":{
+  "corp": {"proxy": {"url": "http://proxy.corp:3128", "user": "{{proxy_user}}", "no_proxy": ".corp"}},
+  "debug": {"extends": "corp", "proxy": "socks5://127.0.0.1:1080"}
+}}`)
+
+	p, err := f.Resolve("debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Proxy{URL: "socks5://127.0.0.1:1080", NoProxy: ".corp"}
+	if *p.Proxy != want {
+		t.Errorf("Proxy = %+v, want %+v (credentials must not carry over to another proxy)", *p.Proxy, want)
+	}
+
+	corp, _ := f.Resolve("corp")
+	o := httpclient.ProxyOptions{}
+	corp.ApplyProxy(&o, func(s string) string { return strings.ReplaceAll(s, "{{proxy_user}}", "me:pw") })
+	if o.URL != "http://proxy.corp:3128" || o.User != "me:pw" || o.NoProxy != ".corp" {
+		t.Errorf("ApplyProxy = %+v", o)
+	}
+
+	o = httpclient.ProxyOptions{URL: "http://cli:8080"}
+	corp.ApplyProxy(&o, nil)
+	if o.URL != "http://cli:8080" || o.User != "" {
+		t.Errorf("command-line proxy should win without profile credentials: %+v", o)
+	}
+}
+
+func TestResolve_HostLimits(t *testing.T) {
+	f := writeConfig(t, `{"environments":{
+  "base": {"limits": {"*": {"rate": "50/s"}, "api.example.com": {"rate": "20/s", "breaker": 5}}},
+  "prod": {"extends": "base", "limits": {"api.example.com": {"burst": 3, "breaker_cooldown": "1m"}}}
+}}`)
+
+	p, err := f.Resolve("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Limit{Rate: "20/s", Burst: 3, Breaker: 5, BreakerCooldown: "1m"}
+	if *p.Limits["api.example.com"] != want {
+		t.Errorf("merged limit = %+v, want %+v", *p.Limits["api.example.com"], want)
+	}
+
+	limits, err := p.HostLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := limits["api.example.com"]
+	if api.Rate.N != 20 || api.Burst != 3 || api.Breaker.Failures != 5 || api.Breaker.Cooldown != time.Minute {
+		t.Errorf("HostLimits()[api.example.com] = %+v", api)
+	}
+	if limits["*"].Rate.N != 50 {
+		t.Errorf("HostLimits()[*] = %+v", limits["*"])
+	}
+
+	bad := &Profile{Limits: map[string]*Limit{"x": {Rate: "lots"}}}
+	if _, err := bad.HostLimits(); err == nil || !strings.Contains(err.Error(), "limits.x") {
+		t.Errorf("invalid rate error = %v", err)
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	f := writeConfig(t, `{"environments":{
+  "a": {"extends": "b"},
+  "b": {"extends": "a"},
+  "orphan": {"extends": "missing"}
+}}`)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"a", "cycle"},
+		{"orphan", "unknown environment"},
+		{"nope", "not found"},
+	}
+	for _, tt := range tests {
+		_, err := f.Resolve(tt.name)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Resolve(%q) error = %v, want containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSetField(t *testing.T) {
+	p := &Profile{}
+	for _, kv := range [][2]string{
+		{"base_url", "https://x"},
+		{"headers.X-Env", "dev"},
+		{"variables.user", "42"},
+		{"tls.insecure", "true"},
+		{"tls.ciphers", "A, B"},
+	} {
+		if err := p.SetField(kv[0], kv[1]); err != nil {
+			t.Fatalf("SetField(%s): %v", kv[0], err)
+		}
+	}
+	if p.BaseURL != "https://x" || p.Headers["X-Env"] != "dev" || p.Variables["user"] != "42" || !p.TLS.Insecure {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+
+	if err := p.SetField("headers.X-Env", ""); err != nil {
+		t.Fatal(err)
+	}
+	if p.Headers != nil {
+		t.Errorf("empty value should remove header, got %v", p.Headers)
+	}
+	if !reflect.DeepEqual(p.TLS.Ciphers, []string{"A", "B"}) {
+		t.Errorf("Ciphers = %v", p.TLS.Ciphers)
+	}
+	for _, key := range []string{"tls.insecure", "tls.ciphers"} {
+		if err := p.SetField(key, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.TLS != nil {
+		t.Errorf("empty TLS block should be dropped, got %+v", p.TLS)
+	}
+	if err := p.SetField("bogus", "x"); err == nil {
+		t.Error("expected error for unknown key")
+	}
+
+	if err := p.SetField("limits.api.example.com.rate", "20/s"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetField("limits.api.example.com.breaker", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if l := p.Limits["api.example.com"]; l == nil || *l != (Limit{Rate: "20/s", Breaker: 5}) {
+		t.Errorf("limits = %+v", p.Limits)
+	}
+	for _, kv := range [][2]string{{"limits.api.example.com.rate", "fast"}, {"limits.rate", "1/s"}, {"limits.api.example.com.speed", "1"}} {
+		if err := p.SetField(kv[0], kv[1]); err == nil {
+			t.Errorf("SetField(%s, %s): expected error", kv[0], kv[1])
+		}
+	}
+	p.SetField("limits.api.example.com.rate", "")
+	p.SetField("limits.api.example.com.breaker", "")
+	if p.Limits != nil {
+		t.Errorf("empty limits should be dropped, got %+v", p.Limits)
+	}
+}
+
+func TestApply(t *testing.T) {
+	p := &Profile{
+		Headers:   map[string]string{"X-Env": "dev", "Authorization": "Basic abc"},
+		AuthToken: "profile-token",
+		TLS:       &TLS{CACert: "/ca.pem"},
+	}
+	upper := strings.ToUpper
+
+	r := httpclient.Request{Headers: []string{"X-Env: cli"}}
+	p.Apply(&r, upper)
+	want := []string{"AUTHORIZATION: BASIC ABC", "X-ENV: DEV", "X-Env: cli"}
+	if !reflect.DeepEqual(r.Headers, want) {
+		t.Errorf("Headers = %v, want %v", r.Headers, want)
+	}
+	if r.Token != "" {
+		t.Errorf("profile token should not override an Authorization header, got %q", r.Token)
+	}
+	if r.TLS.CACert != "/ca.pem" {
+		t.Errorf("TLS.CACert = %q", r.TLS.CACert)
+	}
+
+	r = httpclient.Request{Token: "cli-token"}
+	p.Apply(&r, nil)
+	if r.Token != "cli-token" || len(r.Headers) != 1 {
+		t.Errorf("explicit token should win and drop profile Authorization: %+v", r)
+	}
+
+	var nilProfile *Profile
+	nilProfile.Apply(&r, nil) // must not panic
+}
+
+func TestApply_AuthScheme(t *testing.T) {
+	f := writeConfig(t, `{"environments":{
+  "base": {"auth_token": "old", "auth": {"type": "basic", "username": "{{user}}", "password": "pw"}},
+  "aws": {"extends": "base", "auth": {"type": "sigv4", "access_key": "AK", "secret_key": "SK", "region": "eu-west-1"}}
+}}`)
+
+	base, _ := f.Resolve("base")
+	r := httpclient.Request{}
+	base.Apply(&r, func(s string) string { return strings.ReplaceAll(s, "{{user}}", "me") })
+	if r.Token != "" || r.Auth != (auth.Config{Type: "basic", Username: "me", Password: "pw"}) {
+		t.Errorf("auth scheme should win over auth_token: token %q, auth %+v", r.Token, r.Auth)
+	}
+
+	aws, _ := f.Resolve("aws")
+	if aws.Auth.Type != "sigv4" || aws.Auth.Username != "" || aws.Auth.Region != "eu-west-1" {
+		t.Errorf("child scheme should replace the parent's: %+v", aws.Auth)
+	}
+
+	r = httpclient.Request{Auth: auth.Config{Type: "apikey", Value: "k"}}
+	aws.Apply(&r, nil)
+	if r.Auth.Type != "apikey" {
+		t.Errorf("command-line scheme should win: %+v", r.Auth)
+	}
+
+	p := &Profile{}
+	if err := p.SetField("auth.type", "digest"); err != nil {
+		t.Fatal(err)
+	}
+	p.SetField("auth.username", "me")
+	if p.Auth == nil || p.Auth.Type != "digest" || p.Auth.Username != "me" {
+		t.Errorf("SetField auth = %+v", p.Auth)
+	}
+	if err := p.SetField("auth.colour", "x"); err == nil {
+		t.Error("expected error for unknown auth setting")
+	}
+	p.SetField("auth.type", "")
+	p.SetField("auth.username", "")
+	if p.Auth != nil {
+		t.Errorf("empty auth should be dropped, got %+v", p.Auth)
+	}
+}
+
+func TestApply_OAuth(t *testing.T) {
+	f := writeConfig(t, `{"environments":{
+  "base": {"auth_token": "old", "oauth": {"flow": "client_credentials", "token_url": "https://idp/token", "client_id": "cli", "client_secret": "{{secret}}"}},
+  "dev": {"extends": "base"}
+}}`)
+
+	dev, err := f.Resolve("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.Name() != "dev" {
+		t.Errorf("Name() = %q, want dev", dev.Name())
+	}
+	r := httpclient.Request{}
+	dev.Apply(&r, func(s string) string { return strings.ReplaceAll(s, "{{secret}}", "s3") })
+	src, ok := r.Scheme.(*oauth.Source)
+	if !ok || r.Token != "" {
+		t.Fatalf("oauth should win over auth_token: token %q, scheme %T", r.Token, r.Scheme)
+	}
+	if src.Env != "dev" || src.Config.ClientSecret != "s3" || filepath.Base(src.Path) != "tokens.json" {
+		t.Errorf("source = env %q, config %+v, path %q", src.Env, src.Config, src.Path)
+	}
+
+	r = httpclient.Request{Token: "cli"}
+	dev.Apply(&r, nil)
+	if r.Scheme != nil {
+		t.Error("an explicit token should win over oauth")
+	}
+
+	p := &Profile{}
+	if err := p.SetField("oauth.flow", "device_code"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetField("oauth.redirect_port", "8765"); err != nil || p.OAuth.RedirectPort != 8765 {
+		t.Errorf("SetField redirect_port = %v, %+v", err, p.OAuth)
+	}
+	if err := p.SetField("oauth.redirect_port", "http"); err == nil {
+		t.Error("expected error for a non-numeric port")
+	}
+	if err := p.SetField("oauth.grant", "x"); err == nil {
+		t.Error("expected error for unknown oauth setting")
+	}
+	p.SetField("oauth.flow", "")
+	p.SetField("oauth.redirect_port", "")
+	if p.OAuth != nil {
+		t.Errorf("empty oauth should be dropped, got %+v", p.OAuth)
+	}
+}
+
+func TestTokenSource_ProfileCA(t *testing.T) {
+	idp := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "tok", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer idp.Close()
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idp.Certificate().Raw})
+	if err := os.WriteFile(ca, pemBytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := writeConfig(t, `{"environments":{"dev": {
+  "tls": {"ca_cert": "`+ca+`"},
+  "oauth": {"flow": "client_credentials", "token_url": "`+idp.URL+`/token", "client_id": "cli", "client_secret": "s"}
+}}}`)
+	dev, err := f.Resolve("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httpclient.Request{}
+	dev.Apply(&r, nil)
+	src := r.Scheme.(*oauth.Source)
+	src.Path = filepath.Join(dir, "tokens.json")
+	tok, err := src.Token(context.Background())
+	if err != nil || tok.AccessToken != "tok" {
+		t.Fatalf("token from an endpoint trusted by tls.ca_cert: %v, %+v", err, tok)
+	}
+
+	// the default client doesn't trust the endpoint's certificate
+	bare := oauth.NewSource(src.Config, "dev", filepath.Join(t.TempDir(), "tokens.json"))
+	if _, err := bare.Token(context.Background()); err == nil {
+		t.Error("expected a certificate error without the profile's CA")
+	}
+}
+
+func TestBaseURL_Precedence(t *testing.T) {
+	p := &Profile{BaseURL: "https://env"}
+	tests := []struct {
+		cliBase, cliEnv, want string
+	}{
+		{"", "", "https://env"},
+		{"https://cli", "", "https://cli"},
+		{"https://cli", "dev", "https://env"},
+	}
+	for _, tt := range tests {
+		if got := BaseURL(p, tt.cliBase, tt.cliEnv); got != tt.want {
+			t.Errorf("BaseURL(%q, %q) = %q, want %q", tt.cliBase, tt.cliEnv, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package cookies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/humancto/mozzy/internal/filelock"
+)
+
+const (
+	httpOnlyPrefix = "#HttpOnly_"
+	lockTimeout    = 5 * time.Second
+)
+
+// Format identifies an on-disk cookie file layout.
+type Format int
+
+const (
+	// FormatNetscape is the cookies.txt layout shared with curl and wget.
+	FormatNetscape Format = iota
+	// FormatJSON is a JSON array of Entry values.
+	FormatJSON
+)
+
+// FormatFor picks the file format from the path extension: ".json" selects
+// JSON, anything else the Netscape format.
+func FormatFor(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+	return FormatNetscape
+}
+
+// Load opens the jar stored at path. A missing file yields an empty jar.
+func Load(path string) (*Jar, error) {
+	j := New(path)
+	entries, err := readFile(path)
+	if err != nil {
+		return j, err
+	}
+	now := j.now()
+	for i := range entries {
+		e := entries[i]
+		if e.Expired(now) {
+			continue
+		}
+		j.entries[e.key()] = &e
+	}
+	return j, nil
+}
+
+// Save writes the jar back to its file. The file is re-read under a lock and
+// only the cookies this process changed are applied on top, so concurrent
+// mozzy processes sharing a jar don't clobber each other's cookies.
+func (j *Jar) Save() error {
+	if j.path == "" {
+		return nil
+	}
+
+	lock, err := filelock.Acquire(j.path, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	onDisk, err := readFile(j.path)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	merged := make(map[string]*Entry, len(onDisk)+len(j.entries))
+	for i := range onDisk {
+		e := onDisk[i]
+		merged[e.key()] = &e
+	}
+	for k := range j.dirty {
+		if e, ok := j.entries[k]; ok {
+			merged[k] = e
+		} else {
+			delete(merged, k)
+		}
+	}
+	j.entries = merged
+	j.dirty = make(map[string]bool)
+
+	data, err := Encode(j.sortedLocked(), FormatFor(j.path))
+	if err != nil {
+		return err
+	}
+	return filelock.WriteFileAtomic(j.path, data, 0o600)
+}
+
+func readFile(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries, err := Decode(data, FormatFor(path))
+	if err != nil {
+		return nil, fmt.Errorf("cookie jar %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Encode serializes entries in the given format.
+func Encode(entries []Entry, format Format) ([]byte, error) {
+	if format == FormatJSON {
+		if entries == nil {
+			entries = []Entry{}
+		}
+		return json.MarshalIndent(entries, "", "  ")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Netscape HTTP Cookie File\n")
+	buf.WriteString("# Generated by mozzy. Edit at your own risk.\n\n")
+	for _, e := range entries {
+		domain := e.Domain
+		if !e.HostOnly {
+			domain = "." + domain
+		}
+		if e.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+		fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain,
+			netscapeBool(!e.HostOnly),
+			e.Path,
+			netscapeBool(e.Secure),
+			expires,
+			e.Name,
+			e.Value)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode parses cookie file contents in the given format.
+func Decode(data []byte, format Format) ([]Entry, error) {
+	if format == FormatJSON {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, nil
+		}
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entries[i].Domain = strings.ToLower(strings.TrimPrefix(entries[i].Domain, "."))
+			if entries[i].Path == "" {
+				entries[i].Path = "/"
+			}
+		}
+		return entries, nil
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, httpOnlyPrefix) {
+			httpOnly = true
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// curl writes empty values as a missing trailing field
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", lineNo, len(fields))
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", lineNo, fields[4])
+		}
+
+		e := Entry{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			e.Expires = time.Unix(expires, 0)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
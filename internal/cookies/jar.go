@@ -0,0 +1,249 @@
+package cookies
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a single stored cookie with every attribute needed to write it
+// back to disk.
+type Entry struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	HostOnly bool      `json:"host_only"`
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+	Expires  time.Time `json:"expires,omitempty"` // zero for session cookies
+	Created  time.Time `json:"created"`
+}
+
+func (e *Entry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+// Expired reports whether the cookie has passed its expiry time.
+func (e *Entry) Expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+// Jar is an http.CookieJar backed by a file on disk. Unlike
+// net/http/cookiejar it keeps full cookie attributes so they survive a
+// round-trip through Netscape or JSON files.
+type Jar struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*Entry
+	// dirty tracks keys this process set or deleted since the last load, so
+	// Save can merge them over whatever other processes wrote meanwhile.
+	dirty map[string]bool
+	now   func() time.Time
+}
+
+// New returns an empty jar persisted at path (path may be empty for a purely
+// in-memory jar).
+func New(path string) *Jar {
+	return &Jar{
+		path:    path,
+		entries: make(map[string]*Entry),
+		dirty:   make(map[string]bool),
+		now:     time.Now,
+	}
+}
+
+// Path returns the file backing the jar.
+func (j *Jar) Path() string { return j.path }
+
+// SetCookies implements http.CookieJar.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := canonicalHost(u.Host)
+	now := j.now()
+	for _, c := range cookies {
+		e, ok := j.newEntry(c, host, u.Path, now)
+		if !ok {
+			continue
+		}
+		k := e.key()
+		j.dirty[k] = true
+		if e.Expired(now) {
+			delete(j.entries, k)
+			continue
+		}
+		if old, exists := j.entries[k]; exists {
+			e.Created = old.Created
+		}
+		j.entries[k] = e
+	}
+}
+
+// Cookies implements http.CookieJar.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := canonicalHost(u.Host)
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := j.now()
+
+	var matched []*Entry
+	for _, e := range j.entries {
+		if e.Expired(now) {
+			continue
+		}
+		if e.Secure && !secure {
+			continue
+		}
+		if !domainMatch(e, host) || !pathMatch(e.Path, path) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	// RFC 6265 5.4: longer paths first, then earlier creation time
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		if !matched[a].Created.Equal(matched[b].Created) {
+			return matched[a].Created.Before(matched[b].Created)
+		}
+		return matched[a].Name < matched[b].Name
+	})
+
+	out := make([]*http.Cookie, 0, len(matched))
+	for _, e := range matched {
+		out = append(out, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
+	return out
+}
+
+// Entries returns a snapshot of all non-expired cookies, sorted by domain,
+// path and name.
+func (j *Jar) Entries() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.sortedLocked()
+}
+
+// Clear removes every cookie from the jar.
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for k := range j.entries {
+		j.dirty[k] = true
+	}
+	j.entries = make(map[string]*Entry)
+}
+
+func (j *Jar) sortedLocked() []Entry {
+	now := j.now()
+	list := make([]Entry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if e.Expired(now) {
+			continue
+		}
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Domain != list[b].Domain {
+			return list[a].Domain < list[b].Domain
+		}
+		if list[a].Path != list[b].Path {
+			return list[a].Path < list[b].Path
+		}
+		return list[a].Name < list[b].Name
+	})
+	return list
+}
+
+func (j *Jar) newEntry(c *http.Cookie, host, reqPath string, now time.Time) (*Entry, bool) {
+	if c.Name == "" {
+		return nil, false
+	}
+
+	e := &Entry{
+		Name:     c.Name,
+		Value:    c.Value,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		Created:  now,
+	}
+
+	// Domain attribute: a leading dot is ignored, and the request host must
+	// domain-match it. IP hosts can only set host-only cookies.
+	domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+	if domain == "" || domain == host {
+		e.Domain = host
+		e.HostOnly = domain == ""
+	} else {
+		if net.ParseIP(host) != nil || !strings.HasSuffix(host, "."+domain) {
+			return nil, false
+		}
+		e.Domain = domain
+	}
+
+	if c.Path != "" && strings.HasPrefix(c.Path, "/") {
+		e.Path = c.Path
+	} else {
+		e.Path = defaultPath(reqPath)
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		e.Expires = time.Unix(1, 0)
+	case c.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		e.Expires = c.Expires
+	}
+	return e, true
+}
+
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func domainMatch(e *Entry, host string) bool {
+	if e.HostOnly {
+		return host == e.Domain
+	}
+	return host == e.Domain || strings.HasSuffix(host, "."+e.Domain)
+}
+
+func pathMatch(cookiePath, reqPath string) bool {
+	if cookiePath == reqPath {
+		return true
+	}
+	if strings.HasPrefix(reqPath, cookiePath) {
+		return strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/'
+	}
+	return false
+}
+
+// defaultPath implements RFC 6265 5.1.4.
+func defaultPath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(p, "/")
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
+}
@@ -0,0 +1,165 @@
+package cookies
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	return u
+}
+
+func cookieNames(cs []*http.Cookie) string {
+	names := make([]string, len(cs))
+	for i, c := range cs {
+		names[i] = c.Name + "=" + c.Value
+	}
+	return strings.Join(names, "; ")
+}
+
+func TestJar_DomainAndPathMatching(t *testing.T) {
+	j := New("")
+	j.SetCookies(mustURL(t, "https://api.example.com/v1/login"), []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "wide", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "foreign", Value: "4", Domain: "other.com"},
+	})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.example.com/v1/users", "host=1; secure=3; wide=2"},
+		{"http://api.example.com/v1/users", "host=1; wide=2"},
+		{"https://www.example.com/", "wide=2"},
+		{"https://api.example.com/v2", "secure=3; wide=2"},
+		{"https://other.com/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got := cookieNames(j.Cookies(mustURL(t, tt.url)))
+			if got != tt.want {
+				t.Errorf("Cookies(%s) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJar_ExpiryAndDeletion(t *testing.T) {
+	j := New("")
+	u := mustURL(t, "http://localhost:8080/")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "a", Value: "1", MaxAge: 3600},
+		{Name: "b", Value: "2", Expires: time.Now().Add(-time.Hour)},
+	})
+	if got := cookieNames(j.Cookies(u)); got != "a=1" {
+		t.Fatalf("Cookies() = %q, want a=1", got)
+	}
+
+	j.SetCookies(u, []*http.Cookie{{Name: "a", MaxAge: -1}})
+	if got := j.Cookies(u); len(got) != 0 {
+		t.Errorf("expected cookie deleted by Max-Age<0, got %q", cookieNames(got))
+	}
+}
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	expires := time.Unix(time.Now().Add(24*time.Hour).Unix(), 0)
+	entries := []Entry{
+		{Name: "sid", Value: "abc", Domain: "example.com", Path: "/", Secure: true, HttpOnly: true, Expires: expires},
+		{Name: "pref", Value: "dark", Domain: "api.example.com", Path: "/v1", HostOnly: true},
+	}
+
+	for _, format := range []Format{FormatNetscape, FormatJSON} {
+		data, err := Encode(entries, format)
+		if err != nil {
+			t.Fatalf("Encode(%d): %v", format, err)
+		}
+		got, err := Decode(data, format)
+		if err != nil {
+			t.Fatalf("Decode(%d): %v", format, err)
+		}
+		if len(got) != len(entries) {
+			t.Fatalf("format %d: got %d entries, want %d", format, len(got), len(entries))
+		}
+		for i, want := range entries {
+			g := got[i]
+			if g.Name != want.Name || g.Value != want.Value || g.Domain != want.Domain ||
+				g.Path != want.Path || g.HostOnly != want.HostOnly || g.Secure != want.Secure ||
+				g.HttpOnly != want.HttpOnly || !g.Expires.Equal(want.Expires) {
+				t.Errorf("format %d entry %d = %+v, want %+v", format, i, g, want)
+			}
+		}
+	}
+}
+
+func TestDecode_CurlFile(t *testing.T) {
+	data := []byte("# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_.example.com\tTRUE\t/\tTRUE\t0\tsession\txyz\n" +
+		"localhost\tFALSE\t/\tFALSE\t0\tempty\n")
+	got, err := Decode(data, FormatNetscape)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2", len(got))
+	}
+	if !got[0].HttpOnly || got[0].HostOnly || got[0].Domain != "example.com" || !got[0].Expires.IsZero() {
+		t.Errorf("unexpected first entry: %+v", got[0])
+	}
+	if got[1].Name != "empty" || got[1].Value != "" || !got[1].HostOnly {
+		t.Errorf("unexpected second entry: %+v", got[1])
+	}
+}
+
+func TestSave_PrunesExpiredAndMergesConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	u := mustURL(t, "http://localhost/")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			j, err := Load(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			j.SetCookies(u, []*http.Cookie{{Name: string(rune('a' + i)), Value: "v"}})
+			if err := j.Save(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	j, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(j.Entries()); n != 8 {
+		t.Fatalf("expected 8 cookies after concurrent saves, got %d", n)
+	}
+
+	// Expired cookies are dropped on the next save
+	j.SetCookies(u, []*http.Cookie{{Name: "a", MaxAge: -1}})
+	if err := j.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "\ta\tv") {
+		t.Errorf("expired cookie still on disk:\n%s", data)
+	}
+}
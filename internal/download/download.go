@@ -27,13 +27,34 @@ type DownloadOptions struct {
 	ShowProgress    bool
 	OverwriteExist  bool
 	FollowRedirects bool
+	Transport       http.RoundTripper // optional, e.g. for client certificates
+}
+
+// Result describes a finished download. Response is set as soon as the
+// server answered, so it is available with the error of a failed download.
+type Result struct {
+	Path     string
+	Response *http.Response // body already consumed and closed
+	Bytes    int64
+	Duration time.Duration
 }
 
 // Download downloads a file with optional progress display
 func Download(opts DownloadOptions) (string, error) {
+	res, err := Fetch(opts)
+	return res.Path, err
+}
+
+// Fetch is Download returning the whole Result, which is never nil.
+func Fetch(opts DownloadOptions) (*Result, error) {
+	result := &Result{}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
 	// Create HTTP client
 	client := &http.Client{
-		Timeout: 30 * time.Minute, // Longer timeout for large files
+		Timeout:   30 * time.Minute, // Longer timeout for large files
+		Transport: opts.Transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if !opts.FollowRedirects && len(via) > 0 {
 				return http.ErrUseLastResponse
@@ -45,12 +66,13 @@ func Download(opts DownloadOptions) (string, error) {
 	// Make request
 	resp, err := client.Get(opts.URL)
 	if err != nil {
-		return "", fmt.Errorf("failed to download: %w", err)
+		return result, fmt.Errorf("failed to download: %w", err)
 	}
 	defer resp.Body.Close()
+	result.Response = resp
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("download failed with status: %s", resp.Status)
+		return result, fmt.Errorf("download failed with status: %s", resp.Status)
 	}
 
 	// Determine output path
@@ -68,16 +90,17 @@ func Download(opts DownloadOptions) (string, error) {
 	// Check if file exists
 	if !opts.OverwriteExist {
 		if _, err := os.Stat(outputPath); err == nil {
-			return "", fmt.Errorf("file already exists: %s (use --overwrite to replace)", outputPath)
+			return result, fmt.Errorf("file already exists: %s (use --overwrite to replace)", outputPath)
 		}
 	}
 
 	// Create output file
 	out, err := os.Create(outputPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return result, fmt.Errorf("failed to create file: %w", err)
 	}
 	defer out.Close()
+	result.Path = outputPath
 
 	// Download with progress
 	progress := &Progress{
@@ -88,15 +111,18 @@ func Download(opts DownloadOptions) (string, error) {
 
 	if opts.ShowProgress && resp.ContentLength > 0 {
 		// Progress bar for known size
-		return outputPath, downloadWithProgress(resp.Body, out, progress)
+		err = downloadWithProgress(resp.Body, out, progress)
 	} else if opts.ShowProgress {
 		// Simple progress for unknown size
-		return outputPath, downloadWithSimpleProgress(resp.Body, out, progress)
+		err = downloadWithSimpleProgress(resp.Body, out, progress)
 	} else {
 		// No progress display
 		_, err = io.Copy(out, resp.Body)
-		return outputPath, err
 	}
+	if info, serr := out.Stat(); serr == nil {
+		result.Bytes = info.Size()
+	}
+	return result, err
 }
 
 func downloadWithProgress(src io.Reader, dst io.Writer, progress *Progress) error {
@@ -0,0 +1,308 @@
+// Package envelope renders a request and its outcome as one JSON or YAML
+// document for scripts, the --output format of every command that sends
+// requests.
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/humancto/mozzy/internal/httpclient"
+	"github.com/humancto/mozzy/internal/retry"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output.
+const (
+	Text = "text"
+	JSON = "json"
+	YAML = "yaml"
+)
+
+// Body encodings: a JSON body is embedded as is, UTF-8 text as a string and
+// anything else base64-encoded.
+const (
+	EncodingJSON   = "json"
+	EncodingText   = "text"
+	EncodingBase64 = "base64"
+)
+
+// ParseFormat validates an --output value; empty means text.
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(s); f {
+	case "", Text:
+		return Text, nil
+	case JSON, YAML:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (use text, json or yaml)", s)
+}
+
+// Envelope is the machine-readable result of one request.
+type Envelope struct {
+	Request  Request   `json:"request"`
+	Response *Response `json:"response,omitempty"`
+	File     *File     `json:"file,omitempty"` // where a download was saved
+	Timings  *Timings  `json:"timings,omitempty"`
+	Grade    *Grade    `json:"grade,omitempty"`
+	TLS      *TLS      `json:"tls,omitempty"`
+	Attempts []Attempt `json:"attempts,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+}
+
+type Response struct {
+	Status       int             `json:"status"`
+	Protocol     string          `json:"protocol,omitempty"`
+	URL          string          `json:"url,omitempty"` // final URL after redirects
+	Headers      http.Header     `json:"headers,omitempty"`
+	BodyEncoding string          `json:"body_encoding,omitempty"`
+	Body         json.RawMessage `json:"body,omitempty"`
+	Size         int64           `json:"size"`
+}
+
+type File struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Timings are in milliseconds.
+type Timings struct {
+	DNS       float64 `json:"dns_ms,omitempty"`
+	TCP       float64 `json:"tcp_ms,omitempty"`
+	TLS       float64 `json:"tls_ms,omitempty"`
+	TTFB      float64 `json:"ttfb_ms,omitempty"`
+	Transfer  float64 `json:"transfer_ms,omitempty"`
+	Redirect  float64 `json:"redirect_ms,omitempty"`
+	Redirects int     `json:"redirects,omitempty"`
+	Total     float64 `json:"total_ms"`
+}
+
+type Grade struct {
+	DNS      httpclient.Grade `json:"dns,omitempty"`
+	TCP      httpclient.Grade `json:"tcp,omitempty"`
+	TLS      httpclient.Grade `json:"tls,omitempty"`
+	TTFB     httpclient.Grade `json:"ttfb,omitempty"`
+	Redirect httpclient.Grade `json:"redirect,omitempty"`
+	Overall  httpclient.Grade `json:"overall"`
+}
+
+type TLS struct {
+	Version     string     `json:"version"`
+	Cipher      string     `json:"cipher,omitempty"`
+	CertSubject string     `json:"cert_subject,omitempty"`
+	CertIssuer  string     `json:"cert_issuer,omitempty"`
+	CertExpiry  *time.Time `json:"cert_expiry,omitempty"`
+}
+
+type Attempt struct {
+	Number     int     `json:"number"`
+	Status     int     `json:"status,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	WaitMS     float64 `json:"wait_ms,omitempty"` // backoff before the next attempt
+	Reason     string  `json:"reason,omitempty"`
+}
+
+// New builds the envelope of a request sent with httpclient.Send. err is
+// the error Send returned, if any.
+func New(r httpclient.Request, res *httpclient.Result, err error) *Envelope {
+	e := &Envelope{Request: Request{Method: r.Method, URL: r.URL}}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if res == nil {
+		return e
+	}
+	for _, a := range res.Attempts {
+		e.Attempts = append(e.Attempts, attempt(a))
+	}
+	if res.Response == nil {
+		return e
+	}
+
+	// The headers actually sent, including auth and defaults, are on the
+	// final hop's request
+	if req := res.Response.Request; req != nil {
+		e.Request.Headers = Redact(req.Header, r.Auth.SecretHeaders()...)
+	}
+	e.Response = NewResponse(res.Response, res.Body)
+	if name := r.Auth.SecretParam(); name != "" {
+		e.Response.URL = redactParam(e.Response.URL, name)
+	}
+	e.Timings = NewTimings(res.Timings)
+	g := httpclient.CalculateGrade(res.Timings)
+	e.Grade = &Grade{DNS: g.DNS, TCP: g.TCP, TLS: g.TLS, TTFB: g.TTFB, Redirect: g.Redirect, Overall: g.Overall}
+	if v := res.Info; v.TLSVersion != "" {
+		e.TLS = &TLS{Version: v.TLSVersion, Cipher: v.TLSCipher, CertSubject: v.CertSubject, CertIssuer: v.CertIssuer}
+		if !v.CertExpiry.IsZero() {
+			e.TLS.CertExpiry = &v.CertExpiry
+		}
+	}
+	return e
+}
+
+// secretHeaders carry credentials whatever the auth scheme.
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key", "Api-Key", "X-Auth-Token", "X-Amz-Security-Token"}
+
+// schemeRe matches the scheme that starts a credential, as in "Bearer …".
+var schemeRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]* `)
+
+// Redact returns a copy of the request headers h with credentials masked,
+// so envelopes can go to CI logs. The scheme is kept, as in
+// "Authorization: Bearer ***". extra names more headers to mask, such as
+// those an auth scheme sets.
+func Redact(h http.Header, extra ...string) http.Header {
+	out := h.Clone()
+	for _, name := range append(append([]string{}, secretHeaders...), extra...) {
+		vv := out.Values(name)
+		for i, v := range vv {
+			vv[i] = schemeRe.FindString(v) + "***"
+		}
+	}
+	return out
+}
+
+// redactParam masks the value of the query parameter name in rawURL.
+func redactParam(rawURL, name string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	if !q.Has(name) {
+		return rawURL
+	}
+	for i := range q[name] {
+		q[name][i] = "***"
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// NewResponse describes a response and its (already read) body.
+func NewResponse(res *http.Response, body []byte) *Response {
+	r := &Response{
+		Status:   res.StatusCode,
+		Protocol: res.Proto,
+		Headers:  res.Header.Clone(),
+		Size:     int64(len(body)),
+	}
+	if res.Request != nil && res.Request.URL != nil {
+		r.URL = res.Request.URL.String()
+	}
+	r.BodyEncoding, r.Body = Body(body)
+	return r
+}
+
+// NewTimings converts request timings to milliseconds.
+func NewTimings(t httpclient.TimingInfo) *Timings {
+	return &Timings{
+		DNS:       ms(t.DNSLookup),
+		TCP:       ms(t.TCPConnection),
+		TLS:       ms(t.TLSHandshake),
+		TTFB:      ms(t.ServerProcessing),
+		Transfer:  ms(t.ContentTransfer),
+		Redirect:  ms(t.Redirect),
+		Redirects: t.Redirects,
+		Total:     ms(t.Total),
+	}
+}
+
+// Body encodes a response body for the envelope and names the encoding
+// used; an empty body has neither.
+func Body(b []byte) (string, json.RawMessage) {
+	trimmed := bytes.TrimSpace(b)
+	switch {
+	case len(trimmed) == 0:
+		return "", nil
+	case json.Valid(trimmed):
+		var buf bytes.Buffer
+		if json.Compact(&buf, trimmed) == nil {
+			return EncodingJSON, buf.Bytes()
+		}
+	case utf8.Valid(b):
+		return EncodingText, quote(string(b))
+	}
+	return EncodingBase64, quote(base64.StdEncoding.EncodeToString(b))
+}
+
+// quote encodes s as a JSON string, leaving HTML as written.
+func quote(s string) json.RawMessage {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// Write renders v, an Envelope or any document built from them, as JSON or
+// YAML.
+func Write(w io.Writer, format string, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if format != YAML {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	// JSON is YAML, so decoding it into a node keeps the field order and the
+	// exact numbers of the body; only the flow style has to go
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+	ye := yaml.NewEncoder(w)
+	ye.SetIndent(2)
+	if err := ye.Encode(&doc); err != nil {
+		return err
+	}
+	return ye.Close()
+}
+
+func blockStyle(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode {
+		if n.Tag == "!!str" && !strings.Contains(n.Value, "\n") {
+			n.Style = 0 // the encoder still quotes strings that need it
+		} else if n.Tag == "!!str" {
+			n.Style = yaml.LiteralStyle
+		}
+		return
+	}
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+func attempt(a retry.Attempt) Attempt {
+	out := Attempt{Number: a.Number, Status: a.Status, DurationMS: ms(a.Duration), WaitMS: ms(a.Wait), Reason: a.Reason}
+	if a.Err != nil {
+		out.Error = a.Err.Error()
+	}
+	return out
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
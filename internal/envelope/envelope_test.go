@@ -0,0 +1,157 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/humancto/mozzy/internal/auth"
+	"github.com/humancto/mozzy/internal/httpclient"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBody(t *testing.T) {
+	tests := []struct {
+		in       string
+		encoding string
+		body     string
+	}{
+		{`{"id": 1, "big": 12345678901234567890}`, EncodingJSON, `{"id":1,"big":12345678901234567890}`},
+		{"<h1>hi</h1>\n", EncodingText, `"<h1>hi</h1>\n"`},
+		{"\x89PNG\x00", EncodingBase64, `"iVBORwA="`},
+		{"  ", "", ""},
+	}
+	for _, tt := range tests {
+		enc, body := Body([]byte(tt.in))
+		if enc != tt.encoding || string(body) != tt.body {
+			t.Errorf("Body(%q) = %s %s, want %s %s", tt.in, enc, body, tt.encoding, tt.body)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	r := httpclient.Request{Method: "GET", URL: srv.URL, Headers: []string{"X-Trace: abc"}, RetryCount: 1, RetryCondition: "5xx"}
+	res, err := httpclient.Send(context.Background(), r)
+	e := New(r, res, err)
+	if e.Error != "" || e.Response == nil {
+		t.Fatalf("envelope = %+v", e)
+	}
+	if e.Request.Headers.Get("X-Trace") != "abc" || e.Response.Status != 200 || string(e.Response.Body) != `{"ok":true}` || e.Response.BodyEncoding != EncodingJSON {
+		t.Errorf("request %+v, response %+v", e.Request, e.Response)
+	}
+	if len(e.Attempts) != 2 || e.Attempts[0].Status != 503 || e.Attempts[0].Reason != "Retry-After" {
+		t.Errorf("attempts = %+v", e.Attempts)
+	}
+	if e.Timings == nil || e.Timings.Total <= 0 || e.Grade == nil || e.Grade.Overall == "" || e.TLS != nil {
+		t.Errorf("timings %+v, grade %+v, tls %+v", e.Timings, e.Grade, e.TLS)
+	}
+
+	// A request that never got a response still yields an envelope
+	r.URL = "http://127.0.0.1:1"
+	r.RetryCount = 0
+	res, err = httpclient.Send(context.Background(), r)
+	if e := New(r, res, err); e.Error == "" || e.Response != nil || len(e.Attempts) != 1 {
+		t.Errorf("failed envelope = %+v", e)
+	}
+}
+
+func TestNew_RedactsCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	r := httpclient.Request{Method: "GET", URL: srv.URL, Token: "s3cret-token",
+		Headers: []string{"Cookie: sid=c00kie", "X-Trace: abc"}}
+	res, err := httpclient.Send(context.Background(), r)
+	var out bytes.Buffer
+	if err := Write(&out, JSON, New(r, res, err)); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"s3cret-token", "c00kie"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("envelope leaks %q:\n%s", secret, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), `"Bearer ***"`) || !strings.Contains(out.String(), `"abc"`) {
+		t.Errorf("envelope should keep the scheme and other headers:\n%s", out.String())
+	}
+
+	// headers and query parameters set by an auth scheme
+	for _, a := range []auth.Config{
+		{Type: auth.APIKey, In: "header", Name: "X-Key", Value: "k3y-value"},
+		{Type: auth.APIKey, Value: "k3y-value"},
+		{Type: auth.HMAC, Header: "X-Signature", Secret: "k3y", Format: "{signature}"},
+	} {
+		r := httpclient.Request{Method: "GET", URL: srv.URL, Auth: a}
+		res, err := httpclient.Send(context.Background(), r)
+		e := New(r, res, err)
+		sent := res.Response.Request
+		signature := sent.Header.Get("X-Signature")
+		out.Reset()
+		Write(&out, JSON, e)
+		if strings.Contains(out.String(), "k3y-value") || (signature != "" && strings.Contains(out.String(), signature)) {
+			t.Errorf("%s envelope leaks the credential:\n%s", a.Type, out.String())
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	_, body := Body([]byte(`{"n": 1.50, "tags": ["a"], "note": "line1\nline2", "code": "007"}`))
+	e := &Envelope{
+		Request:  Request{Method: "GET", URL: "http://x/"},
+		Response: &Response{Status: 200, BodyEncoding: EncodingJSON, Body: body},
+	}
+
+	var js bytes.Buffer
+	if err := Write(&js, JSON, e); err != nil {
+		t.Fatal(err)
+	}
+	var back map[string]any
+	if err := json.Unmarshal(js.Bytes(), &back); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, js.String())
+	}
+
+	var ys bytes.Buffer
+	if err := Write(&ys, YAML, e); err != nil {
+		t.Fatal(err)
+	}
+	out := ys.String()
+	for _, want := range []string{"request:\n  method: GET", "n: 1.50", `code: "007"`, "note: |-\n      line1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("YAML missing %q:\n%s", want, out)
+		}
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(ys.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid YAML: %v", err)
+	}
+	if doc["response"].(map[string]any)["body"].(map[string]any)["code"] != "007" {
+		t.Errorf("string that looks like a number lost its quotes:\n%s", out)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]string{"": Text, "text": Text, "JSON": JSON, "yaml": YAML} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("xml should be rejected")
+	}
+}
@@ -0,0 +1,295 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	imagecolor "image/color"
+	_ "image/gif" // image decoders for the image renderer
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/humancto/mozzy/internal/jq"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func init() {
+	Register(&Renderer{
+		Name:   "MessagePack",
+		Types:  []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack", "+msgpack"},
+		Binary: true,
+		Decode: decodeMsgpack,
+		Print:  printDecoded(decodeMsgpack),
+	})
+	Register(&Renderer{
+		Name:   "CBOR",
+		Types:  []string{"application/cbor", "+cbor"},
+		Binary: true,
+		Decode: decodeCBOR,
+		Print:  printDecoded(decodeCBOR),
+	})
+	Register(&Renderer{
+		Name:   "image",
+		Types:  []string{"image/*"},
+		Sniff:  func(b []byte) bool { return imageFormat(b) != "" },
+		Binary: true,
+		Decode: func(b []byte) (any, error) { return imageInfo(b), nil },
+		Print:  printImage,
+	})
+}
+
+func decodeMsgpack(b []byte) (any, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetMapDecoder(func(d *msgpack.Decoder) (any, error) { return d.DecodeUntypedMap() })
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	return plain(v), nil
+}
+
+func decodeCBOR(b []byte) (any, error) {
+	var v any
+	if err := cbor.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return plain(v), nil
+}
+
+// printDecoded prints a binary format as the JSON it decodes to.
+func printDecoded(decode func([]byte) (any, error)) func(io.Writer, []byte) error {
+	return func(w io.Writer, b []byte) error {
+		v, err := decode(b)
+		if err != nil {
+			return err
+		}
+		out, err := jq.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return PrettyJSON(w, bytes.NewReader(out), Pretty)
+	}
+}
+
+// plain converts decoded MessagePack/CBOR values into the types jq and
+// encoding/json work with: string-keyed maps, int or float64 numbers, and
+// byte strings as text or base64.
+func plain(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, e := range x {
+			x[k] = plain(e)
+		}
+		return x
+	case map[any]any:
+		m := make(map[string]any, len(x))
+		for k, e := range x {
+			m[fmt.Sprint(plain(k))] = plain(e)
+		}
+		return m
+	case []any:
+		for i, e := range x {
+			x[i] = plain(e)
+		}
+		return x
+	case []byte:
+		if utf8.Valid(x) {
+			return string(x)
+		}
+		return base64.StdEncoding.EncodeToString(x)
+	case int8:
+		return int(x)
+	case int16:
+		return int(x)
+	case int32:
+		return int(x)
+	case int64:
+		return int(x)
+	case uint8:
+		return int(x)
+	case uint16:
+		return int(x)
+	case uint32:
+		return int(x)
+	case uint64:
+		if x > math.MaxInt64 {
+			return new(big.Int).SetUint64(x)
+		}
+		return int(x)
+	case big.Int:
+		return &x
+	case float32:
+		return float64(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case cbor.Tag:
+		return map[string]any{"tag": int(x.Number), "value": plain(x.Content)}
+	}
+	return v
+}
+
+// imageFormat returns the format name of a PNG, JPEG, GIF, WebP, BMP or
+// TIFF body, or "" if it isn't one.
+func imageFormat(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(b, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(b, []byte("GIF87a")), bytes.HasPrefix(b, []byte("GIF89a")):
+		return "gif"
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return "webp"
+	case bytes.HasPrefix(b, []byte("BM")) && len(b) > 26:
+		return "bmp"
+	case bytes.HasPrefix(b, []byte("II*\x00")), bytes.HasPrefix(b, []byte("MM\x00*")):
+		return "tiff"
+	case sniffed(b, "image/x-icon"):
+		return "ico"
+	}
+	return ""
+}
+
+// imageInfo describes an image body. Dimensions are known for the formats
+// the standard library decodes (PNG, JPEG, GIF).
+func imageInfo(b []byte) map[string]any {
+	info := map[string]any{"format": imageFormat(b), "bytes": len(b)}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err == nil {
+		info["format"] = format
+		info["width"] = cfg.Width
+		info["height"] = cfg.Height
+		info["color_model"] = colorModel(cfg)
+	}
+	if format == "png" {
+		if text := pngText(b); len(text) > 0 {
+			info["text"] = text
+		}
+	}
+	if info["format"] == "" {
+		info["format"] = "unknown"
+	}
+	return info
+}
+
+func printImage(w io.Writer, b []byte) error {
+	info := imageInfo(b)
+	label := color.New(color.FgCyan, color.Bold)
+	gray := color.New(color.FgHiBlack)
+	fmt.Fprintf(w, "🖼️  %s", label.Sprintf("%s image", strings.ToUpper(info["format"].(string))))
+	if wd, ok := info["width"].(int); ok {
+		fmt.Fprintf(w, ", %d×%d", wd, info["height"])
+	}
+	fmt.Fprintf(w, " %s\n", gray.Sprintf("(%s)", formatSize(len(b))))
+	if m, ok := info["color_model"]; ok {
+		fmt.Fprintf(w, "%s Color model:  %s\n", gray.Sprint("•"), m)
+	}
+	if text, ok := info["text"].(map[string]string); ok {
+		keys := make([]string, 0, len(text))
+		for k := range text {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s %-13s %s\n", gray.Sprint("•"), k+":", text[k])
+		}
+	}
+	return nil
+}
+
+func colorModel(cfg image.Config) string {
+	switch cfg.ColorModel {
+	case imagecolor.RGBAModel, imagecolor.RGBA64Model:
+		return "RGBA"
+	case imagecolor.NRGBAModel, imagecolor.NRGBA64Model:
+		return "NRGBA"
+	case imagecolor.GrayModel, imagecolor.Gray16Model:
+		return "grayscale"
+	case imagecolor.YCbCrModel:
+		return "YCbCr"
+	case imagecolor.CMYKModel:
+		return "CMYK"
+	}
+	if p, ok := cfg.ColorModel.(imagecolor.Palette); ok {
+		return fmt.Sprintf("paletted, %d colors", len(p))
+	}
+	return "other"
+}
+
+// pngText returns the tEXt metadata chunks of a PNG, e.g. Software or
+// Author.
+func pngText(b []byte) map[string]string {
+	text := map[string]string{}
+	for p := 8; p+12 <= len(b); {
+		n := int(b[p])<<24 | int(b[p+1])<<16 | int(b[p+2])<<8 | int(b[p+3])
+		typ := string(b[p+4 : p+8])
+		if n < 0 || p+12+n > len(b) || typ == "IEND" {
+			break
+		}
+		if typ == "tEXt" {
+			if k, v, ok := bytes.Cut(b[p+8:p+8+n], []byte{0}); ok {
+				text[string(k)] = string(v)
+			}
+		}
+		p += 12 + n
+	}
+	return text
+}
+
+// hexdump prints the start of a binary body like hexdump -C.
+func hexdump(w io.Writer, b []byte) error {
+	const limit = 512
+	gray := color.New(color.FgHiBlack)
+	fmt.Fprintf(w, "%s\n", gray.Sprintf("Binary body, %s", formatSize(len(b))))
+	shown := b
+	if len(shown) > limit {
+		shown = shown[:limit]
+	}
+	for off := 0; off < len(shown); off += 16 {
+		line := shown[off:min(off+16, len(shown))]
+		var hex, ascii strings.Builder
+		for i := 0; i < 16; i++ {
+			if i == 8 {
+				hex.WriteByte(' ')
+			}
+			if i < len(line) {
+				fmt.Fprintf(&hex, "%02x ", line[i])
+				if line[i] >= 0x20 && line[i] < 0x7f {
+					ascii.WriteByte(line[i])
+				} else {
+					ascii.WriteByte('.')
+				}
+			} else {
+				hex.WriteString("   ")
+			}
+		}
+		fmt.Fprintf(w, "%s  %s |%s|\n", gray.Sprintf("%08x", off), hex.String(), ascii.String())
+	}
+	if len(b) > limit {
+		fmt.Fprintf(w, "%s\n", gray.Sprintf("… %d more bytes (use --output json or redirect to a file for all of it)", len(b)-limit))
+	}
+	return nil
+}
+
+func formatSize(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := int64(n) / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,125 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// maxCellWidth is where long table cells are cut off.
+const maxCellWidth = 40
+
+func init() {
+	Register(&Renderer{
+		Name:   "CSV",
+		Types:  []string{"text/csv", "application/csv", "text/x-csv"},
+		Decode: func(b []byte) (any, error) { return decodeCSV(b, ',') },
+		Print:  func(w io.Writer, b []byte) error { return printCSV(w, b, ',') },
+	})
+	Register(&Renderer{
+		Name:   "TSV",
+		Types:  []string{"text/tab-separated-values", "text/tsv"},
+		Decode: func(b []byte) (any, error) { return decodeCSV(b, '\t') },
+		Print:  func(w io.Writer, b []byte) error { return printCSV(w, b, '\t') },
+	})
+}
+
+func readCSV(b []byte, comma rune) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.Comma = comma
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty table")
+	}
+	return rows, nil
+}
+
+// decodeCSV turns the rows after the header into objects keyed by the
+// header's column names, for --jq.
+func decodeCSV(b []byte, comma rune) (any, error) {
+	rows, err := readCSV(b, comma)
+	if err != nil {
+		return nil, err
+	}
+	header := rows[0]
+	out := make([]any, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		obj := make(map[string]any, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				obj[name] = row[i]
+			} else {
+				obj[name] = nil
+			}
+		}
+		out = append(out, obj)
+	}
+	return out, nil
+}
+
+// printCSV prints the rows as an aligned table with the first row as its
+// header.
+func printCSV(w io.Writer, b []byte, comma rune) error {
+	rows, err := readCSV(b, comma)
+	if err != nil {
+		return err
+	}
+	var widths []int
+	for _, row := range rows {
+		for i, v := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell(v)))
+		}
+	}
+
+	bold := color.New(color.FgCyan, color.Bold)
+	gray := color.New(color.FgHiBlack)
+	for r, row := range rows {
+		var line strings.Builder
+		for i, width := range widths {
+			c := ""
+			if i < len(row) {
+				c = cell(row[i])
+			}
+			if i > 0 {
+				line.WriteString(gray.Sprint(" │ "))
+			}
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(c))
+			if r == 0 {
+				c = bold.Sprint(c)
+			}
+			line.WriteString(c + pad)
+		}
+		fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+		if r == 0 {
+			seps := make([]string, len(widths))
+			for i, width := range widths {
+				seps[i] = strings.Repeat("─", width)
+			}
+			fmt.Fprintln(w, gray.Sprint(strings.Join(seps, "─┼─")))
+		}
+	}
+	fmt.Fprintln(w, gray.Sprintf("%d rows", len(rows)-1))
+	return nil
+}
+
+// cell flattens a value onto one line and shortens it to maxCellWidth.
+func cell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > maxCellWidth {
+		s = string([]rune(s)[:maxCellWidth-1]) + "…"
+	}
+	return s
+}
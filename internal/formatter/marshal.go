@@ -7,6 +7,12 @@ import (
 )
 
 func MarshalJSON(v any) ([]byte, error) {
+	return MarshalJSONWith(v, vars.Interpolate)
+}
+
+// MarshalJSONWith is MarshalJSON with the {{variables}} resolved by
+// interpolate, e.g. a workflow iteration's own variables.
+func MarshalJSONWith(v any, interpolate func(string) string) ([]byte, error) {
 	// First marshal to JSON
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -14,7 +20,7 @@ func MarshalJSON(v any) ([]byte, error) {
 	}
 
 	// Then interpolate {{variables}} in the JSON string
-	interpolated := vars.Interpolate(string(b))
+	interpolated := interpolate(string(b))
 
 	// If interpolation changed the string, we need to re-parse to ensure valid JSON
 	// This handles cases where variables expand to non-string values
@@ -0,0 +1,450 @@
+package formatter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Theme holds the SGR parameters, e.g. "1;36" for bold cyan, each kind of
+// JSON token is printed with. Empty leaves a token uncolored.
+type Theme struct {
+	Key    string
+	String string
+	Number string
+	Bool   string
+	Null   string
+	Punct  string // braces and brackets
+	Fold   string // folded containers and elided array items
+}
+
+// Themes are the built-in --theme names.
+var Themes = map[string]Theme{
+	"default":   {Key: "1;36", String: "32", Number: "33", Bool: "35", Null: "31", Punct: "1;37", Fold: "90"},
+	"monokai":   {Key: "38;5;81", String: "38;5;186", Number: "38;5;141", Bool: "38;5;197", Null: "38;5;197", Punct: "38;5;231", Fold: "38;5;242"},
+	"solarized": {Key: "38;5;33", String: "38;5;37", Number: "38;5;125", Bool: "38;5;166", Null: "38;5;160", Punct: "38;5;245", Fold: "38;5;240"},
+	"github":    {Key: "34", String: "36", Number: "34", Bool: "31", Null: "31", Punct: "1", Fold: "2"},
+	"mono":      {Key: "1", Fold: "2"},
+}
+
+// ParseTheme reads a --theme value: a built-in name, optionally followed by
+// role=SGR overrides, e.g. "monokai,key=1;34" or just "string=32".
+func ParseTheme(s string) (Theme, error) {
+	t := Themes["default"]
+	for i, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		role, sgr, ok := strings.Cut(part, "=")
+		if !ok {
+			named, found := Themes[strings.ToLower(part)]
+			if i > 0 || !found {
+				return Theme{}, fmt.Errorf("unknown theme %q (use %s, or role=SGR overrides)", part, strings.Join(themeNames(), ", "))
+			}
+			t = named
+			continue
+		}
+		if strings.Trim(sgr, "0123456789;") != "" {
+			return Theme{}, fmt.Errorf("theme %s=%q: not an SGR code like 1;36 or 38;5;81", role, sgr)
+		}
+		switch strings.ToLower(role) {
+		case "key":
+			t.Key = sgr
+		case "string":
+			t.String = sgr
+		case "number":
+			t.Number = sgr
+		case "bool":
+			t.Bool = sgr
+		case "null":
+			t.Null = sgr
+		case "punct":
+			t.Punct = sgr
+		case "fold":
+			t.Fold = sgr
+		default:
+			return Theme{}, fmt.Errorf("unknown theme role %q (use key, string, number, bool, null, punct or fold)", role)
+		}
+	}
+	return t, nil
+}
+
+func themeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for n := range Themes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrettyOptions control how JSON is printed.
+type PrettyOptions struct {
+	// Indent is repeated once per nesting level; empty prints compact JSON
+	// on one line.
+	Indent string
+	Theme  Theme
+	// MaxDepth folds objects and arrays nested deeper than this into a
+	// one-line summary; 0 prints everything.
+	MaxDepth int
+	// MaxArray prints at most this many elements of each array; 0 prints
+	// them all.
+	MaxArray int
+}
+
+// Pretty is how response bodies and jq results are printed; the --indent,
+// --theme, --max-depth and --max-array flags set it.
+var Pretty = PrettyOptions{Indent: "  ", Theme: Themes["default"]}
+
+// PrettyJSON prints the JSON document read from r token by token, writing
+// output as it reads, so even very large bodies are never re-indented or
+// held in memory as a whole. Strings and numbers are copied as written.
+func PrettyJSON(w io.Writer, r io.Reader, opts PrettyOptions) error {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, 64<<10)
+	}
+	p := &printer{r: br, w: bufio.NewWriterSize(w, 64<<10), opts: opts, color: !color.NoColor}
+	err := p.value(0)
+	if err == nil {
+		if c, ok := p.next(); ok {
+			err = p.errorf("unexpected %q after the JSON value", c)
+		}
+	}
+	if err == nil {
+		p.w.WriteByte('\n')
+	}
+	if ferr := p.w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+type printer struct {
+	r     *bufio.Reader
+	w     *bufio.Writer
+	opts  PrettyOptions
+	color bool
+	mute  int   // >0 while a folded value is skipped
+	off   int64 // bytes read, for errors
+	num   []byte
+}
+
+func (p *printer) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid JSON at byte %d: %s", p.off, fmt.Sprintf(format, args...))
+}
+
+func (p *printer) readByte() (byte, error) {
+	c, err := p.r.ReadByte()
+	if err == nil {
+		p.off++
+	}
+	return c, err
+}
+
+// next skips whitespace and consumes the next byte; ok is false at the end
+// of the input.
+func (p *printer) next() (byte, bool) {
+	for {
+		c, err := p.readByte()
+		if err != nil {
+			return 0, false
+		}
+		if c != ' ' && c != '\n' && c != '\r' && c != '\t' {
+			return c, true
+		}
+	}
+}
+
+func (p *printer) put(s string) {
+	if p.mute == 0 {
+		p.w.WriteString(s)
+	}
+}
+
+func (p *printer) putColored(sgr, s string) {
+	p.start(sgr)
+	p.put(s)
+	p.end(sgr)
+}
+
+// start and end wrap a token in the escape codes of its theme color.
+func (p *printer) start(sgr string) {
+	if p.mute == 0 && p.color && sgr != "" {
+		p.w.WriteString("\x1b[")
+		p.w.WriteString(sgr)
+		p.w.WriteByte('m')
+	}
+}
+
+func (p *printer) end(sgr string) {
+	if p.mute == 0 && p.color && sgr != "" {
+		p.w.WriteString("\x1b[0m")
+	}
+}
+
+// newline starts a new line indented to depth; compact output has none.
+func (p *printer) newline(depth int) {
+	if p.mute > 0 || p.opts.Indent == "" {
+		return
+	}
+	p.w.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		p.w.WriteString(p.opts.Indent)
+	}
+}
+
+func (p *printer) value(depth int) error {
+	c, ok := p.next()
+	if !ok {
+		return p.errorf("unexpected end of input")
+	}
+	switch {
+	case c == '{':
+		return p.container(depth, '}')
+	case c == '[':
+		return p.container(depth, ']')
+	case c == '"':
+		return p.str(p.opts.Theme.String)
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number(c)
+	case c == 't':
+		return p.literal("true", p.opts.Theme.Bool)
+	case c == 'f':
+		return p.literal("false", p.opts.Theme.Bool)
+	case c == 'n':
+		return p.literal("null", p.opts.Theme.Null)
+	}
+	return p.errorf("unexpected %q", c)
+}
+
+// container prints an object or array whose opening brace was just read.
+func (p *printer) container(depth int, closer byte) error {
+	opening, closing, object := "[", "]", closer == '}'
+	if object {
+		opening, closing = "{", "}"
+	}
+	c, ok := p.next()
+	if !ok {
+		return p.errorf("unexpected end of input")
+	}
+	if c == closer {
+		p.putColored(p.opts.Theme.Punct, opening+closing)
+		return nil
+	}
+	if err := p.r.UnreadByte(); err != nil {
+		return err
+	}
+	p.off--
+
+	fold := p.mute == 0 && p.opts.MaxDepth > 0 && depth >= p.opts.MaxDepth
+	if fold {
+		p.mute++
+	}
+	p.putColored(p.opts.Theme.Punct, opening)
+	n := 0
+	for ; ; n++ {
+		if !object && p.opts.MaxArray > 0 && n == p.opts.MaxArray && p.mute == 0 {
+			// Elide the rest, counting what is left out
+			p.mute++
+			rest, err := p.members(depth, closer)
+			p.mute--
+			if err != nil {
+				return err
+			}
+			p.put(",")
+			p.newline(depth + 1)
+			p.putColored(p.opts.Theme.Fold, fmt.Sprintf("… %d more %s", rest, plural(rest, "item")))
+			break
+		}
+		if n > 0 {
+			p.put(",")
+		}
+		p.newline(depth + 1)
+		if err := p.member(depth, object); err != nil {
+			return err
+		}
+		c, ok := p.next()
+		if !ok {
+			return p.errorf("unexpected end of input")
+		}
+		if c == closer {
+			break
+		}
+		if c != ',' {
+			return p.errorf("expected ',' or %q, got %q", closer, c)
+		}
+	}
+	if fold {
+		p.mute--
+		what := plural(n+1, "item")
+		if object {
+			what = plural(n+1, "key")
+		}
+		p.putColored(p.opts.Theme.Fold, fmt.Sprintf("%s… %d %s%s", opening, n+1, what, closing))
+		return nil
+	}
+	p.newline(depth)
+	p.putColored(p.opts.Theme.Punct, closing)
+	return nil
+}
+
+// members reads the rest of a container, starting at a member that isn't
+// preceded by a comma, and returns how many members that was.
+func (p *printer) members(depth int, closer byte) (int, error) {
+	for n := 0; ; n++ {
+		if err := p.member(depth, closer == '}'); err != nil {
+			return n, err
+		}
+		c, ok := p.next()
+		if !ok {
+			return n, p.errorf("unexpected end of input")
+		}
+		if c == closer {
+			return n + 1, nil
+		}
+		if c != ',' {
+			return n, p.errorf("expected ',' or %q, got %q", closer, c)
+		}
+	}
+}
+
+// member prints one array element, or one key and value of an object.
+func (p *printer) member(depth int, object bool) error {
+	if object {
+		c, ok := p.next()
+		if !ok || c != '"' {
+			return p.errorf("expected an object key")
+		}
+		if err := p.str(p.opts.Theme.Key); err != nil {
+			return err
+		}
+		if c, ok := p.next(); !ok || c != ':' {
+			return p.errorf("expected ':' after an object key")
+		}
+		if p.opts.Indent == "" {
+			p.put(":")
+		} else {
+			p.put(": ")
+		}
+	}
+	return p.value(depth + 1)
+}
+
+// str copies a string whose opening quote was just read, escapes and all.
+func (p *printer) str(sgr string) error {
+	p.start(sgr)
+	p.put(`"`)
+	esc := false
+	for {
+		chunk, err := p.r.ReadSlice('"')
+		p.off += int64(len(chunk))
+		closed := false
+		for i, c := range chunk {
+			switch {
+			case esc:
+				esc = false
+			case c == '\\':
+				esc = true
+			case c == '"':
+				closed = i == len(chunk)-1
+			case c < 0x20:
+				return p.errorf("control character in string")
+			}
+		}
+		if p.mute == 0 {
+			p.w.Write(chunk)
+		}
+		if closed {
+			break
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return p.errorf("unterminated string")
+		}
+	}
+	p.end(sgr)
+	return nil
+}
+
+func (p *printer) number(first byte) error {
+	p.num = append(p.num[:0], first)
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			break
+		}
+		if c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-' {
+			p.off++
+			p.num = append(p.num, c)
+			continue
+		}
+		p.r.UnreadByte()
+		break
+	}
+	if !validNumber(p.num) {
+		return p.errorf("bad number %q", p.num)
+	}
+	p.start(p.opts.Theme.Number)
+	if p.mute == 0 {
+		p.w.Write(p.num)
+	}
+	p.end(p.opts.Theme.Number)
+	return nil
+}
+
+// validNumber checks b against the JSON number grammar.
+func validNumber(b []byte) bool {
+	i := 0
+	digits := func() bool {
+		start := i
+		for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+			i++
+		}
+		return i > start
+	}
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+	if i < len(b) && b[i] == '0' {
+		i++
+	} else if !digits() {
+		return false
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		if !digits() {
+			return false
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if !digits() {
+			return false
+		}
+	}
+	return i == len(b)
+}
+
+func (p *printer) literal(word, sgr string) error {
+	for i := 1; i < len(word); i++ {
+		c, err := p.readByte()
+		if err != nil || c != word[i] {
+			return p.errorf("expected %s", word)
+		}
+	}
+	p.putColored(sgr, word)
+	return nil
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
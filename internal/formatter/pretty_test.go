@@ -0,0 +1,262 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func pretty(t *testing.T, in string, opts PrettyOptions) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := PrettyJSON(&out, strings.NewReader(in), opts); err != nil {
+		t.Fatalf("PrettyJSON(%s): %v", in, err)
+	}
+	return out.String()
+}
+
+func TestPrettyJSON(t *testing.T) {
+	in := `{"msg": "a: 1, b: \"2\"", "uni": "caf\u00e9 ☕", "n": -1.5e3, "ok": true, "nil": null, "list": [1, {}, []], "o": {"k": "v"}}`
+	want := `{
+  "msg": "a: 1, b: \"2\"",
+  "uni": "caf\u00e9 ☕",
+  "n": -1.5e3,
+  "ok": true,
+  "nil": null,
+  "list": [
+    1,
+    {},
+    []
+  ],
+  "o": {
+    "k": "v"
+  }
+}
+`
+	if got := pretty(t, in, PrettyOptions{Indent: "  "}); got != want {
+		t.Errorf("indented:\n%s\nwant:\n%s", got, want)
+	}
+	compact := `{"msg":"a: 1, b: \"2\"","uni":"caf\u00e9 ☕","n":-1.5e3,"ok":true,"nil":null,"list":[1,{},[]],"o":{"k":"v"}}` + "\n"
+	if got := pretty(t, in, PrettyOptions{}); got != compact {
+		t.Errorf("compact:\n%s\nwant:\n%s", got, compact)
+	}
+	if got := pretty(t, "[1,[2]]", PrettyOptions{Indent: "\t"}); got != "[\n\t1,\n\t[\n\t\t2\n\t]\n]\n" {
+		t.Errorf("tabs: %q", got)
+	}
+	if got := pretty(t, ` "just a string" `, PrettyOptions{Indent: "  "}); got != "\"just a string\"\n" {
+		t.Errorf("scalar: %q", got)
+	}
+
+	// Strings longer than the read buffer, ending in escapes, survive intact
+	long := strings.Repeat(`x\\\"`, 40000)
+	if got := pretty(t, `["`+long+`"]`, PrettyOptions{}); got != `["`+long+`"]`+"\n" {
+		t.Error("long string was changed")
+	}
+}
+
+func TestPrettyJSON_Colors(t *testing.T) {
+	color.NoColor = false
+	defer func() { color.NoColor = true }()
+
+	theme := Theme{Key: "1", String: "2", Number: "3", Bool: "4", Null: "5", Punct: "6"}
+	got := pretty(t, `{"a: 1": "b, 2", "n": 3, "t": false, "z": null}`, PrettyOptions{Theme: theme})
+	want := "\x1b[6m{\x1b[0m\x1b[1m\"a: 1\"\x1b[0m:\x1b[2m\"b, 2\"\x1b[0m," +
+		"\x1b[1m\"n\"\x1b[0m:\x1b[3m3\x1b[0m," +
+		"\x1b[1m\"t\"\x1b[0m:\x1b[4mfalse\x1b[0m," +
+		"\x1b[1m\"z\"\x1b[0m:\x1b[5mnull\x1b[0m\x1b[6m}\x1b[0m\n"
+	if got != want {
+		t.Errorf("colors:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestPrettyJSON_Folding(t *testing.T) {
+	in := `{"id": 1, "user": {"name": "ada", "roles": ["a", "b"]}, "tags": [1, 2, 3, 4, 5], "empty": {}}`
+	got := pretty(t, in, PrettyOptions{Indent: "  ", MaxDepth: 1})
+	want := `{
+  "id": 1,
+  "user": {… 2 keys},
+  "tags": [… 5 items],
+  "empty": {}
+}
+`
+	if got != want {
+		t.Errorf("max depth:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = pretty(t, in, PrettyOptions{Indent: "  ", MaxArray: 2})
+	if !strings.Contains(got, "\"tags\": [\n    1,\n    2,\n    … 3 more items\n  ]") || !strings.Contains(got, `"roles": [`) {
+		t.Errorf("max array:\n%s", got)
+	}
+	if got := pretty(t, "[1,2]", PrettyOptions{MaxArray: 2}); got != "[1,2]\n" {
+		t.Errorf("arrays within the limit are printed whole: %q", got)
+	}
+}
+
+func TestPrettyJSON_Invalid(t *testing.T) {
+	for _, in := range []string{``, `{`, `{"a" 1}`, `{"a":1,}`, `[1 2]`, `"open`, `tru`, `01x`, `1.`, `{"a":1} {}`, "\"tab\there\"", `{1: 2}`} {
+		if err := PrettyJSON(&bytes.Buffer{}, strings.NewReader(in), PrettyOptions{}); err == nil {
+			t.Errorf("PrettyJSON(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	th, err := ParseTheme("monokai,key=1;34")
+	if err != nil || th.Key != "1;34" || th.String != Themes["monokai"].String {
+		t.Errorf("ParseTheme = %+v, %v", th, err)
+	}
+	if th, err := ParseTheme("null=0"); err != nil || th.Null != "0" || th.Key != Themes["default"].Key {
+		t.Errorf("overrides on default = %+v, %v", th, err)
+	}
+	for _, bad := range []string{"neon", "key=red", "shadow=1", "key=1,mono"} {
+		if _, err := ParseTheme(bad); err == nil {
+			t.Errorf("ParseTheme(%q) should fail", bad)
+		}
+	}
+}
+
+// bigDocument returns about n bytes of API-like JSON with nested objects,
+// escapes and numbers.
+func bigDocument(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"items": [`)
+	for i := 0; buf.Len() < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"id": %d, "name": "user %d", "note": "said: \"hi\" at 10:30", "score": %d.25, "active": %t, "tags": ["a", "b"], "owner": {"login": "u%d", "site": null}}`, i, i, i, i%2 == 0, i)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+func BenchmarkPrettyJSON(b *testing.B) {
+	color.NoColor = false
+	defer func() { color.NoColor = true }()
+	doc := bigDocument(4 << 20)
+	opts := PrettyOptions{Indent: "  ", Theme: Themes["default"]}
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := PrettyJSON(io.Discard, bytes.NewReader(doc), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLegacyColorize measures the regexp-per-line colorizer PrettyJSON
+// replaced, including the json.Indent it needed first.
+func BenchmarkLegacyColorize(b *testing.B) {
+	color.NoColor = false
+	defer func() { color.NoColor = true }()
+	doc := bigDocument(4 << 20)
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var out bytes.Buffer
+		if err := json.Indent(&out, doc, "", "  "); err != nil {
+			b.Fatal(err)
+		}
+		legacyColorizeJSON(io.Discard, out.String())
+	}
+}
+
+func legacyColorizeJSON(w io.Writer, jsonStr string) {
+	if color.NoColor {
+		fmt.Fprintln(w, jsonStr)
+		return
+	}
+
+	// Define colors
+	cyan := color.New(color.FgCyan, color.Bold)
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+	magenta := color.New(color.FgMagenta)
+	red := color.New(color.FgRed)
+	white := color.New(color.FgWhite, color.Bold)
+
+	lines := strings.Split(jsonStr, "\n")
+
+	// Patterns to identify JSON components
+	keyPattern := regexp.MustCompile(`^(\s*)"([^"]+)":`)
+	stringPattern := regexp.MustCompile(`"([^"]*)"`)
+	numberPattern := regexp.MustCompile(`-?\d+\.?\d*([eE][+-]?\d+)?`)
+	boolPattern := regexp.MustCompile(`\b(true|false)\b`)
+	nullPattern := regexp.MustCompile(`\bnull\b`)
+
+	for _, line := range lines {
+		// Extract leading whitespace
+		leadingSpace := ""
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line) > len(trimmed) {
+			leadingSpace = line[:len(line)-len(trimmed)]
+		}
+
+		// Check if line has a key
+		if keyPattern.MatchString(line) {
+			matches := keyPattern.FindStringSubmatch(line)
+			if len(matches) >= 3 {
+				// Print: whitespace + colored key + colon
+				fmt.Fprint(w, matches[1])
+				cyan.Fprintf(w, "\"%s\"", matches[2])
+				fmt.Fprint(w, ":")
+
+				// Get the rest of the line after the key
+				rest := line[len(matches[0]):]
+				legacyColoredValue(w, rest, green, yellow, magenta, red, white, stringPattern, numberPattern, boolPattern, nullPattern)
+				fmt.Fprintln(w)
+				continue
+			}
+		}
+
+		// No key, just print the line with appropriate colors
+		fmt.Fprint(w, leadingSpace)
+		legacyColoredValue(w, strings.TrimLeft(line, " "), green, yellow, magenta, red, white, stringPattern, numberPattern, boolPattern, nullPattern)
+		fmt.Fprintln(w)
+	}
+}
+
+func legacyColoredValue(w io.Writer, s string, green, yellow, magenta, red, white *color.Color, stringPattern, numberPattern, boolPattern, nullPattern *regexp.Regexp) {
+	s = strings.TrimSpace(s)
+
+	// Check for brackets/braces first
+	if s == "{" || s == "}" || s == "[" || s == "]" {
+		white.Fprint(w, s)
+		return
+	}
+
+	// Remove trailing comma if exists
+	hasComma := strings.HasSuffix(s, ",")
+	if hasComma {
+		s = strings.TrimSuffix(s, ",")
+		s = strings.TrimSpace(s)
+	}
+
+	// Check what type of value this is
+	if stringPattern.MatchString(s) && strings.HasPrefix(s, "\"") {
+		green.Fprint(w, s)
+	} else if boolPattern.MatchString(s) {
+		magenta.Fprint(w, s)
+	} else if nullPattern.MatchString(s) {
+		red.Fprint(w, s)
+	} else if numberPattern.MatchString(s) {
+		yellow.Fprint(w, s)
+	} else if s == "{" || s == "}" {
+		white.Fprint(w, s)
+	} else {
+		// Default: print as-is
+		fmt.Fprint(w, s)
+	}
+
+	if hasComma {
+		fmt.Fprint(w, ",")
+	}
+}
@@ -0,0 +1,200 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/humancto/mozzy/internal/jq"
+)
+
+// Renderer pretty-prints one kind of response body.
+type Renderer struct {
+	Name string
+	// Types are the media types it handles: "application/xml", a
+	// structured syntax suffix such as "+xml", or a wildcard like "image/*".
+	Types []string
+	// Sniff recognises the body when the Content-Type is missing or generic.
+	Sniff func(b []byte) bool
+	// Binary bodies are never taken for JSON, even when the bytes happen to
+	// be valid JSON.
+	Binary bool
+	// Decode turns the body into the value --jq runs on; nil means the body
+	// can't be queried.
+	Decode func(b []byte) (any, error)
+	Print  func(w io.Writer, b []byte) error
+}
+
+// renderers is tried in order; the text and hexdump fallbacks stay last.
+var renderers = []*Renderer{jsonRenderer, textRenderer, hexRenderer}
+
+// Register adds a renderer, tried after those registered before it.
+func Register(r *Renderer) {
+	n := len(renderers)
+	for n > 0 && renderers[n-1].fallback() {
+		n--
+	}
+	renderers = append(renderers[:n], append([]*Renderer{r}, renderers[n:]...)...)
+}
+
+func (r *Renderer) fallback() bool { return r == textRenderer || r == hexRenderer }
+
+// genericTypes say nothing about the body, so it is sniffed instead.
+var genericTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"text/plain":               true,
+}
+
+// RendererFor picks the renderer for a body: the one registered for its
+// Content-Type, or the first whose Sniff recognises it. A body that is valid
+// JSON is always shown as JSON unless its type is binary.
+func RendererFor(contentType string, b []byte) *Renderer {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if !genericTypes[mt] {
+		for _, r := range renderers {
+			if r.handles(mt) {
+				if !r.Binary && r != jsonRenderer && isJSON(b) {
+					return jsonRenderer
+				}
+				return r
+			}
+		}
+	}
+	for _, r := range renderers {
+		if r.Sniff != nil && r.Sniff(b) {
+			return r
+		}
+	}
+	return textRenderer
+}
+
+func (r *Renderer) handles(mt string) bool {
+	for _, t := range r.Types {
+		switch {
+		case strings.HasPrefix(t, "+"):
+			if strings.HasSuffix(mt, t) {
+				return true
+			}
+		case strings.HasSuffix(t, "/*"):
+			if strings.HasPrefix(mt, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		case t == mt:
+			return true
+		}
+	}
+	return false
+}
+
+// PrintBody pretty-prints a response body according to its Content-Type.
+// With a jq query the body is decoded first, so --jq works on XML, YAML,
+// CSV, MessagePack and CBOR as well as JSON.
+func PrintBody(b []byte, contentType, jqQuery string) error {
+	return FprintBody(color.Output, b, contentType, jqQuery)
+}
+
+// FprintBody is PrintBody writing to w.
+func FprintBody(w io.Writer, b []byte, contentType, jqQuery string) error {
+	r := RendererFor(contentType, b)
+	if jqQuery != "" && jqQuery != "." {
+		if r.Decode == nil {
+			return fmt.Errorf("jq query failed: a %s body can't be queried", r.Name)
+		}
+		v, err := r.Decode(b)
+		if err != nil {
+			return fmt.Errorf("jq query failed: decoding %s: %w", r.Name, err)
+		}
+		results, err := jq.Eval(jqQuery, v)
+		if err != nil {
+			return fmt.Errorf("jq query failed: %w", err)
+		}
+		return printResults(w, results)
+	}
+
+	// Valid JSON streams straight to w. Other bodies are rendered whole
+	// first, so one that doesn't parse as what it claims to be can still be
+	// shown as text or hex.
+	if r == jsonRenderer && isJSON(b) {
+		return r.Print(w, b)
+	}
+	var out bytes.Buffer
+	if err := r.Print(&out, b); err != nil {
+		out.Reset()
+		r = textRenderer
+		if !printable(b) {
+			r = hexRenderer
+		}
+		r.Print(&out, b)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+var jsonRenderer = &Renderer{
+	Name:  "JSON",
+	Types: []string{"application/json", "+json", "text/json"},
+	Sniff: isJSON,
+	Decode: func(b []byte) (any, error) {
+		return jq.Decode(b)
+	},
+	Print: func(w io.Writer, b []byte) error {
+		return PrettyJSON(w, bytes.NewReader(b), Pretty)
+	},
+}
+
+var textRenderer = &Renderer{
+	Name:  "text",
+	Types: []string{"text/*"},
+	Sniff: printable,
+	Print: func(w io.Writer, b []byte) error {
+		if !printable(b) {
+			return fmt.Errorf("not printable text")
+		}
+		_, err := fmt.Fprintln(w, string(b))
+		return err
+	},
+}
+
+var hexRenderer = &Renderer{
+	Name:   "binary",
+	Sniff:  func([]byte) bool { return true },
+	Binary: true,
+	Print:  hexdump,
+}
+
+func isJSON(b []byte) bool {
+	b = bytes.TrimSpace(b)
+	return len(b) > 0 && json.Valid(b)
+}
+
+// printable reports whether b is text that is safe to print to a terminal:
+// valid UTF-8 without control characters other than whitespace.
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, c := range b {
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffed reports whether net/http's content sniffing puts b in media type
+// mt.
+func sniffed(b []byte, mt string) bool {
+	t, _, _ := mime.ParseMediaType(http.DetectContentType(b))
+	return t == mt
+}
@@ -0,0 +1,199 @@
+package formatter
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func init() { color.NoColor = true }
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRendererFor(t *testing.T) {
+	mp, _ := msgpack.Marshal(map[string]any{"a": 1})
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"application/json; charset=utf-8", `{"a":1}`, "JSON"},
+		{"application/problem+json", `{"a":1}`, "JSON"},
+		{"text/plain", `{"a":1}`, "JSON"},
+		{"application/xml", "<a>1</a>", "XML"},
+		{"application/atom+xml", "<feed/>", "XML"},
+		{"", `<?xml version="1.0"?><a/>`, "XML"},
+		{"text/html; charset=utf-8", "<p>hi</p>", "HTML"},
+		{"", "<!DOCTYPE html><html></html>", "HTML"},
+		{"application/yaml", "a: 1\n", "YAML"},
+		{"text/yaml", `["a"]`, "JSON"}, // valid JSON is always shown as JSON
+		{"text/csv", "a,b\n1,2\n", "CSV"},
+		{"text/tab-separated-values", "a\tb\n", "TSV"},
+		{"application/msgpack", string(mp), "MessagePack"},
+		{"application/cbor", "\x01", "CBOR"},
+		{"image/png", string(pngBytes(t, 1, 1)), "image"},
+		{"application/octet-stream", string(pngBytes(t, 1, 1)), "image"},
+		{"text/markdown", "# Hi", "text"},
+		{"", "hello", "text"},
+		{"application/octet-stream", "\x00\x01\x02", "binary"},
+		{"application/x-unknown", "\x00\x01\x02", "binary"},
+	}
+	for _, tt := range tests {
+		if got := RendererFor(tt.contentType, []byte(tt.body)).Name; got != tt.want {
+			t.Errorf("RendererFor(%q, %q) = %s, want %s", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	mp, _ := msgpack.Marshal(map[string]any{"id": 7, "tags": []string{"x"}, "raw": []byte{0xff}})
+	cb, _ := cbor.Marshal(map[any]any{"id": 7, 1: 2.5})
+	tests := []struct {
+		contentType string
+		body        []byte
+		want        any
+	}{
+		{"application/xml", []byte(`<users count="2"><user id="1">Ada</user><user id="2">Grace</user><empty/></users>`),
+			map[string]any{"users": map[string]any{
+				"@count": "2",
+				"user": []any{
+					map[string]any{"@id": "1", "#text": "Ada"},
+					map[string]any{"@id": "2", "#text": "Grace"},
+				},
+				"empty": nil,
+			}}},
+		{"application/yaml", []byte("name: ada\nage: 36\ntags: [a, b]\n"),
+			map[string]any{"name": "ada", "age": 36, "tags": []any{"a", "b"}}},
+		{"text/csv", []byte("id,name\n1,Ada\n2\n"),
+			[]any{map[string]any{"id": "1", "name": "Ada"}, map[string]any{"id": "2", "name": nil}}},
+		{"application/msgpack", mp, map[string]any{"id": 7, "tags": []any{"x"}, "raw": "/w=="}},
+		{"application/cbor", cb, map[string]any{"id": 7, "1": 2.5}},
+	}
+	for _, tt := range tests {
+		r := RendererFor(tt.contentType, tt.body)
+		got, err := r.Decode(tt.body)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s Decode = %#v, %v; want %#v", r.Name, got, err, tt.want)
+		}
+	}
+
+	info, _ := RendererFor("image/png", pngBytes(t, 3, 2)).Decode(pngBytes(t, 3, 2))
+	m := info.(map[string]any)
+	if m["format"] != "png" || m["width"] != 3 || m["height"] != 2 || m["color_model"] != "NRGBA" {
+		t.Errorf("image info = %v", m)
+	}
+}
+
+func render(t *testing.T, contentType, body string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := RendererFor(contentType, []byte(body)).Print(&out, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestPrint(t *testing.T) {
+	xml := render(t, "application/xml", `<?xml version="1.0"?><!-- users --><users><user id="1">Ada</user><empty/></users>`)
+	want := `<?xml version="1.0"?>
+<!-- users -->
+<users>
+  <user id="1">Ada</user>
+  <empty/>
+</users>
+`
+	if xml != want {
+		t.Errorf("XML:\n%s\nwant:\n%s", xml, want)
+	}
+
+	html := render(t, "text/html", "<ul><li>one<br><li>two</ul>")
+	if !strings.Contains(html, "<br/>") || !strings.Contains(html, "two") {
+		t.Errorf("HTML:\n%s", html)
+	}
+
+	table := render(t, "text/csv", "id,name\n1,Ada Lovelace\n2,"+strings.Repeat("x", 50)+"\n")
+	want = `id │ name
+───┼─────────────────────────────────────────
+1  │ Ada Lovelace
+2  │ ` + strings.Repeat("x", 39) + `…
+2 rows
+`
+	if table != want {
+		t.Errorf("CSV:\n%s\nwant:\n%s", table, want)
+	}
+
+	img := render(t, "image/png", string(pngBytes(t, 640, 480)))
+	if !strings.Contains(img, "PNG image, 640×480") {
+		t.Errorf("image: %s", img)
+	}
+
+	hex := render(t, "application/octet-stream", "\x00\x01ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	if !strings.Contains(hex, "00000000  00 01 41 42 43 44 45 46  47 48 49 4a 4b 4c 4d 4e  |..ABCDEFGHIJKLMN|") {
+		t.Errorf("hexdump:\n%s", hex)
+	}
+	if long := render(t, "", strings.Repeat("\x00", 600)); !strings.Contains(long, "… 88 more bytes") {
+		t.Errorf("hexdump should stop at 512 bytes:\n%s", long)
+	}
+}
+
+func TestPrintBody(t *testing.T) {
+	old := color.Output
+	defer func() { color.Output = old }()
+	var out bytes.Buffer
+	color.Output = &out
+
+	if err := PrintBody([]byte(`<users><user id="1">Ada</user></users>`), "text/xml", `.users.user["@id"]`); err != nil || out.String() != "\"1\"\n" {
+		t.Errorf("--jq on XML = %q, %v", out.String(), err)
+	}
+	if err := PrintBody([]byte("plain"), "text/plain", ".a"); err == nil {
+		t.Error("--jq on plain text should fail")
+	}
+
+	// A body that isn't what its Content-Type says is still shown
+	out.Reset()
+	if err := PrintBody([]byte("not <xml"), "application/xml", ""); err != nil || out.String() != "not <xml\n" {
+		t.Errorf("malformed XML = %q, %v", out.String(), err)
+	}
+}
+
+// writeCounter counts the writes it gets.
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestFprintBody_StreamsJSON(t *testing.T) {
+	body := []byte("[" + strings.Repeat(`{"id": 1, "name": "Ada"},`, 20000) + `{"id": 2}]`)
+	var w writeCounter
+	if err := FprintBody(&w, body, "application/json", ""); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes < 2 || !strings.HasSuffix(w.String(), "\"id\": 2\n  }\n]\n") {
+		t.Errorf("%d writes, ending %q", w.writes, w.String()[w.Len()-20:])
+	}
+
+	// invalid JSON is still buffered, and falls back to text
+	w = writeCounter{}
+	if err := FprintBody(&w, []byte(`{"id": 1,`), "application/json", ""); err != nil || w.String() != "{\"id\": 1,\n" {
+		t.Errorf("invalid JSON = %q, %v", w.String(), err)
+	}
+}
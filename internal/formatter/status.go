@@ -2,6 +2,7 @@ package formatter
 
 import (
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/fatih/color"
@@ -114,6 +115,12 @@ var statusMessages = map[int]StatusInfo{
 }
 
 func PrintStatusLine(method, url string, statusCode int, duration time.Duration) {
+	FprintStatusLine(color.Output, method, url, statusCode, duration)
+}
+
+// FprintStatusLine writes the status line, and the explanation of a non-2xx
+// status, to w.
+func FprintStatusLine(w io.Writer, method, url string, statusCode int, duration time.Duration) {
 	// Color codes based on status
 	var statusColor *color.Color
 	var arrow string
@@ -151,7 +158,7 @@ func PrintStatusLine(method, url string, statusCode int, duration time.Duration)
 		durationColor = color.New(color.FgRed)
 	}
 
-	fmt.Fprintf(color.Output, "%s %s %s %s %s\n",
+	fmt.Fprintf(w, "%s %s %s %s %s\n",
 		arrow,
 		methodColor.Sprint(method),
 		url,
@@ -161,11 +168,15 @@ func PrintStatusLine(method, url string, statusCode int, duration time.Duration)
 
 	// Print status explanation for non-2xx responses
 	if statusCode < 200 || statusCode >= 300 {
-		PrintStatusExplanation(statusCode)
+		fprintStatusExplanation(w, statusCode)
 	}
 }
 
 func PrintStatusExplanation(statusCode int) {
+	fprintStatusExplanation(color.Output, statusCode)
+}
+
+func fprintStatusExplanation(w io.Writer, statusCode int) {
 	info, exists := statusMessages[statusCode]
 	if !exists {
 		// Generic message for unknown status codes
@@ -188,14 +199,14 @@ func PrintStatusExplanation(statusCode int) {
 
 	// Print emoji and description
 	titleColor := color.New(color.FgCyan, color.Bold)
-	fmt.Fprintf(color.Output, "\n%s  %s\n", info.Emoji, titleColor.Sprint(info.Description))
+	fmt.Fprintf(w, "\n%s  %s\n", info.Emoji, titleColor.Sprint(info.Description))
 
 	// Print tips if available
 	if len(info.Tips) > 0 {
 		tipColor := color.New(color.FgYellow)
-		fmt.Fprintf(color.Output, "\n%s\n", color.New(color.FgWhite, color.Bold).Sprint("💡 Tips:"))
+		fmt.Fprintf(w, "\n%s\n", color.New(color.FgWhite, color.Bold).Sprint("💡 Tips:"))
 		for _, tip := range info.Tips {
-			fmt.Fprintf(color.Output, "  • %s\n", tipColor.Sprint(tip))
+			fmt.Fprintf(w, "  • %s\n", tipColor.Sprint(tip))
 		}
 	}
 	fmt.Println()
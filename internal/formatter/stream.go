@@ -0,0 +1,50 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/humancto/mozzy/internal/jq"
+	"github.com/humancto/mozzy/internal/stream"
+)
+
+// PrintEvent prints one streamed event as soon as it arrives. SSE events get
+// a dim header with their type and ID unless a jq query is given, in which
+// case only the query results are printed so the output can be piped.
+func PrintEvent(ev stream.Event, jqQuery string) error {
+	return FprintEvent(color.Output, ev, jqQuery)
+}
+
+// FprintEvent is PrintEvent writing to w.
+func FprintEvent(w io.Writer, ev stream.Event, jqQuery string) error {
+	if jqQuery != "" && jqQuery != "." {
+		results, err := jq.Eval(jqQuery, ev.Value())
+		if err != nil {
+			return fmt.Errorf("jq query failed: %w", err)
+		}
+		return printResults(w, results)
+	}
+
+	if ev.Format == stream.SSE {
+		var meta []string
+		if ev.Event != "message" {
+			meta = append(meta, "event: "+ev.Event)
+		}
+		if ev.ID != "" {
+			meta = append(meta, "id: "+ev.ID)
+		}
+		if ev.Retry > 0 {
+			meta = append(meta, "retry: "+ev.Retry.String())
+		}
+		if len(meta) > 0 {
+			fmt.Fprintln(w, color.New(color.FgHiBlack).Sprint("── "+strings.Join(meta, "  ")))
+		}
+	}
+	if ev.Format == stream.Lines {
+		fmt.Fprintln(w, ev.Data)
+		return nil
+	}
+	return FprintBody(w, []byte(ev.Data), "", "")
+}
@@ -0,0 +1,233 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/humancto/mozzy/internal/jq"
+	"github.com/humancto/mozzy/internal/ui"
+)
+
+// Table formats accepted by --table.
+const (
+	TableBox      = "box"
+	TableCSV      = "csv"
+	TableTSV      = "tsv"
+	TableMarkdown = "markdown"
+)
+
+// ParseTableFormat validates a --table value; empty means no table.
+func ParseTableFormat(s string) (string, error) {
+	switch f := strings.ToLower(s); f {
+	case "", TableBox, TableCSV, TableTSV:
+		return f, nil
+	case TableMarkdown, "md":
+		return TableMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown table format %q (use box, csv, tsv or markdown)", s)
+}
+
+// TableOptions control how a list of records is laid out.
+type TableOptions struct {
+	Format string
+	// Columns are dot paths into each record, e.g. "id" or "owner.login";
+	// empty means every top-level key.
+	Columns []string
+	// SortBy is a column path, prefixed with "-" to sort descending.
+	SortBy string
+	// Width is the terminal width the box table is fitted to; 0 leaves the
+	// columns as wide as their widest cell.
+	Width int
+}
+
+// PrintTable prints a body holding an array of objects as a table. The body
+// is decoded by its renderer, so JSON, YAML, XML, CSV, MessagePack and CBOR
+// lists all work, and a jq query can first select the array.
+func PrintTable(w io.Writer, b []byte, contentType, jqQuery string, opts TableOptions) error {
+	r := RendererFor(contentType, b)
+	if r.Decode == nil {
+		return fmt.Errorf("a %s body can't be shown as a table", r.Name)
+	}
+	v, err := r.Decode(b)
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", r.Name, err)
+	}
+	if jqQuery != "" && jqQuery != "." {
+		results, err := jq.Eval(jqQuery, v)
+		if err != nil {
+			return fmt.Errorf("jq query failed: %w", err)
+		}
+		v = results
+		if len(results) == 1 {
+			v = results[0]
+		}
+	}
+
+	t, err := BuildTable(v, opts)
+	if err != nil {
+		return err
+	}
+	switch opts.Format {
+	case TableCSV:
+		_, err = io.WriteString(w, t.CSV(','))
+	case TableTSV:
+		_, err = io.WriteString(w, t.CSV('\t'))
+	case TableMarkdown:
+		_, err = io.WriteString(w, t.Markdown())
+	default:
+		t.Width = opts.Width
+		_, err = io.WriteString(w, t.Render())
+	}
+	return err
+}
+
+// BuildTable lays out v, which must be an array, with one row per element.
+// Elements that aren't objects are shown in a single "value" column.
+func BuildTable(v any, opts TableOptions) (*ui.Table, error) {
+	items, ok := v.([]any)
+	if !ok {
+		if m, isObj := v.(map[string]any); isObj {
+			items = []any{m} // a single record is a one-row table
+		} else {
+			return nil, fmt.Errorf("--table needs an array of objects, got %s; select one with --jq", kind(v))
+		}
+	}
+
+	columns := make([]string, len(opts.Columns))
+	for i, c := range opts.Columns {
+		columns[i] = strings.TrimPrefix(strings.TrimSpace(c), ".")
+	}
+	if len(columns) == 0 {
+		columns = keys(items)
+	}
+
+	if opts.SortBy != "" {
+		path, desc := strings.CutPrefix(strings.TrimPrefix(opts.SortBy, "."), "-")
+		path = strings.TrimPrefix(path, ".")
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := lookup(items[i], path), lookup(items[j], path)
+			if a == nil || b == nil {
+				return a != nil // missing values go last either way
+			}
+			if desc {
+				return less(b, a)
+			}
+			return less(a, b)
+		})
+	}
+
+	t := ui.NewTable(columns)
+	for _, item := range items {
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = cellText(lookup(item, c))
+		}
+		t.AddRow(row)
+	}
+	return t, nil
+}
+
+// keys returns the top-level keys of the objects in items, sorted as jq
+// prints them; scalars give a "value" column.
+func keys(items []any) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, it := range items {
+		m, ok := it.(map[string]any)
+		if !ok {
+			return []string{"value"}
+		}
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// lookup follows a dot path such as "owner.login" or "tags.0" into v; the
+// path "value" on a scalar is the scalar itself.
+func lookup(v any, path string) any {
+	if _, ok := v.(map[string]any); !ok && path == "value" {
+		return v
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch x := v.(type) {
+		case map[string]any:
+			v = x[part]
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < -len(x) || i >= len(x) {
+				return nil
+			}
+			if i < 0 {
+				i += len(x)
+			}
+			v = x[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// less orders numbers numerically and everything else by its text.
+func less(a, b any) bool {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return x < y
+		}
+	}
+	return cellText(a) < cellText(b)
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	}
+	return 0, false
+}
+
+// cellText shows strings as is and other values as compact JSON.
+func cellText(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	return "a number"
+}
@@ -0,0 +1,90 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const users = `[
+  {"id": 2, "name": "Grace", "owner": {"login": "gh"}, "tags": ["b"]},
+  {"id": 10, "name": "Ada", "owner": {"login": "al"}, "tags": ["a", "c"], "bio": "<b>hi</b>"},
+  {"id": 1, "name": "Linus"}
+]`
+
+func table(t *testing.T, body, contentType, query string, opts TableOptions) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := PrintTable(&out, []byte(body), contentType, query, opts); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestPrintTable(t *testing.T) {
+	got := table(t, users, "application/json", "", TableOptions{Format: TableCSV})
+	want := `bio,id,name,owner,tags
+,2,Grace,"{""login"":""gh""}","[""b""]"
+<b>hi</b>,10,Ada,"{""login"":""al""}","[""a"",""c""]"
+,1,Linus,,
+`
+	if got != want {
+		t.Errorf("default columns:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = table(t, users, "application/json", "", TableOptions{Format: TableTSV, Columns: []string{"id", ".owner.login", "tags.0", "tags.-1"}, SortBy: "id"})
+	want = "id\towner.login\ttags.0\ttags.-1\n1\t\t\t\n2\tgh\tb\tb\n10\tal\ta\tc\n"
+	if got != want {
+		t.Errorf("paths sorted by id:\n%q\nwant:\n%q", got, want)
+	}
+
+	got = table(t, users, "", "", TableOptions{Format: TableMarkdown, Columns: []string{"name", "owner.login"}, SortBy: "-owner.login"})
+	want = "| name | owner.login |\n| --- | --- |\n| Grace | gh |\n| Ada | al |\n| Linus |  |\n"
+	if got != want {
+		t.Errorf("descending, missing last:\n%s\nwant:\n%s", got, want)
+	}
+
+	// --jq selects the array first; other formats decode too
+	got = table(t, `{"data": {"items": [{"n": 1}, {"n": 2}]}}`, "application/json", ".data.items", TableOptions{Format: TableCSV})
+	if got != "n\n1\n2\n" {
+		t.Errorf("with --jq: %q", got)
+	}
+	got = table(t, "id,name\n1,Ada\n", "text/csv", ".[] | select(.id == \"1\")", TableOptions{Format: TableCSV})
+	if got != "id,name\n1,Ada\n" {
+		t.Errorf("CSV body with --jq: %q", got)
+	}
+	got = table(t, users, "application/json", ".[].name", TableOptions{Format: TableCSV})
+	if got != "value\nGrace\nAda\nLinus\n" {
+		t.Errorf("scalars: %q", got)
+	}
+
+	for _, body := range []string{`"text"`, `42`} {
+		if err := PrintTable(&bytes.Buffer{}, []byte(body), "application/json", "", TableOptions{}); err == nil {
+			t.Errorf("%s should not make a table", body)
+		}
+	}
+}
+
+func TestPrintTable_FitsWidth(t *testing.T) {
+	body := `[{"id": 1, "description": "` + strings.Repeat("long text ", 20) + `"}]`
+	got := table(t, body, "application/json", "", TableOptions{Format: TableBox, Width: 40})
+	for _, line := range strings.Split(strings.TrimSpace(got), "\n") {
+		if w := len([]rune(line)); w > 40 {
+			t.Errorf("line is %d wide: %q", w, line)
+		}
+	}
+	if !strings.Contains(got, "│ long text long text long tex... │ 1  │") {
+		t.Errorf("long cell should be truncated:\n%s", got)
+	}
+}
+
+func TestParseTableFormat(t *testing.T) {
+	for in, want := range map[string]string{"": "", "box": TableBox, "CSV": TableCSV, "md": TableMarkdown} {
+		if got, err := ParseTableFormat(in); err != nil || got != want {
+			t.Errorf("ParseTableFormat(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseTableFormat("html"); err == nil {
+		t.Error("html should be rejected")
+	}
+}
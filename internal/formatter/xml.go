@@ -0,0 +1,205 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+func init() {
+	Register(&Renderer{
+		Name:   "XML",
+		Types:  []string{"application/xml", "text/xml", "+xml"},
+		Sniff:  func(b []byte) bool { return bytes.HasPrefix(bytes.TrimSpace(b), []byte("<?xml")) },
+		Decode: decodeXML,
+		Print: func(w io.Writer, b []byte) error {
+			root, err := parseXML(b, false)
+			if err != nil {
+				return err
+			}
+			printXMLNodes(w, root.children, 0)
+			return nil
+		},
+	})
+	Register(&Renderer{
+		Name:  "HTML",
+		Types: []string{"text/html", "application/xhtml+xml"},
+		Sniff: func(b []byte) bool { return sniffed(b, "text/html") },
+		Print: func(w io.Writer, b []byte) error {
+			root, err := parseXML(b, true)
+			if err != nil {
+				return err
+			}
+			printXMLNodes(w, root.children, 0)
+			return nil
+		},
+	})
+}
+
+// xmlNode is an element, or for text, comments and directives just the raw
+// content.
+type xmlNode struct {
+	kind     string // "element", "text", "comment", "proc" or "directive"
+	name     string
+	attrs    []xml.Attr
+	text     string
+	children []*xmlNode
+}
+
+// parseXML reads a document into a tree, keeping namespace prefixes as
+// written. html parses loosely, closing void elements such as <br>.
+func parseXML(b []byte, html bool) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	next := d.RawToken
+	if html {
+		d.Strict = false
+		d.AutoClose = xml.HTMLAutoClose
+		d.Entity = xml.HTMLEntity
+		next = d.Token
+	}
+	root := &xmlNode{kind: "element"}
+	stack := []*xmlNode{root}
+	for {
+		tok, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{kind: "element", name: xmlName(t.Name), attrs: t.Attr}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected </%s>", xmlName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if s := strings.TrimSpace(string(t)); s != "" {
+				parent.children = append(parent.children, &xmlNode{kind: "text", text: s})
+			}
+		case xml.Comment:
+			parent.children = append(parent.children, &xmlNode{kind: "comment", text: strings.TrimSpace(string(t))})
+		case xml.ProcInst:
+			parent.children = append(parent.children, &xmlNode{kind: "proc", name: t.Target, text: string(t.Inst)})
+		case xml.Directive:
+			parent.children = append(parent.children, &xmlNode{kind: "directive", text: string(t)})
+		}
+	}
+	if len(stack) != 1 && !html {
+		return nil, fmt.Errorf("unclosed <%s>", stack[len(stack)-1].name)
+	}
+	return root, nil
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+func printXMLNodes(w io.Writer, nodes []*xmlNode, depth int) {
+	tag := color.New(color.FgBlue, color.Bold)
+	attr := color.New(color.FgCyan)
+	value := color.New(color.FgGreen)
+	gray := color.New(color.FgHiBlack)
+
+	indent := strings.Repeat("  ", depth)
+	for _, n := range nodes {
+		switch n.kind {
+		case "text":
+			fmt.Fprintf(w, "%s%s\n", indent, xmlEscape(n.text))
+		case "comment":
+			fmt.Fprintf(w, "%s%s\n", indent, gray.Sprintf("<!-- %s -->", n.text))
+		case "proc":
+			fmt.Fprintf(w, "%s%s\n", indent, gray.Sprintf("<?%s %s?>", n.name, n.text))
+		case "directive":
+			fmt.Fprintf(w, "%s%s\n", indent, gray.Sprintf("<!%s>", n.text))
+		case "element":
+			fmt.Fprintf(w, "%s%s", indent, tag.Sprint("<"+n.name))
+			for _, a := range n.attrs {
+				fmt.Fprintf(w, " %s=%s", attr.Sprint(xmlName(a.Name)), value.Sprintf("%q", a.Value))
+			}
+			switch {
+			case len(n.children) == 0:
+				fmt.Fprintln(w, tag.Sprint("/>"))
+			case len(n.children) == 1 && n.children[0].kind == "text" && !strings.Contains(n.children[0].text, "\n"):
+				// Short text stays on the element's line
+				fmt.Fprintf(w, "%s%s%s\n", tag.Sprint(">"), xmlEscape(n.children[0].text), tag.Sprintf("</%s>", n.name))
+			default:
+				fmt.Fprintln(w, tag.Sprint(">"))
+				printXMLNodes(w, n.children, depth+1)
+				fmt.Fprintf(w, "%s%s\n", indent, tag.Sprintf("</%s>", n.name))
+			}
+		}
+	}
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// decodeXML converts a document for --jq the way xmltodict does: attributes
+// become "@name" keys, text next to attributes or children "#text", and
+// repeated children an array.
+func decodeXML(b []byte) (any, error) {
+	root, err := parseXML(b, false)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	for _, n := range root.children {
+		if n.kind == "element" {
+			out[n.name] = xmlValue(n)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no root element")
+	}
+	return out, nil
+}
+
+func xmlValue(n *xmlNode) any {
+	m := map[string]any{}
+	for _, a := range n.attrs {
+		m["@"+xmlName(a.Name)] = a.Value
+	}
+	var text []string
+	for _, c := range n.children {
+		switch c.kind {
+		case "text":
+			text = append(text, c.text)
+		case "element":
+			v := xmlValue(c)
+			prev, seen := m[c.name]
+			if list, ok := prev.([]any); ok {
+				m[c.name] = append(list, v)
+			} else if seen {
+				m[c.name] = []any{prev, v}
+			} else {
+				m[c.name] = v
+			}
+		}
+	}
+	if len(m) == 0 {
+		if len(text) == 0 {
+			return nil
+		}
+		return strings.Join(text, " ")
+	}
+	if len(text) > 0 {
+		m["#text"] = strings.Join(text, " ")
+	}
+	return m
+}
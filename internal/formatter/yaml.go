@@ -0,0 +1,56 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	Register(&Renderer{
+		Name:   "YAML",
+		Types:  []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml", "+yaml"},
+		Decode: decodeYAML,
+		Print:  printYAML,
+	})
+}
+
+func decodeYAML(b []byte) (any, error) {
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return plain(v), nil
+}
+
+// yamlKey matches "key:" at the start of a line, optionally as a list item.
+var yamlKey = regexp.MustCompile(`^(\s*(?:- )?)("[^"]*"|'[^']*'|[^\s#'"][^:#]*?):(\s|$)`)
+
+// printYAML prints the document as written, with keys and comments
+// coloured; it only has to parse.
+func printYAML(w io.Writer, b []byte) error {
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	key := color.New(color.FgCyan, color.Bold)
+	gray := color.New(color.FgHiBlack)
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#"), trimmed == "---", trimmed == "...":
+			fmt.Fprintln(w, gray.Sprint(line))
+		case yamlKey.MatchString(line):
+			m := yamlKey.FindStringSubmatchIndex(line)
+			fmt.Fprintf(w, "%s%s:%s\n", line[:m[3]], key.Sprint(line[m[4]:m[5]]), line[m[5]+1:])
+		default:
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
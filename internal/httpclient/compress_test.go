@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/humancto/mozzy/internal/compress"
+)
+
+func TestDoRequest_Compression(t *testing.T) {
+	plain := []byte(strings.Repeat(`{"ok":true}`, 50))
+	var gotBody []byte
+	var gotEncoding, gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAccept = r.Header.Get("Accept-Encoding")
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gotBody, _ = io.ReadAll(r.Body)
+		enc, _ := compress.Encode(plain, "zstd")
+		w.Header().Set("Content-Encoding", "zstd")
+		w.Write(enc)
+	}))
+	defer srv.Close()
+
+	r := Request{Method: "POST", URL: srv.URL, Body: plain, Compress: "gzip"}
+	res, body, _, v, err := doRequest(context.Background(), r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, plain) {
+		t.Errorf("zstd response not decoded: %q", body)
+	}
+	if res.Header.Get("Content-Encoding") != "" || res.Header.Get("Content-Length") != "" || res.ContentLength != -1 {
+		t.Errorf("decoded response keeps its wire headers: %v, length %d", res.Header, res.ContentLength)
+	}
+	if gotAccept != compress.AcceptEncoding {
+		t.Errorf("Accept-Encoding = %q", gotAccept)
+	}
+	if sent, err := compress.Decode(gotBody, gotEncoding); gotEncoding != "gzip" || err != nil || !bytes.Equal(sent, plain) {
+		t.Errorf("request body sent as %q: %v", gotEncoding, err)
+	}
+	if v.DecodedSize != int64(len(plain)) || v.WireSize >= v.DecodedSize || v.CompressionRatio <= 0 {
+		t.Errorf("sizes: wire %d, decoded %d, ratio %.1f", v.WireSize, v.DecodedSize, v.CompressionRatio)
+	}
+	if v.RequestEncoding != "gzip" || v.RequestRatio <= 0 {
+		t.Errorf("request encoding %q ratio %.1f", v.RequestEncoding, v.RequestRatio)
+	}
+
+	// --raw keeps the wire bytes
+	r.Raw = true
+	res, body, _, v, err = doRequest(context.Background(), r, false)
+	if err != nil || bytes.Equal(body, plain) || int64(len(body)) != v.WireSize {
+		t.Errorf("raw body = %d bytes, %v", len(body), err)
+	}
+	if res.Header.Get("Content-Encoding") != "zstd" {
+		t.Errorf("raw response should keep Content-Encoding, got %v", res.Header)
+	}
+
+	// Streams are decoded as they are read
+	r.Raw = false
+	res, _, _, _, err = doRequest(context.Background(), r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "" {
+		t.Errorf("decoded stream keeps Content-Encoding: %v", res.Header)
+	}
+	if streamed, _ := io.ReadAll(res.Body); !bytes.Equal(streamed, plain) {
+		t.Errorf("streamed body not decoded: %q", streamed)
+	}
+}
@@ -0,0 +1,192 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DialOptions controls how host names are turned into connections, like
+// curl's --resolve, --connect-to and --dns-servers.
+type DialOptions struct {
+	Resolve    []string // "host:port:addr[,addr...]" pins host:port to addresses
+	ConnectTo  []string // "host:port:connect-host:connect-port"; empty parts match/keep anything
+	DNSServers []string // "ip[:port]" servers used instead of the system resolver
+	UnixSocket string   // send every request over this Unix domain socket
+}
+
+func (o DialOptions) empty() bool {
+	return len(o.Resolve) == 0 && len(o.ConnectTo) == 0 && len(o.DNSServers) == 0 && o.UnixSocket == ""
+}
+
+type connectRule struct {
+	host, port     string
+	toHost, toPort string
+}
+
+// dialer applies the overrides before connecting. The HTTP request keeps its
+// original host, so the Host header and TLS SNI are unchanged.
+type dialer struct {
+	net.Dialer
+	resolve   map[string][]string // "host:port" → addresses
+	connectTo []connectRule
+	resolver  *net.Resolver
+	socket    string
+}
+
+func newDialer(o DialOptions) (*dialer, error) {
+	d := &dialer{
+		Dialer:  net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		resolve: map[string][]string{},
+		socket:  o.UnixSocket,
+	}
+
+	for _, entry := range o.Resolve {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid --resolve %q (want host:port:addr)", entry)
+		}
+		var addrs []string
+		for _, a := range strings.Split(parts[2], ",") {
+			a = strings.Trim(strings.TrimSpace(a), "[]")
+			if net.ParseIP(a) == nil {
+				return nil, fmt.Errorf("invalid --resolve %q: %q is not an IP address", entry, a)
+			}
+			addrs = append(addrs, a)
+		}
+		d.resolve[strings.ToLower(parts[0])+":"+parts[1]] = addrs
+	}
+
+	for _, entry := range o.ConnectTo {
+		parts := splitHostPorts(entry)
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid --connect-to %q (want host:port:connect-host:connect-port)", entry)
+		}
+		d.connectTo = append(d.connectTo, connectRule{
+			host: strings.ToLower(parts[0]), port: parts[1],
+			toHost: parts[2], toPort: parts[3],
+		})
+	}
+
+	if len(o.DNSServers) > 0 {
+		var servers []string
+		for _, s := range o.DNSServers {
+			s = strings.TrimSpace(s)
+			if _, _, err := net.SplitHostPort(s); err != nil {
+				s = net.JoinHostPort(strings.Trim(s, "[]"), "53")
+			}
+			servers = append(servers, s)
+		}
+		d.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var last error
+				ctx, cancel := untraced(ctx)
+				defer cancel()
+				for _, s := range servers {
+					conn, err := d.Dialer.DialContext(ctx, network, s)
+					if err == nil {
+						return conn, nil
+					}
+					last = err
+				}
+				return nil, last
+			},
+		}
+	}
+	return d, nil
+}
+
+// untraced returns a context with ctx's deadline and cancellation but not
+// its values, so that dialing a DNS server doesn't fire the request's
+// httptrace connect hooks, concurrently for A and AAAA lookups.
+func untraced(ctx context.Context) (context.Context, context.CancelFunc) {
+	plain, cancel := context.WithCancel(context.Background())
+	if deadline, ok := ctx.Deadline(); ok {
+		plain, cancel = context.WithDeadline(context.Background(), deadline)
+	}
+	stop := context.AfterFunc(ctx, cancel)
+	return plain, func() {
+		stop()
+		cancel()
+	}
+}
+
+// splitHostPorts splits "a:b:c:d" while allowing bracketed IPv6 hosts.
+func splitHostPorts(s string) []string {
+	parts := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		if strings.HasPrefix(s, "[") {
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil
+			}
+			parts = append(parts, s[1:end])
+			s = strings.TrimPrefix(s[end+1:], ":")
+			continue
+		}
+		if i == 3 {
+			parts = append(parts, s)
+			break
+		}
+		field, rest, ok := strings.Cut(s, ":")
+		if !ok {
+			return nil
+		}
+		parts = append(parts, field)
+		s = rest
+	}
+	return parts
+}
+
+// DialContext applies --connect-to, then --resolve, then the custom resolver.
+// With a Unix socket every connection goes to the socket instead.
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.socket != "" {
+		return d.Dialer.DialContext(ctx, "unix", d.socket)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range d.connectTo {
+		if (r.host == "" || r.host == strings.ToLower(host)) && (r.port == "" || r.port == port) {
+			if r.toHost != "" {
+				host = r.toHost
+			}
+			if r.toPort != "" {
+				port = r.toPort
+			}
+			break
+		}
+	}
+
+	addrs, ok := d.resolve[strings.ToLower(host)+":"+port]
+	if !ok && d.resolver != nil && net.ParseIP(host) == nil {
+		ips, err := d.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, ip.IP.String())
+		}
+	}
+	if len(addrs) == 0 {
+		return d.Dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+	}
+
+	var errs []error
+	for _, a := range addrs {
+		conn, err := d.Dialer.DialContext(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,146 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func hostEchoServer(t *testing.T) (port string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	t.Cleanup(srv.Close)
+	_, port, _ = net.SplitHostPort(srv.Listener.Addr().String())
+	return port
+}
+
+func TestDoRequest_Resolve(t *testing.T) {
+	port := hostEchoServer(t)
+
+	r := Request{
+		Method: "GET",
+		URL:    "http://api.example.test:" + port + "/",
+		Dial:   DialOptions{Resolve: []string{"api.example.test:" + port + ":127.0.0.2,127.0.0.1"}},
+	}
+	_, body, timings, info, err := doRequest(context.Background(), r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "api.example.test:"+port {
+		t.Errorf("Host = %q, want original host", body)
+	}
+	if info.ResolvedIP != "127.0.0.1" {
+		t.Errorf("ResolvedIP = %q, want 127.0.0.1", info.ResolvedIP)
+	}
+	if timings.DNSLookup != 0 {
+		t.Errorf("DNSLookup = %v, want 0 for a pinned address", timings.DNSLookup)
+	}
+}
+
+func TestDoRequest_ConnectTo(t *testing.T) {
+	port := hostEchoServer(t)
+
+	r := Request{
+		Method: "GET",
+		URL:    "http://old.example.test/",
+		Dial:   DialOptions{ConnectTo: []string{"other.test::127.0.0.2:1", "old.example.test:80:127.0.0.1:" + port}},
+	}
+	_, body, _, info, err := doRequest(context.Background(), r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "old.example.test" || info.ResolvedIP != "127.0.0.1" {
+		t.Errorf("Host = %q, ResolvedIP = %q", body, info.ResolvedIP)
+	}
+}
+
+func TestDoRequest_DNSServer(t *testing.T) {
+	port := hostEchoServer(t)
+
+	// the DNS server's address differs from its answer, so that dialing it
+	// can't pass for the connection to the resolved address
+	pc, err := net.ListenPacket("udp", "127.0.0.2:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go serveDNS(pc, net.IPv4(127, 0, 0, 1))
+
+	r := Request{
+		Method: "GET",
+		URL:    "http://only-in-custom-dns.test:" + port + "/",
+		Dial:   DialOptions{DNSServers: []string{pc.LocalAddr().String()}},
+	}
+	_, body, timings, info, err := doRequest(context.Background(), r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(body), "only-in-custom-dns.test") {
+		t.Errorf("Host = %q", body)
+	}
+	if info.ResolvedIP != "127.0.0.1" || timings.DNSLookup <= 0 {
+		t.Errorf("ResolvedIP = %q, DNSLookup = %v", info.ResolvedIP, timings.DNSLookup)
+	}
+}
+
+// serveDNS answers every A query with ip and every other query with no
+// records.
+func serveDNS(pc net.PacketConn, ip net.IP) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		q := buf[:n]
+		// question ends after the QNAME labels plus QTYPE and QCLASS
+		end := 12
+		for end < n && q[end] != 0 {
+			end += int(q[end]) + 1
+		}
+		end += 5
+		if end > n {
+			continue
+		}
+		qtype := binary.BigEndian.Uint16(q[end-4 : end-2])
+
+		resp := append([]byte{}, q[:end]...)
+		resp[2] = 0x81 // QR, RD
+		resp[3] = 0x80 // RA
+		binary.BigEndian.PutUint16(resp[6:8], 0)
+		binary.BigEndian.PutUint16(resp[8:10], 0)
+		binary.BigEndian.PutUint16(resp[10:12], 0)
+		if qtype == 1 {
+			binary.BigEndian.PutUint16(resp[6:8], 1)
+			resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+			resp = append(resp, ip.To4()...)
+		}
+		pc.WriteTo(resp, addr)
+	}
+}
+
+func TestNewDialer_Errors(t *testing.T) {
+	for _, o := range []DialOptions{
+		{Resolve: []string{"host:443"}},
+		{Resolve: []string{"host:443:not-an-ip"}},
+		{ConnectTo: []string{"host:443"}},
+	} {
+		if _, err := newDialer(o); err == nil {
+			t.Errorf("newDialer(%+v) should fail", o)
+		}
+	}
+
+	d, err := newDialer(DialOptions{ConnectTo: []string{"[::1]:443:[fe80::1]:8443"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := d.connectTo[0]; r.host != "::1" || r.toHost != "fe80::1" || r.toPort != "8443" {
+		t.Errorf("IPv6 connect-to parsed as %+v", r)
+	}
+}
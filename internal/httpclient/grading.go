@@ -22,6 +22,7 @@ type PerformanceGrade struct {
 	TCP      Grade
 	TLS      Grade
 	TTFB     Grade
+	Redirect Grade // only set when the request was redirected
 	Overall  Grade
 	Total    time.Duration
 }
@@ -32,6 +33,7 @@ var (
 	tcpThresholds = []int{30, 80, 150, 300}    // A: <30ms, B: <80ms, C: <150ms, D: <300ms, F: >=300ms
 	tlsThresholds = []int{50, 100, 200, 400}   // A: <50ms, B: <100ms, C: <200ms, D: <400ms, F: >=400ms
 	ttfbThresholds = []int{100, 300, 800, 1500} // A: <100ms, B: <300ms, C: <800ms, D: <1500ms, F: >=1500ms
+	redirectThresholds = []int{50, 150, 400, 1000} // A: <50ms, B: <150ms, C: <400ms, D: <1000ms, F: >=1000ms
 )
 
 // gradeFromDuration assigns a grade based on duration and thresholds
@@ -134,6 +136,12 @@ func CalculateGrade(timings TimingInfo) PerformanceGrade {
 		grades = append(grades, pg.TTFB)
 	}
 
+	// Grade time lost to redirects before the final request
+	if timings.Redirects > 0 {
+		pg.Redirect = gradeFromDuration(timings.Redirect, redirectThresholds)
+		grades = append(grades, pg.Redirect)
+	}
+
 	// Calculate overall grade
 	pg.Overall = calculateOverallGrade(grades)
 
@@ -160,6 +168,10 @@ func (pg PerformanceGrade) GetRecommendations() []string {
 		recommendations = append(recommendations, "• Time to first byte is high. Server processing may be slow or needs caching")
 	}
 
+	if pg.Redirect == GradeD || pg.Redirect == GradeF {
+		recommendations = append(recommendations, "• Redirects add significant latency. Request the final URL directly")
+	}
+
 	if pg.Overall == GradeA {
 		recommendations = append(recommendations, "✨ Excellent performance! This endpoint is well-optimized")
 	} else if pg.Overall == GradeB {
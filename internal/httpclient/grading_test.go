@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -327,3 +328,25 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestCalculateGrade_Redirects(t *testing.T) {
+	base := TimingInfo{ServerProcessing: 50 * time.Millisecond}
+	if g := CalculateGrade(base); g.Redirect != "" {
+		t.Errorf("no redirects should not be graded, got %q", g.Redirect)
+	}
+
+	slow := base
+	slow.Redirects = 3
+	slow.Redirect = 1200 * time.Millisecond
+	g := CalculateGrade(slow)
+	if g.Redirect != GradeF || g.Overall != GradeC {
+		t.Errorf("Redirect = %q, Overall = %q", g.Redirect, g.Overall)
+	}
+	found := false
+	for _, rec := range g.GetRecommendations() {
+		found = found || strings.Contains(rec, "Redirects")
+	}
+	if !found {
+		t.Error("expected a redirect recommendation")
+	}
+}
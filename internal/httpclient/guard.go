@@ -0,0 +1,196 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/humancto/mozzy/internal/breaker"
+	"github.com/humancto/mozzy/internal/ratelimit"
+)
+
+// Limits throttle and protect the requests sent to one host.
+type Limits struct {
+	Rate    ratelimit.Rate   // zero: unlimited
+	Burst   int              // requests allowed back to back (default 1)
+	Breaker breaker.Settings // Failures 0: no circuit breaker
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l.Rate.IsZero() && l.Burst == 0 && l.Breaker.Failures == 0
+}
+
+// overlay returns l with the fields set in o replacing its own.
+func (l Limits) overlay(o Limits) Limits {
+	if !o.Rate.IsZero() {
+		l.Rate = o.Rate
+	}
+	if o.Burst > 0 {
+		l.Burst = o.Burst
+	}
+	if o.Breaker.Failures > 0 {
+		l.Breaker.Failures = o.Breaker.Failures
+	}
+	if o.Breaker.Cooldown > 0 {
+		l.Breaker.Cooldown = o.Breaker.Cooldown
+	}
+	return l
+}
+
+// Guard holds a rate limiter and circuit breaker per host. Share one Guard
+// between all requests of a run (load workers, workflow steps) so they pace
+// and trip together.
+type Guard struct {
+	hosts    map[string]Limits // "host", "host:port" or "*" for any host
+	override Limits            // command line settings, applied to every host
+
+	mu       sync.Mutex
+	limiters map[string]*ratelimit.Limiter
+	breakers map[string]*breaker.Breaker
+	waited   time.Duration
+	rejected int
+
+	// held while a request may change a breaker's state, so the change is
+	// reported to that request's log
+	reportMu sync.Mutex
+	reportTo io.Writer
+}
+
+// NewGuard returns a guard using hosts (usually from the environment) with
+// override layered on top. It returns nil when no limit is configured.
+func NewGuard(override Limits, hosts map[string]Limits) *Guard {
+	if override.IsZero() && len(hosts) == 0 {
+		return nil
+	}
+	return &Guard{
+		hosts:    hosts,
+		override: override,
+		limiters: map[string]*ratelimit.Limiter{},
+		breakers: map[string]*breaker.Breaker{},
+	}
+}
+
+// Stats returns the total time requests waited for the rate limiter and the
+// number of requests refused by an open breaker.
+func (g *Guard) Stats() (waited time.Duration, rejected int) {
+	if g == nil {
+		return 0, 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.waited, g.rejected
+}
+
+// Limits returns the limits that apply to requests for rawURL.
+func (g *Guard) Limits(rawURL string) Limits {
+	if g == nil {
+		return Limits{}
+	}
+	return g.limits(hostKey(rawURL))
+}
+
+// hostKey is the "host:port" a URL's limiter and breaker are kept under.
+func hostKey(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
+
+// limits looks up host's own entry, then the host name without the port,
+// then "*".
+func (g *Guard) limits(host string) Limits {
+	l, ok := g.hosts[host]
+	if !ok {
+		if u, err := url.Parse("//" + host); err == nil {
+			l, ok = g.hosts[u.Hostname()]
+		}
+	}
+	if !ok {
+		l = g.hosts["*"]
+	}
+	return l.overlay(g.override)
+}
+
+func (g *Guard) get(host string) (*ratelimit.Limiter, *breaker.Breaker) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	lim, ok := g.limiters[host]
+	if !ok {
+		l := g.limits(host)
+		lim = ratelimit.NewLimiter(l.Rate, l.Burst)
+		g.limiters[host] = lim
+		g.breakers[host] = breaker.New(l.Breaker, func(from, to breaker.State, failures int) {
+			reportBreaker(g.reportTo, host, from, to, failures, l.Breaker.Cooldown)
+		})
+	}
+	return lim, g.breakers[host]
+}
+
+// acquire checks the breaker for the request's host and waits for the rate
+// limiter. The returned func records the outcome of the request. Breaker
+// changes are reported to log.
+func (g *Guard) acquire(ctx context.Context, rawURL string, log io.Writer) (func(status int, err error), error) {
+	if g == nil {
+		return func(int, error) {}, nil
+	}
+	host := hostKey(rawURL)
+	lim, br := g.get(host)
+
+	var err error
+	g.report(log, func() { err = br.Allow() })
+	if err != nil {
+		g.mu.Lock()
+		g.rejected++
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+	waited, err := lim.Wait(ctx)
+	if err != nil {
+		br.Release() // never sent, so it says nothing about the host
+		return nil, err
+	}
+	if waited > 0 {
+		g.mu.Lock()
+		g.waited += waited
+		g.mu.Unlock()
+	}
+	return func(status int, err error) {
+		if err != nil && ctx.Err() != nil {
+			br.Release() // cancelled by us, not a failure of the host
+			return
+		}
+		g.report(log, func() { br.Record(err == nil && status < 500) })
+	}, nil
+}
+
+// report runs f, which may change a breaker's state, with changes reported
+// to log.
+func (g *Guard) report(log io.Writer, f func()) {
+	g.reportMu.Lock()
+	defer g.reportMu.Unlock()
+	g.reportTo = log
+	f()
+}
+
+func reportBreaker(w io.Writer, host string, from, to breaker.State, failures int, cooldown time.Duration) {
+	if cooldown <= 0 {
+		cooldown = breaker.DefaultCooldown
+	}
+	switch to {
+	case breaker.Open:
+		if from == breaker.HalfOpen {
+			fmt.Fprintf(w, "🔴 Circuit re-opened for %s: probe failed (next probe in %s)\n", host, cooldown)
+		} else {
+			fmt.Fprintf(w, "🔴 Circuit opened for %s after %d consecutive failures (next probe in %s)\n", host, failures, cooldown)
+		}
+	case breaker.HalfOpen:
+		fmt.Fprintf(w, "🟡 Circuit half-open for %s: sending a probe\n", host)
+	case breaker.Closed:
+		fmt.Fprintf(w, "🟢 Circuit closed for %s: requests flowing again\n", host)
+	}
+}
@@ -0,0 +1,167 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/humancto/mozzy/internal/breaker"
+	"github.com/humancto/mozzy/internal/ratelimit"
+)
+
+func TestGuard_Limits(t *testing.T) {
+	g := NewGuard(Limits{Burst: 5}, map[string]Limits{
+		"*":                    {Rate: ratelimit.Rate{N: 1, Per: time.Second}},
+		"api.example.com":      {Rate: ratelimit.Rate{N: 20, Per: time.Second}, Breaker: breaker.Settings{Failures: 3}},
+		"api.example.com:8443": {Rate: ratelimit.Rate{N: 5, Per: time.Second}},
+	})
+
+	tests := []struct {
+		url      string
+		rate     int
+		failures int
+	}{
+		{"https://api.example.com/users", 20, 3},
+		{"https://api.example.com:8443/users", 5, 0},
+		{"https://other.example.com/", 1, 0},
+	}
+	for _, tt := range tests {
+		l := g.Limits(tt.url)
+		if l.Rate.N != tt.rate || l.Breaker.Failures != tt.failures || l.Burst != 5 {
+			t.Errorf("Limits(%s) = %+v", tt.url, l)
+		}
+	}
+
+	if NewGuard(Limits{}, nil) != nil {
+		t.Error("NewGuard without limits should return nil")
+	}
+}
+
+func TestGuard_BreakerStopsRequests(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	g := NewGuard(Limits{Breaker: breaker.Settings{Failures: 2, Cooldown: time.Hour}}, nil)
+	var log bytes.Buffer
+	r := Request{Method: "GET", URL: srv.URL, Guard: g, Log: &log}
+
+	for i := 0; i < 2; i++ {
+		res, _, _, err := Do(context.Background(), r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	_, _, _, err := Do(context.Background(), r)
+	if !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("third request: err = %v, want open circuit", err)
+	}
+	if _, rejected := g.Stats(); hits != 2 || rejected != 1 {
+		t.Errorf("hits = %d, rejected = %d", hits, rejected)
+	}
+	if !strings.Contains(log.String(), "Circuit opened for 127.0.0.1") {
+		t.Errorf("breaker change should go to the request's log, got %q", log.String())
+	}
+}
+
+func TestGuard_CancelledProbe(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/slow":
+			<-r.Context().Done()
+		case fail.Load():
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	g := NewGuard(Limits{Breaker: breaker.Settings{Failures: 1, Cooldown: 10 * time.Millisecond}}, nil)
+	r := Request{Method: "GET", URL: srv.URL, Guard: g, Log: &bytes.Buffer{}}
+	res, _, _, err := Do(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	fail.Store(false)
+	time.Sleep(20 * time.Millisecond)
+
+	// the probe is cancelled while in flight: the next request probes instead
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	slow := r
+	slow.URL += "/slow"
+	if _, _, _, err := Do(ctx, slow); err == nil {
+		t.Fatal("expected the probe to be cancelled")
+	}
+	res, _, _, err = Do(context.Background(), r)
+	if err != nil {
+		t.Fatalf("request after a cancelled probe: %v", err)
+	}
+	res.Body.Close()
+	if _, br := g.get(hostKey(srv.URL)); br.State() != breaker.Closed {
+		t.Errorf("state = %v, want closed after a successful probe", br.State())
+	}
+}
+
+func TestGuard_ProbeCancelledWhileWaiting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	g := NewGuard(Limits{
+		Rate:    ratelimit.Rate{N: 1, Per: time.Second},
+		Breaker: breaker.Settings{Failures: 1, Cooldown: 10 * time.Millisecond},
+	}, nil)
+	r := Request{Method: "GET", URL: srv.URL, Guard: g, Log: &bytes.Buffer{}}
+	res, _, _, err := Do(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	time.Sleep(20 * time.Millisecond)
+
+	// the probe gives up waiting for the limiter and is never sent
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := Do(ctx, r); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the limiter wait to time out", err)
+	}
+	if _, br := g.get(hostKey(srv.URL)); br.State() != breaker.HalfOpen || br.Allow() != nil {
+		t.Errorf("state = %v, want half-open with the probe released", br.State())
+	}
+}
+
+func TestGuard_RateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	g := NewGuard(Limits{Rate: ratelimit.Rate{N: 50, Per: time.Second}}, nil) // one every 20ms
+	r := Request{Method: "GET", URL: srv.URL, Guard: g}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		res, _, _, err := Do(context.Background(), r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("4 requests at 50/s took %v", elapsed)
+	}
+	if waited, _ := g.Stats(); waited <= 0 {
+		t.Error("expected time spent waiting for the limiter")
+	}
+}
@@ -6,14 +6,18 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/http/httptrace"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/humancto/mozzy/internal/auth"
+	"github.com/humancto/mozzy/internal/compress"
+	"github.com/humancto/mozzy/internal/cookies"
 	"github.com/humancto/mozzy/internal/retry"
 	"github.com/humancto/mozzy/internal/throttle"
 )
@@ -30,6 +34,26 @@ type Request struct {
 	RetryCondition string // e.g., "5xx", ">=500", "429,5xx"
 	CookieJar      string
 	Throttle       string // e.g., "3g", "4g", "slow"
+	TLS            TLSOptions
+	Proxy          ProxyOptions
+	Dial           DialOptions
+	Redirect       RedirectOptions
+	Retry          retry.Options       // backoff, jitter, budget and Idempotency-Key
+	OnAttempt      func(retry.Attempt) // called after every attempt, e.g. for workflow reports
+	Guard          *Guard              // shared rate limiter and circuit breaker, may be nil
+	Auth           auth.Config         // scheme selected with --auth-type; Token is the plain Bearer shortcut
+	Scheme         auth.Scheme         // ready-made scheme such as an OAuth2 token source, used when Auth is unset
+	Compress       string              // compress the body with this coding: gzip or zstd
+	Raw            bool                // keep the response body as sent, without undoing its Content-Encoding
+	Log            io.Writer           // warnings, retries, breaker changes and -v details; default os.Stderr
+}
+
+// log is where the request's messages go.
+func (r Request) log() io.Writer {
+	if r.Log != nil {
+		return r.Log
+	}
+	return os.Stderr
 }
 
 type TimingInfo struct {
@@ -38,11 +62,15 @@ type TimingInfo struct {
 	TLSHandshake     time.Duration
 	ServerProcessing time.Duration
 	ContentTransfer  time.Duration
+	Redirect         time.Duration // time spent on redirects before the final request
+	Redirects        int
 	Total            time.Duration
 }
 
 type VerboseInfo struct {
 	ResolvedIP      string
+	UnixSocket      string // socket path when connected over a Unix socket
+	Proxy           string // proxy URL (credentials redacted), if one was used
 	DNSLatency      time.Duration
 	Protocol        string
 	TLSVersion      string
@@ -53,80 +81,201 @@ type VerboseInfo struct {
 	RequestSize     int64
 	ResponseSize    int64
 	Compressed      bool
-	CompressionRatio float64
+	CompressionRatio float64 // decoded vs wire size of the response body
+	ContentEncoding string
+	WireSize        int64 // response body bytes as received
+	DecodedSize     int64 // response body bytes after decoding
+	RequestEncoding string
+	RequestRatio    float64
+	Hops            []Hop // every request of a redirect chain, final one last
+}
+
+var (
+	jarsMu sync.Mutex
+	jars   = map[string]*cookies.Jar{}
+)
+
+// CookieJar returns the process-wide jar for path, loading it from disk on
+// first use so CLI verbs and workflow steps share the same session.
+func CookieJar(path string) (*cookies.Jar, error) {
+	jarsMu.Lock()
+	defer jarsMu.Unlock()
+	if jar, ok := jars[path]; ok {
+		return jar, nil
+	}
+	jar, err := cookies.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	jars[path] = jar
+	return jar, nil
 }
 
-var globalCookieJar *cookiejar.Jar
+// Result is everything known about a finished request: the final response
+// and body, its timings and connection details, and every attempt made.
+type Result struct {
+	Response *http.Response
+	Body     []byte
+	Timings  TimingInfo
+	Info     VerboseInfo
+	Attempts []retry.Attempt
+}
 
 func Do(ctx context.Context, r Request) (*http.Response, []byte, time.Duration, error) {
-	var timings TimingInfo
-	var verboseInfo VerboseInfo
+	res, err := Send(ctx, r)
+	return res.Response, res.Body, res.Timings.Total, err
+}
+
+// Send is Do returning the whole Result. The Result is never nil, so the
+// attempts made so far are available even when err is set.
+func Send(ctx context.Context, r Request) (*Result, error) {
+	result := &Result{}
 	var res *http.Response
 	var body []byte
 	var err error
 
-	// Parse retry conditions
-	conditions, parseErr := retry.ParseConditions(r.RetryCondition)
-	if parseErr != nil {
-		return nil, nil, 0, fmt.Errorf("invalid retry condition: %w", parseErr)
-	}
-
-	policy := &retry.Policy{
-		MaxRetries: r.RetryCount,
-		Conditions: conditions,
+	policy, err := retry.NewPolicy(r.RetryCount, r.RetryCondition, r.Retry)
+	if err != nil {
+		return result, fmt.Errorf("invalid retry condition: %w", err)
 	}
 
-	// Retry logic with exponential backoff
-	maxRetries := r.RetryCount
-	if maxRetries < 0 {
-		maxRetries = 0
+	// An Idempotency-Key makes POST/PATCH safe to retry; the same key is
+	// sent on every attempt
+	if key := r.Retry.IdempotencyKey; key != "" && !hasHeader(r.Headers, "Idempotency-Key") {
+		if key == "auto" {
+			key = retry.NewIdempotencyKey()
+		}
+		r.Headers = append(append([]string{}, r.Headers...), "Idempotency-Key: "+key)
 	}
+	keyed := hasHeader(r.Headers, "Idempotency-Key")
+	deadline, _ := ctx.Deadline()
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
-			if r.Verbose {
-				fmt.Fprintf(os.Stderr, "⏱  Retry %d/%d after %v backoff...\n", attempt, maxRetries, backoff)
-			}
-			time.Sleep(backoff)
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		done, gerr := r.Guard.acquire(ctx, r.URL, r.log())
+		if gerr != nil {
+			result.Timings.Total = time.Since(start)
+			return result, gerr
 		}
+		attemptStart := time.Now()
+		res, body, result.Timings, result.Info, err = doRequest(ctx, r, false)
 
-		res, body, timings, verboseInfo, err = doRequest(ctx, r)
-
-		// Check if we should retry based on policy
-		shouldRetry := false
-		if attempt < maxRetries {
-			if err != nil {
-				shouldRetry = policy.ShouldRetry(0, err)
-			} else {
-				shouldRetry = policy.ShouldRetry(res.StatusCode, nil)
-			}
+		a := retry.Attempt{Number: attempt, Err: err, Duration: time.Since(attemptStart)}
+		if res != nil {
+			a.Status = res.StatusCode
 		}
-
-		if !shouldRetry {
+		done(a.Status, err)
+		wait, reason, again := policy.Decide(retry.Outcome{
+			Attempt:  attempt,
+			Method:   r.Method,
+			Keyed:    keyed,
+			Response: res,
+			Err:      err,
+			Elapsed:  time.Since(start),
+		}, deadline)
+		if again {
+			a.Wait = wait
+		}
+		a.Reason = reason
+		result.Attempts = append(result.Attempts, a)
+		if r.OnAttempt != nil {
+			r.OnAttempt(a)
+		}
+		if r.Verbose && (again || attempt > 1) {
+			printAttempt(r.log(), a, policy.MaxRetries+1, again)
+		}
+		if !again {
+			if reason != "" {
+				fmt.Fprintf(r.log(), "⚠️  Not retrying: %s\n", reason)
+			}
 			break
 		}
 
-		if r.Verbose && shouldRetry {
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "❌ Request failed: %v\n", err)
-			} else {
-				fmt.Fprintf(os.Stderr, "❌ Status %d matches retry condition\n", res.StatusCode)
-			}
+		if res != nil {
+			res.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			result.Timings.Total = time.Since(start)
+			return result, ctx.Err()
+		case <-time.After(wait):
 		}
 	}
 
 	if r.Verbose && err == nil {
+		printVerbose(r, res, result.Timings, result.Info)
+	}
+
+	result.Response, result.Body = res, body
+	return result, err
+}
+
+// printAttempt prints one line per attempt when retries are in play.
+func printAttempt(w io.Writer, a retry.Attempt, max int, again bool) {
+	icon, outcome := "✅", fmt.Sprintf("%d", a.Status)
+	if a.Err != nil {
+		icon, outcome = "❌", a.Err.Error()
+	} else if again || a.Status >= 400 {
+		icon = "❌"
+	}
+	line := fmt.Sprintf("Attempt %d/%d: %s in %s", a.Number, max, outcome, formatDuration(a.Duration))
+	if again {
+		line += fmt.Sprintf(" → retrying in %s (%s)", formatDuration(a.Wait), a.Reason)
+	}
+	fmt.Fprintf(w, "%s %s\n", icon, line)
+}
+
+func hasHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if k, _, ok := strings.Cut(h, ":"); ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Open sends r and returns as soon as the response headers arrive, leaving
+// the body unread for the caller to stream. The client timeout does not
+// apply; the stream lasts until ctx ends or the server closes it. Retries
+// are not attempted.
+func Open(ctx context.Context, r Request) (*http.Response, time.Duration, error) {
+	done, err := r.Guard.acquire(ctx, r.URL, r.log())
+	if err != nil {
+		return nil, 0, err
+	}
+	res, _, timings, verboseInfo, err := doRequest(ctx, r, true)
+	if res != nil {
+		done(res.StatusCode, err)
+	} else {
+		done(0, err)
+	}
+	if err != nil {
+		return nil, timings.Total, err
+	}
+	if r.Verbose {
 		printVerbose(r, res, timings, verboseInfo)
 	}
+	return res, timings.Total, nil
+}
 
-	return res, body, timings.Total, err
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
 
-func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingInfo, VerboseInfo, error) {
+func doRequest(ctx context.Context, r Request, stream bool) (*http.Response, []byte, TimingInfo, VerboseInfo, error) {
 	var timings TimingInfo
 	var verboseInfo VerboseInfo
-	var dnsStart, connectStart, tlsStart, reqStart time.Time
+	var dnsStart, connectStart, tlsStart time.Time
+
+	if err := normalizeUnix(&r); err != nil {
+		return nil, nil, timings, verboseInfo, err
+	}
+	verboseInfo.UnixSocket = r.Dial.UnixSocket
+
+	// Each redirect hop gets its own timings
+	hops := &redirectTracker{opts: r.Redirect, log: r.log()}
+	hops.begin(r.Method, r.URL)
 
 	// Request tracing for timing and connection info
 	trace := &httptrace.ClientTrace{
@@ -134,8 +283,8 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 			dnsStart = time.Now()
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
-			timings.DNSLookup = time.Since(dnsStart)
-			verboseInfo.DNSLatency = timings.DNSLookup
+			hops.cur.Timing.DNSLookup = time.Since(dnsStart)
+			verboseInfo.DNSLatency = hops.cur.Timing.DNSLookup
 			if len(info.Addrs) > 0 {
 				verboseInfo.ResolvedIP = info.Addrs[0].String()
 			}
@@ -143,10 +292,17 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 		ConnectStart: func(_, _ string) {
 			connectStart = time.Now()
 		},
-		ConnectDone: func(_, addr string, _ error) {
-			timings.TCPConnection = time.Since(connectStart)
-			// If DNS didn't resolve an IP, capture it from connect
-			if verboseInfo.ResolvedIP == "" && addr != "" {
+		ConnectDone: func(network, addr string, err error) {
+			hops.cur.Timing.TCPConnection = time.Since(connectStart)
+			if network == "unix" {
+				return
+			}
+			// If DNS didn't resolve an IP (IP literal, --resolve, --connect-to),
+			// capture the address actually connected to
+			if verboseInfo.ResolvedIP == "" && addr != "" && err == nil {
+				if host, _, err := net.SplitHostPort(addr); err == nil {
+					addr = host
+				}
 				verboseInfo.ResolvedIP = addr
 			}
 		},
@@ -154,22 +310,31 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 			tlsStart = time.Now()
 		},
 		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
-			timings.TLSHandshake = time.Since(tlsStart)
+			hops.cur.Timing.TLSHandshake = time.Since(tlsStart)
 		},
 		GotFirstResponseByte: func() {
-			if !reqStart.IsZero() {
-				timings.ServerProcessing = time.Since(reqStart)
-			}
+			hops.cur.Timing.ServerProcessing = time.Since(hops.start)
 		},
 	}
 
-	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), r.Method, r.URL, bytes.NewReader(r.Body))
+	reqBody := r.Body
+	if r.Compress != "" && len(reqBody) > 0 {
+		encoded, err := compress.Encode(reqBody, r.Compress)
+		if err != nil {
+			return nil, nil, timings, verboseInfo, err
+		}
+		verboseInfo.RequestEncoding = r.Compress
+		verboseInfo.RequestRatio = compress.Ratio(int64(len(encoded)), int64(len(reqBody)))
+		reqBody = encoded
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), r.Method, r.URL, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, nil, timings, verboseInfo, err
 	}
 
 	// Calculate request size
-	verboseInfo.RequestSize = int64(len(r.Body))
+	verboseInfo.RequestSize = int64(len(reqBody))
 	if req.Header != nil {
 		for k, vv := range req.Header {
 			for _, v := range vv {
@@ -192,28 +357,74 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
 		}
 	}
+	if verboseInfo.RequestEncoding != "" {
+		req.Header.Set("Content-Encoding", verboseInfo.RequestEncoding)
+	}
+	// Asking for the codings ourselves turns off the transport's transparent
+	// gzip, so every coding is decoded below and the wire size stays known
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", compress.AcceptEncoding)
+	}
+
+	transport, err := transportFor(r)
+	if err != nil {
+		return nil, nil, timings, verboseInfo, err
+	}
+
+	// Auth schemes that sign the request wrap the transport so every
+	// attempt and same-host redirect hop is signed afresh
+	var rt http.RoundTripper = transport
+	scheme, err := schemeFor(r.Auth, req.URL.Host)
+	if err != nil {
+		return nil, nil, timings, verboseInfo, err
+	}
+	if scheme == nil {
+		scheme = r.Scheme
+	}
+	if scheme != nil {
+		at := &auth.Transport{Base: transport, Scheme: scheme, Host: req.URL.Host}
+		if r.Redirect.Trusted {
+			at.Host = ""
+		}
+		rt = at
+	}
 
 	// Cookie jar setup
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: rt, CheckRedirect: hops.checkRedirect}
+	if stream {
+		// Streams run until the caller's context ends
+		client.Timeout = 0
+	}
+	var jar *cookies.Jar
 	if r.CookieJar != "" {
-		if globalCookieJar == nil {
-			globalCookieJar, _ = cookiejar.New(nil)
-			loadCookies(r.CookieJar, globalCookieJar)
+		jar, err = CookieJar(r.CookieJar)
+		if err != nil {
+			return nil, nil, timings, verboseInfo, err
 		}
-		client.Jar = globalCookieJar
+		client.Jar = jar
 	}
 
 	start := time.Now()
-	reqStart = start
 	res, err := client.Do(req)
-	timings.Total = time.Since(start)
+	total := time.Since(start)
 
 	if err != nil {
+		timings = chainTimings(append(hops.hops, hops.cur))
+		timings.Total = total
 		return nil, nil, timings, verboseInfo, err
 	}
+	hops.finish(res)
+	timings = chainTimings(hops.hops)
+	timings.Total = total
+	verboseInfo.Hops = hops.hops
 
 	// Capture protocol info
 	verboseInfo.Protocol = res.Proto
+	if transport.Proxy != nil {
+		if u, err := transport.Proxy(req); err == nil && u != nil {
+			verboseInfo.Proxy = u.Redacted()
+		}
+	}
 
 	// Capture TLS info if HTTPS
 	if res.TLS != nil {
@@ -234,7 +445,7 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 		profile, err := throttle.GetProfile(r.Throttle)
 		if err != nil {
 			if r.Verbose {
-				fmt.Fprintf(os.Stderr, "⚠️  %v, throttling disabled\n", err)
+				fmt.Fprintf(r.log(), "⚠️  %v, throttling disabled\n", err)
 			}
 		} else {
 			// Apply latency before reading response
@@ -242,12 +453,32 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 			// Wrap reader with throttling
 			bodyReader = throttle.NewThrottledReader(res.Body, profile.Bandwidth)
 			if r.Verbose {
-				fmt.Fprintf(os.Stderr, "🐌 Throttling: %s (%d KB/s, %v latency)\n",
+				fmt.Fprintf(r.log(), "🐌 Throttling: %s (%d KB/s, %v latency)\n",
 					profile.Name, profile.Bandwidth/1024, profile.Latency)
 			}
 		}
 	}
 
+	encoding := res.Header.Get("Content-Encoding")
+	if stream {
+		if !r.Raw {
+			if bodyReader, err = compress.NewReader(bodyReader, encoding); err != nil {
+				res.Body.Close()
+				return nil, nil, timings, verboseInfo, fmt.Errorf("%w (use --raw to keep the encoded body)", err)
+			}
+			if len(compress.Codings(encoding)) > 0 {
+				markDecoded(res)
+			}
+		}
+		res.Body = readCloser{bodyReader, res.Body}
+		if jar != nil {
+			if err := jar.Save(); err != nil && r.Verbose {
+				fmt.Fprintf(r.log(), "⚠️  Failed to save cookies: %v\n", err)
+			}
+		}
+		return res, nil, timings, verboseInfo, nil
+	}
+
 	bodyStart := time.Now()
 	body, err := io.ReadAll(bodyReader)
 	timings.ContentTransfer = time.Since(bodyStart)
@@ -256,7 +487,8 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 		return res, nil, timings, verboseInfo, err
 	}
 
-	// Calculate response size
+	// Calculate response size from the bytes on the wire
+	verboseInfo.WireSize = int64(len(body))
 	verboseInfo.ResponseSize = int64(len(body))
 	if res.Header != nil {
 		for k, vv := range res.Header {
@@ -266,24 +498,69 @@ func doRequest(ctx context.Context, r Request) (*http.Response, []byte, TimingIn
 		}
 	}
 
-	// Check if response was compressed
-	contentEncoding := res.Header.Get("Content-Encoding")
-	if contentEncoding == "gzip" || contentEncoding == "deflate" || contentEncoding == "br" {
+	// Undo the Content-Encoding; the ratio compares wire and decoded bytes
+	verboseInfo.DecodedSize = verboseInfo.WireSize
+	if len(compress.Codings(encoding)) > 0 {
 		verboseInfo.Compressed = true
-		// ContentLength is uncompressed size if available
-		if res.ContentLength > 0 {
-			verboseInfo.CompressionRatio = (1.0 - float64(len(body))/float64(res.ContentLength)) * 100
+		verboseInfo.ContentEncoding = encoding
+		if !r.Raw {
+			decoded, err := compress.Decode(body, encoding)
+			if err != nil {
+				return res, nil, timings, verboseInfo, fmt.Errorf("decoding %s response: %w (use --raw to keep the encoded body)", encoding, err)
+			}
+			body = decoded
+			verboseInfo.DecodedSize = int64(len(body))
+			verboseInfo.CompressionRatio = compress.Ratio(verboseInfo.WireSize, verboseInfo.DecodedSize)
+			markDecoded(res)
 		}
 	}
 
 	// Save cookies if jar is enabled
-	if r.CookieJar != "" && globalCookieJar != nil {
-		saveCookies(r.CookieJar, globalCookieJar)
+	if jar != nil {
+		if err := jar.Save(); err != nil && r.Verbose {
+			fmt.Fprintf(r.log(), "⚠️  Failed to save cookies: %v\n", err)
+		}
 	}
 
 	return res, body, timings, verboseInfo, nil
 }
 
+// markDecoded marks res as no longer encoded once its body is decoded, as
+// net/http does for transparent gzip, so headers shown later match the body.
+func markDecoded(res *http.Response) {
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+}
+
+var (
+	schemesMu sync.Mutex
+	schemes   = map[string]auth.Scheme{}
+)
+
+// schemeFor returns the scheme for c on host. Schemes are kept for the life
+// of the process, so a Digest challenge is answered once per host and
+// credentials rather than costing every request a 401 round trip.
+func schemeFor(c auth.Config, host string) (auth.Scheme, error) {
+	if c.Type == "" {
+		return nil, nil
+	}
+	key := fmt.Sprintf("%s %#v", host, c)
+
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	if s, ok := schemes[key]; ok {
+		return s, nil
+	}
+	s, err := auth.New(c)
+	if err != nil {
+		return nil, err
+	}
+	schemes[key] = s
+	return s, nil
+}
+
 func tlsVersionString(version uint16) string {
 	switch version {
 	case tls.VersionTLS10:
@@ -305,30 +582,37 @@ func printVerbose(r Request, res *http.Response, t TimingInfo, v VerboseInfo) {
 	green := color.New(color.FgGreen).SprintFunc()
 	yellow := color.New(color.FgYellow).SprintFunc()
 	blue := color.New(color.FgBlue).SprintFunc()
+	w := r.log()
 
 	// DNS & Connection Info
-	if v.ResolvedIP != "" || v.Protocol != "" {
-		fmt.Fprintf(os.Stderr, "\n%s\n", cyan("🌐 Connection Info:"))
+	if v.ResolvedIP != "" || v.UnixSocket != "" || v.Protocol != "" {
+		fmt.Fprintf(w, "\n%s\n", cyan("🌐 Connection Info:"))
 		if v.ResolvedIP != "" {
-			fmt.Fprintf(os.Stderr, "%s Resolved IP:  %s\n", gray("•"), blue(v.ResolvedIP))
+			fmt.Fprintf(w, "%s Resolved IP:  %s\n", gray("•"), blue(v.ResolvedIP))
+		}
+		if v.UnixSocket != "" {
+			fmt.Fprintf(w, "%s Unix socket:  %s\n", gray("•"), blue(v.UnixSocket))
 		}
 		if v.Protocol != "" {
-			fmt.Fprintf(os.Stderr, "%s Protocol:     %s\n", gray("•"), blue(v.Protocol))
+			fmt.Fprintf(w, "%s Protocol:     %s\n", gray("•"), blue(v.Protocol))
+		}
+		if v.Proxy != "" {
+			fmt.Fprintf(w, "%s Proxy:        %s\n", gray("•"), blue(v.Proxy))
 		}
 		if v.TLSVersion != "" {
-			fmt.Fprintf(os.Stderr, "%s TLS Version:  %s\n", gray("•"), green(v.TLSVersion))
+			fmt.Fprintf(w, "%s TLS Version:  %s\n", gray("•"), green(v.TLSVersion))
 			if v.TLSCipher != "" {
-				fmt.Fprintf(os.Stderr, "%s TLS Cipher:   %s\n", gray("•"), gray(v.TLSCipher))
+				fmt.Fprintf(w, "%s TLS Cipher:   %s\n", gray("•"), gray(v.TLSCipher))
 			}
 		}
 	}
 
 	// TLS Certificate Info
 	if v.CertSubject != "" {
-		fmt.Fprintf(os.Stderr, "\n%s\n", cyan("🔐 TLS Certificate:"))
-		fmt.Fprintf(os.Stderr, "%s Subject:      %s\n", gray("•"), yellow(v.CertSubject))
+		fmt.Fprintf(w, "\n%s\n", cyan("🔐 TLS Certificate:"))
+		fmt.Fprintf(w, "%s Subject:      %s\n", gray("•"), yellow(v.CertSubject))
 		if v.CertIssuer != "" {
-			fmt.Fprintf(os.Stderr, "%s Issuer:       %s\n", gray("•"), gray(v.CertIssuer))
+			fmt.Fprintf(w, "%s Issuer:       %s\n", gray("•"), gray(v.CertIssuer))
 		}
 		if !v.CertExpiry.IsZero() {
 			daysRemaining := int(time.Until(v.CertExpiry).Hours() / 24)
@@ -336,7 +620,7 @@ func printVerbose(r Request, res *http.Response, t TimingInfo, v VerboseInfo) {
 			if daysRemaining < 30 {
 				expiryColor = yellow
 			}
-			fmt.Fprintf(os.Stderr, "%s Expires:      %s (%s)\n",
+			fmt.Fprintf(w, "%s Expires:      %s (%s)\n",
 				gray("•"),
 				v.CertExpiry.Format("2006-01-02"),
 				expiryColor(fmt.Sprintf("%d days remaining", daysRemaining)))
@@ -345,107 +629,178 @@ func printVerbose(r Request, res *http.Response, t TimingInfo, v VerboseInfo) {
 
 	// Transfer Sizes
 	if v.RequestSize > 0 || v.ResponseSize > 0 {
-		fmt.Fprintf(os.Stderr, "\n%s\n", cyan("📦 Transfer Details:"))
+		fmt.Fprintf(w, "\n%s\n", cyan("📦 Transfer Details:"))
 		if v.RequestSize > 0 {
-			fmt.Fprintf(os.Stderr, "%s Request:      %s\n", gray("•"), formatBytes(v.RequestSize))
+			sizeStr := formatBytes(v.RequestSize)
+			if v.RequestEncoding != "" {
+				sizeStr += fmt.Sprintf(" (%s compressed, %.1f%% reduction)",
+					yellow(v.RequestEncoding),
+					v.RequestRatio)
+			}
+			fmt.Fprintf(w, "%s Request:      %s\n", gray("•"), sizeStr)
 		}
 		if v.ResponseSize > 0 {
 			sizeStr := formatBytes(v.ResponseSize)
-			if v.Compressed {
-				sizeStr += fmt.Sprintf(" (%s compressed, %.1f%% reduction)",
-					yellow("gzip"),
+			if v.Compressed && v.DecodedSize != v.WireSize {
+				sizeStr += fmt.Sprintf(" (%s compressed, %s → %s, %.1f%% reduction)",
+					yellow(v.ContentEncoding),
+					formatBytes(v.WireSize),
+					formatBytes(v.DecodedSize),
 					v.CompressionRatio)
+			} else if v.Compressed {
+				sizeStr += fmt.Sprintf(" (%s, not decoded)", yellow(v.ContentEncoding))
 			}
-			fmt.Fprintf(os.Stderr, "%s Response:     %s\n", gray("•"), sizeStr)
+			fmt.Fprintf(w, "%s Response:     %s\n", gray("•"), sizeStr)
 		}
 	}
 
 	// Request headers
-	fmt.Fprintf(os.Stderr, "\n%s\n", cyan("→ Request Headers:"))
-	fmt.Fprintf(os.Stderr, "%s %s %s\n", gray(">"), r.Method, r.URL)
-	fmt.Fprintf(os.Stderr, "%s Host: %s\n", gray(">"), res.Request.Host)
-	fmt.Fprintf(os.Stderr, "%s User-Agent: mozzy/1.6.0\n", gray(">"))
+	fmt.Fprintf(w, "\n%s\n", cyan("→ Request Headers:"))
+	fmt.Fprintf(w, "%s %s %s\n", gray(">"), r.Method, r.URL)
+	fmt.Fprintf(w, "%s Host: %s\n", gray(">"), res.Request.Host)
+	fmt.Fprintf(w, "%s User-Agent: mozzy/1.6.0\n", gray(">"))
 	for k, v := range res.Request.Header {
-		fmt.Fprintf(os.Stderr, "%s %s: %s\n", gray(">"), k, strings.Join(v, ", "))
+		fmt.Fprintf(w, "%s %s: %s\n", gray(">"), k, strings.Join(v, ", "))
 	}
 
 	// Response headers
-	fmt.Fprintf(os.Stderr, "\n%s\n", cyan("← Response Headers:"))
-	fmt.Fprintf(os.Stderr, "%s HTTP/%d.%d %s\n", gray("<"), res.ProtoMajor, res.ProtoMinor, res.Status)
+	fmt.Fprintf(w, "\n%s\n", cyan("← Response Headers:"))
+	fmt.Fprintf(w, "%s HTTP/%d.%d %s\n", gray("<"), res.ProtoMajor, res.ProtoMinor, res.Status)
 	for k, v := range res.Header {
-		fmt.Fprintf(os.Stderr, "%s %s: %s\n", gray("<"), k, strings.Join(v, ", "))
+		fmt.Fprintf(w, "%s %s: %s\n", gray("<"), k, strings.Join(v, ", "))
+	}
+
+	// Redirect chain, one line per hop
+	if len(v.Hops) > 1 {
+		fmt.Fprintf(w, "\n%s\n", cyan(fmt.Sprintf("↪️  Redirect Chain (%d redirects):", len(v.Hops)-1)))
+		for i, h := range v.Hops {
+			branch, indent := "├─", "│ "
+			if i == len(v.Hops)-1 {
+				branch, indent = "└─", "  "
+			}
+			fmt.Fprintf(w, "%s %d. %s %s %s\n", gray(branch), i+1, statusColor(h.Status), h.Method, h.URL)
+			if h.Location != "" && i < len(v.Hops)-1 {
+				fmt.Fprintf(w, "%s    %s %s\n", gray(indent), gray("Location:"), h.Location)
+			}
+			fmt.Fprintf(w, "%s    %s\n", gray(indent), gray(hopTimings(h.Timing)))
+			if len(h.DroppedHeaders) > 0 {
+				fmt.Fprintf(w, "%s    %s\n", gray(indent), yellow(fmt.Sprintf("⚠️  Not forwarded: %s (cross-host redirect; --location-trusted keeps them)", strings.Join(h.DroppedHeaders, ", "))))
+			}
+		}
 	}
 
 	// Enhanced Timing breakdown with visual bars
-	fmt.Fprintf(os.Stderr, "\n%s\n", cyan("⏱  Request Timeline:"))
+	fmt.Fprintf(w, "\n%s\n", cyan("⏱  Request Timeline:"))
 	totalMs := float64(t.Total.Milliseconds())
 
+	if t.Redirect > 0 {
+		pct := float64(t.Redirect.Milliseconds()) / totalMs * 100
+		bar := renderTimingBar(pct)
+		fmt.Fprintf(w, "%s Redirects (%d)    %6s  %s  %s\n",
+			gray("├─"), t.Redirects, green(formatDuration(t.Redirect)), bar, gray(fmt.Sprintf("%.0f%%", pct)))
+	}
+
 	if t.DNSLookup > 0 {
 		pct := float64(t.DNSLookup.Milliseconds()) / totalMs * 100
 		bar := renderTimingBar(pct)
-		fmt.Fprintf(os.Stderr, "%s DNS Lookup       %6s  %s  %s\n",
+		fmt.Fprintf(w, "%s DNS Lookup       %6s  %s  %s\n",
 			gray("├─"), green(formatDuration(t.DNSLookup)), bar, gray(fmt.Sprintf("%.0f%%", pct)))
 	}
 	if t.TCPConnection > 0 {
 		pct := float64(t.TCPConnection.Milliseconds()) / totalMs * 100
 		bar := renderTimingBar(pct)
-		fmt.Fprintf(os.Stderr, "%s TCP Connect      %6s  %s  %s\n",
+		fmt.Fprintf(w, "%s TCP Connect      %6s  %s  %s\n",
 			gray("├─"), green(formatDuration(t.TCPConnection)), bar, gray(fmt.Sprintf("%.0f%%", pct)))
 	}
 	if t.TLSHandshake > 0 {
 		pct := float64(t.TLSHandshake.Milliseconds()) / totalMs * 100
 		bar := renderTimingBar(pct)
-		fmt.Fprintf(os.Stderr, "%s TLS Handshake    %6s  %s  %s\n",
+		fmt.Fprintf(w, "%s TLS Handshake    %6s  %s  %s\n",
 			gray("├─"), green(formatDuration(t.TLSHandshake)), bar, gray(fmt.Sprintf("%.0f%%", pct)))
 	}
 	if t.ServerProcessing > 0 {
 		pct := float64(t.ServerProcessing.Milliseconds()) / totalMs * 100
 		bar := renderTimingBar(pct)
-		fmt.Fprintf(os.Stderr, "%s Server Response  %6s  %s  %s\n",
+		fmt.Fprintf(w, "%s Server Response  %6s  %s  %s\n",
 			gray("├─"), green(formatDuration(t.ServerProcessing)), bar, gray(fmt.Sprintf("%.0f%%", pct)))
 	}
 	if t.ContentTransfer > 0 {
 		pct := float64(t.ContentTransfer.Milliseconds()) / totalMs * 100
 		bar := renderTimingBar(pct)
-		fmt.Fprintf(os.Stderr, "%s Content Transfer %6s  %s  %s\n",
+		fmt.Fprintf(w, "%s Content Transfer %6s  %s  %s\n",
 			gray("├─"), green(formatDuration(t.ContentTransfer)), bar, gray(fmt.Sprintf("%.0f%%", pct)))
 	}
-	fmt.Fprintf(os.Stderr, "%s Total            %6s  %s\n",
+	fmt.Fprintf(w, "%s Total            %6s  %s\n",
 		gray("└─"), green(formatDuration(t.Total)), yellow("████████████████████"))
 
 	// Performance Grading
 	grade := CalculateGrade(t)
-	fmt.Fprintf(os.Stderr, "\n%s\n", cyan("📊 Performance Grade:"))
+	fmt.Fprintf(w, "\n%s\n", cyan("📊 Performance Grade:"))
 
 	if grade.DNS != "" {
-		fmt.Fprintf(os.Stderr, "%s DNS Lookup:      %s\n",
+		fmt.Fprintf(w, "%s DNS Lookup:      %s\n",
 			gray("├─"), FormatGradeWithColor(grade.DNS))
 	}
 	if grade.TCP != "" {
-		fmt.Fprintf(os.Stderr, "%s TCP Connect:     %s\n",
+		fmt.Fprintf(w, "%s TCP Connect:     %s\n",
 			gray("├─"), FormatGradeWithColor(grade.TCP))
 	}
 	if grade.TLS != "" {
-		fmt.Fprintf(os.Stderr, "%s TLS Handshake:   %s\n",
+		fmt.Fprintf(w, "%s TLS Handshake:   %s\n",
 			gray("├─"), FormatGradeWithColor(grade.TLS))
 	}
 	if grade.TTFB != "" {
-		fmt.Fprintf(os.Stderr, "%s Server Response: %s\n",
+		fmt.Fprintf(w, "%s Server Response: %s\n",
 			gray("├─"), FormatGradeWithColor(grade.TTFB))
 	}
-	fmt.Fprintf(os.Stderr, "%s Overall:         %s\n",
+	if grade.Redirect != "" {
+		fmt.Fprintf(w, "%s Redirects:       %s\n",
+			gray("├─"), FormatGradeWithColor(grade.Redirect))
+	}
+	fmt.Fprintf(w, "%s Overall:         %s\n",
 		gray("└─"), FormatGradeWithColor(grade.Overall))
 
 	// Performance Recommendations
 	recommendations := grade.GetRecommendations()
 	if len(recommendations) > 0 {
-		fmt.Fprintf(os.Stderr, "\n%s\n", cyan("💡 Performance Insights:"))
+		fmt.Fprintf(w, "\n%s\n", cyan("💡 Performance Insights:"))
 		for _, rec := range recommendations {
-			fmt.Fprintf(os.Stderr, "  %s\n", gray(rec))
+			fmt.Fprintf(w, "  %s\n", gray(rec))
 		}
 	}
 
-	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(w, "\n")
+}
+
+// hopTimings summarises the phases of one redirect hop.
+func hopTimings(t TimingInfo) string {
+	parts := []string{}
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"DNS", t.DNSLookup},
+		{"TCP", t.TCPConnection},
+		{"TLS", t.TLSHandshake},
+		{"TTFB", t.ServerProcessing},
+	} {
+		if p.d > 0 {
+			parts = append(parts, p.name+" "+formatDuration(p.d))
+		}
+	}
+	parts = append(parts, "total "+formatDuration(t.Total))
+	return strings.Join(parts, " · ")
+}
+
+func statusColor(code int) string {
+	c := color.New(color.FgGreen)
+	switch {
+	case code >= 400:
+		c = color.New(color.FgRed)
+	case code >= 300:
+		c = color.New(color.FgYellow)
+	}
+	return c.Sprint(code)
 }
 
 func renderTimingBar(percentage float64) string {
@@ -483,13 +838,3 @@ func formatDuration(d time.Duration) string {
 	}
 	return fmt.Sprintf("%.2fs", d.Seconds())
 }
-
-func loadCookies(filename string, jar *cookiejar.Jar) {
-	// Simple cookie persistence - would need full implementation
-	// For now, this is a placeholder
-}
-
-func saveCookies(filename string, jar *cookiejar.Jar) {
-	// Simple cookie persistence - would need full implementation
-	// For now, this is a placeholder
-}
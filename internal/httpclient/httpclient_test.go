@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/humancto/mozzy/internal/auth"
+	"github.com/humancto/mozzy/internal/retry"
+)
+
+// flakyServer fails with 503 and Retry-After: 0 until it has been called
+// failures times, recording the Idempotency-Key of each request.
+func flakyServer(t *testing.T, failures int) (url string, keys *[]string) {
+	t.Helper()
+	keys = new([]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*keys = append(*keys, r.Header.Get("Idempotency-Key"))
+		if len(*keys) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, keys
+}
+
+func TestDoRetries(t *testing.T) {
+	url, keys := flakyServer(t, 2)
+	var attempts []retry.Attempt
+	r := Request{
+		Method:     "GET",
+		URL:        url,
+		RetryCount: 3,
+		Retry:      retry.Options{Base: time.Hour}, // Retry-After must win over the backoff
+		OnAttempt:  func(a retry.Attempt) { attempts = append(attempts, a) },
+	}
+
+	res, body, _, err := Do(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 200 || string(body) != `{"ok":true}` || len(*keys) != 3 {
+		t.Fatalf("got %d %s after %d requests", res.StatusCode, body, len(*keys))
+	}
+	if len(attempts) != 3 || attempts[0].Status != 503 || attempts[0].Reason != "Retry-After" || attempts[2].Status != 200 {
+		t.Errorf("attempts = %+v", attempts)
+	}
+}
+
+func TestDoRetries_NonIdempotent(t *testing.T) {
+	url, keys := flakyServer(t, 1)
+	var attempts []retry.Attempt
+	r := Request{
+		Method:     "POST",
+		URL:        url,
+		Body:       []byte(`{}`),
+		RetryCount: 3,
+		OnAttempt:  func(a retry.Attempt) { attempts = append(attempts, a) },
+	}
+
+	res, _, _, err := Do(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 503 || len(*keys) != 1 || len(attempts) != 1 || attempts[0].Reason == "" {
+		t.Errorf("POST without key: status %d, %d requests, attempts %+v", res.StatusCode, len(*keys), attempts)
+	}
+
+	url, keys = flakyServer(t, 1)
+	r.URL = url
+	r.Retry.IdempotencyKey = "auto"
+	res, _, _, err = Do(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 200 || len(*keys) != 2 {
+		t.Fatalf("POST with key: status %d after %d requests", res.StatusCode, len(*keys))
+	}
+	if k := (*keys)[0]; k == "" || (*keys)[1] != k {
+		t.Errorf("Idempotency-Key should be the same on every attempt, got %q", *keys)
+	}
+}
+
+func TestDo_DigestChallengeReused(t *testing.T) {
+	var hits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("Authorization")
+		hits = append(hits, r.Method+" "+got)
+		if !strings.HasPrefix(got, "Digest ") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="api", nonce="n1", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	r := Request{Method: "POST", URL: srv.URL, Body: []byte("{}"), Auth: auth.Config{Type: auth.Digest, Username: "u", Password: "p"}}
+	for i := 0; i < 2; i++ {
+		res, _, _, err := Do(context.Background(), r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, res.StatusCode)
+		}
+	}
+	if len(hits) != 3 || !strings.Contains(hits[2], "nc=00000002") {
+		t.Errorf("the second request should reuse the challenge, got:\n%s", strings.Join(hits, "\n"))
+	}
+}
@@ -0,0 +1,182 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestDo_HTTPProxyWithAuth(t *testing.T) {
+	var gotURL, gotAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		gotAuth = r.Header.Get("Proxy-Authorization")
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	req := Request{
+		Method: "GET",
+		URL:    "http://api.example.test/users",
+		Proxy:  ProxyOptions{URL: proxy.URL, User: "alice:s3cret"},
+	}
+	_, body, _, err := Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "via proxy" || gotURL != "http://api.example.test/users" {
+		t.Errorf("proxy saw %q, body %q", gotURL, body)
+	}
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:s3cret")); gotAuth != want {
+		t.Errorf("Proxy-Authorization = %q, want %q", gotAuth, want)
+	}
+}
+
+func TestDo_NoProxyBypass(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer origin.Close()
+
+	req := Request{
+		Method: "GET",
+		URL:    origin.URL,
+		Proxy:  ProxyOptions{URL: "http://127.0.0.1:1", NoProxy: "127.0.0.0/8"},
+	}
+	_, body, _, err := Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "direct" {
+		t.Errorf("body = %q, want direct connection", body)
+	}
+}
+
+func TestDo_SOCKS5Proxy(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.Host))
+	}))
+	defer origin.Close()
+	originAddr := origin.Listener.Addr().String()
+
+	var gotTarget, gotUser string
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serveSOCKS5(ln, originAddr, &gotTarget, &gotUser)
+
+	_, port, _ := net.SplitHostPort(originAddr)
+	req := Request{
+		Method: "GET",
+		URL:    "http://service.internal:" + port + "/",
+		Proxy:  ProxyOptions{URL: "socks5h://bob:pw@" + ln.Addr().String()},
+	}
+	_, body, _, err := Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello service.internal:"+port {
+		t.Errorf("body = %q", body)
+	}
+	if gotTarget != "service.internal:"+port || gotUser != "bob:pw" {
+		t.Errorf("SOCKS5 CONNECT target %q user %q", gotTarget, gotUser)
+	}
+}
+
+// serveSOCKS5 accepts one connection, performs a username/password SOCKS5
+// handshake and tunnels it to dest regardless of the requested address.
+func serveSOCKS5(ln net.Listener, dest string, target, user *string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 262)
+	// greeting: VER NMETHODS METHODS...
+	io.ReadFull(conn, buf[:2])
+	io.ReadFull(conn, buf[:buf[1]])
+	conn.Write([]byte{5, 2}) // username/password
+
+	// auth: VER ULEN UNAME PLEN PASSWD
+	io.ReadFull(conn, buf[:2])
+	uname := make([]byte, buf[1])
+	io.ReadFull(conn, uname)
+	io.ReadFull(conn, buf[:1])
+	passwd := make([]byte, buf[0])
+	io.ReadFull(conn, passwd)
+	*user = string(uname) + ":" + string(passwd)
+	conn.Write([]byte{1, 0})
+
+	// request: VER CMD RSV ATYP ADDR PORT
+	io.ReadFull(conn, buf[:4])
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	io.ReadFull(conn, buf[:2])
+	*target = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+
+	up, err := net.Dial("tcp", dest)
+	if err != nil {
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer up.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(up, conn)
+	io.Copy(conn, up)
+}
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		target  string
+		noProxy string
+		want    bool
+	}{
+		{"http://api.example.com", "example.com", true},
+		{"http://example.com", ".example.com", true},
+		{"http://badexample.com", "example.com", false},
+		{"https://api.example.com", "api.example.com:8443", false},
+		{"https://api.example.com:8443", "api.example.com:8443", true},
+		{"http://10.1.2.3:8080", "10.0.0.0/8", true},
+		{"http://[::1]:8080", "::1", true},
+		{"http://anything", "*", true},
+		{"http://anything", "", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.target)
+		if got := BypassProxy(u, tt.noProxy); got != tt.want {
+			t.Errorf("BypassProxy(%s, %q) = %v, want %v", tt.target, tt.noProxy, got, tt.want)
+		}
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	u, err := ParseProxyURL("proxy.corp:3128")
+	if err != nil || u.String() != "http://proxy.corp:3128" {
+		t.Errorf("ParseProxyURL(host:port) = %v, %v", u, err)
+	}
+	if u, err := ParseProxyURL("socks5h://localhost:1080"); err != nil || u.Scheme != "socks5" {
+		t.Errorf("ParseProxyURL(socks5h) = %v, %v", u, err)
+	}
+	if _, err := ParseProxyURL("ftp://proxy:21"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
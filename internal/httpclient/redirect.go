@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxRedirects matches net/http's own limit.
+const DefaultMaxRedirects = 10
+
+// RedirectOptions controls how 3xx responses are followed.
+type RedirectOptions struct {
+	NoFollow bool // return the first 3xx response as-is
+	Max      int  // redirects to follow before failing (0 = DefaultMaxRedirects)
+	Trusted  bool // keep Authorization and Cookie headers on cross-host redirects
+}
+
+func (o RedirectOptions) max() int {
+	if o.Max > 0 {
+		return o.Max
+	}
+	return DefaultMaxRedirects
+}
+
+// Hop is one request in a redirect chain.
+type Hop struct {
+	Method         string
+	URL            string
+	Status         int
+	Location       string     // Location header of a 3xx hop
+	DroppedHeaders []string   // credentials net/http stripped on a cross-host redirect
+	Timing         TimingInfo // Total is the time until the response headers
+}
+
+// sensitiveHeaders are the headers net/http removes when a redirect leaves
+// the original host.
+var sensitiveHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+// redirectTracker records each hop of a request and applies the redirect
+// policy. Trace callbacks write the timings of the current hop.
+type redirectTracker struct {
+	opts  RedirectOptions
+	log   io.Writer
+	hops  []Hop // finished hops
+	cur   Hop
+	start time.Time
+}
+
+func (t *redirectTracker) begin(method, url string) {
+	t.cur = Hop{Method: method, URL: url}
+	t.start = time.Now()
+}
+
+// finish closes the current hop with the response that ended it.
+func (t *redirectTracker) finish(res *http.Response) {
+	t.cur.Status = res.StatusCode
+	t.cur.Location = res.Header.Get("Location")
+	t.cur.Timing.Total = time.Since(t.start)
+	t.hops = append(t.hops, t.cur)
+}
+
+// checkRedirect is the http.Client CheckRedirect hook.
+func (t *redirectTracker) checkRedirect(req *http.Request, via []*http.Request) error {
+	if t.opts.NoFollow {
+		return http.ErrUseLastResponse
+	}
+	if max := t.opts.max(); len(via) >= max {
+		return fmt.Errorf("stopped after %d redirects", max)
+	}
+
+	t.finish(req.Response)
+	t.begin(req.Method, req.URL.String())
+
+	orig := via[0].Header
+	for _, h := range sensitiveHeaders {
+		if orig.Get(h) == "" || req.Header.Get(h) != "" {
+			continue
+		}
+		if t.opts.Trusted {
+			req.Header[h] = orig[h]
+			continue
+		}
+		t.cur.DroppedHeaders = append(t.cur.DroppedHeaders, h)
+		if h == "Authorization" {
+			fmt.Fprintf(t.log, "⚠️  Authorization header not sent to %s after redirect (use --location-trusted to keep it)\n", req.URL.Host)
+		}
+	}
+	return nil
+}
+
+// chainTimings adds up the phases of every hop. Redirect is the time spent
+// before the final request started.
+func chainTimings(hops []Hop) TimingInfo {
+	var t TimingInfo
+	for i, h := range hops {
+		t.DNSLookup += h.Timing.DNSLookup
+		t.TCPConnection += h.Timing.TCPConnection
+		t.TLSHandshake += h.Timing.TLSHandshake
+		t.ServerProcessing += h.Timing.ServerProcessing
+		if i < len(hops)-1 {
+			t.Redirect += h.Timing.Total
+			t.Redirects++
+		}
+	}
+	return t
+}
+
+// RedirectChain reconstructs the hops that led to res from the redirect
+// responses net/http keeps on each request. Timings are not available.
+func RedirectChain(res *http.Response) []Hop {
+	var hops []Hop
+	for r := res; r != nil && r.Request != nil; r = r.Request.Response {
+		hops = append([]Hop{{
+			Method:   r.Request.Method,
+			URL:      r.Request.URL.String(),
+			Status:   r.StatusCode,
+			Location: r.Header.Get("Location"),
+		}}, hops...)
+	}
+	return hops
+}
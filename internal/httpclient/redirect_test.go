@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/humancto/mozzy/internal/auth"
+)
+
+// redirectServers returns a server on 127.0.0.1 that redirects twice, the
+// second time to "localhost" so net/http treats it as another host.
+func redirectServers(t *testing.T) (start string, gotAuth *string) {
+	t.Helper()
+	auth := new(string)
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(final.Close)
+	finalURL := strings.Replace(final.URL, "127.0.0.1", "localhost", 1) + "/final"
+
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/next", http.StatusMovedPermanently)
+		case "/next":
+			http.Redirect(w, r, finalURL, http.StatusFound)
+		}
+	}))
+	t.Cleanup(first.Close)
+	return first.URL + "/start", auth
+}
+
+func TestRedirectChain(t *testing.T) {
+	start, gotAuth := redirectServers(t)
+	r := Request{Method: "GET", URL: start, Token: "secret"}
+
+	res, body, timings, info, err := doRequest(context.Background(), r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 200 || string(body) != `{"ok":true}` {
+		t.Fatalf("final response %d %s", res.StatusCode, body)
+	}
+
+	var statuses []int
+	for _, h := range info.Hops {
+		statuses = append(statuses, h.Status)
+	}
+	if !reflect.DeepEqual(statuses, []int{301, 302, 200}) {
+		t.Errorf("hop statuses = %v", statuses)
+	}
+	if info.Hops[0].Location != "/next" || !strings.HasSuffix(info.Hops[2].URL, "/final") {
+		t.Errorf("hops = %+v", info.Hops)
+	}
+	if timings.Redirects != 2 || timings.Redirect <= 0 || timings.Redirect > timings.Total {
+		t.Errorf("Redirects = %d, Redirect = %v, Total = %v", timings.Redirects, timings.Redirect, timings.Total)
+	}
+	if *gotAuth != "" || !reflect.DeepEqual(info.Hops[2].DroppedHeaders, []string{"Authorization"}) {
+		t.Errorf("cross-host hop: auth %q, dropped %v", *gotAuth, info.Hops[2].DroppedHeaders)
+	}
+	if got := RedirectChain(res); len(got) != 3 || got[0].Status != 301 || got[1].Location == "" {
+		t.Errorf("RedirectChain = %+v", got)
+	}
+
+	// --location-trusted keeps the credentials
+	r.Redirect.Trusted = true
+	_, _, _, info, err = doRequest(context.Background(), r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *gotAuth != "Bearer secret" || len(info.Hops[2].DroppedHeaders) != 0 {
+		t.Errorf("trusted: auth %q, dropped %v", *gotAuth, info.Hops[2].DroppedHeaders)
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	start, _ := redirectServers(t)
+
+	res, _, _, info, err := doRequest(context.Background(), Request{Method: "GET", URL: start, Redirect: RedirectOptions{NoFollow: true}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 301 || res.Header.Get("Location") != "/next" || len(info.Hops) != 1 {
+		t.Errorf("no-follow: status %d, hops %+v", res.StatusCode, info.Hops)
+	}
+
+	_, _, _, _, err = doRequest(context.Background(), Request{Method: "GET", URL: start, Redirect: RedirectOptions{Max: 1}}, false)
+	if err == nil || !strings.Contains(err.Error(), "stopped after 1 redirects") {
+		t.Errorf("max 1: err = %v", err)
+	}
+}
+
+func TestRedirectAuthScheme(t *testing.T) {
+	start, gotAuth := redirectServers(t)
+	r := Request{Method: "GET", URL: start, Auth: auth.Config{Type: auth.Basic, Username: "u", Password: "p"}}
+
+	if _, _, _, _, err := doRequest(context.Background(), r, false); err != nil {
+		t.Fatal(err)
+	}
+	if *gotAuth != "" {
+		t.Errorf("basic credentials followed a cross-host redirect: %q", *gotAuth)
+	}
+
+	r.Redirect.Trusted = true
+	if _, _, _, _, err := doRequest(context.Background(), r, false); err != nil {
+		t.Fatal(err)
+	}
+	if *gotAuth != "Basic dTpw" {
+		t.Errorf("trusted: Authorization = %q", *gotAuth)
+	}
+}
@@ -0,0 +1,237 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// TLSOptions configures certificate verification, client certificates and
+// protocol limits.
+type TLSOptions struct {
+	Insecure     bool   // skip server certificate verification
+	CACert       string // PEM bundle trusted in addition to the system roots
+	Cert         string // client certificate: PEM, or PKCS#12 (.p12/.pfx)
+	Key          string // client private key (PEM); optional if Cert holds it
+	CertPassword string // password for a PKCS#12 bundle
+	MinVersion   string // "1.0" … "1.3"
+	MaxVersion   string // "1.0" … "1.3"
+	Ciphers      string // comma-separated cipher suite names (TLS 1.0–1.2)
+	ServerName   string // SNI and verification name override
+}
+
+// ProxyOptions routes requests through an HTTP(S) or SOCKS5 proxy. With no
+// URL the standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY variables apply.
+type ProxyOptions struct {
+	URL     string // http://, https://, socks5:// or socks5h://, optionally with user:pass@
+	User    string // "user:password", overrides credentials in URL
+	NoProxy string // comma-separated hosts, domains or CIDRs that bypass the proxy
+}
+
+// TransportOptions describes how connections are made. Requests with the same
+// settings share one connection pool.
+type TransportOptions struct {
+	TLS   TLSOptions
+	Proxy ProxyOptions
+	Dial  DialOptions
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = map[string]*http.Transport{}
+)
+
+// transportFor returns a pooled transport configured for r.
+func transportFor(r Request) (*http.Transport, error) {
+	return Transport(TransportOptions{TLS: r.TLS, Proxy: r.Proxy, Dial: r.Dial})
+}
+
+// Transport returns a pooled transport for o, for callers such as download
+// and upload that manage their own requests.
+func Transport(o TransportOptions) (*http.Transport, error) {
+	key := fmt.Sprintf("%#v", o)
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[key]; ok {
+		return t, nil
+	}
+
+	tlsConfig, err := buildTLSConfig(o.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+	if o.Proxy.URL != "" {
+		proxy, err := proxyFunc(o.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = proxy
+	}
+	if !o.Dial.empty() {
+		d, err := newDialer(o.Dial)
+		if err != nil {
+			return nil, err
+		}
+		t.DialContext = d.DialContext
+		if o.Dial.UnixSocket != "" {
+			t.Proxy = nil
+		}
+	}
+	transports[key] = t
+	return t, nil
+}
+
+func buildTLSConfig(o TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: o.Insecure, ServerName: o.ServerName}
+
+	if o.CACert != "" {
+		pem, err := os.ReadFile(o.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", o.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.Cert != "" || o.Key != "" {
+		cert, err := loadClientCert(o)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	var err error
+	if cfg.MinVersion, err = ParseTLSVersion(o.MinVersion); err != nil {
+		return nil, err
+	}
+	if cfg.MaxVersion, err = ParseTLSVersion(o.MaxVersion); err != nil {
+		return nil, err
+	}
+	if cfg.MinVersion != 0 && cfg.MaxVersion != 0 && cfg.MinVersion > cfg.MaxVersion {
+		return nil, fmt.Errorf("TLS min version %s is above max version %s", o.MinVersion, o.MaxVersion)
+	}
+
+	if o.Ciphers != "" {
+		if cfg.CipherSuites, err = ParseCipherSuites(o.Ciphers); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadClientCert loads a PEM certificate/key pair or a PKCS#12 bundle. A PEM
+// file holding both the certificate and the key may be given without Key.
+func loadClientCert(o TLSOptions) (tls.Certificate, error) {
+	if o.Cert == "" {
+		return tls.Certificate{}, fmt.Errorf("client key given without a certificate")
+	}
+	data, err := os.ReadFile(o.Cert)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+
+	if isPKCS12(o.Cert, data) {
+		key, leaf, chain, err := pkcs12.DecodeChain(data, o.CertPassword)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 bundle %s: %w", o.Cert, err)
+		}
+		cert := tls.Certificate{PrivateKey: key, Leaf: leaf, Certificate: [][]byte{leaf.Raw}}
+		for _, c := range chain {
+			cert.Certificate = append(cert.Certificate, c.Raw)
+		}
+		return cert, nil
+	}
+
+	keyData := data
+	if o.Key != "" {
+		if keyData, err = os.ReadFile(o.Key); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to read client key: %w", err)
+		}
+	}
+	cert, err := tls.X509KeyPair(data, keyData)
+	if err != nil {
+		if o.Key == "" {
+			return tls.Certificate{}, fmt.Errorf("failed to load client certificate (use --key if the key is in a separate file): %w", err)
+		}
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func isPKCS12(path string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".p12", ".pfx":
+		return true
+	}
+	return !bytes.Contains(data, []byte("-----BEGIN"))
+}
+
+// ParseTLSVersion converts "1.0", "1.1", "1.2" or "1.3" (optionally prefixed
+// with "TLS") to a crypto/tls version constant. Empty means the Go default.
+func ParseTLSVersion(s string) (uint16, error) {
+	v := strings.TrimSpace(strings.ToLower(s))
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "tls"), "v")
+	switch strings.TrimSpace(v) {
+	case "":
+		return 0, nil
+	case "1.0", "1":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q (want 1.0, 1.1, 1.2 or 1.3)", s)
+}
+
+// ParseCipherSuites converts a comma-separated list of IANA cipher suite
+// names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256) to their IDs. TLS 1.3
+// suites are not configurable in Go and are rejected.
+func ParseCipherSuites(list string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		if id == tls.TLS_AES_128_GCM_SHA256 || id == tls.TLS_AES_256_GCM_SHA384 || id == tls.TLS_CHACHA20_POLY1305_SHA256 {
+			return nil, fmt.Errorf("cipher suite %s is TLS 1.3 only and cannot be selected", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,215 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type testPKI struct {
+	dir        string
+	caFile     string
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+	clientP12  string
+	caPool     *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mozzy test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, name string, usage x509.ExtKeyUsage) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return cert, key
+	}
+
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	p := &testPKI{dir: dir, caPool: x509.NewCertPool()}
+	p.caPool.AddCert(ca)
+	p.caFile = writePEM("ca.pem", "CERTIFICATE", caDER)
+
+	srv, srvKey := issue(2, "mozzy.test", x509.ExtKeyUsageServerAuth)
+	p.serverCert = tls.Certificate{Certificate: [][]byte{srv.Raw}, PrivateKey: srvKey}
+
+	cli, cliKey := issue(3, "client", x509.ExtKeyUsageClientAuth)
+	keyDER, _ := x509.MarshalECPrivateKey(cliKey)
+	p.clientCert = writePEM("client.pem", "CERTIFICATE", cli.Raw)
+	p.clientKey = writePEM("client-key.pem", "EC PRIVATE KEY", keyDER)
+
+	pfx, err := pkcs12.Modern.Encode(cliKey, cli, nil, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.clientP12 = filepath.Join(dir, "client.p12")
+	if err := os.WriteFile(p.clientP12, pfx, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// startMTLSServer serves "ok" and reports the negotiated TLS version.
+func startMTLSServer(t *testing.T, p *testPKI) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tlsVersionString(r.TLS.Version)))
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // expected handshake failures
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{p.serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    p.caPool,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestDo_MutualTLS(t *testing.T) {
+	p := newTestPKI(t)
+	url := startMTLSServer(t, p)
+
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		want    string
+		wantErr string
+	}{
+		{
+			name: "PEM cert and key",
+			opts: TLSOptions{CACert: p.caFile, ServerName: "mozzy.test", Cert: p.clientCert, Key: p.clientKey},
+			want: "TLS 1.3",
+		},
+		{
+			name: "PKCS#12 bundle",
+			opts: TLSOptions{CACert: p.caFile, ServerName: "mozzy.test", Cert: p.clientP12, CertPassword: "s3cret"},
+			want: "TLS 1.3",
+		},
+		{
+			name: "max version",
+			opts: TLSOptions{CACert: p.caFile, ServerName: "mozzy.test", Cert: p.clientCert, Key: p.clientKey, MaxVersion: "1.2"},
+			want: "TLS 1.2",
+		},
+		{
+			name: "insecure skips verification",
+			opts: TLSOptions{Insecure: true, Cert: p.clientCert, Key: p.clientKey},
+			want: "TLS 1.3",
+		},
+		{
+			name:    "server name mismatch",
+			opts:    TLSOptions{CACert: p.caFile, Cert: p.clientCert, Key: p.clientKey},
+			wantErr: "certificate",
+		},
+		{
+			name:    "wrong PKCS#12 password",
+			opts:    TLSOptions{CACert: p.caFile, ServerName: "mozzy.test", Cert: p.clientP12, CertPassword: "nope"},
+			wantErr: "PKCS#12",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, body, _, err := Do(context.Background(), Request{Method: "GET", URL: url, TLS: tt.opts})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("negotiated %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestDo_ClientCertRequired(t *testing.T) {
+	p := newTestPKI(t)
+	url := startMTLSServer(t, p)
+
+	_, _, _, err := Do(context.Background(), Request{Method: "GET", URL: url, TLS: TLSOptions{CACert: p.caFile, ServerName: "mozzy.test"}})
+	if err == nil {
+		t.Fatal("expected handshake failure without a client certificate")
+	}
+}
+
+func TestParseTLSOptions(t *testing.T) {
+	if v, err := ParseTLSVersion("TLSv1.2"); err != nil || v != tls.VersionTLS12 {
+		t.Errorf("ParseTLSVersion(TLSv1.2) = %v, %v", v, err)
+	}
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("expected error for unknown version")
+	}
+
+	ids, err := ParseCipherSuites("tls_ecdhe_ecdsa_with_aes_128_gcm_sha256, TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384")
+	if err != nil || len(ids) != 2 || ids[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("ParseCipherSuites = %v, %v", ids, err)
+	}
+	if _, err := ParseCipherSuites("TLS_AES_128_GCM_SHA256"); err == nil {
+		t.Error("expected error for TLS 1.3 suite")
+	}
+	if _, err := ParseCipherSuites("RC4-MD5"); err == nil {
+		t.Error("expected error for unknown suite")
+	}
+
+	if _, err := Transport(TransportOptions{TLS: TLSOptions{MinVersion: "1.3", MaxVersion: "1.2"}}); err == nil {
+		t.Error("expected error when min version is above max")
+	}
+}
@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// unixPlaceholderHost is the Host used for requests sent over a Unix socket;
+// daemons such as Docker ignore it but HTTP/1.1 requires one.
+const unixPlaceholderHost = "localhost"
+
+// SplitUnixURL splits a unix:// URL into the socket path and the HTTP path.
+// Both "unix:///var/run/docker.sock:/v1.43/info" and, when the socket exists,
+// "unix:///var/run/docker.sock/v1.43/info" are accepted.
+func SplitUnixURL(raw string) (socket, path string, err error) {
+	rest, ok := strings.CutPrefix(raw, "unix://")
+	if !ok {
+		return "", "", fmt.Errorf("not a unix:// URL: %s", raw)
+	}
+	if sock, p, found := strings.Cut(rest, ":"); found {
+		return sock, ensureSlash(p), nil
+	}
+
+	// No separator: the socket is the first path prefix that is a socket file
+	for i := 1; i <= len(rest); i++ {
+		if i < len(rest) && rest[i] != '/' {
+			continue
+		}
+		if fi, err := os.Stat(rest[:i]); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return rest[:i], ensureSlash(rest[i:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("no Unix socket found in %s (separate socket and path with ':', e.g. unix:///var/run/docker.sock:/info)", raw)
+}
+
+func ensureSlash(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		return "/" + p
+	}
+	return p
+}
+
+// normalizeUnix rewrites unix:// URLs, and host-less URLs when UnixSocket is
+// set, into http://localhost/... requests dialed over the socket.
+func normalizeUnix(r *Request) error {
+	if strings.HasPrefix(r.URL, "unix://") {
+		socket, path, err := SplitUnixURL(r.URL)
+		if err != nil {
+			return err
+		}
+		r.Dial.UnixSocket = socket
+		r.URL = "http://" + unixPlaceholderHost + path
+		return nil
+	}
+	if r.Dial.UnixSocket != "" && strings.HasPrefix(r.URL, "/") {
+		r.URL = "http://" + unixPlaceholderHost + r.URL
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func unixServer(t *testing.T) string {
+	t.Helper()
+	// Socket paths are length-limited, so avoid the long t.TempDir() path
+	dir, err := os.MkdirTemp("", "mozzy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "api.sock")
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.URL.RequestURI()))
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return sock
+}
+
+func TestDoRequest_UnixSocket(t *testing.T) {
+	sock := unixServer(t)
+
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"flag with path", Request{URL: "/v1/info", Dial: DialOptions{UnixSocket: sock}}, "localhost /v1/info"},
+		{"flag with full URL", Request{URL: "http://docker/v1/info", Dial: DialOptions{UnixSocket: sock}}, "docker /v1/info"},
+		{"unix scheme with separator", Request{URL: "unix://" + sock + ":/containers/json?all=1"}, "localhost /containers/json?all=1"},
+		{"unix scheme without separator", Request{URL: "unix://" + sock + "/containers/json"}, "localhost /containers/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Method = "GET"
+			_, body, timings, info, err := doRequest(context.Background(), tt.req, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("body = %q, want %q", body, tt.want)
+			}
+			if info.UnixSocket != sock || info.ResolvedIP != "" {
+				t.Errorf("UnixSocket = %q, ResolvedIP = %q", info.UnixSocket, info.ResolvedIP)
+			}
+			if timings.DNSLookup != 0 || timings.TLSHandshake != 0 {
+				t.Errorf("unexpected DNS/TLS phases: %+v", timings)
+			}
+		})
+	}
+}
+
+func TestSplitUnixURL_NoSocket(t *testing.T) {
+	if _, _, err := SplitUnixURL("unix:///nonexistent/dir/api"); err == nil {
+		t.Error("expected error when no socket file is found")
+	}
+	sock, path, err := SplitUnixURL("unix:///run/app.sock:info")
+	if err != nil || sock != "/run/app.sock" || path != "/info" {
+		t.Errorf("SplitUnixURL = %q, %q, %v", sock, path, err)
+	}
+}
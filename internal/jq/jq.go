@@ -0,0 +1,307 @@
+package jq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/itchyny/gojq"
+)
+
+// Query is a compiled jq program. The same engine backs --jq output
+// filtering, --capture and JSON assertions.
+type Query struct {
+	src  string
+	expr string // src, or its jq translation for legacy paths
+	code *gojq.Code
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]*Query{}
+)
+
+// legacyPathRe matches the dotted paths mozzy accepted before full jq
+// support, including keys that jq would not parse bare (e.g. .user-id).
+var legacyPathRe = regexp.MustCompile(`^\.?[^\s|(),:"'$]+$`)
+
+// dashedKeyRe finds a dash joining two words, as in .user-id. jq reads
+// .foo-length as .foo minus length, which compiles, so such paths are taken
+// as keys up front; .count-1 and .a-.b stay subtractions.
+var dashedKeyRe = regexp.MustCompile(`[A-Za-z0-9_]-[A-Za-z_]`)
+
+// legacyIndexRe matches legacy paths that start with an array index.
+var legacyIndexRe = regexp.MustCompile(`^\[\d+\]([.\[]|$)`)
+
+// Compile parses and compiles src. Compiled queries are cached, so calling
+// Compile repeatedly with the same source (e.g. once per streamed event) is
+// cheap.
+func Compile(src string) (*Query, error) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		src = "."
+	}
+
+	cacheMu.Lock()
+	q, ok := cache[src]
+	cacheMu.Unlock()
+	if ok {
+		return q, nil
+	}
+
+	expr := src
+	if legacyIndexRe.MatchString(src) {
+		// "[0].id" is a legacy path, not an array literal
+		expr = "." + src
+	} else if dashedKeyRe.MatchString(src) {
+		if converted, ok := convertLegacyPath(src); ok {
+			expr = converted
+		}
+	}
+	code, err := compile(expr)
+	if err != nil {
+		// Fall back to the legacy path syntax: "[0].id", ".user-id.name"
+		converted, ok := convertLegacyPath(src)
+		if !ok {
+			return nil, fmt.Errorf("invalid jq query %q: %w", src, err)
+		}
+		if code, err = compile(converted); err != nil {
+			return nil, fmt.Errorf("invalid jq query %q: %w", src, err)
+		}
+		expr = converted
+	}
+
+	q = &Query{src: src, expr: expr, code: code}
+	cacheMu.Lock()
+	cache[src] = q
+	cacheMu.Unlock()
+	return q, nil
+}
+
+func compile(src string) (*gojq.Code, error) {
+	parsed, err := gojq.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(parsed, gojq.WithEnvironLoader(os.Environ))
+}
+
+// String returns the query source.
+func (q *Query) String() string { return q.src }
+
+// Run evaluates the query against input and collects every output value.
+func (q *Query) Run(input any) ([]any, error) {
+	var out []any
+	iter := q.code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return out, nil
+		}
+		if err, isErr := v.(error); isErr {
+			if haltErr, ok := err.(*gojq.HaltError); ok && haltErr.Value() == nil {
+				return out, nil
+			}
+			return out, err
+		}
+		out = append(out, v)
+	}
+}
+
+// Eval compiles src and runs it against input.
+func Eval(src string, input any) ([]any, error) {
+	q, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return q.Run(input)
+}
+
+// EvalJSON decodes data and runs src against it.
+func EvalJSON(data []byte, src string) ([]any, error) {
+	input, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return Eval(src, input)
+}
+
+// First returns the first output of src, or nil when there is none.
+func First(src string, input any) (any, error) {
+	results, err := Eval(src, input)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// Exists reports whether src addresses something present in input. For path
+// expressions (.a.b[0]) a key holding null still counts as present; other
+// expressions exist when they produce a non-null value.
+func Exists(src string, input any) (bool, error) {
+	q, err := Compile(src)
+	if err != nil {
+		return false, err
+	}
+	if paths, err := Eval("[path("+q.expr+")]", input); err == nil && len(paths) == 1 {
+		list, _ := paths[0].([]any)
+		for _, p := range list {
+			if steps, ok := p.([]any); ok && pathPresent(input, steps) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	results, err := q.Run(input)
+	if err != nil {
+		return false, nil
+	}
+	for _, r := range results {
+		if r != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func pathPresent(v any, steps []any) bool {
+	cur := v
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return false
+			}
+			next, exists := obj[s]
+			if !exists {
+				return false
+			}
+			cur = next
+		case int, float64:
+			arr, ok := cur.([]any)
+			if !ok {
+				return false
+			}
+			idx := toInt(s)
+			if idx < 0 {
+				idx += len(arr)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return false
+			}
+			cur = arr[idx]
+		default:
+			// Slices and other path components: treat as present
+			return cur != nil
+		}
+	}
+	return true
+}
+
+func toInt(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+// Decode parses JSON into values the jq engine understands.
+func Decode(data []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Marshal encodes a jq result as indented JSON without HTML escaping, the
+// way jq prints it.
+func Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// String renders a result the way captures and assertions compare it:
+// strings as-is, integral numbers without a decimal point, everything else
+// as compact JSON.
+func String(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(x)
+	case int:
+		return strconv.Itoa(x)
+	case float64:
+		if x == float64(int64(x)) {
+			return strconv.FormatInt(int64(x), 10)
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprintf("%v", x)
+		}
+		return string(b)
+	}
+}
+
+// convertLegacyPath rewrites a legacy dotted path such as "[0].id" or
+// ".user-id.name" into jq syntax (.[0].id, .["user-id"]["name"]).
+func convertLegacyPath(src string) (string, bool) {
+	if !legacyPathRe.MatchString(src) {
+		return "", false
+	}
+	path := strings.TrimPrefix(src, ".")
+
+	var b strings.Builder
+	b.WriteString(".")
+	key := ""
+	flush := func() {
+		if key != "" {
+			b.WriteString("[" + strconv.Quote(key) + "]")
+			key = ""
+		}
+	}
+	for i := 0; i < len(path); i++ {
+		switch ch := path[i]; ch {
+		case '.':
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return "", false
+			}
+			idx := path[i+1 : i+end]
+			if _, err := strconv.Atoi(idx); err != nil {
+				return "", false
+			}
+			b.WriteString("[" + idx + "]")
+			i += end
+		default:
+			key += string(ch)
+		}
+	}
+	flush()
+	return b.String(), true
+}
@@ -0,0 +1,113 @@
+package jq
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sample = `{
+  "items": [
+    {"id": 1, "name": "alpha", "active": true, "price": 2.5},
+    {"id": 2, "name": "beta", "active": false, "price": 4},
+    {"id": 3, "name": "gamma", "active": true, "price": null}
+  ],
+  "user-id": "u-1",
+  "foo": 5,
+  "foo-length": 9,
+  "meta": {"total": 3}
+}`
+
+func TestEvalJSON(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []any
+	}{
+		{".meta.total", []any{float64(3)}},
+		{".items[] | select(.active) | .id", []any{float64(1), float64(3)}},
+		{".items | map(.name)", []any{[]any{"alpha", "beta", "gamma"}}},
+		{".items | length", []any{3}},
+		{".meta | keys", []any{[]any{"total"}}},
+		{".items[1:] | map(.id)", []any{[]any{float64(2), float64(3)}}},
+		{`.items[0] | {id, label: "\(.name)-\(.id)"}`, []any{map[string]any{"id": float64(1), "label": "alpha-1"}}},
+		{".meta.total * 2 + 1", []any{float64(7)}},
+		{".items[0].id.nope", nil},
+		{".user-id", []any{"u-1"}},
+		{".foo-length", []any{float64(9)}},
+		{".foo-1", []any{float64(4)}},
+		{".foo-.meta.total", []any{float64(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := EvalJSON([]byte(sample), tt.query)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("EvalJSON(%q) = %v, want error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalJSON(%q): %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalJSON(%q) = %#v, want %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyArrayPath(t *testing.T) {
+	got, err := EvalJSON([]byte(`[{"id": 7}]`), "[0].id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []any{float64(7)}) {
+		t.Errorf("got %v, want [7]", got)
+	}
+}
+
+func TestExists(t *testing.T) {
+	data, err := Decode([]byte(`{"a": {"b": null}, "list": [1]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{".a.b", true},
+		{".a.c", false},
+		{".list[0]", true},
+		{".list[5]", false},
+		{".list | length", true},
+		{".missing // empty", false},
+	}
+	for _, tt := range tests {
+		got, err := Exists(tt.query, data)
+		if err != nil {
+			t.Fatalf("Exists(%q): %v", tt.query, err)
+		}
+		if got != tt.want {
+			t.Errorf("Exists(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"x", "x"},
+		{float64(42), "42"},
+		{1.5, "1.5"},
+		{true, "true"},
+		{nil, "null"},
+		{[]any{"a", float64(1)}, `["a",1]`},
+	}
+	for _, tt := range tests {
+		if got := String(tt.in); got != tt.want {
+			t.Errorf("String(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
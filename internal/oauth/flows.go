@@ -0,0 +1,283 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Login runs c's flow from the start and returns the new token. Interactive
+// flows print their instructions to out and wait for the user until ctx
+// ends.
+func Login(ctx context.Context, c Config, client *http.Client, out io.Writer) (*Token, error) {
+	if client == nil {
+		client = defaultClient
+	}
+	if err := c.Discover(ctx, client); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	form := url.Values{}
+	switch c.Flow {
+	case ClientCredentials:
+		form.Set("grant_type", "client_credentials")
+	case Password:
+		form.Set("grant_type", "password")
+		form.Set("username", c.Username)
+		form.Set("password", c.Password)
+	case RefreshToken:
+		return Refresh(ctx, c, client, c.RefreshToken)
+	case AuthorizationCode:
+		return authorizationCode(ctx, c, client, out)
+	case DeviceCode:
+		return deviceCode(ctx, c, client, out)
+	}
+	setScope(form, c)
+	return c.exchange(ctx, client, form, now)
+}
+
+// Refresh trades refreshToken for a new token. Servers that do not rotate
+// refresh tokens leave it out of the reply, so the old one is kept.
+func Refresh(ctx context.Context, c Config, client *http.Client, refreshToken string) (*Token, error) {
+	if client == nil {
+		client = defaultClient
+	}
+	if err := c.Discover(ctx, client); err != nil {
+		return nil, err
+	}
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+	if c.Scope != "" {
+		form.Set("scope", c.Scope)
+	}
+	t, err := c.exchange(ctx, client, form, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if t.RefreshToken == "" {
+		t.RefreshToken = refreshToken
+	}
+	return t, nil
+}
+
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+func setScope(form url.Values, c Config) {
+	if c.Scope != "" {
+		form.Set("scope", c.Scope)
+	}
+	if c.Audience != "" {
+		form.Set("audience", c.Audience)
+	}
+}
+
+// authorizationCode runs the authorization code flow with PKCE (RFC 7636):
+// the browser is sent to the authorization endpoint and the code comes back
+// to a listener on the loopback interface (RFC 8252 section 7.3).
+func authorizationCode(ctx context.Context, c Config, client *http.Client, out io.Writer) (*Token, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", c.RedirectPort))
+	if err != nil {
+		return nil, fmt.Errorf("oauth: cannot listen for the redirect: %w", err)
+	}
+	defer ln.Close()
+	redirectURI := fmt.Sprintf("http://%s/callback", ln.Addr())
+
+	verifier := randomString(32)
+	state := randomString(16)
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, err := url.Parse(c.AuthURL)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: invalid auth_url: %w", err)
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	if c.Scope != "" {
+		q.Set("scope", c.Scope)
+	}
+	if c.Audience != "" {
+		q.Set("audience", c.Audience)
+	}
+	authURL.RawQuery = q.Encode()
+
+	type result struct {
+		code string
+		err  error
+	}
+	done := make(chan result, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("state") != state:
+			res.err = fmt.Errorf("oauth: redirect has the wrong state")
+		case q.Get("error") != "":
+			res.err = &Error{Code: q.Get("error"), Description: q.Get("error_description")}
+		case q.Get("code") == "":
+			res.err = fmt.Errorf("oauth: redirect has no code")
+		default:
+			res.code = q.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "mozzy: login complete, you can close this tab.")
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	fmt.Fprintf(out, "🌐 Open this URL to sign in:\n\n  %s\n\n", authURL)
+	if err := openBrowser(authURL.String()); err != nil {
+		fmt.Fprintf(out, "   (could not open a browser: %v)\n", err)
+	}
+	fmt.Fprintf(out, "⏳ Waiting for the redirect to %s ...\n", redirectURI)
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("oauth: login timed out: %w", ctx.Err())
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	return c.exchange(ctx, client, form, time.Now())
+}
+
+// deviceAuth is the device authorization endpoint's reply (RFC 8628).
+type deviceAuth struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURL         string `json:"verification_url"` // older Google spelling
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               any    `json:"expires_in"`
+	Interval                any    `json:"interval"`
+	Error
+}
+
+// pollUnit is the unit of the device flow's interval and expires_in; tests
+// shorten it.
+var pollUnit = time.Second
+
+// deviceCode runs the device authorization grant: the user enters a code
+// on another device while the token endpoint is polled.
+func deviceCode(ctx context.Context, c Config, client *http.Client, out io.Writer) (*Token, error) {
+	form := url.Values{}
+	setScope(form, c)
+	var da deviceAuth
+	if err := c.post(ctx, client, c.DeviceURL, form, &da); err != nil {
+		return nil, err
+	}
+	if da.DeviceCode == "" {
+		return nil, fmt.Errorf("oauth: device response from %s has no device_code", c.DeviceURL)
+	}
+	verify := da.VerificationURI
+	if verify == "" {
+		verify = da.VerificationURL
+	}
+	fmt.Fprintf(out, "🔑 Visit %s and enter the code: %s\n", verify, da.UserCode)
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "   or open %s\n", da.VerificationURIComplete)
+	}
+
+	interval := time.Duration(seconds(da.Interval)) * pollUnit
+	if interval <= 0 {
+		interval = 5 * pollUnit
+	}
+	if expiresIn := seconds(da.ExpiresIn); expiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(expiresIn)*pollUnit)
+		defer cancel()
+	}
+	fmt.Fprintln(out, "⏳ Waiting for approval...")
+
+	poll := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {da.DeviceCode},
+	}
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("oauth: device code expired before it was approved")
+		}
+		t, err := c.exchange(ctx, client, poll, time.Now())
+		var oe *Error
+		switch {
+		case err == nil:
+			return t, nil
+		case errors.As(err, &oe) && oe.Code == "authorization_pending":
+		case errors.As(err, &oe) && oe.Code == "slow_down":
+			interval += 5 * pollUnit
+		default:
+			return nil, err
+		}
+	}
+}
+
+// randomString returns n random bytes, base64url-encoded without padding,
+// which is within the PKCE verifier alphabet.
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// openBrowser opens u in the user's browser. Tests replace it.
+var openBrowser = func(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// NoBrowser stops login from opening a browser; the URL is only printed.
+func NoBrowser() {
+	openBrowser = func(string) error { return nil }
+}
@@ -0,0 +1,380 @@
+// Package oauth obtains OAuth2 / OpenID Connect access tokens for an
+// environment, caches them in the project and refreshes them before they
+// expire.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/humancto/mozzy/internal/jwtutil"
+)
+
+// Flows accepted in an environment's oauth.flow.
+const (
+	ClientCredentials = "client_credentials"
+	Password          = "password"
+	RefreshToken      = "refresh_token"
+	AuthorizationCode = "authorization_code"
+	DeviceCode        = "device_code"
+)
+
+// Flows lists the flow names in the order they are documented.
+var Flows = []string{ClientCredentials, Password, RefreshToken, AuthorizationCode, DeviceCode}
+
+// RefreshSkew is how long before its expiry a token is replaced, so a
+// request never leaves with a token that lapses in flight.
+const RefreshSkew = 30 * time.Second
+
+// Config declares how an environment obtains its tokens. Endpoints left
+// empty are looked up from the issuer's OpenID Connect discovery document.
+type Config struct {
+	Flow string `json:"flow" yaml:"flow"`
+
+	Issuer    string `json:"issuer,omitempty" yaml:"issuer,omitempty"` // OIDC discovery
+	TokenURL  string `json:"token_url,omitempty" yaml:"token_url,omitempty"`
+	AuthURL   string `json:"auth_url,omitempty" yaml:"auth_url,omitempty"`     // authorization_code
+	DeviceURL string `json:"device_url,omitempty" yaml:"device_url,omitempty"` // device_code
+
+	ClientID     string `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	ClientAuth   string `json:"client_auth,omitempty" yaml:"client_auth,omitempty"` // basic (default) or post
+	Scope        string `json:"scope,omitempty" yaml:"scope,omitempty"`             // space-separated
+	Audience     string `json:"audience,omitempty" yaml:"audience,omitempty"`
+
+	Username     string `json:"username,omitempty" yaml:"username,omitempty"` // password
+	Password     string `json:"password,omitempty" yaml:"password,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty" yaml:"refresh_token,omitempty"` // refresh_token
+
+	RedirectPort int `json:"redirect_port,omitempty" yaml:"redirect_port,omitempty"` // loopback port, 0 picks a free one
+}
+
+// Interactive reports whether the flow needs a user at a browser, so it can
+// only run from `mozzy auth login`.
+func (c Config) Interactive() bool {
+	return c.Flow == AuthorizationCode || c.Flow == DeviceCode
+}
+
+// Validate checks that the settings the flow needs are present. Endpoints
+// are not required when an issuer is set.
+func (c Config) Validate() error {
+	need := func(v, name string) error {
+		if v == "" {
+			return fmt.Errorf("oauth %s flow needs %s", c.Flow, name)
+		}
+		return nil
+	}
+	endpoint := func(v, name string) error {
+		if c.Issuer != "" {
+			return nil
+		}
+		return need(v, name)
+	}
+	var errs []error
+	switch c.Flow {
+	case ClientCredentials:
+		errs = append(errs, endpoint(c.TokenURL, "token_url"), need(c.ClientID, "client_id"))
+	case Password:
+		errs = append(errs, endpoint(c.TokenURL, "token_url"), need(c.Username, "username"))
+	case RefreshToken:
+		errs = append(errs, endpoint(c.TokenURL, "token_url"), need(c.RefreshToken, "refresh_token"))
+	case AuthorizationCode:
+		errs = append(errs, endpoint(c.AuthURL, "auth_url"), endpoint(c.TokenURL, "token_url"), need(c.ClientID, "client_id"))
+	case DeviceCode:
+		errs = append(errs, endpoint(c.DeviceURL, "device_url"), endpoint(c.TokenURL, "token_url"), need(c.ClientID, "client_id"))
+	case "":
+		return fmt.Errorf("oauth needs a flow (want %s)", strings.Join(Flows, ", "))
+	default:
+		return fmt.Errorf("unknown oauth flow %q (want %s)", c.Flow, strings.Join(Flows, ", "))
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	switch c.ClientAuth {
+	case "", "basic", "post":
+	default:
+		return fmt.Errorf("unknown oauth client_auth %q (want basic or post)", c.ClientAuth)
+	}
+	return nil
+}
+
+// fingerprint identifies the client a cached token was issued to, so a
+// token is not reused after the environment's oauth settings change.
+func (c Config) fingerprint() string {
+	return strings.Join([]string{c.Flow, c.Issuer, c.TokenURL, c.ClientID, c.Username, c.Scope, c.Audience}, "|")
+}
+
+// Set assigns one setting by its JSON name, as given with
+// `mozzy env set <env> oauth.<name>`.
+func (c *Config) Set(name, value string) error {
+	if name == "redirect_port" {
+		n := 0
+		if value != "" {
+			var err error
+			if n, err = strconv.Atoi(value); err != nil || n < 0 || n > 65535 {
+				return fmt.Errorf("redirect_port must be a port number")
+			}
+		}
+		c.RedirectPort = n
+		return nil
+	}
+	f, ok := c.fields()[name]
+	if !ok {
+		names := []string{"redirect_port"}
+		for n := range c.fields() {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown oauth setting %q (want %s)", name, strings.Join(names, ", "))
+	}
+	*f = value
+	return nil
+}
+
+func (c *Config) fields() map[string]*string {
+	return map[string]*string{
+		"flow": &c.Flow, "issuer": &c.Issuer,
+		"token_url": &c.TokenURL, "auth_url": &c.AuthURL, "device_url": &c.DeviceURL,
+		"client_id": &c.ClientID, "client_secret": &c.ClientSecret, "client_auth": &c.ClientAuth,
+		"scope": &c.Scope, "audience": &c.Audience,
+		"username": &c.Username, "password": &c.Password, "refresh_token": &c.RefreshToken,
+	}
+}
+
+// Interpolate returns a copy of c with fn applied to every setting, for
+// {{vars}} in client secrets and passwords.
+func (c Config) Interpolate(fn func(string) string) Config {
+	if fn == nil {
+		return c
+	}
+	for _, f := range c.fields() {
+		*f = fn(*f)
+	}
+	return c
+}
+
+// Token is an issued token set as kept in the cache.
+type Token struct {
+	AccessToken  string     `json:"access_token"`
+	TokenType    string     `json:"token_type,omitempty"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	IDToken      string     `json:"id_token,omitempty"`
+	Scope        string     `json:"scope,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // nil when the server gave no lifetime
+	ObtainedAt   time.Time  `json:"obtained_at"`
+	Flow         string     `json:"flow"`
+	Client       string     `json:"client"` // fingerprint of the settings it was issued for
+}
+
+// Valid reports whether t can still be sent at now, leaving RefreshSkew to
+// spare.
+func (t *Token) Valid(now time.Time) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Add(RefreshSkew).Before(*t.ExpiresAt)
+}
+
+// Error is an OAuth2 error response (RFC 6749 section 5.2).
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth: %s: %s", e.Code, e.Description)
+	}
+	return "oauth: " + e.Code
+}
+
+// tokenResponse is the token endpoint's reply. expires_in is a number, but
+// some servers send it as a string.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	Scope        string `json:"scope"`
+	ExpiresIn    any    `json:"expires_in"`
+	Error
+}
+
+// exchange posts form to the token endpoint and returns the issued token.
+func (c Config) exchange(ctx context.Context, client *http.Client, form url.Values, now time.Time) (*Token, error) {
+	var res tokenResponse
+	if err := c.post(ctx, client, c.TokenURL, form, &res); err != nil {
+		return nil, err
+	}
+	if res.AccessToken == "" {
+		return nil, fmt.Errorf("oauth: token response from %s has no access_token", c.TokenURL)
+	}
+	return &Token{
+		AccessToken:  res.AccessToken,
+		TokenType:    res.TokenType,
+		RefreshToken: res.RefreshToken,
+		IDToken:      res.IDToken,
+		Scope:        res.Scope,
+		ExpiresAt:    expiry(res.AccessToken, seconds(res.ExpiresIn), now),
+		ObtainedAt:   now,
+		Flow:         c.Flow,
+		Client:       c.fingerprint(),
+	}, nil
+}
+
+// post sends a form to an OAuth endpoint with the client's credentials and
+// decodes the JSON (or form-encoded) reply into out. Error replies are
+// returned as *Error.
+func (c Config) post(ctx context.Context, client *http.Client, endpoint string, form url.Values, out interface{ oauthError() *Error }) error {
+	if c.ClientID != "" && (c.ClientSecret == "" || c.ClientAuth == "post") {
+		form.Set("client_id", c.ClientID)
+	}
+	if c.ClientSecret != "" && c.ClientAuth == "post" {
+		form.Set("client_secret", c.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.ClientSecret != "" && c.ClientAuth != "post" {
+		// RFC 6749 2.3.1: both parts are form-encoded before Basic encoding
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if err := decode(res.Header.Get("Content-Type"), body, out); err != nil {
+		if res.StatusCode >= 400 {
+			return fmt.Errorf("oauth: %s returned %s", endpoint, res.Status)
+		}
+		return fmt.Errorf("oauth: invalid response from %s: %w", endpoint, err)
+	}
+	if e := out.oauthError(); e.Code != "" {
+		e.Status = res.StatusCode
+		return e
+	}
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("oauth: %s returned %s", endpoint, res.Status)
+	}
+	return nil
+}
+
+func (e *Error) oauthError() *Error { return e }
+
+// decode reads a JSON reply, or a form-encoded one as sent by some older
+// providers.
+func decode(contentType string, body []byte, out any) error {
+	if mt, _, _ := mime.ParseMediaType(contentType); mt == "application/x-www-form-urlencoded" {
+		q, err := url.ParseQuery(string(body))
+		if err != nil {
+			return err
+		}
+		m := make(map[string]string, len(q))
+		for k := range q {
+			m[k] = q.Get(k)
+		}
+		if body, err = json.Marshal(m); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(body, out)
+}
+
+// expiry returns when accessToken lapses: its exp claim when it is a JWT,
+// otherwise expiresIn seconds from now. It is nil when neither is known.
+func expiry(accessToken string, expiresIn int64, now time.Time) *time.Time {
+	if strings.Count(accessToken, ".") == 2 {
+		if _, claims, err := jwtutil.Decode(accessToken); err == nil {
+			if exp, ok := claims["exp"].(float64); ok {
+				t := time.Unix(int64(exp), 0)
+				return &t
+			}
+		}
+	}
+	if expiresIn > 0 {
+		t := now.Add(time.Duration(expiresIn) * time.Second)
+		return &t
+	}
+	return nil
+}
+
+func seconds(v any) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+// discovery holds the endpoints of an OpenID Connect discovery document.
+type discovery struct {
+	TokenEndpoint  string `json:"token_endpoint"`
+	AuthEndpoint   string `json:"authorization_endpoint"`
+	DeviceEndpoint string `json:"device_authorization_endpoint"`
+}
+
+// Discover fills the endpoints left empty in c from the issuer's
+// /.well-known/openid-configuration.
+func (c *Config) Discover(ctx context.Context, client *http.Client) error {
+	missing := c.TokenURL == "" ||
+		(c.Flow == AuthorizationCode && c.AuthURL == "") ||
+		(c.Flow == DeviceCode && c.DeviceURL == "")
+	if c.Issuer == "" || !missing {
+		return nil
+	}
+	u := strings.TrimRight(c.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oauth discovery: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth discovery: %s returned %s", u, res.Status)
+	}
+	var d discovery
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&d); err != nil {
+		return fmt.Errorf("oauth discovery: %w", err)
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = d.TokenEndpoint
+	}
+	if c.AuthURL == "" {
+		c.AuthURL = d.AuthEndpoint
+	}
+	if c.DeviceURL == "" {
+		c.DeviceURL = d.DeviceEndpoint
+	}
+	if c.TokenURL == "" {
+		return fmt.Errorf("oauth discovery: %s has no token_endpoint", u)
+	}
+	return nil
+}
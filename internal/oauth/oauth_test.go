@@ -0,0 +1,325 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/humancto/mozzy/internal/auth"
+)
+
+// idp is a fake token endpoint that issues numbered tokens and records the
+// forms it receives.
+type idp struct {
+	*httptest.Server
+	mu        sync.Mutex
+	forms     []map[string]string
+	basic     []string
+	issued    int
+	expiresIn int
+	pending   int // device polls answered with authorization_pending
+	codes     map[string]string
+}
+
+func newIDP(t *testing.T) *idp {
+	p := &idp{expiresIn: 3600, codes: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"token_endpoint":                p.URL + "/token",
+			"authorization_endpoint":        p.URL + "/authorize",
+			"device_authorization_endpoint": p.URL + "/device",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		p.mu.Lock()
+		p.codes["code-1"] = q.Get("code_challenge")
+		p.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=code-1&state="+q.Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"device_code": "dev-1", "user_code": "ABCD-EFGH",
+			"verification_uri": p.URL + "/activate", "interval": 1, "expires_in": 600,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		user, pass, _ := r.BasicAuth()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.forms = append(p.forms, form)
+		p.basic = append(p.basic, user+":"+pass)
+
+		fail := func(code string) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":%q}`, code)
+		}
+		switch form["grant_type"] {
+		case "refresh_token":
+			if !strings.HasPrefix(form["refresh_token"], "refresh-") {
+				fail("invalid_grant")
+				return
+			}
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(form["code_verifier"]))
+			if p.codes[form["code"]] != base64.RawURLEncoding.EncodeToString(sum[:]) {
+				fail("invalid_grant")
+				return
+			}
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			if p.pending > 0 {
+				p.pending--
+				fail("authorization_pending")
+				return
+			}
+		}
+		p.issued++
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("token-%d", p.issued),
+			"token_type":    "bearer",
+			"refresh_token": fmt.Sprintf("refresh-%d", p.issued),
+			"expires_in":    p.expiresIn,
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *idp) lastForm() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.forms[len(p.forms)-1]
+}
+
+func TestLogin_ClientCredentials(t *testing.T) {
+	p := newIDP(t)
+	c := Config{Flow: ClientCredentials, TokenURL: p.URL + "/token", ClientID: "cli", ClientSecret: "s3cret", Scope: "read write"}
+	tok, err := Login(context.Background(), c, nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "token-1" || tok.RefreshToken != "refresh-1" || tok.Flow != ClientCredentials {
+		t.Errorf("token = %+v", tok)
+	}
+	if tok.ExpiresAt == nil || time.Until(*tok.ExpiresAt) < 59*time.Minute {
+		t.Errorf("ExpiresAt = %v, want about an hour from now", tok.ExpiresAt)
+	}
+	form := p.lastForm()
+	if form["grant_type"] != "client_credentials" || form["scope"] != "read write" || form["client_id"] != "" {
+		t.Errorf("form = %v", form)
+	}
+	if p.basic[0] != "cli:s3cret" {
+		t.Errorf("client auth = %q, want Basic cli:s3cret", p.basic[0])
+	}
+}
+
+func TestLogin_PasswordClientPost(t *testing.T) {
+	p := newIDP(t)
+	c := Config{Flow: Password, TokenURL: p.URL + "/token", ClientID: "cli", ClientSecret: "s", ClientAuth: "post", Username: "ada", Password: "pw"}
+	if _, err := Login(context.Background(), c, nil, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	form := p.lastForm()
+	if form["username"] != "ada" || form["password"] != "pw" || form["client_id"] != "cli" || form["client_secret"] != "s" {
+		t.Errorf("form = %v", form)
+	}
+	if p.basic[0] != ":" {
+		t.Errorf("client_auth post should not send Basic, got %q", p.basic[0])
+	}
+}
+
+func TestLogin_AuthorizationCodePKCE(t *testing.T) {
+	p := newIDP(t)
+	openBrowser = func(u string) error {
+		go func() {
+			res, err := http.Get(u)
+			if err == nil {
+				res.Body.Close()
+			}
+		}()
+		return nil
+	}
+	t.Cleanup(func() { openBrowser = nil })
+
+	// Endpoints come from the issuer's discovery document
+	c := Config{Flow: AuthorizationCode, Issuer: p.URL, ClientID: "cli", Scope: "openid"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tok, err := Login(ctx, c, nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "token-1" {
+		t.Errorf("token = %+v", tok)
+	}
+	form := p.lastForm()
+	if form["code"] != "code-1" || !strings.HasPrefix(form["redirect_uri"], "http://127.0.0.1:") || form["client_id"] != "cli" {
+		t.Errorf("form = %v", form)
+	}
+}
+
+func TestLogin_DeviceCode(t *testing.T) {
+	p := newIDP(t)
+	p.pending = 2
+	pollUnit = time.Millisecond
+	t.Cleanup(func() { pollUnit = time.Second })
+
+	var out strings.Builder
+	c := Config{Flow: DeviceCode, TokenURL: p.URL + "/token", DeviceURL: p.URL + "/device", ClientID: "tv"}
+	tok, err := Login(context.Background(), c, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "token-1" || len(p.forms) != 3 {
+		t.Errorf("token = %+v after %d polls", tok, len(p.forms))
+	}
+	if !strings.Contains(out.String(), "ABCD-EFGH") {
+		t.Errorf("instructions missing user code:\n%s", out.String())
+	}
+}
+
+func TestLogin_Errors(t *testing.T) {
+	p := newIDP(t)
+	c := Config{Flow: RefreshToken, TokenURL: p.URL + "/token", RefreshToken: "revoked"}
+	_, err := Login(context.Background(), c, nil, io.Discard)
+	var oe *Error
+	if !errors.As(err, &oe) || oe.Code != "invalid_grant" || oe.Status != http.StatusBadRequest {
+		t.Errorf("err = %v, want invalid_grant", err)
+	}
+
+	for _, c := range []Config{
+		{},
+		{Flow: "implicit"},
+		{Flow: ClientCredentials, ClientID: "cli"},
+		{Flow: DeviceCode, TokenURL: "x", ClientID: "cli"},
+		{Flow: ClientCredentials, TokenURL: "x", ClientID: "cli", ClientAuth: "jwt"},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", c)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Now()
+	exp := now.Add(10 * time.Minute).Truncate(time.Second)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": exp.Unix()}).SignedString([]byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The JWT's own exp wins over expires_in
+	if got := expiry(signed, 3600, now); got == nil || !got.Equal(exp) {
+		t.Errorf("expiry(jwt) = %v, want %v", got, exp)
+	}
+	if got := expiry("opaque", 60, now); got == nil || !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("expiry(opaque, 60) = %v", got)
+	}
+	if got := expiry("opaque", 0, now); got != nil {
+		t.Errorf("expiry without lifetime = %v, want nil", got)
+	}
+	if seconds("120") != 120 || seconds(float64(5)) != 5 {
+		t.Error("seconds should accept numbers and numeric strings")
+	}
+}
+
+func TestSource_CachesAndRefreshes(t *testing.T) {
+	p := newIDP(t)
+	path := filepath.Join(t.TempDir(), ".mozzy", "tokens.json")
+	c := Config{Flow: ClientCredentials, TokenURL: p.URL + "/token", ClientID: "cli"}
+
+	now := time.Now()
+	s := NewSource(c, "dev", path)
+	s.now = func() time.Time { return now }
+	get := func(s *Source) string {
+		t.Helper()
+		tok, err := s.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok.AccessToken
+	}
+
+	if got := get(s); got != "token-1" {
+		t.Fatalf("first token = %q", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("cache file: %v, %v", info, err)
+	}
+
+	// A second process reads the cached token instead of fetching one
+	s2 := NewSource(c, "dev", path)
+	s2.now = s.now
+	if got := get(s2); got != "token-1" || p.issued != 1 {
+		t.Errorf("cached token = %q after %d issued", got, p.issued)
+	}
+
+	// Within RefreshSkew of expiry the refresh token is used
+	now = now.Add(time.Hour - RefreshSkew/2)
+	if got := get(s); got != "token-2" {
+		t.Errorf("refreshed token = %q", got)
+	}
+	if form := p.lastForm(); form["grant_type"] != "refresh_token" || form["refresh_token"] != "refresh-1" {
+		t.Errorf("refresh form = %v", form)
+	}
+
+	// Changed settings do not reuse the token
+	c.Scope = "admin"
+	if got := get(NewSource(c, "dev", path)); got != "token-3" {
+		t.Errorf("token after scope change = %q", got)
+	}
+}
+
+func TestSource_InteractiveNeedsLogin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	s := NewSource(Config{Flow: DeviceCode, TokenURL: "http://127.0.0.1:1/token", ClientID: "tv"}, "prod", path)
+	_, err := s.Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "auth login") {
+		t.Errorf("err = %v, want a hint to run auth login", err)
+	}
+}
+
+func TestSource_RetriesAfter401(t *testing.T) {
+	p := newIDP(t)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first token is revoked server-side
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer api.Close()
+
+	c := Config{Flow: ClientCredentials, TokenURL: p.URL + "/token", ClientID: "cli"}
+	s := NewSource(c, "dev", filepath.Join(t.TempDir(), "tokens.json"))
+	client := &http.Client{Transport: &auth.Transport{Base: http.DefaultTransport, Scheme: s}}
+	res, err := client.Get(api.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200 after refreshing the token", res.StatusCode)
+	}
+}
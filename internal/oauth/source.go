@@ -0,0 +1,139 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source supplies an environment's access token to requests. It reuses the
+// cached token, refreshes it shortly before it expires and runs the
+// non-interactive flows when there is none. As an auth.Challenger, a 401
+// discards the token and the request is sent once more with a new one.
+type Source struct {
+	Config Config
+	Env    string
+	Path   string       // token cache, see StorePath
+	Client *http.Client // for the token endpoint; nil uses a default
+
+	mu    sync.Mutex
+	tok   *Token
+	stale bool // the server rejected tok
+	now   func() time.Time
+}
+
+// NewSource returns a token source for env that caches tokens at path.
+func NewSource(c Config, env, path string) *Source {
+	return &Source{Config: c, Env: env, Path: path, now: time.Now}
+}
+
+// Token returns a token that is valid for at least RefreshSkew.
+func (s *Source) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if !s.stale && s.tok.Valid(now) {
+		return s.tok, nil
+	}
+
+	st, err := LoadStore(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	cached := st.Get(s.Env, s.Config)
+	rejected := s.stale && s.tok != nil && cached != nil && cached.AccessToken == s.tok.AccessToken
+	if cached.Valid(now) && !rejected {
+		// Possibly refreshed by another mozzy process in the meantime
+		s.tok, s.stale = cached, false
+		return cached, nil
+	}
+
+	t, err := s.obtain(ctx, cached)
+	if err != nil {
+		return nil, err
+	}
+	err = UpdateStore(s.Path, func(st *Store) error {
+		st.Tokens[s.Env] = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.tok, s.stale = t, false
+	return t, nil
+}
+
+// obtain refreshes cached when it has a refresh token and otherwise runs the
+// configured flow, which is only possible for the non-interactive ones.
+func (s *Source) obtain(ctx context.Context, cached *Token) (*Token, error) {
+	var refreshErr error
+	if cached != nil && cached.RefreshToken != "" {
+		t, err := Refresh(ctx, s.Config, s.Client, cached.RefreshToken)
+		if err == nil {
+			if t.IDToken == "" {
+				t.IDToken = cached.IDToken
+			}
+			t.Flow = cached.Flow
+			fmt.Fprintf(os.Stderr, "🔑 Refreshed OAuth token for %s%s\n", s.Env, expiresIn(t, s.now()))
+			return t, nil
+		}
+		var oe *Error
+		if !errors.As(err, &oe) {
+			return nil, err
+		}
+		// The refresh token was revoked or has expired: start over
+		refreshErr = err
+	}
+	if s.Config.Interactive() {
+		msg := fmt.Sprintf("no valid OAuth token for environment %q: run `mozzy --env %s auth login`", s.Env, s.Env)
+		if refreshErr != nil {
+			msg += fmt.Sprintf(" (refresh failed: %v)", refreshErr)
+		}
+		return nil, errors.New(msg)
+	}
+	t, err := Login(ctx, s.Config, s.Client, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "🔑 Obtained OAuth token for %s%s\n", s.Env, expiresIn(t, s.now()))
+	return t, nil
+}
+
+// Apply sets the Authorization header to the current access token.
+func (s *Source) Apply(req *http.Request, _ []byte) error {
+	t, err := s.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	req.Header.Set("Authorization", typ+" "+t.AccessToken)
+	return nil
+}
+
+// Challenge marks the token as rejected so the next Apply replaces it. It
+// reports false when a new token cannot be obtained without the user.
+func (s *Source) Challenge(*http.Response) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok == nil || (s.tok.RefreshToken == "" && s.Config.Interactive()) {
+		return false
+	}
+	s.stale = true
+	return true
+}
+
+func expiresIn(t *Token, now time.Time) string {
+	if t.ExpiresAt == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (expires in %s)", t.ExpiresAt.Sub(now).Round(time.Second))
+}
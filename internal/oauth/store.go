@@ -0,0 +1,88 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/humancto/mozzy/internal/filelock"
+)
+
+const storeLockTimeout = 5 * time.Second
+
+// Store is the per-project token cache at .mozzy/tokens.json, holding one
+// token set per environment. It is written with 0600 permissions because
+// it contains live credentials.
+type Store struct {
+	Tokens map[string]*Token `json:"tokens"`
+}
+
+// StorePath returns the location of the token cache in projectDir.
+func StorePath(projectDir string) string {
+	return filepath.Join(projectDir, ".mozzy", "tokens.json")
+}
+
+// LoadStore reads the token cache at path. A missing file yields an empty
+// store.
+func LoadStore(path string) (*Store, error) {
+	s := &Store{Tokens: map[string]*Token{}}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+	}
+	if s.Tokens == nil {
+		s.Tokens = map[string]*Token{}
+	}
+	return s, nil
+}
+
+// UpdateStore loads the cache at path under a file lock, applies fn and
+// writes the result back atomically.
+func UpdateStore(path string, fn func(*Store) error) error {
+	lock, err := filelock.Acquire(path, storeLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	s, err := LoadStore(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return filelock.WriteFileAtomic(path, data, 0o600)
+}
+
+// Get returns env's cached token if it was issued for c's current settings.
+func (s *Store) Get(env string, c Config) *Token {
+	t := s.Tokens[env]
+	if t == nil || t.Client != c.fingerprint() {
+		return nil
+	}
+	return t
+}
+
+// Envs returns the environments with a cached token, sorted.
+func (s *Store) Envs() []string {
+	envs := make([]string, 0, len(s.Tokens))
+	for env := range s.Tokens {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	return envs
+}
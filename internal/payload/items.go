@@ -0,0 +1,100 @@
+// Package payload builds request bodies: JSON objects and url-encoded or
+// multipart forms from httpie-style inline items, raw files and stdin.
+package payload
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Item kinds, named after their separators.
+const (
+	Field     = "="   // name=value: string field
+	RawJSON   = ":="  // name:=30: raw JSON value
+	FieldFile = "=@"  // name=@notes.txt: string field read from a file
+	JSONFile  = ":=@" // name:=@data.json: JSON value read from a file
+	FilePart  = "@"   // name@photo.png: file uploaded as a multipart part
+	Query     = "=="  // name==value: URL query parameter
+	Header    = ":"   // Name:value: request header
+)
+
+// separators in the order they are tried at the same position, so that the
+// longest one wins (":=@" before ":=" before ":").
+var separators = []string{JSONFile, RawJSON, Query, FieldFile, Field, FilePart, Header}
+
+// Item is one inline request item given after the URL.
+type Item struct {
+	Kind  string
+	Name  string
+	Value string
+}
+
+// ParseItem splits s at its first separator. A backslash escapes a
+// separator character that belongs to the name, e.g. `a\=b=c`.
+func ParseItem(s string) (Item, error) {
+	var name strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.ContainsRune("=:@\\", rune(s[i+1])) {
+			name.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		for _, sep := range separators {
+			if strings.HasPrefix(s[i:], sep) {
+				if name.Len() == 0 {
+					return Item{}, fmt.Errorf("request item %q has no name", s)
+				}
+				return Item{Kind: sep, Name: name.String(), Value: s[i+len(sep):]}, nil
+			}
+		}
+		name.WriteByte(s[i])
+	}
+	return Item{}, fmt.Errorf("invalid request item %q (want name=value, name:=json, name@file, name==query or Header:value)", s)
+}
+
+// ParseItems parses every item in args.
+func ParseItems(args []string) ([]Item, error) {
+	items := make([]Item, 0, len(args))
+	for _, a := range args {
+		it, err := ParseItem(a)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// ParsePart parses a -F multipart part: name@file or curl's name=@file for
+// files and name=value for text, each optionally followed by ;type=<mime>
+// and ;filename=<name>.
+func ParsePart(s string) (Part, error) {
+	spec, opts, _ := strings.Cut(s, ";")
+	var p Part
+	if name, file, ok := strings.Cut(spec, "=@"); ok && !strings.Contains(name, "@") {
+		p = Part{Name: name, File: file}
+	} else if name, value, ok := strings.Cut(spec, "="); ok && !strings.Contains(name, "@") {
+		p = Part{Name: name, Value: value}
+	} else if name, file, ok := strings.Cut(spec, "@"); ok {
+		p = Part{Name: name, File: file}
+	} else {
+		return Part{}, fmt.Errorf("invalid part %q (want name@file or name=value)", s)
+	}
+	if p.Name == "" {
+		return Part{}, fmt.Errorf("part %q has no name", s)
+	}
+	if opts != "" {
+		for _, o := range strings.Split(opts, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(o), "=")
+			switch k {
+			case "type":
+				p.ContentType = v
+			case "filename":
+				p.Filename = v
+			default:
+				return Part{}, fmt.Errorf("part %q: unknown option %q (want type or filename)", s, k)
+			}
+		}
+	}
+	return p, nil
+}
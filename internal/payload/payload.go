@@ -0,0 +1,313 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Spec collects the body options of a request. At most one of JSON, File
+// and the field items/parts may be used.
+type Spec struct {
+	JSON        string   // inline JSON, @file or - / @- for stdin
+	File        string   // raw body from a file, - for stdin
+	ContentType string   // overrides the inferred Content-Type
+	Form        bool     // url-encode fields instead of sending a JSON object
+	Parts       []string // -F multipart parts
+	Items       []Item
+}
+
+// Body is a built request body together with what the items add to the
+// rest of the request.
+type Body struct {
+	Data        []byte
+	ContentType string     // empty when unknown, e.g. raw stdin
+	Query       url.Values // from name==value items
+	Headers     []string   // "Name: value" from Name:value items
+}
+
+// Part is one multipart form part: a text Value or the contents of File.
+type Part struct {
+	Name        string `yaml:"name"`
+	Value       string `yaml:"value,omitempty"`
+	File        string `yaml:"file,omitempty"`         // "-" reads stdin
+	ContentType string `yaml:"content_type,omitempty"` // default inferred from the file extension
+	Filename    string `yaml:"filename,omitempty"`     // default the file's base name
+}
+
+// Build assembles the body described by s. stdin is read for "-".
+func Build(s Spec, stdin io.Reader) (*Body, error) {
+	b := &Body{Query: url.Values{}}
+	var fields []Item
+	multi := len(s.Parts) > 0
+	for _, it := range s.Items {
+		switch it.Kind {
+		case Query:
+			b.Query.Add(it.Name, it.Value)
+		case Header:
+			b.Headers = append(b.Headers, it.Name+": "+it.Value)
+		default:
+			fields = append(fields, it)
+			multi = multi || it.Kind == FilePart
+		}
+	}
+
+	sources := 0
+	for _, used := range []bool{s.JSON != "", s.File != "", len(fields)+len(s.Parts) > 0} {
+		if used {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, fmt.Errorf("use only one of --json, --file and body fields")
+	}
+
+	var err error
+	switch {
+	case s.File != "":
+		b.Data, err = readSource(s.File, stdin)
+		if s.File != "-" {
+			b.ContentType = ContentTypeFor(s.File)
+		}
+	case s.JSON != "":
+		src := s.JSON
+		if src == "-" || strings.HasPrefix(src, "@") {
+			b.Data, err = readSource(strings.TrimPrefix(src, "@"), stdin)
+		} else {
+			b.Data = []byte(src)
+		}
+		b.ContentType = "application/json"
+	case multi:
+		// Files can only travel in a multipart form; parts keep the order
+		// they were given in, -F parts last
+		parts := make([]Part, 0, len(fields)+len(s.Parts))
+		for _, f := range fields {
+			p, err := fieldPart(f, stdin)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, p)
+		}
+		for _, spec := range s.Parts {
+			p, err := ParsePart(spec)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, p)
+		}
+		b.Data, b.ContentType, err = Multipart(parts, stdin)
+	case len(fields) > 0 && s.Form:
+		// Fields keep the order they were given in
+		pairs := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if f.Kind == RawJSON || f.Kind == JSONFile {
+				return nil, fmt.Errorf("%s%s%s: raw JSON values cannot be form-encoded", f.Name, f.Kind, f.Value)
+			}
+			v, err := fieldValue(f, stdin)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, url.QueryEscape(f.Name)+"="+url.QueryEscape(v))
+		}
+		b.Data, b.ContentType = []byte(strings.Join(pairs, "&")), "application/x-www-form-urlencoded"
+	case len(fields) > 0:
+		b.Data, err = jsonObject(fields, stdin)
+		b.ContentType = "application/json"
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.ContentType != "" {
+		b.ContentType = s.ContentType
+	}
+	return b, nil
+}
+
+// jsonObject builds an object from the fields in the order given; a
+// repeated name keeps its first position and its last value.
+func jsonObject(fields []Item, stdin io.Reader) ([]byte, error) {
+	var order []string
+	values := map[string]json.RawMessage{}
+	for _, f := range fields {
+		var raw []byte
+		switch f.Kind {
+		case RawJSON, JSONFile:
+			raw = []byte(f.Value)
+			if f.Kind == JSONFile {
+				var err error
+				if raw, err = readSource(f.Value, stdin); err != nil {
+					return nil, err
+				}
+			}
+			if !json.Valid(raw) {
+				return nil, fmt.Errorf("%s%s%s: invalid JSON", f.Name, f.Kind, f.Value)
+			}
+		default:
+			v, err := fieldValue(f, stdin)
+			if err != nil {
+				return nil, err
+			}
+			raw, _ = json.Marshal(v)
+		}
+		if _, ok := values[f.Name]; !ok {
+			order = append(order, f.Name)
+		}
+		values[f.Name] = bytes.TrimSpace(raw)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range order {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(values[name])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// fieldValue returns the string value of a = or =@ field.
+func fieldValue(f Item, stdin io.Reader) (string, error) {
+	if f.Kind != FieldFile {
+		return f.Value, nil
+	}
+	b, err := readSource(f.Value, stdin)
+	return string(b), err
+}
+
+// fieldPart turns an item into a part of a multipart form.
+func fieldPart(f Item, stdin io.Reader) (Part, error) {
+	switch f.Kind {
+	case FilePart:
+		return Part{Name: f.Name, File: f.Value}, nil
+	case RawJSON, JSONFile:
+		data := []byte(f.Value)
+		if f.Kind == JSONFile {
+			var err error
+			if data, err = readSource(f.Value, stdin); err != nil {
+				return Part{}, err
+			}
+		}
+		if !json.Valid(data) {
+			return Part{}, fmt.Errorf("%s%s%s: invalid JSON", f.Name, f.Kind, f.Value)
+		}
+		return Part{Name: f.Name, Value: string(data), ContentType: "application/json"}, nil
+	}
+	v, err := fieldValue(f, stdin)
+	return Part{Name: f.Name, Value: v}, err
+}
+
+// Form url-encodes fields, sorted by name.
+func Form(fields map[string]string) ([]byte, string) {
+	values := url.Values{}
+	for k, v := range fields {
+		values.Set(k, v)
+	}
+	return []byte(values.Encode()), "application/x-www-form-urlencoded"
+}
+
+// Multipart encodes parts as multipart/form-data and returns the body and
+// its Content-Type with the boundary.
+func Multipart(parts []Part, stdin io.Reader) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		if p.Name == "" {
+			return nil, "", fmt.Errorf("multipart part needs a name")
+		}
+		h := textproto.MIMEHeader{}
+		var data []byte
+		if p.File != "" {
+			var err error
+			if data, err = readSource(p.File, stdin); err != nil {
+				return nil, "", err
+			}
+			filename := p.Filename
+			if filename == "" && p.File != "-" {
+				filename = filepath.Base(p.File)
+			}
+			if filename == "" {
+				filename = "stdin"
+			}
+			ct := p.ContentType
+			if ct == "" {
+				ct = ContentTypeFor(p.File)
+			}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(p.Name), escapeQuotes(filename)))
+			h.Set("Content-Type", ct)
+		} else {
+			data = []byte(p.Value)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(p.Name)))
+			if p.ContentType != "" {
+				h.Set("Content-Type", p.ContentType)
+			}
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := pw.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string { return quoteEscaper.Replace(s) }
+
+// commonTypes are checked before mime.TypeByExtension, whose answers for
+// these depend on the system's mime.types file.
+var commonTypes = map[string]string{
+	".csv":    "text/csv",
+	".txt":    "text/plain; charset=utf-8",
+	".md":     "text/markdown",
+	".yaml":   "application/yaml",
+	".yml":    "application/yaml",
+	".ndjson": "application/x-ndjson",
+	".zip":    "application/zip",
+	".gz":     "application/gzip",
+	".tar":    "application/x-tar",
+	".mp4":    "video/mp4",
+	".mp3":    "audio/mpeg",
+}
+
+// ContentTypeFor infers a Content-Type from path's extension, falling back
+// to application/octet-stream.
+func ContentTypeFor(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if t, ok := commonTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// readSource reads a file, or stdin for "-". stdin can only be read once.
+func readSource(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		if stdin == nil {
+			return nil, fmt.Errorf("no stdin to read the body from")
+		}
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
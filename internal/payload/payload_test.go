@@ -0,0 +1,183 @@
+package payload
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseItem(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Item
+	}{
+		{"name=Ada", Item{Field, "name", "Ada"}},
+		{"age:=30", Item{RawJSON, "age", "30"}},
+		{"bio=@about.txt", Item{FieldFile, "bio", "about.txt"}},
+		{"tags:=@tags.json", Item{JSONFile, "tags", "tags.json"}},
+		{"avatar@me.png", Item{FilePart, "avatar", "me.png"}},
+		{"page==2", Item{Query, "page", "2"}},
+		{"X-Trace:abc", Item{Header, "X-Trace", "abc"}},
+		{"email=ada@example.com", Item{Field, "email", "ada@example.com"}},
+		{"url=http://x/?a=b", Item{Field, "url", "http://x/?a=b"}},
+		{`a\=b=c`, Item{Field, "a=b", "c"}},
+		{"empty=", Item{Field, "empty", ""}},
+	}
+	for _, tt := range tests {
+		got, err := ParseItem(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseItem(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"plain", "=value"} {
+		if _, err := ParseItem(bad); err == nil {
+			t.Errorf("ParseItem(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParsePart(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Part
+	}{
+		{"avatar@me.png", Part{Name: "avatar", File: "me.png"}},
+		{"avatar=@me.png", Part{Name: "avatar", File: "me.png"}},
+		{"note=hi", Part{Name: "note", Value: "hi"}},
+		{"doc@r.bin;type=application/pdf;filename=report.pdf", Part{Name: "doc", File: "r.bin", ContentType: "application/pdf", Filename: "report.pdf"}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePart(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePart(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParsePart("doc@x;size=1"); err == nil {
+		t.Error("unknown part option should fail")
+	}
+}
+
+func build(t *testing.T, s Spec, items ...string) *Body {
+	t.Helper()
+	parsed, err := ParseItems(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Items = parsed
+	b, err := Build(s, strings.NewReader("from stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBuild_JSON(t *testing.T) {
+	dir := t.TempDir()
+	tags := filepath.Join(dir, "tags.json")
+	os.WriteFile(tags, []byte(`["a", "b"]`+"\n"), 0o644)
+
+	b := build(t, Spec{}, "name=Ada", "age:=30", "admin:=true", "tags:=@"+tags, "page==2", "X-Trace:abc", "name=Grace")
+	want := `{"name":"Grace","age":30,"admin":true,"tags":["a", "b"]}`
+	if string(b.Data) != want || b.ContentType != "application/json" {
+		t.Errorf("body = %s (%s), want %s", b.Data, b.ContentType, want)
+	}
+	if b.Query.Get("page") != "2" || len(b.Headers) != 1 || b.Headers[0] != "X-Trace: abc" {
+		t.Errorf("query %v, headers %v", b.Query, b.Headers)
+	}
+
+	s := Spec{Items: []Item{{RawJSON, "n", "{oops"}}}
+	if _, err := Build(s, nil); err == nil {
+		t.Error("invalid raw JSON should fail")
+	}
+}
+
+func TestBuild_Form(t *testing.T) {
+	b := build(t, Spec{Form: true}, "grant_type=password", "user=ada lovelace", "note=@-")
+	if string(b.Data) != "grant_type=password&user=ada+lovelace&note=from+stdin" || b.ContentType != "application/x-www-form-urlencoded" {
+		t.Errorf("body = %s (%s)", b.Data, b.ContentType)
+	}
+	if _, err := Build(Spec{Form: true, Items: []Item{{RawJSON, "n", "1"}}}, nil); err == nil {
+		t.Error("raw JSON in a url-encoded form should fail")
+	}
+}
+
+func TestBuild_Multipart(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "me.png")
+	os.WriteFile(img, []byte("\x89PNG"), 0o644)
+
+	b := build(t, Spec{Parts: []string{"doc@-;type=text/plain;filename=notes.txt"}}, "caption=hello", "meta:={\"v\":1}", "avatar@"+img)
+	mt, params, err := mime.ParseMediaType(b.ContentType)
+	if err != nil || mt != "multipart/form-data" {
+		t.Fatalf("Content-Type = %q", b.ContentType)
+	}
+	r := multipart.NewReader(bytes.NewReader(b.Data), params["boundary"])
+	type part struct{ name, filename, ctype, data string }
+	var got []part
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(p)
+		got = append(got, part{p.FormName(), p.FileName(), p.Header.Get("Content-Type"), string(data)})
+	}
+	want := []part{
+		{"caption", "", "", "hello"},
+		{"meta", "", "application/json", `{"v":1}`},
+		{"avatar", "me.png", "image/png", "\x89PNG"},
+		{"doc", "notes.txt", "text/plain", "from stdin"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parts = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("part %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBuild_FileAndStdin(t *testing.T) {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "users.csv")
+	os.WriteFile(csv, []byte("id\n1\n"), 0o644)
+
+	if b := build(t, Spec{File: csv}); string(b.Data) != "id\n1\n" || b.ContentType != "text/csv" {
+		t.Errorf("--file = %q (%s)", b.Data, b.ContentType)
+	}
+	if b := build(t, Spec{File: "-"}); string(b.Data) != "from stdin" || b.ContentType != "" {
+		t.Errorf("--file - = %q (%s)", b.Data, b.ContentType)
+	}
+	if b := build(t, Spec{JSON: "-"}); string(b.Data) != "from stdin" || b.ContentType != "application/json" {
+		t.Errorf("--json - = %q (%s)", b.Data, b.ContentType)
+	}
+	if b := build(t, Spec{File: csv, ContentType: "text/plain"}); b.ContentType != "text/plain" {
+		t.Errorf("--content-type should win, got %s", b.ContentType)
+	}
+
+	if _, err := Build(Spec{JSON: "{}", Items: []Item{{Field, "a", "b"}}}, nil); err == nil {
+		t.Error("--json with fields should fail")
+	}
+}
+
+func TestContentTypeFor(t *testing.T) {
+	for path, want := range map[string]string{
+		"a.json":    "application/json",
+		"b.PNG":     "image/png",
+		"c.yaml":    "application/yaml",
+		"d.unknown": "application/octet-stream",
+	} {
+		if got := ContentTypeFor(path); got != want {
+			t.Errorf("ContentTypeFor(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package ratelimit paces outgoing requests with a token bucket.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate is N requests per Per. The zero Rate means unlimited.
+type Rate struct {
+	N   int
+	Per time.Duration
+}
+
+// ParseRate parses "20/s", "100/m", "5/500ms" or "1/h". A bare number is per
+// second.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Rate{}, nil
+	}
+	num, unit, found := strings.Cut(s, "/")
+	n, err := strconv.Atoi(strings.TrimSpace(num))
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: want requests/unit, e.g. 20/s", s)
+	}
+	per := time.Second
+	if found {
+		switch unit = strings.TrimSpace(unit); unit {
+		case "s", "sec", "second":
+			per = time.Second
+		case "m", "min", "minute":
+			per = time.Minute
+		case "h", "hour":
+			per = time.Hour
+		default:
+			per, err = time.ParseDuration(unit)
+			if err != nil || per <= 0 {
+				return Rate{}, fmt.Errorf("invalid rate %q: unknown unit %q (use s, m, h or a duration)", s, unit)
+			}
+		}
+	}
+	return Rate{N: n, Per: per}, nil
+}
+
+// IsZero reports whether the rate is unlimited.
+func (r Rate) IsZero() bool { return r.N <= 0 || r.Per <= 0 }
+
+// Interval is the time between two requests at this rate.
+func (r Rate) Interval() time.Duration {
+	if r.IsZero() {
+		return 0
+	}
+	return r.Per / time.Duration(r.N)
+}
+
+func (r Rate) String() string {
+	if r.IsZero() {
+		return "unlimited"
+	}
+	switch r.Per {
+	case time.Second:
+		return fmt.Sprintf("%d/s", r.N)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", r.N)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", r.N)
+	}
+	return fmt.Sprintf("%d/%s", r.N, r.Per)
+}
+
+// Limiter is a token bucket that refills at Rate and holds up to burst
+// tokens. It is safe for concurrent use.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewLimiter returns a limiter for r that starts full. A burst below 1 is
+// treated as 1, which spaces requests evenly. A zero rate never waits.
+func NewLimiter(r Rate, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{interval: r.Interval(), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until a token is available or ctx ends, and returns how long
+// it waited.
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	d := l.reserve(time.Now())
+	if d <= 0 {
+		return 0, nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return d, nil
+	case <-ctx.Done():
+		l.cancel()
+		return 0, ctx.Err()
+	}
+}
+
+// reserve takes a token, going into debt if none is left, and returns how
+// long the caller must wait for it.
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	if l.interval <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+// cancel returns a reserved token that was not used.
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
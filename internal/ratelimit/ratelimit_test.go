@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{"20/s", Rate{20, time.Second}, false},
+		{"600/m", Rate{600, time.Minute}, false},
+		{"1/h", Rate{1, time.Hour}, false},
+		{"5/500ms", Rate{5, 500 * time.Millisecond}, false},
+		{"10", Rate{10, time.Second}, false},
+		{"", Rate{}, false},
+		{"0/s", Rate{}, true},
+		{"fast", Rate{}, true},
+		{"5/fortnight", Rate{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseRate(%q) = %v, %v; want %v (err %v)", tt.in, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if got := (Rate{20, time.Second}).Interval(); got != 50*time.Millisecond {
+		t.Errorf("Interval() = %v", got)
+	}
+	if got := (Rate{600, time.Minute}).String(); got != "600/m" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	start := time.Now()
+	l := NewLimiter(Rate{10, time.Second}, 2)
+	l.last = start
+
+	// the burst is available at once, then one token every 100ms
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, w := range want {
+		if got := l.reserve(start); got != w {
+			t.Errorf("reserve #%d = %v, want %v", i+1, got, w)
+		}
+	}
+
+	// after a long pause the bucket refills only up to the burst
+	later := start.Add(10 * time.Second)
+	for i, w := range []time.Duration{0, 0, 100 * time.Millisecond} {
+		if got := l.reserve(later); got != w {
+			t.Errorf("after pause, reserve #%d = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l := NewLimiter(Rate{50, time.Second}, 1) // one every 20ms
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("4 requests at 50/s took %v, want >= 60ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = NewLimiter(Rate{1, time.Hour}, 1)
+	l.Wait(ctx)
+	if _, err := l.Wait(ctx); err == nil {
+		t.Error("expected context error while waiting")
+	}
+
+	if d, _ := NewLimiter(Rate{}, 0).Wait(context.Background()); d != 0 {
+		t.Errorf("unlimited limiter waited %v", d)
+	}
+}
@@ -0,0 +1,276 @@
+package retry
+
+import (
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for the backoff schedule.
+const (
+	DefaultBase    = 1 * time.Second
+	DefaultMax     = 30 * time.Second
+	DefaultMaxWait = 2 * time.Minute
+)
+
+// Jitter strategies for spreading out retries.
+const (
+	JitterNone         = "none"         // base * 2^n
+	JitterFull         = "full"         // random in [0, base * 2^n)
+	JitterEqual        = "equal"        // half fixed, half random
+	JitterDecorrelated = "decorrelated" // random in [base, previous * 3)
+)
+
+// Options are the backoff settings given on the command line or in a
+// workflow. Zero values mean the defaults.
+type Options struct {
+	Base           time.Duration // first backoff
+	Max            time.Duration // cap for a single computed backoff
+	MaxWait        time.Duration // cap for a wait asked for by the server (Retry-After, RateLimit-Reset)
+	Jitter         string        // none, full (default), equal or decorrelated
+	Budget         time.Duration // total time allowed across all attempts and waits
+	IdempotencyKey string        // "auto" generates a key; any other value is sent as-is
+}
+
+// Attempt is the outcome of one try of a request.
+type Attempt struct {
+	Number   int // 1-based
+	Status   int // 0 when the request failed
+	Err      error
+	Duration time.Duration
+	Wait     time.Duration // delay before the next attempt, 0 on the last one
+	Reason   string        // why it waited ("backoff", "Retry-After", ...) or why it stopped retrying
+}
+
+// Outcome describes a finished attempt for Decide.
+type Outcome struct {
+	Attempt  int
+	Method   string
+	Keyed    bool           // the request carries an Idempotency-Key
+	Response *http.Response // nil when the request failed
+	Err      error
+	Elapsed  time.Duration // since the first attempt started
+}
+
+// NewPolicy builds a policy from the retry count, the --retry-on conditions
+// and the backoff options.
+func NewPolicy(maxRetries int, conditions string, o Options) (*Policy, error) {
+	conds, err := ParseConditions(conditions)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ParseJitter(o.Jitter); err != nil {
+		return nil, err
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &Policy{MaxRetries: maxRetries, Conditions: conds, Options: o}, nil
+}
+
+// ParseJitter validates a jitter strategy name; empty means full.
+func ParseJitter(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "":
+		return JitterFull, nil
+	case JitterNone, JitterFull, JitterEqual, JitterDecorrelated:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown jitter %q (use none, full, equal or decorrelated)", s)
+}
+
+// Idempotent reports whether method can be repeated safely (RFC 9110).
+func Idempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// Decide reports whether to retry after o and how long to wait first.
+// reason names the source of the wait, or explains why a retryable failure
+// is not retried. Retries stop early when the wait would run past the
+// budget or deadline (zero for none).
+func (p *Policy) Decide(o Outcome, deadline time.Time) (wait time.Duration, reason string, retry bool) {
+	if o.Attempt > p.MaxRetries {
+		return 0, "", false
+	}
+	status := 0
+	if o.Response != nil {
+		status = o.Response.StatusCode
+	}
+	if !p.ShouldRetry(status, o.Err) {
+		return 0, "", false
+	}
+	if !Idempotent(o.Method) && !o.Keyed {
+		return 0, fmt.Sprintf("%s is not idempotent; send an Idempotency-Key to retry it", strings.ToUpper(o.Method)), false
+	}
+
+	wait, reason = p.backoff(o.Attempt), "backoff"
+	if o.Response != nil {
+		if d, source, ok := ServerDelay(o.Response, time.Now()); ok {
+			wait, reason = d, source
+			if max := p.maxWait(); d > max {
+				wait, reason = max, fmt.Sprintf("%s of %s capped at %s", source, d, max)
+			}
+		}
+	}
+
+	if p.Budget > 0 && o.Elapsed+wait > p.Budget {
+		return 0, fmt.Sprintf("retry budget of %s exhausted", p.Budget), false
+	}
+	if !deadline.IsZero() && time.Now().Add(wait).After(deadline) {
+		return 0, "waiting would pass the request deadline", false
+	}
+	return wait, reason, true
+}
+
+func (p *Policy) maxWait() time.Duration {
+	if p.MaxWait > 0 {
+		return p.MaxWait
+	}
+	return DefaultMaxWait
+}
+
+// backoff computes the wait before retry n (1-based).
+func (p *Policy) backoff(n int) time.Duration {
+	base, max := p.Base, p.Max
+	if base <= 0 {
+		base = DefaultBase
+	}
+	if max <= 0 {
+		max = DefaultMax
+	}
+	if max < base {
+		max = base
+	}
+
+	exp := base
+	for i := 1; i < n && exp < max; i++ {
+		exp *= 2
+	}
+	if exp > max {
+		exp = max
+	}
+
+	jitter, _ := ParseJitter(p.Jitter)
+	var d time.Duration
+	switch jitter {
+	case JitterNone:
+		d = exp
+	case JitterEqual:
+		d = exp/2 + randDuration(exp/2)
+	case JitterDecorrelated:
+		prev := p.prev
+		if prev < base {
+			prev = base
+		}
+		d = base + randDuration(prev*3-base)
+		if d > max {
+			d = max
+		}
+	default:
+		d = randDuration(exp)
+	}
+	p.prev = d
+	return d
+}
+
+func randDuration(n time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(mathrand.Int63n(int64(n)))
+}
+
+// ServerDelay returns the wait a response asks for: Retry-After, or for
+// rate-limited responses the RateLimit / X-RateLimit reset time.
+func ServerDelay(res *http.Response, now time.Time) (time.Duration, string, bool) {
+	h := res.Header
+	if v := h.Get("Retry-After"); v != "" {
+		if d, ok := parseRetryAfter(v, now); ok {
+			return d, "Retry-After", true
+		}
+	}
+
+	limited := res.StatusCode == http.StatusTooManyRequests
+	for _, name := range []string{"RateLimit-Remaining", "X-RateLimit-Remaining", "X-Rate-Limit-Remaining"} {
+		if strings.TrimSpace(h.Get(name)) == "0" {
+			limited = true
+		}
+	}
+	if !limited {
+		return 0, "", false
+	}
+
+	// IETF draft structured header: RateLimit: limit=100, remaining=0, reset=30
+	if v := h.Get("RateLimit"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			if k, val, ok := strings.Cut(strings.TrimSpace(part), "="); ok && strings.TrimSpace(k) == "reset" {
+				if d, ok := parseReset(val, now); ok {
+					return d, "RateLimit reset", true
+				}
+			}
+		}
+	}
+	for _, name := range []string{"RateLimit-Reset", "X-RateLimit-Reset", "X-Rate-Limit-Reset"} {
+		if v := h.Get(name); v != "" {
+			if d, ok := parseReset(v, now); ok {
+				return d, name, true
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP-date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// parseReset accepts seconds until reset or, for large values, a Unix
+// timestamp (as GitHub and others send).
+func parseReset(v string, now time.Time) (time.Duration, bool) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	if n > 1e9 {
+		d := time.Unix(int64(n), 0).Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return time.Duration(n * float64(time.Second)), true
+}
+
+// NewIdempotencyKey returns a random UUIDv4 for the Idempotency-Key header.
+func NewIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,169 @@
+package retry
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Caps(t *testing.T) {
+	p := &Policy{Options: Options{Base: 100 * time.Millisecond, Max: time.Second, Jitter: JitterNone}}
+
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+}
+
+func TestBackoff_JitterBounds(t *testing.T) {
+	base, max := 100*time.Millisecond, 2*time.Second
+
+	tests := []struct {
+		jitter   string
+		min, max time.Duration // for attempt 3, where base * 2^n = 400ms
+	}{
+		{JitterFull, 0, 400 * time.Millisecond},
+		{JitterEqual, 200 * time.Millisecond, 400 * time.Millisecond},
+		{JitterDecorrelated, base, max},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.jitter, func(t *testing.T) {
+			p := &Policy{Options: Options{Base: base, Max: max, Jitter: tt.jitter}}
+			for i := 0; i < 200; i++ {
+				if got := p.backoff(3); got < tt.min || got > tt.max {
+					t.Fatalf("backoff(3) = %v, want within [%v, %v]", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJitter(t *testing.T) {
+	if j, err := ParseJitter(""); err != nil || j != JitterFull {
+		t.Errorf(`ParseJitter("") = %q, %v`, j, err)
+	}
+	if j, err := ParseJitter("Equal"); err != nil || j != JitterEqual {
+		t.Errorf(`ParseJitter("Equal") = %q, %v`, j, err)
+	}
+	if _, err := ParseJitter("gaussian"); err == nil {
+		t.Error("expected error for unknown jitter")
+	}
+}
+
+func TestServerDelay(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		status     int
+		headers    map[string]string
+		want       time.Duration
+		wantSource string
+		wantOK     bool
+	}{
+		{"retry-after seconds", 503, map[string]string{"Retry-After": "7"}, 7 * time.Second, "Retry-After", true},
+		{"retry-after date", 503, map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, 90 * time.Second, "Retry-After", true},
+		{"retry-after in the past", 503, map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0, "Retry-After", true},
+		{"retry-after garbage", 503, map[string]string{"Retry-After": "soon"}, 0, "", false},
+		{"x-ratelimit-reset epoch", 429, map[string]string{"X-RateLimit-Reset": "1767323105"}, 60 * time.Second, "X-RateLimit-Reset", true},
+		{"ratelimit-reset seconds", 429, map[string]string{"RateLimit-Reset": "12"}, 12 * time.Second, "RateLimit-Reset", true},
+		{"structured ratelimit", 429, map[string]string{"RateLimit": "limit=100, remaining=0, reset=30"}, 30 * time.Second, "RateLimit reset", true},
+		{"remaining zero", 503, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "5"}, 5 * time.Second, "X-RateLimit-Reset", true},
+		{"reset without limiting", 503, map[string]string{"X-RateLimit-Remaining": "10", "X-RateLimit-Reset": "5"}, 0, "", false},
+		{"no hints", 503, nil, 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.headers {
+				res.Header.Set(k, v)
+			}
+			got, source, ok := ServerDelay(res, now)
+			if got != tt.want || source != tt.wantSource || ok != tt.wantOK {
+				t.Errorf("ServerDelay() = %v, %q, %v; want %v, %q, %v", got, source, ok, tt.want, tt.wantSource, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	p, err := NewPolicy(3, "", Options{Base: time.Millisecond, Jitter: JitterNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unavailable := &http.Response{StatusCode: 503, Header: http.Header{}}
+
+	tests := []struct {
+		name       string
+		outcome    Outcome
+		wantRetry  bool
+		wantReason string
+	}{
+		{"GET 503", Outcome{Attempt: 1, Method: "GET", Response: unavailable}, true, "backoff"},
+		{"GET network error", Outcome{Attempt: 1, Method: "GET", Err: errors.New("refused")}, true, "backoff"},
+		{"GET 200", Outcome{Attempt: 1, Method: "GET", Response: &http.Response{StatusCode: 200}}, false, ""},
+		{"retries used up", Outcome{Attempt: 4, Method: "GET", Response: unavailable}, false, ""},
+		{"POST without key", Outcome{Attempt: 1, Method: "POST", Response: unavailable}, false, "not idempotent"},
+		{"POST with key", Outcome{Attempt: 1, Method: "POST", Keyed: true, Response: unavailable}, true, "backoff"},
+		{"PUT is idempotent", Outcome{Attempt: 1, Method: "PUT", Response: unavailable}, true, "backoff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, reason, again := p.Decide(tt.outcome, time.Time{})
+			if again != tt.wantRetry || !strings.Contains(reason, tt.wantReason) {
+				t.Errorf("Decide() = %q, %v; want %q, %v", reason, again, tt.wantReason, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestDecide_BudgetAndDeadline(t *testing.T) {
+	res := &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": {"5"}}}
+
+	p, _ := NewPolicy(3, "", Options{Budget: 2 * time.Second})
+	_, reason, again := p.Decide(Outcome{Attempt: 1, Method: "GET", Response: res}, time.Time{})
+	if again || !strings.Contains(reason, "budget") {
+		t.Errorf("budget: Decide() = %q, %v", reason, again)
+	}
+
+	p, _ = NewPolicy(3, "", Options{})
+	_, reason, again = p.Decide(Outcome{Attempt: 1, Method: "GET", Response: res}, time.Now().Add(time.Second))
+	if again || !strings.Contains(reason, "deadline") {
+		t.Errorf("deadline: Decide() = %q, %v", reason, again)
+	}
+
+	wait, reason, again := p.Decide(Outcome{Attempt: 1, Method: "GET", Response: res}, time.Time{})
+	if !again || wait != 5*time.Second || reason != "Retry-After" {
+		t.Errorf("Retry-After: Decide() = %v, %q, %v", wait, reason, again)
+	}
+
+	day := &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": {"86400"}}}
+	wait, reason, again = p.Decide(Outcome{Attempt: 1, Method: "GET", Response: day}, time.Time{})
+	if !again || wait != DefaultMaxWait || reason != "Retry-After of 24h0m0s capped at 2m0s" {
+		t.Errorf("default cap: Decide() = %v, %q, %v", wait, reason, again)
+	}
+	p, _ = NewPolicy(3, "", Options{MaxWait: 3 * time.Second})
+	wait, _, again = p.Decide(Outcome{Attempt: 1, Method: "GET", Response: res}, time.Time{})
+	if !again || wait != 3*time.Second {
+		t.Errorf("MaxWait: Decide() = %v, %v", wait, again)
+	}
+}
+
+func TestNewIdempotencyKey(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := NewIdempotencyKey(), NewIdempotencyKey()
+	if !uuid.MatchString(a) {
+		t.Errorf("NewIdempotencyKey() = %q, not a UUIDv4", a)
+	}
+	if a == b {
+		t.Error("keys should differ")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Condition represents a retry condition
@@ -16,6 +17,9 @@ type Condition struct {
 type Policy struct {
 	MaxRetries int
 	Conditions []Condition
+	Options
+
+	prev time.Duration // last wait, for decorrelated jitter
 }
 
 // ShouldRetry determines if a request should be retried based on conditions
@@ -0,0 +1,133 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLine bounds a single SSE or NDJSON line.
+const maxLine = 4 << 20
+
+// SSEReader parses a text/event-stream body as described by the HTML
+// Server-Sent Events specification.
+type SSEReader struct {
+	sc          *bufio.Scanner
+	first       bool
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewSSEReader returns a reader that yields events from r as they arrive.
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{sc: newLineScanner(r), first: true}
+}
+
+// LastEventID returns the most recent id: field seen, even on events that
+// were never dispatched.
+func (s *SSEReader) LastEventID() string { return s.lastEventID }
+
+// Retry returns the reconnection delay last requested by the server, or 0.
+func (s *SSEReader) Retry() time.Duration { return s.retry }
+
+// Next blocks until the next event is dispatched. It returns io.EOF when the
+// stream ends; a trailing event without its blank line is discarded.
+func (s *SSEReader) Next() (Event, error) {
+	var (
+		data    strings.Builder
+		hasData bool
+		typ     string
+		retry   time.Duration
+	)
+	for s.sc.Scan() {
+		line := s.sc.Text()
+		if s.first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			s.first = false
+		}
+
+		if line == "" {
+			if !hasData {
+				typ = ""
+				continue
+			}
+			if typ == "" {
+				typ = "message"
+			}
+			return Event{
+				Format: SSE,
+				ID:     s.lastEventID,
+				Event:  typ,
+				Data:   strings.TrimSuffix(data.String(), "\n"),
+				Retry:  retry,
+			}, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment / keep-alive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			typ = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				retry = time.Duration(ms) * time.Millisecond
+				s.retry = retry
+			}
+		}
+	}
+	if err := s.sc.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// newLineScanner splits on \n, \r\n and a lone \r without waiting for more
+// input than the current line needs.
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLine)
+	skipLF := false
+	sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		start := 0
+		if skipLF && len(data) > 0 {
+			// The previous line ended in \r; swallow the \n of a \r\n pair
+			skipLF = false
+			if data[0] == '\n' {
+				start = 1
+			}
+		}
+		if i := bytes.IndexAny(data[start:], "\r\n"); i >= 0 {
+			i += start
+			advance := i + 1
+			if data[i] == '\r' {
+				if i+1 < len(data) {
+					if data[i+1] == '\n' {
+						advance++
+					}
+				} else {
+					skipLF = true
+				}
+			}
+			return advance, data[start:i], nil
+		}
+		if atEOF && len(data) > start {
+			return len(data), data[start:], nil
+		}
+		return start, nil, nil
+	})
+	return sc
+}
@@ -0,0 +1,244 @@
+// Package stream reads Server-Sent Events, NDJSON and other chunked
+// responses incrementally, so events can be printed as they arrive instead
+// of after the server closes the connection.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/humancto/mozzy/internal/jq"
+)
+
+// Format is the framing of a streamed body.
+type Format int
+
+const (
+	// Lines treats every line of the body as one event.
+	Lines Format = iota
+	// SSE is a text/event-stream body.
+	SSE
+	// NDJSON is newline-delimited JSON (one document per line).
+	NDJSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case SSE:
+		return "sse"
+	case NDJSON:
+		return "ndjson"
+	}
+	return "lines"
+}
+
+// Detect picks the framing from a Content-Type header.
+func Detect(contentType string) Format {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	switch mt {
+	case "text/event-stream":
+		return SSE
+	case "application/x-ndjson", "application/ndjson", "application/jsonl",
+		"application/x-jsonlines", "application/json-seq", "application/stream+json":
+		return NDJSON
+	}
+	return Lines
+}
+
+// Event is one unit of a stream: a dispatched SSE event, an NDJSON document
+// or a line of text.
+type Event struct {
+	Format Format
+	ID     string        // SSE last event ID
+	Event  string        // SSE event type ("message" by default)
+	Data   string        // payload; multi-line SSE data is joined with \n
+	Retry  time.Duration // SSE retry: field sent with this event, if any
+}
+
+// Value returns the payload decoded as JSON, or the raw string when it is
+// not JSON. This is the input --jq and --until see.
+func (e Event) Value() any {
+	data := strings.TrimPrefix(e.Data, "\x1e") // RFC 7464 record separator
+	if json.Valid([]byte(data)) {
+		if v, err := jq.Decode([]byte(data)); err == nil {
+			return v
+		}
+	}
+	return e.Data
+}
+
+// Options control when a stream stops and whether it resumes.
+type Options struct {
+	MaxEvents   int    // stop after this many events (0 = unlimited)
+	Until       string // jq expression; stop after the first event where it is truthy
+	Reconnect   bool   // reopen SSE streams that end, sending Last-Event-ID
+	LastEventID string // resume from this event ID on the first request
+}
+
+// DefaultRetry is the SSE reconnection delay used until the server sends a
+// retry: field.
+const DefaultRetry = 3 * time.Second
+
+// Opener sends the request. lastEventID is non-empty when resuming, and
+// attempt counts reconnections (0 for the first request).
+type Opener func(ctx context.Context, lastEventID string, attempt int) (*http.Response, error)
+
+// Result summarises a finished stream.
+type Result struct {
+	Events      int
+	LastEventID string
+	Status      int    // status code of the last response
+	Stopped     string // "max-events", "until", or "" when the stream ended
+}
+
+// Validate checks that the stopping conditions are usable.
+func (o Options) Validate() error {
+	if o.MaxEvents < 0 {
+		return fmt.Errorf("max events must not be negative")
+	}
+	if o.Until != "" {
+		if _, err := jq.Compile(o.Until); err != nil {
+			return fmt.Errorf("invalid until expression: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run opens the stream and calls fn for every event until the body ends, a
+// stopping condition is met or ctx is done. With Reconnect set, SSE streams
+// that end cleanly are reopened after the server's retry delay.
+func Run(ctx context.Context, o Options, open Opener, fn func(Event) error) (Result, error) {
+	res := Result{LastEventID: o.LastEventID}
+	if err := o.Validate(); err != nil {
+		return res, err
+	}
+	delay := DefaultRetry
+
+	for attempt := 0; ; attempt++ {
+		resp, err := open(ctx, res.LastEventID, attempt)
+		if err != nil {
+			if attempt == 0 || !o.Reconnect || ctx.Err() != nil {
+				return res, err
+			}
+		} else {
+			res.Status = resp.StatusCode
+			format := Detect(resp.Header.Get("Content-Type"))
+			r := &reader{opts: o, res: &res, fn: fn}
+			stop, readErr, err := r.consume(resp.Body, format)
+			resp.Body.Close()
+			if r.retry > 0 {
+				delay = r.retry
+			}
+			switch {
+			case err != nil:
+				return res, err
+			case stop != "":
+				res.Stopped = stop
+				return res, nil
+			case ctx.Err() != nil:
+				return res, ctx.Err()
+			}
+			// Per the SSE spec, only successful event streams are resumed
+			if !o.Reconnect || format != SSE || resp.StatusCode != http.StatusOK {
+				return res, readErr
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reader consumes one response body.
+type reader struct {
+	opts  Options
+	res   *Result
+	fn    func(Event) error
+	retry time.Duration // reconnection delay requested by the server
+}
+
+// consume reads events until the body ends or a stopping condition is met.
+// It returns the stopping condition, the error that ended the body early
+// (a dropped connection) and any error from the handler or --until, which
+// must not be retried.
+func (r *reader) consume(body io.Reader, format Format) (stop string, readErr, err error) {
+	var next func() (Event, error)
+	switch format {
+	case SSE:
+		sse := NewSSEReader(body)
+		next = func() (Event, error) {
+			ev, err := sse.Next()
+			r.res.LastEventID = sse.LastEventID()
+			r.retry = sse.Retry()
+			return ev, err
+		}
+	default:
+		sc := newLineScanner(body)
+		next = func() (Event, error) {
+			for sc.Scan() {
+				line := sc.Text()
+				if format == NDJSON && strings.TrimSpace(line) == "" {
+					continue
+				}
+				return Event{Format: format, Data: line}, nil
+			}
+			if err := sc.Err(); err != nil {
+				return Event{}, err
+			}
+			return Event{}, io.EOF
+		}
+	}
+
+	for {
+		ev, err := next()
+		if err == io.EOF {
+			return "", nil, nil
+		}
+		if err != nil {
+			return "", err, nil
+		}
+
+		r.res.Events++
+		if err := r.fn(ev); err != nil {
+			return "", nil, err
+		}
+		if r.opts.MaxEvents > 0 && r.res.Events >= r.opts.MaxEvents {
+			return "max-events", nil, nil
+		}
+		if r.opts.Until != "" {
+			done, err := truthy(r.opts.Until, ev.Value())
+			if err != nil {
+				return "", nil, fmt.Errorf("until expression failed: %w", err)
+			}
+			if done {
+				return "until", nil, nil
+			}
+		}
+	}
+}
+
+// truthy reports whether src yields any value other than false or null.
+func truthy(src string, input any) (bool, error) {
+	results, err := jq.Eval(src, input)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range results {
+		if r != nil && r != false {
+			return true, nil
+		}
+	}
+	return false, nil
+}
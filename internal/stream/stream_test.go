@@ -0,0 +1,157 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, body string) ([]Event, *SSEReader) {
+	t.Helper()
+	r := NewSSEReader(strings.NewReader(body))
+	var events []Event
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			return events, r
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	body := "\ufeff: keep-alive\n" +
+		"data: first\n\n" +
+		"event: update\r\nid: 7\r\ndata: {\"n\":1}\r\ndata:line2\r\n\r\n" +
+		"retry: 1500\rdata\r\r" +
+		"id: 8\n\n" + // id without data: not dispatched, but remembered
+		"data: trailing without blank line"
+
+	events, r := readAll(t, body)
+	want := []Event{
+		{Format: SSE, Event: "message", Data: "first"},
+		{Format: SSE, Event: "update", ID: "7", Data: "{\"n\":1}\nline2"},
+		{Format: SSE, Event: "message", ID: "7", Data: "", Retry: 1500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events =\n%+v\nwant\n%+v", events, want)
+	}
+	if r.LastEventID() != "8" || r.Retry() != 1500*time.Millisecond {
+		t.Errorf("LastEventID = %q, Retry = %v", r.LastEventID(), r.Retry())
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := map[string]Format{
+		"text/event-stream; charset=utf-8": SSE,
+		"application/x-ndjson":             NDJSON,
+		"application/jsonl":                NDJSON,
+		"text/plain":                       Lines,
+		"":                                 Lines,
+	}
+	for ct, want := range tests {
+		if got := Detect(ct); got != want {
+			t.Errorf("Detect(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func opener(srv *httptest.Server, seen *[]string) Opener {
+	return func(ctx context.Context, lastEventID string, attempt int) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		*seen = append(*seen, lastEventID)
+		return http.DefaultClient.Do(req)
+	}
+}
+
+func TestRun_NDJSONStopConditions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 1; i <= 5; i++ {
+			fmt.Fprintf(w, "{\"n\":%d,\"done\":%v}\n\n", i, i == 3)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		opts Options
+		want []string
+		stop string
+	}{
+		{Options{}, []string{"1", "2", "3", "4", "5"}, ""},
+		{Options{MaxEvents: 2}, []string{"1", "2"}, "max-events"},
+		{Options{Until: ".done"}, []string{"1", "2", "3"}, "until"},
+	}
+	for _, tt := range tests {
+		var got []string
+		var seen []string
+		res, err := Run(context.Background(), tt.opts, opener(srv, &seen), func(ev Event) error {
+			got = append(got, fmt.Sprint(ev.Value().(map[string]any)["n"]))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) || res.Stopped != tt.stop || res.Events != len(tt.want) {
+			t.Errorf("%+v: events %v, result %+v", tt.opts, got, res)
+		}
+	}
+}
+
+func TestRun_ReconnectsWithLastEventID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch r.Header.Get("Last-Event-ID") {
+		case "":
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: a\n\nid: 2\ndata: b\n\n")
+		case "2":
+			fmt.Fprint(w, "id: 3\ndata: c\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	var seen []string
+	var data []string
+	res, err := Run(context.Background(), Options{Reconnect: true, MaxEvents: 3}, opener(srv, &seen), func(ev Event) error {
+		data = append(data, ev.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, []string{"a", "b", "c"}) || res.LastEventID != "3" || res.Stopped != "max-events" {
+		t.Errorf("data = %v, result = %+v", data, res)
+	}
+	if !reflect.DeepEqual(seen, []string{"", "2"}) {
+		t.Errorf("Last-Event-ID sent = %q", seen)
+	}
+
+	// A 204 ends the stream instead of reconnecting
+	seen = nil
+	res, err = Run(context.Background(), Options{Reconnect: true, LastEventID: "3"}, opener(srv, &seen), func(Event) error { return nil })
+	if err != nil || res.Status != http.StatusNoContent || len(seen) != 1 {
+		t.Errorf("resume after 3: result %+v, err %v, requests %q", res, err, seen)
+	}
+}
+
+func TestRun_InvalidUntil(t *testing.T) {
+	_, err := Run(context.Background(), Options{Until: ".["}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "until") {
+		t.Errorf("expected until compile error, got %v", err)
+	}
+}
@@ -1,10 +1,14 @@
 package ui
 
 import (
+	"encoding/csv"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
 )
 
 // Table represents a simple table for CLI output
@@ -28,7 +32,9 @@ func (t *Table) AddRow(cells []string) {
 	t.Rows = append(t.Rows, cells)
 }
 
-// Render renders the table with box drawing characters
+// Render renders the table with box drawing characters. With Width set,
+// the widest columns are narrowed until the table fits and their cells
+// truncated.
 func (t *Table) Render() string {
 	if len(t.Headers) == 0 {
 		return ""
@@ -37,22 +43,29 @@ func (t *Table) Render() string {
 	// Calculate column widths
 	colWidths := make([]int, len(t.Headers))
 	for i, header := range t.Headers {
-		colWidths[i] = len(header)
+		colWidths[i] = lipgloss.Width(header)
 	}
 
 	for _, row := range t.Rows {
 		for i, cell := range row {
-			if i < len(colWidths) && len(cell) > colWidths[i] {
-				colWidths[i] = len(cell)
+			if i < len(colWidths) && lipgloss.Width(flatten(cell)) > colWidths[i] {
+				colWidths[i] = lipgloss.Width(flatten(cell))
 			}
 		}
 	}
+	if t.Width > 0 {
+		fitWidths(colWidths, t.Width-3*len(colWidths)-1)
+	}
 
 	// Add padding
 	for i := range colWidths {
 		colWidths[i] += 2 // Add 1 space padding on each side
 	}
 
+	// The cells are padded by hand so they line up with the borders
+	headerStyle := TableHeaderStyle.UnsetPadding()
+	rowStyle := TableRowStyle.UnsetPadding()
+
 	var sb strings.Builder
 
 	// Top border
@@ -68,8 +81,7 @@ func (t *Table) Render() string {
 	// Header row
 	sb.WriteString("│")
 	for i, header := range t.Headers {
-		paddedHeader := fmt.Sprintf(" %-*s", colWidths[i]-1, header)
-		sb.WriteString(TableHeaderStyle.Render(paddedHeader))
+		sb.WriteString(headerStyle.Render(pad(Truncate(header, colWidths[i]-2), colWidths[i])))
 		sb.WriteString("│")
 	}
 	sb.WriteString("\n")
@@ -87,17 +99,13 @@ func (t *Table) Render() string {
 	// Data rows
 	for _, row := range t.Rows {
 		sb.WriteString("│")
-		for i, cell := range row {
-			if i >= len(colWidths) {
-				break
+		for i := range colWidths {
+			cell := ""
+			if i < len(row) {
+				cell = flatten(row[i])
 			}
 			// Truncate if too long
-			displayCell := cell
-			if len(cell) > colWidths[i]-2 {
-				displayCell = cell[:colWidths[i]-5] + "..."
-			}
-			paddedCell := fmt.Sprintf(" %-*s", colWidths[i]-1, displayCell)
-			sb.WriteString(TableRowStyle.Render(paddedCell))
+			sb.WriteString(rowStyle.Render(pad(Truncate(cell, colWidths[i]-2), colWidths[i])))
 			sb.WriteString("│")
 		}
 		sb.WriteString("\n")
@@ -116,6 +124,101 @@ func (t *Table) Render() string {
 	return sb.String()
 }
 
+// pad surrounds s with one space and fills it out to width columns.
+func pad(s string, width int) string {
+	return " " + s + strings.Repeat(" ", max(width-1-lipgloss.Width(s), 0))
+}
+
+// flatten puts a multi-line value on one line.
+func flatten(s string) string {
+	if !strings.ContainsAny(s, "\n\r\t") {
+		return s
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Truncate shortens s to width display columns, ending it with "...".
+func Truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= 3 {
+		return strings.Repeat(".", max(width, 0))
+	}
+	var sb strings.Builder
+	w := 0
+	for _, r := range s {
+		rw := lipgloss.Width(string(r))
+		if w+rw > width-3 {
+			break
+		}
+		sb.WriteRune(r)
+		w += rw
+	}
+	return sb.String() + "..."
+}
+
+// fitWidths narrows the widest columns until they add up to at most
+// total, keeping every column at least minColumnWidth wide.
+func fitWidths(widths []int, total int) {
+	const minColumnWidth = 6
+	sum := 0
+	for _, w := range widths {
+		sum += w
+	}
+	for sum > total {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			return
+		}
+		widths[widest]--
+		sum--
+	}
+}
+
+// CSV renders the table as CSV, or with sep '\t' as TSV.
+func (t *Table) CSV(sep rune) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Comma = sep
+	w.Write(t.Headers)
+	for _, row := range t.Rows {
+		w.Write(row)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// Markdown renders the table as a GitHub-flavored Markdown table.
+func (t *Table) Markdown() string {
+	cell := func(s string) string {
+		return strings.ReplaceAll(flatten(s), "|", "\\|")
+	}
+	var sb strings.Builder
+	line := func(cells []string) {
+		sb.WriteString("|")
+		for i := range t.Headers {
+			c := ""
+			if i < len(cells) {
+				c = cell(cells[i])
+			}
+			sb.WriteString(" " + c + " |")
+		}
+		sb.WriteString("\n")
+	}
+	line(t.Headers)
+	sb.WriteString("|" + strings.Repeat(" --- |", len(t.Headers)) + "\n")
+	for _, row := range t.Rows {
+		line(row)
+	}
+	return sb.String()
+}
+
 // RenderSimple renders a simpler version without heavy borders
 func (t *Table) RenderSimple() string {
 	if len(t.Headers) == 0 {
@@ -207,3 +310,13 @@ func KeyValue(pairs map[string]string) string {
 
 	return sb.String()
 }
+
+// TerminalWidth returns the width of the terminal stdout is attached to,
+// $COLUMNS when it isn't one, or 0 if neither is known.
+func TerminalWidth() int {
+	if w, _, err := term.GetSize(os.Stdout.Fd()); err == nil && w > 0 {
+		return w
+	}
+	w, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	return w
+}
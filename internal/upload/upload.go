@@ -35,6 +35,7 @@ type UploadOptions struct {
 	AuthToken     string
 	ShowProgress  bool
 	Timeout       time.Duration
+	Transport     http.RoundTripper // optional, e.g. for client certificates
 }
 
 // Progress tracks upload progress
@@ -133,7 +134,8 @@ func Upload(opts UploadOptions) (*http.Response, []byte, error) {
 	}
 
 	client := &http.Client{
-		Timeout: timeout,
+		Timeout:   timeout,
+		Transport: opts.Transport,
 	}
 
 	// Execute request
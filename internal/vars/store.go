@@ -0,0 +1,221 @@
+package vars
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/filelock"
+)
+
+const storeLockTimeout = 5 * time.Second
+
+// Value is a persisted variable. ExpiresAt is nil for variables that never
+// expire.
+type Value struct {
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Expired reports whether the value's TTL has elapsed.
+func (v Value) Expired(now time.Time) bool {
+	return v.ExpiresAt != nil && !v.ExpiresAt.After(now)
+}
+
+// Store is the per-project variable file at .mozzy/vars.json. Variables live
+// either in the shared scope or in a per-environment scope; lookups prefer the
+// environment scope.
+type Store struct {
+	Shared       map[string]Value            `json:"vars"`
+	Environments map[string]map[string]Value `json:"environments,omitempty"`
+}
+
+// Item is a single variable as returned by List.
+type Item struct {
+	Name  string
+	Env   string // empty for the shared scope
+	Value Value
+}
+
+// ProjectDir returns the directory that owns the current project's .mozzy/
+// folder (see config.ProjectDir).
+func ProjectDir() string {
+	return config.ProjectDir()
+}
+
+// StorePath returns the location of the project variable store.
+func StorePath() string {
+	return filepath.Join(ProjectDir(), ".mozzy", "vars.json")
+}
+
+// LoadStore reads the project store. A missing file yields an empty store.
+func LoadStore() (*Store, error) {
+	return readStore(StorePath())
+}
+
+// UpdateStore loads the store under a file lock, applies fn, drops expired
+// variables and writes the result back atomically.
+func UpdateStore(fn func(*Store) error) error {
+	p := StorePath()
+	lock, err := filelock.Acquire(p, storeLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	s, err := readStore(p)
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	s.prune(time.Now())
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := filelock.WriteFileAtomic(p, data, 0o600); err != nil {
+		return err
+	}
+
+	persistedMu.Lock()
+	persisted = s
+	persistedMu.Unlock()
+	return nil
+}
+
+func newStore() *Store {
+	return &Store{
+		Shared:       map[string]Value{},
+		Environments: map[string]map[string]Value{},
+	}
+}
+
+func readStore(p string) (*Store, error) {
+	s := newStore()
+	data, err := os.ReadFile(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", p, err)
+		}
+	}
+	if s.Shared == nil {
+		s.Shared = map[string]Value{}
+	}
+	if s.Environments == nil {
+		s.Environments = map[string]map[string]Value{}
+	}
+	return s, nil
+}
+
+func (s *Store) scope(env string, create bool) map[string]Value {
+	if env == "" {
+		return s.Shared
+	}
+	m, ok := s.Environments[env]
+	if !ok && create {
+		m = map[string]Value{}
+		s.Environments[env] = m
+	}
+	return m
+}
+
+// Lookup returns the live value of name, checking env's scope before the
+// shared scope.
+func (s *Store) Lookup(env, name string) (string, bool) {
+	now := time.Now()
+	if env != "" {
+		if v, ok := s.scope(env, false)[name]; ok && !v.Expired(now) {
+			return v.Value, true
+		}
+	}
+	if v, ok := s.Shared[name]; ok && !v.Expired(now) {
+		return v.Value, true
+	}
+	return "", false
+}
+
+// Set stores name in env's scope (or the shared scope when env is empty). A
+// ttl of zero means the variable never expires.
+func (s *Store) Set(env, name, value string, ttl time.Duration) {
+	now := time.Now()
+	v := Value{Value: value, UpdatedAt: now}
+	if ttl > 0 {
+		exp := now.Add(ttl)
+		v.ExpiresAt = &exp
+	}
+	s.scope(env, true)[name] = v
+}
+
+// Unset removes name from env's scope and reports whether it existed.
+func (s *Store) Unset(env, name string) bool {
+	m := s.scope(env, false)
+	if _, ok := m[name]; !ok {
+		return false
+	}
+	delete(m, name)
+	return true
+}
+
+// Clear removes every variable in env's scope.
+func (s *Store) Clear(env string) {
+	if env == "" {
+		s.Shared = map[string]Value{}
+		return
+	}
+	delete(s.Environments, env)
+}
+
+// List returns the live variables in the shared scope and, if env is set,
+// in that environment's scope, sorted by scope then name.
+func (s *Store) List(env string) []Item {
+	now := time.Now()
+	var items []Item
+	add := func(scopeEnv string, m map[string]Value) {
+		for name, v := range m {
+			if !v.Expired(now) {
+				items = append(items, Item{Name: name, Env: scopeEnv, Value: v})
+			}
+		}
+	}
+	add("", s.Shared)
+	if env != "" {
+		add(env, s.scope(env, false))
+	}
+	sort.Slice(items, func(a, b int) bool {
+		if items[a].Env != items[b].Env {
+			return items[a].Env < items[b].Env
+		}
+		return items[a].Name < items[b].Name
+	})
+	return items
+}
+
+func (s *Store) prune(now time.Time) {
+	for name, v := range s.Shared {
+		if v.Expired(now) {
+			delete(s.Shared, name)
+		}
+	}
+	for env, m := range s.Environments {
+		for name, v := range m {
+			if v.Expired(now) {
+				delete(m, name)
+			}
+		}
+		if len(m) == 0 {
+			delete(s.Environments, env)
+		}
+	}
+}
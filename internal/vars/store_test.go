@@ -0,0 +1,95 @@
+package vars
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// chdirTemp runs the test inside a fresh project directory and resets the
+// package-level state that depends on it.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/.mozzy.json", []byte(`{"default_env":"dev"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	reset := func() {
+		mu.Lock()
+		store = map[string]string{}
+		activeEnv = ""
+		mu.Unlock()
+		persistedMu.Lock()
+		persisted = nil
+		persistedMu.Unlock()
+	}
+	reset()
+	t.Cleanup(func() {
+		os.Chdir(old)
+		reset()
+	})
+	return dir
+}
+
+func TestStore_ScopesAndTTL(t *testing.T) {
+	s := newStore()
+	s.Set("", "host", "shared.example.com", 0)
+	s.Set("dev", "host", "dev.example.com", 0)
+	s.Set("dev", "token", "short-lived", time.Millisecond)
+
+	if v, _ := s.Lookup("dev", "host"); v != "dev.example.com" {
+		t.Errorf("env scope should win, got %q", v)
+	}
+	if v, _ := s.Lookup("prod", "host"); v != "shared.example.com" {
+		t.Errorf("unknown env should fall back to shared, got %q", v)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := s.Lookup("dev", "token"); ok {
+		t.Error("expired variable should not resolve")
+	}
+	if items := s.List("dev"); len(items) != 2 {
+		t.Errorf("List() = %d items, want 2 live variables", len(items))
+	}
+
+	if !s.Unset("dev", "host") || s.Unset("dev", "host") {
+		t.Error("Unset should report existence exactly once")
+	}
+	s.Clear("")
+	if _, ok := s.Lookup("", "host"); ok {
+		t.Error("Clear should empty the shared scope")
+	}
+}
+
+func TestCapturePersistent_SurvivesProcessState(t *testing.T) {
+	chdirTemp(t)
+	UseEnv(EnvName(""))
+	if ActiveEnv() != "dev" {
+		t.Fatalf("EnvName should fall back to default_env, got %q", ActiveEnv())
+	}
+
+	if err := CapturePersistent([]byte(`{"access_token":"abc123"}`), "token=.access_token", 0); err != nil {
+		t.Fatalf("CapturePersistent: %v", err)
+	}
+
+	// Simulate a new invocation: drop everything held in memory
+	mu.Lock()
+	store = map[string]string{}
+	mu.Unlock()
+	persistedMu.Lock()
+	persisted = nil
+	persistedMu.Unlock()
+
+	if got := Interpolate("Bearer {{token}}"); got != "Bearer abc123" {
+		t.Errorf("Interpolate() = %q, want value from persistent store", got)
+	}
+
+	UseEnv("prod")
+	if got := Interpolate("{{token}}"); got != "{{token}}" {
+		t.Errorf("variable captured under dev leaked into prod: %q", got)
+	}
+}
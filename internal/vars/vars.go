@@ -1,165 +1,212 @@
 package vars
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/humancto/mozzy/internal/config"
+	"github.com/humancto/mozzy/internal/jq"
+	"github.com/humancto/mozzy/internal/jwtutil"
 )
 
-var store = map[string]string{}
+var (
+	mu    sync.RWMutex
+	store = map[string]string{}
+
+	// activeEnv selects which environment scope of the persistent store is
+	// consulted by Interpolate.
+	activeEnv string
+
+	// profileVars are the active environment's `variables`, the lowest
+	// priority source for Interpolate.
+	profileVars = map[string]string{}
+
+	persistedMu sync.Mutex
+	persisted   *Store
+)
+
+var placeholderRe = regexp.MustCompile(`\{\{([a-zA-Z0-9_.-]+)\}\}`)
+
+// UseEnv selects the environment scope used for persistent variable lookups.
+func UseEnv(name string) {
+	mu.Lock()
+	activeEnv = name
+	mu.Unlock()
+}
+
+// ActiveEnv returns the environment scope selected by UseEnv.
+func ActiveEnv() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return activeEnv
+}
+
+// SetProfileVars installs the active environment profile's variables.
+func SetProfileVars(m map[string]string) {
+	mu.Lock()
+	profileVars = make(map[string]string, len(m))
+	for k, v := range m {
+		profileVars[k] = v
+	}
+	mu.Unlock()
+}
 
-// Interpolate replaces {{name}} occurrences using the in-memory store
+// Interpolate replaces {{name}} occurrences using variables captured in this
+// process first, then the persistent project store, then the active
+// environment profile's variables.
 func Interpolate(s string) string {
-	re := regexp.MustCompile(`\{\{([a-zA-Z0-9_.-]+)\}\}`)
-	return re.ReplaceAllStringFunc(s, func(m string) string {
-		key := re.FindStringSubmatch(m)[1]
-		if v, ok := store[key]; ok {
+	return Expand(s, Lookup)
+}
+
+// Expand replaces {{name}} occurrences with the values lookup finds,
+// leaving unknown names as they are.
+func Expand(s string, lookup func(name string) (string, bool)) string {
+	return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+		key := placeholderRe.FindStringSubmatch(m)[1]
+		if v, ok := lookup(key); ok {
 			return v
 		}
 		return m
 	})
 }
 
+// Lookup resolves a single variable the same way Interpolate does.
+func Lookup(name string) (string, bool) {
+	mu.RLock()
+	v, ok := store[name]
+	env := activeEnv
+	pv, pok := profileVars[name]
+	mu.RUnlock()
+	if ok {
+		return v, true
+	}
+	if s := persistedStore(); s != nil {
+		if v, ok := s.Lookup(env, name); ok {
+			return v, true
+		}
+	}
+	return pv, pok
+}
+
+// Set stores a variable in memory for the rest of this process.
+func Set(name, value string) {
+	mu.Lock()
+	store[name] = value
+	mu.Unlock()
+}
+
+func persistedStore() *Store {
+	persistedMu.Lock()
+	defer persistedMu.Unlock()
+	if persisted == nil {
+		s, err := LoadStore()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warn: ignoring variable store: %v\n", err)
+			s = newStore()
+		}
+		persisted = s
+	}
+	return persisted
+}
+
 // Capture parses JSON body and stores `name=path` where path is dot-notation
 // Example: "token=.access_token" or "firstId=.[0].id"
 func Capture(body []byte, spec string) error {
-	parts := strings.SplitN(spec, "=", 2)
-	if len(parts) != 2 { return fmt.Errorf("invalid capture %q (want name=.json.path)", spec) }
-	name, path := parts[0], parts[1]
-	path = strings.TrimPrefix(path, ".")
-
-	var data any
-	if err := json.Unmarshal(body, &data); err != nil { return err }
-
-	cur := data
-	if path != "" {
-		// Parse path segments supporting both object keys and array indices
-		// e.g., "[0].id", "data.users[1].name"
-		segments := parsePath(path)
-		for _, seg := range segments {
-			if seg.isArray {
-				// Array index access
-				switch node := cur.(type) {
-				case []any:
-					if seg.index >= 0 && seg.index < len(node) {
-						cur = node[seg.index]
-					} else {
-						return fmt.Errorf("array index %d out of bounds (length %d)", seg.index, len(node))
-					}
-				default:
-					return fmt.Errorf("expected array at index %d, got %T", seg.index, cur)
-				}
-			} else {
-				// Object key access
-				switch node := cur.(type) {
-				case map[string]any:
-					cur = node[seg.key]
-				default:
-					return fmt.Errorf("capture path not found at %q", seg.key)
-				}
-			}
-		}
-	}
-	switch v := cur.(type) {
-	case string:
-		store[name] = v
-	case float64:
-		// Convert numbers to string without JSON encoding
-		store[name] = fmt.Sprintf("%.0f", v)
-	case bool:
-		store[name] = fmt.Sprintf("%t", v)
-	case nil:
-		store[name] = "null"
-	default:
-		// store as JSON string for complex types
-		b, _ := json.Marshal(v)
-		store[name] = string(b)
+	name, value, err := Extract(body, spec)
+	if err != nil {
+		return err
 	}
+	Set(name, value)
 	return nil
 }
 
-type pathSegment struct {
-	isArray bool
-	index   int
-	key     string
+// CapturePersistent captures like Capture and also saves the variable to the
+// project store under the active environment, so later invocations can use
+// it. With a zero ttl, JWT values expire together with their exp claim.
+func CapturePersistent(body []byte, spec string, ttl time.Duration) error {
+	name, value, err := Extract(body, spec)
+	if err != nil {
+		return err
+	}
+	Set(name, value)
+	if ttl == 0 {
+		ttl = jwtTTL(value)
+	}
+	env := ActiveEnv()
+	return UpdateStore(func(s *Store) error {
+		s.Set(env, name, value, ttl)
+		return nil
+	})
 }
 
-func parsePath(path string) []pathSegment {
-	if path == "" {
-		return nil
+// jwtTTL returns the remaining lifetime of a JWT value, or zero if value is
+// not a token with an exp claim.
+func jwtTTL(value string) time.Duration {
+	if strings.Count(value, ".") != 2 {
+		return 0
 	}
-
-	var segments []pathSegment
-	current := ""
-	inBracket := false
-
-	for i := 0; i < len(path); i++ {
-		ch := path[i]
-
-		switch ch {
-		case '[':
-			// Save current segment as object key if not empty
-			if current != "" {
-				segments = append(segments, pathSegment{key: current})
-				current = ""
-			}
-			inBracket = true
-		case ']':
-			if inBracket {
-				// Parse array index
-				idx := 0
-				fmt.Sscanf(current, "%d", &idx)
-				segments = append(segments, pathSegment{isArray: true, index: idx})
-				current = ""
-				inBracket = false
-			}
-		case '.':
-			if !inBracket {
-				// Segment boundary
-				if current != "" {
-					segments = append(segments, pathSegment{key: current})
-					current = ""
-				}
-			} else {
-				current += string(ch)
-			}
-		default:
-			current += string(ch)
-		}
+	_, claims, err := jwtutil.Decode(value)
+	if err != nil {
+		return 0
 	}
-
-	// Add final segment if exists
-	if current != "" {
-		segments = append(segments, pathSegment{key: current})
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return 0
+	}
+	if ttl := time.Until(time.Unix(int64(exp), 0)); ttl > 0 {
+		return ttl
 	}
+	return 0
+}
+
+// Extract evaluates a `name=<jq expression>` capture spec against a JSON body and returns
+// the variable name and its string value without storing it.
+func Extract(body []byte, spec string) (string, string, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 { return "", "", fmt.Errorf("invalid capture %q (want name=jq expression)", spec) }
+	name, query := parts[0], parts[1]
+
+	data, err := jq.Decode(body)
+	if err != nil { return "", "", err }
+
+	// The first result of the jq expression is captured, e.g. ".token",
+	// ".items[] | select(.active) | .id" or "[0].id"
+	results, err := jq.Eval(query, data)
+	if err != nil { return "", "", err }
+	if len(results) == 0 { return "", "", fmt.Errorf("capture %q produced no value", query) }
+	return name, jq.String(results[0]), nil
+}
+
+// EnvName returns the environment in effect: the CLI value if given,
+// otherwise default_env from .mozzy.json.
+func EnvName(cliEnv string) string {
+	if cliEnv != "" { return cliEnv }
+	f, err := config.Load()
+	if err != nil { return "" }
+	return f.DefaultEnv
+}
 
-	return segments
+// ActivateProfile resolves the environment selected by cliEnv (or
+// default_env) and makes its scope and variables available to Interpolate.
+// It returns a nil profile when no environment is selected.
+func ActivateProfile(cliEnv string) (*config.Profile, error) {
+	p, name, err := config.Active(cliEnv)
+	UseEnv(name)
+	if err != nil { return nil, err }
+	if p != nil {
+		SetProfileVars(p.Variables)
+	}
+	return p, nil
 }
 
 // ResolveBase picks base from env file or CLI flag
 func ResolveBase(cliBase, envName string) string {
 	if envName == "" && cliBase != "" { return cliBase }
-	// load .mozzy.json if present
-	wd, _ := os.Getwd()
-	cfgPath := filepath.Join(wd, ".mozzy.json")
-	b, err := os.ReadFile(cfgPath)
+	p, _, err := config.Active(envName)
 	if err != nil { return cliBase }
-
-	var cfg struct {
-		DefaultEnv    string            `json:"default_env"`
-		Environments  map[string]string `json:"environments"`
-	}
-	_ = json.Unmarshal(b, &cfg)
-
-	target := envName
-	if target == "" && cfg.DefaultEnv != "" { target = cfg.DefaultEnv }
-	if target != "" {
-		if base, ok := cfg.Environments[target]; ok {
-			return base
-		}
-	}
-	return cliBase
+	return config.BaseURL(p, cliBase, envName)
 }